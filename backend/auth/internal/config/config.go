@@ -7,18 +7,24 @@ import (
 	// autoload the environment variables
 
 	"github.com/aritradevelops/billbharat/backend/shared/timex"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/caarlos0/env/v10"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type Config struct {
-	Http        Http        `envPrefix:"HTTP_"`
-	Database    Database    `envPrefix:"DATABASE_"`
-	Service     Service     `envPrefix:"SERVICE_"`
-	Grpc        Grpc        `envPrefix:"GRPC_"`
-	Deployment  Deployment  `envPrefix:"DEPLOYMENT_"`
-	Jwt         Jwt         `envPrefix:"JWT_"`
-	EventBroker EventBroker `envPrefix:"EVENT_BROKER_"`
+	Http        Http           `envPrefix:"HTTP_"`
+	Database    Database       `envPrefix:"DATABASE_"`
+	Service     Service        `envPrefix:"SERVICE_"`
+	Grpc        Grpc           `envPrefix:"GRPC_"`
+	Deployment  Deployment     `envPrefix:"DEPLOYMENT_"`
+	Jwt         Jwt            `envPrefix:"JWT_"`
+	Gateway     Gateway        `envPrefix:"GATEWAY_"`
+	EventBroker EventBroker    `envPrefix:"EVENT_BROKER_"`
+	Tracing     tracing.Config `envPrefix:"TRACING_"`
+	Log         Log            `envPrefix:"LOG_"`
+	Internal    Internal       `envPrefix:"INTERNAL_"`
+	Cdc         Cdc            `envPrefix:"CDC_"`
 }
 
 type Http struct {
@@ -49,11 +55,43 @@ type Jwt struct {
 	Lifetime timex.Duration `env:"LIFETIME,required"`
 }
 
+// Gateway is the trust in the identity the broker forwards after verifying the access token
+type Gateway struct {
+	// Secret is shared with the broker, the forwarded identity is ignored when it is empty
+	Secret string `env:"SECRET"`
+	// VerifyToken verifies the access token along with the forwarded identity
+	VerifyToken bool `env:"VERIFY_TOKEN" envDefault:"false"`
+}
+
+// Log sets the levels of the logs, Levels sets apart packages, e.g. events:warn
+type Log struct {
+	Level  string            `env:"LEVEL" envDefault:"info"`
+	Levels map[string]string `env:"LEVELS"`
+}
+
 type EventBroker struct {
+	// Driver is either kafka or memory, memory keeps the events in process
+	// and is only meant for running a single service without a broker
+	Driver  string   `env:"DRIVER" envDefault:"kafka"`
 	Servers []string `env:"SERVERS,required" envSeparator:","`
 	GroupID string   `env:"GROUP_ID,required"`
 }
 
+type Internal struct {
+	// ApiKey is shared with the other services for the internal routes
+	ApiKey string `env:"API_KEY,required"`
+}
+
+// Cdc publishes the user and business events from the postgres logical
+// replication stream, it needs wal_level=logical and a user with REPLICATION
+type Cdc struct {
+	Enabled     bool   `env:"ENABLED" envDefault:"false"`
+	Slot        string `env:"SLOT" envDefault:"billbharat_auth"`
+	Publication string `env:"PUBLICATION" envDefault:"billbharat_auth"`
+	// LsnFile keeps the last published position across restarts
+	LsnFile string `env:"LSN_FILE" envDefault:".cdc.lsn"`
+}
+
 func Load() (Config, error) {
 	var config Config
 	err := env.Parse(&config)
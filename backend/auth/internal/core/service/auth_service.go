@@ -158,16 +158,18 @@ type ChangePasswordResponse struct {
 }
 
 type authService struct {
-	repository   repository.Repository
-	eventManager events.EventManager
-	jwtManager   *jwtutil.JwtManager
+	repository        repository.Repository
+	userTopic         *events.ManageUserTopic
+	notificationTopic *events.ManageNotificationTopic
+	jwtManager        *jwtutil.JwtManager
 }
 
 func NewAuthService(repository repository.Repository, jwtManager *jwtutil.JwtManager, eventManager events.EventManager) AuthService {
 	return &authService{
-		repository:   repository,
-		jwtManager:   jwtManager,
-		eventManager: eventManager,
+		repository:        repository,
+		jwtManager:        jwtManager,
+		userTopic:         events.NewManageUserTopic(eventManager, events.SubscribeOpts{}),
+		notificationTopic: events.NewManageNotificationTopic(eventManager, events.SubscribeOpts{}),
 	}
 }
 
@@ -233,7 +235,7 @@ func (s *authService) Register(ctx context.Context, payload RegisterPayload) (Re
 		return response, err
 	}
 
-	err = s.eventManager.EmitManageUserEvent(ctx, events.NewUserManageEvent("create", events.ManageUserEventPayload(user)))
+	err = s.userTopic.Publish(ctx, events.NewUserManageEvent("create", events.ManageUserEventPayload(user)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage user event")
 		return response, err
@@ -243,6 +245,7 @@ func (s *authService) Register(ctx context.Context, payload RegisterPayload) (Re
 	response.Name = user.Name
 	response.Email = user.Email
 	response.Phone = user.Phone
+	registrations.Inc()
 	return response, nil
 }
 
@@ -285,12 +288,12 @@ func (s *authService) VerifyEmail(ctx context.Context, payload VerifyEmailPayloa
 		logger.Error().Err(err).Msg("failed to set verification request consumed at")
 		return response, err
 	}
-	err = s.eventManager.EmitManageUserEvent(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
+	err = s.userTopic.Publish(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage user event")
 		return response, InternalError
 	}
-	err = s.eventManager.EmitManageNotificationEvent(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+	err = s.notificationTopic.Publish(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
 		Event: notification.EMAIL_VERIFIED,
 		Kind:  notification.P2P,
 		Payload: []events.NotificationChannelPayload{
@@ -299,7 +302,10 @@ func (s *authService) VerifyEmail(ctx context.Context, payload VerifyEmailPayloa
 				Data:    notification.NewEmail(user.Email),
 			},
 		},
-		Tokens: user,
+		Tokens: map[string]string{
+			"Name":  user.Name,
+			"Email": user.Email,
+		},
 	}))
 
 	if err != nil {
@@ -349,13 +355,13 @@ func (s *authService) VerifyPhone(ctx context.Context, payload VerifyPhonePayloa
 		logger.Error().Err(err).Msg("failed to set verification request consumed at")
 		return response, err
 	}
-	err = s.eventManager.EmitManageUserEvent(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
+	err = s.userTopic.Publish(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage user event")
 		return response, InternalError
 	}
 
-	err = s.eventManager.EmitManageNotificationEvent(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+	err = s.notificationTopic.Publish(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
 		Event: notification.PHONE_VERIFIED,
 		Kind:  notification.P2P,
 		Payload: []events.NotificationChannelPayload{
@@ -364,7 +370,10 @@ func (s *authService) VerifyPhone(ctx context.Context, payload VerifyPhonePayloa
 				Data:    notification.NewSMS(user.Phone),
 			},
 		},
-		Tokens: user,
+		Tokens: map[string]string{
+			"Name":  user.Name,
+			"Phone": user.Phone,
+		},
 	}))
 
 	if err != nil {
@@ -376,6 +385,12 @@ func (s *authService) VerifyPhone(ctx context.Context, payload VerifyPhonePayloa
 }
 
 func (s *authService) Login(ctx context.Context, payload LoginPayload) (LoginResponse, error) {
+	response, err := s.login(ctx, payload)
+	logins.WithLabelValues(loginResult(err)).Inc()
+	return response, err
+}
+
+func (s *authService) login(ctx context.Context, payload LoginPayload) (LoginResponse, error) {
 	var response LoginResponse
 	errs := validation.Validate(payload)
 	if errs != nil {
@@ -426,9 +441,11 @@ func (s *authService) Login(ctx context.Context, payload LoginPayload) (LoginRes
 		return response, InternalError
 	}
 	var businessID uuid.UUID
+	var role string
 	// if user has exactly one business, issue token for that business
 	if len(business) == 1 {
 		businessID = business[0].Business.ID
+		role = business[0].BusinessUser.Role
 		response.BusinessFound = true
 	}
 
@@ -438,6 +455,7 @@ func (s *authService) Login(ctx context.Context, payload LoginPayload) (LoginRes
 		Name:       user.Name,
 		Dp:         user.Dp,
 		BusinessID: businessID.String(),
+		Role:       role,
 	})
 
 	if err != nil {
@@ -522,7 +540,7 @@ func (s *authService) SendEmailVerificationRequest(ctx context.Context, payload
 		logger.Error().Err(err).Msg("failed to create verification request")
 		return response, InternalError
 	}
-	err = s.eventManager.EmitManageNotificationEvent(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+	err = s.notificationTopic.Publish(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
 		Event: notification.EMAIL_VERIFICATION,
 		Kind:  notification.P2P,
 		Payload: []events.NotificationChannelPayload{
@@ -594,7 +612,7 @@ func (s *authService) SendPhoneVerificationRequest(ctx context.Context, payload
 		logger.Error().Err(err).Msg("failed to create verification request")
 		return response, InternalError
 	}
-	err = s.eventManager.EmitManageNotificationEvent(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+	err = s.notificationTopic.Publish(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
 		Event: notification.PHONE_VERIFICATION,
 		Kind:  notification.P2P,
 		Payload: []events.NotificationChannelPayload{
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/auth/internal/core/jwtutil"
+	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+var registerPayload = RegisterPayload{
+	Name:        "Asha Roy",
+	Email:       "asha@example.com",
+	CountryCode: "+91",
+	Phone:       "9876543210",
+	Password:    "Str0ng!pass",
+}
+
+func newTestServices(repo *fakeRepository, eventManager events.EventManager) *Service {
+	return New(repo, jwtutil.NewJwtManager("secret", time.Hour), eventManager)
+}
+
+func TestRegisterEmitsOneManageUserCreateEvent(t *testing.T) {
+	repo := newFakeRepository()
+	recorder := events.NewRecorder()
+	services := newTestServices(repo, recorder)
+
+	response, err := services.Auth.Register(context.Background(), registerPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := events.Recorded[events.ManageUserEventPayload](recorder, events.ManageUserEvent, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 {
+		t.Fatalf("emitted %d manage-user create events, want 1", len(created))
+	}
+	if created[0].Data.ID != response.ID || created[0].Data.Email != registerPayload.Email {
+		t.Fatalf("emitted %+v for %+v", created[0].Data, response)
+	}
+	if created[0].Data.Phone != "+919876543210" {
+		t.Fatalf("phone = %q, want the country code prepended", created[0].Data.Phone)
+	}
+	if len(repo.passwords) != 1 || repo.passwords[0].Password == registerPayload.Password {
+		t.Fatal("password not stored hashed")
+	}
+}
+
+func TestRegisterRejectsAnExistingEmailWithoutEmitting(t *testing.T) {
+	repo := newFakeRepository()
+	recorder := events.NewRecorder()
+	services := newTestServices(repo, recorder)
+	if _, err := services.Auth.Register(context.Background(), registerPayload); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Reset()
+
+	_, err := services.Auth.Register(context.Background(), registerPayload)
+	if !errors.Is(err, UserExistsErr) {
+		t.Fatalf("err = %v, want %v", err, UserExistsErr)
+	}
+	if emitted := recorder.Events(events.ManageUserEvent, ""); len(emitted) != 0 {
+		t.Fatalf("emitted %d events, want none", len(emitted))
+	}
+}
+
+func TestRegisterFailsWhenTheEventIsNotPublished(t *testing.T) {
+	recorder := events.NewRecorder()
+	recorder.Err = errors.New("broker down")
+	services := newTestServices(newFakeRepository(), recorder)
+
+	if _, err := services.Auth.Register(context.Background(), registerPayload); err == nil {
+		t.Fatal("registered without the event")
+	}
+}
+
+func TestVerifyEmailEmitsTheUpdateAndTheNotification(t *testing.T) {
+	repo := newFakeRepository()
+	recorder := events.NewRecorder()
+	services := newTestServices(repo, recorder)
+	registered, err := services.Auth.Register(context.Background(), registerPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.verificationRequests = append(repo.verificationRequests, dao.VerificationRequest{
+		ID: uuid.New(), UserID: registered.ID, Type: dao.VerificationTypeEmail, Code: "123456",
+		ExpiresAt: time.Now().Add(VerificationRequestExpiry),
+	})
+	recorder.Reset()
+
+	_, err = services.Auth.VerifyEmail(context.Background(), VerifyEmailPayload{Email: registerPayload.Email, Code: "654321"})
+	if !errors.Is(err, InvalidVerificationCodeErr) {
+		t.Fatalf("err = %v, want %v", err, InvalidVerificationCodeErr)
+	}
+	if _, err := services.Auth.VerifyEmail(context.Background(), VerifyEmailPayload{Email: registerPayload.Email, Code: "123456"}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := events.Recorded[events.ManageUserEventPayload](recorder, events.ManageUserEvent, "update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || !updated[0].Data.EmailVerified {
+		t.Fatalf("updates = %+v, want one with the email verified", updated)
+	}
+	notifications, err := events.Recorded[events.ManageNotificationEventPayload](recorder, events.ManageNotification, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].Data.Event != notification.EMAIL_VERIFIED {
+		t.Fatalf("notifications = %+v, want one email_verified", notifications)
+	}
+}
@@ -68,13 +68,19 @@ type SwitchBusinessPayload struct {
 }
 
 type businessService struct {
-	repository   repository.Repository
-	eventManager events.EventManager
-	jwtManager   *jwtutil.JwtManager
+	repository        repository.Repository
+	businessTopic     *events.ManageBusinessTopic
+	businessUserTopic *events.ManageBusinessUserTopic
+	jwtManager        *jwtutil.JwtManager
 }
 
 func NewBusinessService(repository repository.Repository, eventManager events.EventManager, jwtManager *jwtutil.JwtManager) BusinessService {
-	return &businessService{repository: repository, eventManager: eventManager, jwtManager: jwtManager}
+	return &businessService{
+		repository:        repository,
+		businessTopic:     events.NewManageBusinessTopic(eventManager, events.SubscribeOpts{}),
+		businessUserTopic: events.NewManageBusinessUserTopic(eventManager, events.SubscribeOpts{}),
+		jwtManager:        jwtManager,
+	}
 }
 
 func (s *businessService) Create(ctx context.Context, initiator string, payload CreateBusinessPayload) (CreateBusinessResponse, error) {
@@ -118,13 +124,13 @@ func (s *businessService) Create(ctx context.Context, initiator string, payload
 		return response, err
 	}
 
-	err = s.eventManager.EmitManageBusinessEvent(ctx, events.NewBusinessManageEvent("create", events.MangageBusinessEventPayload(business)))
+	err = s.businessTopic.Publish(ctx, events.NewBusinessManageEvent("create", events.MangageBusinessEventPayload(business)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage business event")
 		return response, InternalError
 	}
 
-	err = s.eventManager.EmitManageBusinessUserEvent(ctx, events.NewBusinessUserManageEvent("create", events.MangageBusinessUserEventPayload(businessUser)))
+	err = s.businessUserTopic.Publish(ctx, events.NewBusinessUserManageEvent("create", events.MangageBusinessUserEventPayload(businessUser)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage business user event")
 		return response, InternalError
@@ -147,6 +153,7 @@ func (s *businessService) Create(ctx context.Context, initiator string, payload
 		Currencies:      business.Currencies,
 	}
 
+	businessesCreated.Inc()
 	return response, nil
 }
 
@@ -178,9 +185,10 @@ func (s *businessService) Select(ctx context.Context, initiator string, business
 		return response, InternalError
 	}
 
-	if !slices.ContainsFunc(businesses, func(business dao.FindBusinessesByUserIDRow) bool {
+	selected := slices.IndexFunc(businesses, func(business dao.FindBusinessesByUserIDRow) bool {
 		return business.Business.ID.String() == businessID
-	}) {
+	})
+	if selected == -1 {
 		return response, BusinessNotFoundErr
 	}
 
@@ -196,6 +204,7 @@ func (s *businessService) Select(ctx context.Context, initiator string, business
 		Name:       user.Name,
 		Dp:         user.Dp,
 		BusinessID: businessID,
+		Role:       businesses[selected].BusinessUser.Role,
 	})
 
 	if err != nil {
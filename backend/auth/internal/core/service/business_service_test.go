@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/google/uuid"
+)
+
+var createBusinessPayload = CreateBusinessPayload{
+	Name:            "Roy Traders",
+	Industry:        "Retail",
+	PrimaryCurrency: "INR",
+	Currencies:      []string{"INR"},
+}
+
+func TestCreateBusinessEmitsTheBusinessAndItsOwner(t *testing.T) {
+	repo := newFakeRepository()
+	recorder := events.NewRecorder()
+	services := newTestServices(repo, recorder)
+	owner := uuid.New()
+
+	response, err := services.Business.Create(context.Background(), owner.String(), createBusinessPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	businesses, err := events.Recorded[events.MangageBusinessEventPayload](recorder, events.ManageBusinessEvent, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(businesses) != 1 || businesses[0].Data.ID.String() != response.ID || businesses[0].Data.OwnerID != owner {
+		t.Fatalf("businesses = %+v, want the one created for %s", businesses, owner)
+	}
+	members, err := events.Recorded[events.MangageBusinessUserEventPayload](recorder, events.ManageBusinessUserEvent, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].Data.UserID != owner || members[0].Data.Role != "Owner" {
+		t.Fatalf("members = %+v, want the owner", members)
+	}
+}
+
+func TestCreateBusinessFailsWhenTheEventIsNotPublished(t *testing.T) {
+	recorder := events.NewRecorder()
+	recorder.Err = errors.New("broker down")
+	services := newTestServices(newFakeRepository(), recorder)
+
+	_, err := services.Business.Create(context.Background(), uuid.NewString(), createBusinessPayload)
+	if !errors.Is(err, InternalError) {
+		t.Fatalf("err = %v, want %v", err, InternalError)
+	}
+}
+
+func TestSelectBusinessOnlyOfTheUser(t *testing.T) {
+	repo := newFakeRepository()
+	services := newTestServices(repo, events.NewRecorder())
+	registered, err := services.Auth.Register(context.Background(), registerPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	business, err := services.Business.Create(context.Background(), registered.ID.String(), createBusinessPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = services.Business.Select(context.Background(), registered.ID.String(), uuid.NewString(), SwitchBusinessPayload{})
+	if !errors.Is(err, BusinessNotFoundErr) {
+		t.Fatalf("err = %v, want %v", err, BusinessNotFoundErr)
+	}
+	response, err := services.Business.Select(context.Background(), registered.ID.String(), business.ID, SwitchBusinessPayload{
+		UserIP: "127.0.0.1", UserAgent: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.AccessToken == "" || len(repo.sessions) != 1 || repo.sessions[0].BusinessID.String() != business.ID {
+		t.Fatalf("no session for the business selected: %+v", repo.sessions)
+	}
+}
@@ -21,3 +21,15 @@ var (
 	InternalError = &ServiceError{HttpErrorCode: fiber.StatusInternalServerError,
 		DevErrorCode: "general_internal_error", Short: "Internal server error", Long: "Internal server error"}
 )
+
+// Errors is the catalog of the errors of the service, documented in its openapi spec
+var Errors = []*ServiceError{
+	InternalError,
+	UserExistsErr, UserNotFoundErr, VerificationRequestExpiredErr, InvalidVerificationCodeErr,
+	UserEmailNotVerifiedErr, UserPhoneNotVerifiedErr, UserDeactivatedErr, InvalidCredentialsErr,
+	InvalidLoginMethodErr, UserEmailVerifiedErr, TooManyVerificationRequestsErr, UserPhoneVerifiedErr,
+	PasswordAlreadyUsedErr, PasswordMismatchErr,
+	BusinessNotFoundErr, InvalidBusinessIdErr,
+	InvitationNotFoundErr,
+	InvalidSnapshotCursorErr,
+}
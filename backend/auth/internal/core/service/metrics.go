@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/aritradevelops/billbharat/backend/auth/internal/core/validation"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	registrations = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Users registered.",
+	})
+	logins = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts, by result, e.g. success or user.invalid_credentials.",
+	}, []string{"result"})
+	businessesCreated = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "auth_businesses_created_total",
+		Help: "Businesses created.",
+	})
+	invitations = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_invitations_total",
+		Help: "Invitations to a business, sent or accepted.",
+	}, []string{"status"})
+)
+
+// loginResult keeps the failed logins apart by the reason shown to the user
+func loginResult(err error) string {
+	var serviceErr *ServiceError
+	var validationErrs validation.ValidationErrors
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &serviceErr):
+		return serviceErr.Short
+	case errors.As(err, &validationErrs):
+		return "validation"
+	}
+	return "error"
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/dao"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeRepository keeps the rows the services touch in maps, the queries no test
+// reaches panic on the nil Querier
+type fakeRepository struct {
+	dao.Querier
+
+	mu                   sync.Mutex
+	users                map[uuid.UUID]dao.User
+	passwords            []dao.Password
+	verificationRequests []dao.VerificationRequest
+	businesses           map[uuid.UUID]dao.Business
+	businessUsers        []dao.BusinessUser
+	sessions             []dao.CreateSessionParams
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		users:      map[uuid.UUID]dao.User{},
+		businesses: map[uuid.UUID]dao.Business{},
+	}
+}
+
+// fakeTx commits nothing, the fake repository writes through
+type fakeTx struct {
+	pgx.Tx
+}
+
+func (fakeTx) Commit(ctx context.Context) error   { return nil }
+func (fakeTx) Rollback(ctx context.Context) error { return nil }
+
+func (r *fakeRepository) StartTransaction(ctx context.Context) (pgx.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (r *fakeRepository) WithTx(tx pgx.Tx) dao.Querier {
+	return r
+}
+
+func (r *fakeRepository) CreateUser(ctx context.Context, arg dao.CreateUserParams) (dao.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	user := dao.User{
+		ID: uuid.New(), HumanID: arg.HumanID, Name: arg.Name, Email: arg.Email,
+		EmailVerified: arg.EmailVerified, Phone: arg.Phone, CreatedAt: now, CreatedBy: arg.CreatedBy,
+		UpdatedAt: now, Locale: "en",
+	}
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *fakeRepository) FindUserByEmail(ctx context.Context, email string) (dao.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return dao.User{}, pgx.ErrNoRows
+}
+
+func (r *fakeRepository) FindUserById(ctx context.Context, id uuid.UUID) (dao.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return dao.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (r *fakeRepository) SetUserEmailVerified(ctx context.Context, id uuid.UUID) (dao.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return dao.User{}, pgx.ErrNoRows
+	}
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	r.users[id] = user
+	return user, nil
+}
+
+func (r *fakeRepository) CreatePassword(ctx context.Context, arg dao.CreatePasswordParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.passwords = append(r.passwords, dao.Password{ID: uuid.New(), UserID: arg.UserID, Password: arg.Password})
+	return nil
+}
+
+func (r *fakeRepository) FindVerificationRequestByUserIdAndType(ctx context.Context, arg dao.FindVerificationRequestByUserIdAndTypeParams) (dao.VerificationRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, request := range r.verificationRequests {
+		if request.UserID == arg.UserID && request.Type == arg.Type {
+			return request, nil
+		}
+	}
+	return dao.VerificationRequest{}, pgx.ErrNoRows
+}
+
+func (r *fakeRepository) SetVerificationRequestConsumedAt(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func (r *fakeRepository) CreateBusiness(ctx context.Context, arg dao.CreateBusinessParams) (dao.Business, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	business := dao.Business{
+		ID: uuid.New(), Name: arg.Name, Description: arg.Description, Logo: arg.Logo, Industry: arg.Industry,
+		PrimaryCurrency: arg.PrimaryCurrency, OwnerID: arg.OwnerID, Currencies: arg.Currencies,
+		CreatedAt: now, CreatedBy: arg.CreatedBy, UpdatedAt: now,
+	}
+	r.businesses[business.ID] = business
+	return business, nil
+}
+
+func (r *fakeRepository) CreateBusinessUser(ctx context.Context, arg dao.CreateBusinessUserParams) (dao.BusinessUser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	businessUser := dao.BusinessUser{
+		UserID: arg.UserID, BusinessID: arg.BusinessID, Role: arg.Role,
+		CreatedAt: now, CreatedBy: arg.CreatedBy, UpdatedAt: now,
+	}
+	r.businessUsers = append(r.businessUsers, businessUser)
+	return businessUser, nil
+}
+
+func (r *fakeRepository) FindBusinessesByUserID(ctx context.Context, userID uuid.UUID) ([]dao.FindBusinessesByUserIDRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rows []dao.FindBusinessesByUserIDRow
+	for _, businessUser := range r.businessUsers {
+		if businessUser.UserID == userID {
+			rows = append(rows, dao.FindBusinessesByUserIDRow{
+				BusinessUser: businessUser, Business: r.businesses[businessUser.BusinessID],
+			})
+		}
+	}
+	return rows, nil
+}
+
+func (r *fakeRepository) CreateSession(ctx context.Context, arg dao.CreateSessionParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions = append(r.sessions, arg)
+	return nil
+}
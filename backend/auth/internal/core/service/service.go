@@ -10,6 +10,7 @@ type Service struct {
 	Auth     AuthService
 	User     UserService
 	Business BusinessService
+	Snapshot SnapshotService
 }
 
 func New(repository repository.Repository, jwtManager *jwtutil.JwtManager, eventManger events.EventManager) *Service {
@@ -17,5 +18,6 @@ func New(repository repository.Repository, jwtManager *jwtutil.JwtManager, event
 		Auth:     NewAuthService(repository, jwtManager, eventManger),
 		User:     NewUserService(repository, eventManger),
 		Business: NewBusinessService(repository, eventManger, jwtManager),
+		Snapshot: NewSnapshotService(repository),
 	}
 }
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/google/uuid"
+)
+
+var (
+	InvalidSnapshotCursorErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "snapshot_001", Short: "snapshot.invalid_cursor", Long: "invalid snapshot cursor",
+	}
+)
+
+// SnapshotService exports the full state of the entities mirrored by the other
+// services through events, so that they can backfill their copies.
+// deleted rows are exported as well, the consumers need to know about them.
+type SnapshotService interface {
+	Users(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.ManageUserEventPayload], error)
+	Businesses(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.MangageBusinessEventPayload], error)
+	BusinessUsers(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.MangageBusinessUserEventPayload], error)
+}
+
+type SnapshotPayload struct {
+	// After is the cursor returned with the previous page
+	After string `query:"after"`
+	Limit int    `query:"limit"`
+}
+
+func (p SnapshotPayload) limit() int32 {
+	if p.Limit <= 0 {
+		return snapshot.DefaultPageSize
+	}
+	return int32(min(p.Limit, snapshot.MaxPageSize))
+}
+
+type snapshotService struct {
+	repository repository.Repository
+}
+
+func NewSnapshotService(repository repository.Repository) SnapshotService {
+	return &snapshotService{repository: repository}
+}
+
+func (s *snapshotService) Users(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.ManageUserEventPayload], error) {
+	var page snapshot.Page[events.ManageUserEventPayload]
+	after, err := parseCursor(payload.After)
+	if err != nil {
+		return page, err
+	}
+	users, err := s.repository.ListUsersAfterID(ctx, dao.ListUsersAfterIDParams{ID: after, Limit: payload.limit()})
+	if err != nil {
+		return page, err
+	}
+	page.Items = make([]events.ManageUserEventPayload, 0, len(users))
+	for _, user := range users {
+		page.Items = append(page.Items, events.ManageUserEventPayload(user))
+	}
+	if len(users) == int(payload.limit()) {
+		page.Next = users[len(users)-1].ID.String()
+	}
+	return page, nil
+}
+
+func (s *snapshotService) Businesses(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.MangageBusinessEventPayload], error) {
+	var page snapshot.Page[events.MangageBusinessEventPayload]
+	after, err := parseCursor(payload.After)
+	if err != nil {
+		return page, err
+	}
+	businesses, err := s.repository.ListBusinessesAfterID(ctx, dao.ListBusinessesAfterIDParams{ID: after, Limit: payload.limit()})
+	if err != nil {
+		return page, err
+	}
+	page.Items = make([]events.MangageBusinessEventPayload, 0, len(businesses))
+	for _, business := range businesses {
+		page.Items = append(page.Items, events.MangageBusinessEventPayload(business))
+	}
+	if len(businesses) == int(payload.limit()) {
+		page.Next = businesses[len(businesses)-1].ID.String()
+	}
+	return page, nil
+}
+
+// business users are keyed by (business_id, user_id), the cursor is both joined by a "/"
+func (s *snapshotService) BusinessUsers(ctx context.Context, payload SnapshotPayload) (snapshot.Page[events.MangageBusinessUserEventPayload], error) {
+	var page snapshot.Page[events.MangageBusinessUserEventPayload]
+	params := dao.ListBusinessUsersAfterParams{Limit: payload.limit()}
+	if payload.After != "" {
+		businessID, userID, _ := strings.Cut(payload.After, "/")
+		var err error
+		if params.BusinessID, err = parseCursor(businessID); err != nil {
+			return page, err
+		}
+		if params.UserID, err = parseCursor(userID); err != nil {
+			return page, err
+		}
+	}
+	businessUsers, err := s.repository.ListBusinessUsersAfter(ctx, params)
+	if err != nil {
+		return page, err
+	}
+	page.Items = make([]events.MangageBusinessUserEventPayload, 0, len(businessUsers))
+	for _, businessUser := range businessUsers {
+		page.Items = append(page.Items, events.MangageBusinessUserEventPayload(businessUser))
+	}
+	if len(businessUsers) == int(payload.limit()) {
+		last := businessUsers[len(businessUsers)-1]
+		page.Next = last.BusinessID.String() + "/" + last.UserID.String()
+	}
+	return page, nil
+}
+
+// parseCursor returns the nil uuid for the first page, which sorts before every other uuid
+func parseCursor(cursor string) (uuid.UUID, error) {
+	if cursor == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(cursor)
+	if err != nil {
+		return uuid.Nil, InvalidSnapshotCursorErr
+	}
+	return id, nil
+}
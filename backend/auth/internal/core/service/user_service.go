@@ -28,6 +28,7 @@ var (
 type UserService interface {
 	Profile(ctx context.Context, initiator string, payload ProfilePayload) (ProfileResponse, error)
 	UpdateDP(ctx context.Context, initiator string, payload UpdateDPPayload) (ProfileResponse, error)
+	ChangeLocale(ctx context.Context, initiator string, payload ChangeLocalePayload) (ProfileResponse, error)
 	Invite(ctx context.Context, initiator string, businessId string, payload InvitePayload) (InviteResponse, error)
 	AcceptInvitation(ctx context.Context, initiator string, hash string) (AcceptInvitationResponse, error)
 }
@@ -41,12 +42,18 @@ type UpdateDPPayload struct {
 	Dp *string `json:"dp"`
 }
 
+// ChangeLocalePayload sets the language the user is notified in
+type ChangeLocalePayload struct {
+	Locale string `json:"locale" validate:"required,oneof=en bn"`
+}
+
 type ProfileResponse struct {
 	HumanID string  `json:"human_id"`
 	Email   string  `json:"email"`
 	Name    string  `json:"name"`
 	Dp      *string `json:"dp"`
 	Phone   string  `json:"phone"`
+	Locale  string  `json:"locale"`
 }
 
 type InvitePayload struct {
@@ -65,12 +72,17 @@ type AcceptInvitationResponse struct {
 }
 
 type userService struct {
-	repository   repository.Repository
-	eventManager events.EventManager
+	repository        repository.Repository
+	userTopic         *events.ManageUserTopic
+	notificationTopic *events.ManageNotificationTopic
 }
 
 func NewUserService(repository repository.Repository, eventManager events.EventManager) UserService {
-	return &userService{repository: repository, eventManager: eventManager}
+	return &userService{
+		repository:        repository,
+		userTopic:         events.NewManageUserTopic(eventManager, events.SubscribeOpts{}),
+		notificationTopic: events.NewManageNotificationTopic(eventManager, events.SubscribeOpts{}),
+	}
 }
 
 func (s *userService) Profile(ctx context.Context, initiator string, payload ProfilePayload) (ProfileResponse, error) {
@@ -89,6 +101,7 @@ func (s *userService) Profile(ctx context.Context, initiator string, payload Pro
 		Name:    user.Name,
 		Dp:      user.Dp,
 		Phone:   user.Phone,
+		Locale:  user.Locale,
 	}
 	return response, nil
 }
@@ -107,7 +120,37 @@ func (s *userService) UpdateDP(ctx context.Context, initiator string, payload Up
 		logger.Error().Err(err).Msg("failed to update user dp")
 		return response, UserNotFoundErr
 	}
-	err = s.eventManager.EmitManageUserEvent(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
+	err = s.userTopic.Publish(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to emit manage user event")
+		return response, InternalError
+	}
+	response = ProfileResponse{
+		HumanID: user.HumanID,
+		Email:   user.Email,
+		Name:    user.Name,
+		Dp:      user.Dp,
+		Phone:   user.Phone,
+		Locale:  user.Locale,
+	}
+	return response, nil
+}
+
+func (s *userService) ChangeLocale(ctx context.Context, initiator string, payload ChangeLocalePayload) (ProfileResponse, error) {
+	var response ProfileResponse
+	if err := validation.Validate(payload); err != nil {
+		return response, err
+	}
+
+	user, err := s.repository.UpdateUserLocale(ctx, dao.UpdateUserLocaleParams{
+		ID:     uuid.MustParse(initiator),
+		Locale: payload.Locale,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update user locale")
+		return response, UserNotFoundErr
+	}
+	err = s.userTopic.Publish(ctx, events.NewUserManageEvent("update", events.ManageUserEventPayload(user)))
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to emit manage user event")
 		return response, InternalError
@@ -118,6 +161,7 @@ func (s *userService) UpdateDP(ctx context.Context, initiator string, payload Up
 		Name:    user.Name,
 		Dp:      user.Dp,
 		Phone:   user.Phone,
+		Locale:  user.Locale,
 	}
 	return response, nil
 }
@@ -154,13 +198,22 @@ func (s *userService) Invite(ctx context.Context, initiator string, businessId s
 			return
 		}
 
-		err = s.eventManager.EmitManageNotificationEvent(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
-			Event: notification.USER_INVITED,
-			Kind:  notification.P2P,
-			Payload: []events.NotificationChannelPayload{
-				{Channel: notification.EMAIL, Data: notification.NewEmail(invitation.Email)},
-				{Channel: notification.SMS, Data: notification.NewSMS(invitation.Phone)},
-			},
+		channels := []events.NotificationChannelPayload{
+			{Channel: notification.EMAIL, Data: notification.NewEmail(invitation.Email)},
+			{Channel: notification.SMS, Data: notification.NewSMS(invitation.Phone)},
+		}
+		// an invitee who already has an account gets it in the app too
+		if invitee, err := s.repository.FindUserByEmail(ctx, invitation.Email); err == nil {
+			channels = append(channels, events.NotificationChannelPayload{
+				Channel: notification.PUSH,
+				Data:    notification.NewPushMessage(invitee.ID.String()).WithLink(fmt.Sprintf("/invites/%s", invitationHash)),
+			})
+		}
+
+		err = s.notificationTopic.Publish(ctx, events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+			Event:   notification.USER_INVITED,
+			Kind:    notification.P2P,
+			Payload: channels,
 			Tokens: map[string]string{
 				"InvitationURL": fmt.Sprintf("https://%s/invites/%s", payload.Origin, invitationHash),
 				"Email":         invitation.Email,
@@ -170,12 +223,14 @@ func (s *userService) Invite(ctx context.Context, initiator string, businessId s
 				"ExpiresAt":     invitation.ExpiresAt.Format("2006-01-02 15:04:05"),
 				"BusinessName":  business.Name,
 			},
+			BusinessID: &invitation.BusinessID,
 		}))
 		if err != nil {
 			logger.Info().Msgf("Failed to send notification to %s", invitation.Name)
 		}
 	}(invitation)
 
+	invitations.WithLabelValues("sent").Inc()
 	return response, nil
 }
 
@@ -231,6 +286,7 @@ func (s *userService) AcceptInvitation(ctx context.Context, initiator string, ha
 		return response, InternalError
 	}
 
+	invitations.WithLabelValues("accepted").Inc()
 	return response, nil
 }
 
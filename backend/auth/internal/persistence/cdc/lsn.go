@@ -0,0 +1,75 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LSN is a position in the postgres write ahead log
+type LSN uint64
+
+// ParseLSN parses the textual X/X form of an lsn, e.g. 16/B374D848
+func ParseLSN(s string) (LSN, error) {
+	var upper, lower uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &upper, &lower); err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", s, err)
+	}
+	return LSN(uint64(upper)<<32 | uint64(lower)), nil
+}
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// LSNStore keeps the position up to which the changes have been published,
+// so that the publisher resumes from there after a restart.
+type LSNStore interface {
+	// Load returns 0 when nothing was stored yet
+	Load(ctx context.Context) (LSN, error)
+	Save(ctx context.Context, lsn LSN) error
+}
+
+// FileLSNStore stores the lsn in a local file
+type FileLSNStore struct {
+	path string
+}
+
+func NewFileLSNStore(path string) *FileLSNStore {
+	return &FileLSNStore{path: path}
+}
+
+func (s *FileLSNStore) Load(ctx context.Context) (LSN, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ParseLSN(strings.TrimSpace(string(data)))
+}
+
+// Save writes to a temporary file first so that a crash never leaves a torn lsn behind
+func (s *FileLSNStore) Save(ctx context.Context, lsn LSN) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".lsn-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(lsn.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,32 @@
+package cdc
+
+import (
+	"context"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+)
+
+// ownedTopics drops the messages of the topics fed by the Publisher, so that
+// the services can keep publishing as usual without duplicating the events.
+type ownedTopics struct {
+	events.EventManager
+}
+
+func WithoutOwnedTopics(eventManager events.EventManager) events.EventManager {
+	return &ownedTopics{EventManager: eventManager}
+}
+
+func (o *ownedTopics) Publish(ctx context.Context, msgs ...events.Message) error {
+	kept := make([]events.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		switch msg.Topic {
+		case events.ManageUserEvent, events.ManageBusinessEvent, events.ManageBusinessUserEvent:
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return o.EventManager.Publish(ctx, kept...)
+}
@@ -0,0 +1,224 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// decoding of the pgoutput logical replication protocol (version 1),
+// see https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html
+
+var errShortMessage = errors.New("pgoutput: message too short")
+
+// postgres epoch, timestamps on the wire are microseconds since then
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type beginMessage struct {
+	finalLSN   LSN
+	commitTime time.Time
+	xid        uint32
+}
+
+type commitMessage struct {
+	commitLSN  LSN
+	endLSN     LSN
+	commitTime time.Time
+}
+
+type relationColumn struct {
+	name    string
+	typeOID uint32
+}
+
+type relationMessage struct {
+	id        uint32
+	namespace string
+	name      string
+	columns   []relationColumn
+}
+
+type tupleColumnKind byte
+
+const (
+	tupleNull      tupleColumnKind = 'n'
+	tupleUnchanged tupleColumnKind = 'u' // toasted value which did not change
+	tupleText      tupleColumnKind = 't'
+)
+
+type tupleColumn struct {
+	kind  tupleColumnKind
+	value []byte
+}
+
+type changeKind byte
+
+const (
+	changeInsert changeKind = 'I'
+	changeUpdate changeKind = 'U'
+	changeDelete changeKind = 'D'
+)
+
+type changeMessage struct {
+	kind       changeKind
+	relationID uint32
+	// old is only sent for updates and deletes, with REPLICA IDENTITY FULL it holds every column
+	old []tupleColumn
+	new []tupleColumn
+}
+
+// decoder is a cursor over a single message
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) < 1 {
+		d.err = errShortMessage
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.buf) < 2 {
+		d.err = errShortMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.buf)
+	d.buf = d.buf[2:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil || len(d.buf) < 4 {
+		d.err = errShortMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.buf)
+	d.buf = d.buf[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil || len(d.buf) < 8 {
+		d.err = errShortMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+	return v
+}
+
+func (d *decoder) time() time.Time {
+	return postgresEpoch.Add(time.Duration(int64(d.uint64())) * time.Microsecond)
+}
+
+// string reads a null terminated string
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	for i, b := range d.buf {
+		if b == 0 {
+			s := string(d.buf[:i])
+			d.buf = d.buf[i+1:]
+			return s
+		}
+	}
+	d.err = errShortMessage
+	return ""
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errShortMessage
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) tuple() []tupleColumn {
+	n := int(d.uint16())
+	columns := make([]tupleColumn, 0, n)
+	for range n {
+		column := tupleColumn{kind: tupleColumnKind(d.byte())}
+		switch column.kind {
+		case tupleNull, tupleUnchanged:
+		case tupleText:
+			column.value = d.bytes(int(d.uint32()))
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("pgoutput: unsupported tuple column kind %q", column.kind)
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// decodeMessage decodes the messages the publisher cares about,
+// every other message type (origin, type, truncate, ...) decodes to nil.
+func decodeMessage(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+	d := &decoder{buf: data[1:]}
+	var msg any
+	switch data[0] {
+	case 'B':
+		msg = beginMessage{finalLSN: LSN(d.uint64()), commitTime: d.time(), xid: d.uint32()}
+	case 'C':
+		d.byte() // flags, unused
+		msg = commitMessage{commitLSN: LSN(d.uint64()), endLSN: LSN(d.uint64()), commitTime: d.time()}
+	case 'R':
+		relation := relationMessage{id: d.uint32(), namespace: d.string(), name: d.string()}
+		d.byte() // replica identity
+		n := int(d.uint16())
+		for range n {
+			d.byte() // flags, 1 marks a key column
+			column := relationColumn{name: d.string(), typeOID: d.uint32()}
+			d.uint32() // type modifier
+			relation.columns = append(relation.columns, column)
+		}
+		msg = relation
+	case 'I':
+		change := changeMessage{kind: changeInsert, relationID: d.uint32()}
+		if tag := d.byte(); tag != 'N' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected insert tuple %q", tag)
+		}
+		change.new = d.tuple()
+		msg = change
+	case 'U':
+		change := changeMessage{kind: changeUpdate, relationID: d.uint32()}
+		tag := d.byte()
+		if tag == 'K' || tag == 'O' {
+			change.old = d.tuple()
+			tag = d.byte()
+		}
+		if tag != 'N' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected update tuple %q", tag)
+		}
+		change.new = d.tuple()
+		msg = change
+	case 'D':
+		change := changeMessage{kind: changeDelete, relationID: d.uint32()}
+		if tag := d.byte(); tag != 'K' && tag != 'O' && d.err == nil {
+			return nil, fmt.Errorf("pgoutput: unexpected delete tuple %q", tag)
+		}
+		change.old = d.tuple()
+		msg = change
+	default:
+		return nil, nil
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return msg, nil
+}
@@ -0,0 +1,223 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// the messages below are built the way pgoutput writes them
+
+func pgTime(t time.Time) uint64 {
+	return uint64(t.Sub(postgresEpoch).Microseconds())
+}
+
+func beginBytes(finalLSN LSN, commitTime time.Time, xid uint32) []byte {
+	b := []byte{'B'}
+	b = binary.BigEndian.AppendUint64(b, uint64(finalLSN))
+	b = binary.BigEndian.AppendUint64(b, pgTime(commitTime))
+	return binary.BigEndian.AppendUint32(b, xid)
+}
+
+func commitBytes(commitLSN, endLSN LSN, commitTime time.Time) []byte {
+	b := []byte{'C', 0}
+	b = binary.BigEndian.AppendUint64(b, uint64(commitLSN))
+	b = binary.BigEndian.AppendUint64(b, uint64(endLSN))
+	return binary.BigEndian.AppendUint64(b, pgTime(commitTime))
+}
+
+func relationBytes(id uint32, namespace, name string, columns []relationColumn) []byte {
+	b := []byte{'R'}
+	b = binary.BigEndian.AppendUint32(b, id)
+	b = append(append(b, namespace...), 0)
+	b = append(append(b, name...), 0)
+	b = append(b, 'f')
+	b = binary.BigEndian.AppendUint16(b, uint16(len(columns)))
+	for _, column := range columns {
+		b = append(b, 0)
+		b = append(append(b, column.name...), 0)
+		b = binary.BigEndian.AppendUint32(b, column.typeOID)
+		b = binary.BigEndian.AppendUint32(b, 0xFFFFFFFF) // no type modifier
+	}
+	return b
+}
+
+func tupleBytes(b []byte, columns []tupleColumn) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(columns)))
+	for _, column := range columns {
+		b = append(b, byte(column.kind))
+		if column.kind == tupleText {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(column.value)))
+			b = append(b, column.value...)
+		}
+	}
+	return b
+}
+
+func insertBytes(relationID uint32, new []tupleColumn) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{'I'}, relationID)
+	return tupleBytes(append(b, 'N'), new)
+}
+
+func updateBytes(relationID uint32, old, new []tupleColumn) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{'U'}, relationID)
+	if old != nil {
+		b = tupleBytes(append(b, 'O'), old)
+	}
+	return tupleBytes(append(b, 'N'), new)
+}
+
+func deleteBytes(relationID uint32, old []tupleColumn) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{'D'}, relationID)
+	return tupleBytes(append(b, 'O'), old)
+}
+
+func text(s string) tupleColumn {
+	return tupleColumn{kind: tupleText, value: []byte(s)}
+}
+
+func TestDecodeBeginAndCommit(t *testing.T) {
+	commitTime := time.Date(2026, time.March, 4, 10, 30, 0, 123000, time.UTC)
+
+	msg, err := decodeMessage(beginBytes(0x16B374D848, commitTime, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin, ok := msg.(beginMessage)
+	if !ok || begin.finalLSN != 0x16B374D848 || begin.xid != 42 || !begin.commitTime.Equal(commitTime) {
+		t.Fatalf("begin = %+v", msg)
+	}
+
+	msg, err = decodeMessage(commitBytes(0x100, 0x128, commitTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, ok := msg.(commitMessage)
+	if !ok || commit.commitLSN != 0x100 || commit.endLSN != 0x128 || !commit.commitTime.Equal(commitTime) {
+		t.Fatalf("commit = %+v", msg)
+	}
+}
+
+func TestDecodeRelation(t *testing.T) {
+	columns := []relationColumn{{name: "id", typeOID: 2950}, {name: "name", typeOID: 25}}
+
+	msg, err := decodeMessage(relationBytes(16384, "public", "users", columns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	relation, ok := msg.(relationMessage)
+	if !ok || relation.id != 16384 || relation.namespace != "public" || relation.name != "users" {
+		t.Fatalf("relation = %+v", msg)
+	}
+	if len(relation.columns) != 2 || relation.columns[0] != columns[0] || relation.columns[1] != columns[1] {
+		t.Fatalf("columns = %+v", relation.columns)
+	}
+}
+
+func TestDecodeChanges(t *testing.T) {
+	old := []tupleColumn{text("1"), text("Asha"), {kind: tupleNull}}
+	new := []tupleColumn{text("1"), text("Asha Roy"), {kind: tupleUnchanged}}
+
+	msg, err := decodeMessage(insertBytes(7, old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := msg.(changeMessage); change.kind != changeInsert || change.relationID != 7 || change.old != nil || !equalTuples(change.new, old) {
+		t.Fatalf("insert = %+v", change)
+	}
+
+	msg, err = decodeMessage(updateBytes(7, old, new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := msg.(changeMessage); change.kind != changeUpdate || !equalTuples(change.old, old) || !equalTuples(change.new, new) {
+		t.Fatalf("update = %+v", change)
+	}
+
+	// without a replica identity change the old row is not sent
+	msg, err = decodeMessage(updateBytes(7, nil, new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := msg.(changeMessage); change.old != nil || !equalTuples(change.new, new) {
+		t.Fatalf("update = %+v", change)
+	}
+
+	msg, err = decodeMessage(deleteBytes(7, old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := msg.(changeMessage); change.kind != changeDelete || !equalTuples(change.old, old) || change.new != nil {
+		t.Fatalf("delete = %+v", change)
+	}
+}
+
+func TestDecodeIgnoresOtherMessages(t *testing.T) {
+	// truncate of one relation
+	msg, err := decodeMessage([]byte{'T', 0, 0, 0, 1, 0, 0, 0, 0, 7})
+	if err != nil || msg != nil {
+		t.Fatalf("msg = %+v, err = %v", msg, err)
+	}
+}
+
+func TestDecodeRejectsBrokenMessages(t *testing.T) {
+	full := insertBytes(7, []tupleColumn{text("1"), text("Asha")})
+	for n := range len(full) {
+		if _, err := decodeMessage(full[:n]); err == nil {
+			t.Fatalf("decoded the first %d bytes of an insert", n)
+		}
+	}
+	if _, err := decodeMessage(commitBytes(1, 2, time.Now())[:10]); !errors.Is(err, errShortMessage) {
+		t.Fatalf("err = %v, want %v", err, errShortMessage)
+	}
+	broken := binary.BigEndian.AppendUint32([]byte{'I'}, 7)
+	broken = append(broken, 'N', 0, 1, 'b', 0, 0, 0, 1, 1) // binary values are not requested
+	if _, err := decodeMessage(broken); err == nil {
+		t.Fatal("decoded a binary tuple column")
+	}
+}
+
+func TestDecodeCopyData(t *testing.T) {
+	payload := insertBytes(7, []tupleColumn{text("1")})
+	data := binary.BigEndian.AppendUint64([]byte{'w'}, 0x200)
+	data = binary.BigEndian.AppendUint64(data, 0x300)
+	data = binary.BigEndian.AppendUint64(data, 0)
+	data = append(data, payload...)
+
+	msg, err := decodeCopyData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xlog := msg.(xLogData)
+	if xlog.walStart != 0x200 || string(xlog.data) != string(payload) {
+		t.Fatalf("xlog = %+v", xlog)
+	}
+	// the receive buffer is reused, the data must not alias it
+	data[len(data)-1] = 'x'
+	if string(xlog.data) != string(payload) {
+		t.Fatal("xlog data aliases the receive buffer")
+	}
+
+	data = binary.BigEndian.AppendUint64([]byte{'k'}, 0x400)
+	data = binary.BigEndian.AppendUint64(data, 0)
+	msg, err = decodeCopyData(append(data, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keepalive := msg.(keepalive); keepalive.walEnd != 0x400 || !keepalive.replyRequested {
+		t.Fatalf("keepalive = %+v", keepalive)
+	}
+}
+
+func equalTuples(a, b []tupleColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind != b[i].kind || string(a[i].value) != string(b[i].value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type PublisherOpts struct {
+	// Uri of the database, the user needs the REPLICATION attribute
+	Uri         string
+	Slot        string
+	Publication string
+	Store       LSNStore
+	// StatusInterval is how often the published position is confirmed to postgres. defaults to 10s
+	StatusInterval time.Duration
+	// RetryInterval is the wait before reconnecting after a failure. defaults to 5s
+	RetryInterval time.Duration
+}
+
+// Publisher turns the changes of the mirrored tables into manage-* events by reading
+// the logical replication stream, so no write can be missed by forgetting an emit.
+// The changes of a transaction are published once it commits, then its lsn is stored,
+// so the event manager has to return from Publish only once the brokers have the events.
+// A crash in between publishes the transaction again on restart (at least once), the
+// consumers already ignore data older than what they have.
+type Publisher struct {
+	opts              PublisherOpts
+	typeMap           *pgtype.Map
+	userTopic         *events.ManageUserTopic
+	businessTopic     *events.ManageBusinessTopic
+	businessUserTopic *events.ManageBusinessUserTopic
+
+	// state of the current replication session
+	relations map[uint32]relationMessage
+	changes   []changeMessage
+	published LSN
+}
+
+// tables are published in the order of the events topics they feed
+var tables = []string{"users", "businesses", "business_users"}
+
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+func NewPublisher(eventManager events.EventManager, opts PublisherOpts) (*Publisher, error) {
+	if !identifier.MatchString(opts.Slot) || !identifier.MatchString(opts.Publication) {
+		return nil, fmt.Errorf("invalid slot %q or publication %q", opts.Slot, opts.Publication)
+	}
+	if opts.StatusInterval == 0 {
+		opts.StatusInterval = 10 * time.Second
+	}
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = 5 * time.Second
+	}
+	return &Publisher{
+		opts:              opts,
+		typeMap:           pgtype.NewMap(),
+		userTopic:         events.NewManageUserTopic(eventManager, events.SubscribeOpts{}),
+		businessTopic:     events.NewManageBusinessTopic(eventManager, events.SubscribeOpts{}),
+		businessUserTopic: events.NewManageBusinessUserTopic(eventManager, events.SubscribeOpts{}),
+	}, nil
+}
+
+// Start streams the changes until ctx is done, reconnecting on failures
+func (p *Publisher) Start(ctx context.Context) {
+	for {
+		err := p.run(ctx)
+		if ctx.Err() != nil {
+			logger.Info().Msg("cdc publisher stopped")
+			return
+		}
+		logger.Error().Err(err).Msg("cdc publisher failed, reconnecting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.opts.RetryInterval):
+		}
+	}
+}
+
+func (p *Publisher) run(ctx context.Context) error {
+	conn, err := connectReplication(ctx, p.opts.Uri)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	if err := conn.ensurePublication(ctx, p.opts.Publication, tables); err != nil {
+		return fmt.Errorf("failed to create publication: %w", err)
+	}
+	if err := conn.ensureSlot(ctx, p.opts.Slot); err != nil {
+		return fmt.Errorf("failed to create replication slot: %w", err)
+	}
+	p.published, err = p.opts.Store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load lsn: %w", err)
+	}
+	// relations are announced again by the new session
+	p.relations = map[uint32]relationMessage{}
+	p.changes = nil
+	if err := conn.startReplication(ctx, p.opts.Slot, p.opts.Publication, p.published); err != nil {
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+	logger.Info().Str("slot", p.opts.Slot).Str("lsn", p.published.String()).Msg("cdc replication started")
+
+	nextStatus := time.Now().Add(p.opts.StatusInterval)
+	for {
+		if !time.Now().Before(nextStatus) {
+			if err := conn.sendStandbyStatus(p.published); err != nil {
+				return err
+			}
+			nextStatus = time.Now().Add(p.opts.StatusInterval)
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := conn.conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyData:
+			data, err := decodeCopyData(msg.Data)
+			if err != nil {
+				return err
+			}
+			switch data := data.(type) {
+			case keepalive:
+				if data.replyRequested {
+					nextStatus = time.Now()
+				}
+			case xLogData:
+				if err := p.handle(ctx, data.data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (p *Publisher) handle(ctx context.Context, data []byte) error {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		return err
+	}
+	switch msg := msg.(type) {
+	case relationMessage:
+		p.relations[msg.id] = msg
+	case beginMessage:
+		p.changes = p.changes[:0]
+	case changeMessage:
+		p.changes = append(p.changes, msg)
+	case commitMessage:
+		// already published before a restart
+		if msg.endLSN <= p.published {
+			return nil
+		}
+		for _, change := range p.changes {
+			if err := p.publish(ctx, change, msg.commitTime); err != nil {
+				return err
+			}
+		}
+		if err := p.opts.Store.Save(ctx, msg.endLSN); err != nil {
+			return fmt.Errorf("failed to save lsn: %w", err)
+		}
+		p.published = msg.endLSN
+	}
+	return nil
+}
+
+func (p *Publisher) publish(ctx context.Context, change changeMessage, commitTime time.Time) error {
+	relation, ok := p.relations[change.relationID]
+	if !ok {
+		return fmt.Errorf("unknown relation %d", change.relationID)
+	}
+	r, err := newRow(relation, change)
+	if err != nil {
+		return err
+	}
+	action := map[changeKind]string{changeInsert: "create", changeUpdate: "update", changeDelete: "delete"}[change.kind]
+
+	switch relation.name {
+	case "users":
+		var user events.ManageUserEventPayload
+		if err := r.scan(p.typeMap, userColumns(&user)); err != nil {
+			return p.skip(relation, err)
+		}
+		if change.kind == changeDelete {
+			user.DeletedAt, user.UpdatedAt = &commitTime, commitTime
+		}
+		return p.userTopic.Publish(ctx, events.NewUserManageEvent(action, user))
+	case "businesses":
+		var business events.MangageBusinessEventPayload
+		if err := r.scan(p.typeMap, businessColumns(&business)); err != nil {
+			return p.skip(relation, err)
+		}
+		if change.kind == changeDelete {
+			business.DeletedAt, business.UpdatedAt = &commitTime, commitTime
+		}
+		return p.businessTopic.Publish(ctx, events.NewBusinessManageEvent(action, business))
+	case "business_users":
+		var businessUser events.MangageBusinessUserEventPayload
+		if err := r.scan(p.typeMap, businessUserColumns(&businessUser)); err != nil {
+			return p.skip(relation, err)
+		}
+		if change.kind == changeDelete {
+			businessUser.DeletedAt, businessUser.UpdatedAt = &commitTime, commitTime
+		}
+		return p.businessUserTopic.Publish(ctx, events.NewBusinessUserManageEvent(action, businessUser))
+	}
+	return nil
+}
+
+// skip drops a change that can not be turned into an event, e.g. a delete
+// streamed before the replica identity was set to full only carries the key.
+func (p *Publisher) skip(relation relationMessage, err error) error {
+	logger.Warn().Err(err).Str("table", relation.name).Msg("cdc change skipped")
+	return nil
+}
+
+type rowColumn struct {
+	typeOID uint32
+	null    bool
+	value   []byte
+}
+
+// row is a change by column name, unchanged toasted values are taken from the old row
+type row map[string]rowColumn
+
+func newRow(relation relationMessage, change changeMessage) (row, error) {
+	tuple := change.new
+	if change.kind == changeDelete {
+		tuple = change.old
+	}
+	if len(tuple) != len(relation.columns) {
+		return nil, fmt.Errorf("relation %s has %d columns, change has %d", relation.name, len(relation.columns), len(tuple))
+	}
+	r := make(row, len(relation.columns))
+	for i, column := range relation.columns {
+		value := tuple[i]
+		if value.kind == tupleUnchanged && i < len(change.old) {
+			value = change.old[i]
+		}
+		if value.kind == tupleUnchanged {
+			return nil, fmt.Errorf("unchanged value of %s.%s is not available", relation.name, column.name)
+		}
+		r[column.name] = rowColumn{typeOID: column.typeOID, null: value.kind == tupleNull, value: value.value}
+	}
+	return r, nil
+}
+
+// scan decodes the text values of the row into dests, keyed by column name
+func (r row) scan(typeMap *pgtype.Map, dests map[string]any) error {
+	for name, dest := range dests {
+		column, ok := r[name]
+		if !ok {
+			return fmt.Errorf("column %s is missing", name)
+		}
+		var src []byte
+		if !column.null {
+			src = column.value
+		}
+		if err := typeMap.Scan(column.typeOID, pgtype.TextFormatCode, src, dest); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func userColumns(u *events.ManageUserEventPayload) map[string]any {
+	return map[string]any{
+		"id":             &u.ID,
+		"human_id":       &u.HumanID,
+		"name":           &u.Name,
+		"email":          &u.Email,
+		"dp":             &u.Dp,
+		"email_verified": &u.EmailVerified,
+		"phone":          &u.Phone,
+		"phone_verified": &u.PhoneVerified,
+		"created_at":     &u.CreatedAt,
+		"created_by":     &u.CreatedBy,
+		"updated_at":     &u.UpdatedAt,
+		"updated_by":     &u.UpdatedBy,
+		"deactivated_at": &u.DeactivatedAt,
+		"deactivated_by": &u.DeactivatedBy,
+		"deleted_at":     &u.DeletedAt,
+		"deleted_by":     &u.DeletedBy,
+		"locale":         &u.Locale,
+	}
+}
+
+func businessColumns(b *events.MangageBusinessEventPayload) map[string]any {
+	return map[string]any{
+		"id":               &b.ID,
+		"name":             &b.Name,
+		"description":      &b.Description,
+		"logo":             &b.Logo,
+		"industry":         &b.Industry,
+		"primary_currency": &b.PrimaryCurrency,
+		"owner_id":         &b.OwnerID,
+		"currencies":       &b.Currencies,
+		"created_at":       &b.CreatedAt,
+		"created_by":       &b.CreatedBy,
+		"updated_at":       &b.UpdatedAt,
+		"updated_by":       &b.UpdatedBy,
+		"deleted_at":       &b.DeletedAt,
+		"deleted_by":       &b.DeletedBy,
+	}
+}
+
+func businessUserColumns(bu *events.MangageBusinessUserEventPayload) map[string]any {
+	return map[string]any{
+		"user_id":     &bu.UserID,
+		"business_id": &bu.BusinessID,
+		"role":        &bu.Role,
+		"created_at":  &bu.CreatedAt,
+		"created_by":  &bu.CreatedBy,
+		"updated_at":  &bu.UpdatedAt,
+		"updated_by":  &bu.UpdatedBy,
+		"deleted_at":  &bu.DeletedAt,
+		"deleted_by":  &bu.DeletedBy,
+	}
+}
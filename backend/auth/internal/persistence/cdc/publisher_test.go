@@ -0,0 +1,111 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type memoryLSNStore struct {
+	lsn LSN
+}
+
+func (s *memoryLSNStore) Load(ctx context.Context) (LSN, error) { return s.lsn, nil }
+
+func (s *memoryLSNStore) Save(ctx context.Context, lsn LSN) error {
+	s.lsn = lsn
+	return nil
+}
+
+var businessUsersRelation = []relationColumn{
+	{name: "user_id", typeOID: pgtype.UUIDOID},
+	{name: "business_id", typeOID: pgtype.UUIDOID},
+	{name: "role", typeOID: pgtype.TextOID},
+	{name: "created_at", typeOID: pgtype.TimestamptzOID},
+	{name: "created_by", typeOID: pgtype.UUIDOID},
+	{name: "updated_at", typeOID: pgtype.TimestamptzOID},
+	{name: "updated_by", typeOID: pgtype.UUIDOID},
+	{name: "deleted_at", typeOID: pgtype.TimestamptzOID},
+	{name: "deleted_by", typeOID: pgtype.UUIDOID},
+}
+
+func newTestPublisher(t *testing.T, eventManager events.EventManager, store LSNStore) *Publisher {
+	t.Helper()
+	p, err := NewPublisher(eventManager, PublisherOpts{Slot: "billbharat", Publication: "billbharat", Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.relations = map[uint32]relationMessage{}
+	return p
+}
+
+// transaction streams an insert into business_users, committed at endLSN
+func transaction(t *testing.T, p *Publisher, userID, businessID uuid.UUID, endLSN LSN) error {
+	t.Helper()
+	now := time.Now()
+	row := []tupleColumn{
+		text(userID.String()), text(businessID.String()), text("Owner"),
+		text("2026-03-04 10:30:00+00"), text(userID.String()), text("2026-03-04 10:30:00+00"),
+		{kind: tupleNull}, {kind: tupleNull}, {kind: tupleNull},
+	}
+	for _, msg := range [][]byte{
+		relationBytes(1, "public", "business_users", businessUsersRelation),
+		beginBytes(endLSN, now, 1),
+		insertBytes(1, row),
+	} {
+		if err := p.handle(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p.handle(context.Background(), commitBytes(endLSN-8, endLSN, now))
+}
+
+func TestPublisherStoresTheLSNOnceTheEventsArePublished(t *testing.T) {
+	recorder := events.NewRecorder()
+	store := &memoryLSNStore{}
+	p := newTestPublisher(t, recorder, store)
+	userID, businessID := uuid.New(), uuid.New()
+
+	if err := transaction(t, p, userID, businessID, 0x100); err != nil {
+		t.Fatal(err)
+	}
+	created, err := events.Recorded[events.MangageBusinessUserEventPayload](recorder, events.ManageBusinessUserEvent, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Data.UserID != userID || created[0].Data.BusinessID != businessID || created[0].Data.Role != "Owner" {
+		t.Fatalf("created = %+v", created)
+	}
+	if store.lsn != 0x100 {
+		t.Fatalf("stored lsn = %s, want 0/100", store.lsn)
+	}
+
+	// replayed after a restart
+	recorder.Reset()
+	if err := transaction(t, p, userID, businessID, 0x100); err != nil {
+		t.Fatal(err)
+	}
+	if emitted := recorder.Events(events.ManageBusinessUserEvent, ""); len(emitted) != 0 {
+		t.Fatalf("published %d events again", len(emitted))
+	}
+}
+
+func TestPublisherKeepsTheLSNWhenPublishFails(t *testing.T) {
+	recorder := events.NewRecorder()
+	recorder.Err = errors.New("broker down")
+	store := &memoryLSNStore{lsn: 0x80}
+	p := newTestPublisher(t, recorder, store)
+	p.published = store.lsn
+
+	if err := transaction(t, p, uuid.New(), uuid.New(), 0x100); err == nil {
+		t.Fatal("the failed publish was swallowed")
+	}
+	if store.lsn != 0x80 || p.published != 0x80 {
+		t.Fatalf("lsn moved to %s past an unpublished transaction", store.lsn)
+	}
+}
@@ -0,0 +1,158 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// replicationConn is a connection in logical replication mode, see
+// https://www.postgresql.org/docs/current/protocol-replication.html
+type replicationConn struct {
+	conn *pgconn.PgConn
+}
+
+func connectReplication(ctx context.Context, uri string) (*replicationConn, error) {
+	config, err := pgconn.ParseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	// "database" allows plain sql next to the replication commands
+	config.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &replicationConn{conn: conn}, nil
+}
+
+func (c *replicationConn) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.conn.Close(ctx)
+}
+
+func (c *replicationConn) exec(ctx context.Context, sql string) ([]*pgconn.Result, error) {
+	return c.conn.Exec(ctx, sql).ReadAll()
+}
+
+func (c *replicationConn) exists(ctx context.Context, sql string) (bool, error) {
+	results, err := c.exec(ctx, sql)
+	if err != nil {
+		return false, err
+	}
+	return len(results) > 0 && len(results[0].Rows) > 0, nil
+}
+
+// ensurePublication publishes the tables, the full replica identity makes postgres
+// send the old row on updates and deletes, so unchanged toasted values and
+// deleted rows can be published with all their columns.
+func (c *replicationConn) ensurePublication(ctx context.Context, publication string, tables []string) error {
+	exists, err := c.exists(ctx, fmt.Sprintf("SELECT 1 FROM pg_publication WHERE pubname = '%s'", publication))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		quoted := make([]string, 0, len(tables))
+		for _, table := range tables {
+			quoted = append(quoted, fmt.Sprintf("%q", table))
+		}
+		if _, err := c.exec(ctx, fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", publication, strings.Join(quoted, ", "))); err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		if _, err := c.exec(ctx, fmt.Sprintf("ALTER TABLE %q REPLICA IDENTITY FULL", table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *replicationConn) ensureSlot(ctx context.Context, slot string) error {
+	exists, err := c.exists(ctx, fmt.Sprintf("SELECT 1 FROM pg_replication_slots WHERE slot_name = '%s'", slot))
+	if err != nil || exists {
+		return err
+	}
+	_, err = c.exec(ctx, fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT", slot))
+	return err
+}
+
+// startReplication switches the connection to copy both mode, from here on
+// only copy data messages are exchanged. postgres resumes from the later of
+// lsn and the position last confirmed on the slot.
+func (c *replicationConn) startReplication(ctx context.Context, slot string, publication string, lsn LSN) error {
+	c.conn.Frontend().SendQuery(&pgproto3.Query{
+		String: fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL %s (proto_version '1', publication_names '%s')", slot, lsn, publication),
+	})
+	if err := c.conn.Frontend().Flush(); err != nil {
+		return err
+	}
+	for {
+		msg, err := c.conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		}
+	}
+}
+
+// sendStandbyStatus confirms that everything up to lsn was handled,
+// postgres is then free to recycle the wal before it.
+func (c *replicationConn) sendStandbyStatus(lsn LSN) error {
+	data := make([]byte, 0, 34)
+	data = append(data, 'r')
+	data = binary.BigEndian.AppendUint64(data, uint64(lsn)) // written
+	data = binary.BigEndian.AppendUint64(data, uint64(lsn)) // flushed
+	data = binary.BigEndian.AppendUint64(data, uint64(lsn)) // applied
+	data = binary.BigEndian.AppendUint64(data, uint64(time.Since(postgresEpoch).Microseconds()))
+	data = append(data, 0) // no reply requested
+	c.conn.Frontend().Send(&pgproto3.CopyData{Data: data})
+	return c.conn.Frontend().Flush()
+}
+
+// xLogData carries a pgoutput message
+type xLogData struct {
+	walStart LSN
+	data     []byte
+}
+
+type keepalive struct {
+	walEnd         LSN
+	replyRequested bool
+}
+
+// decodeCopyData decodes the replication stream envelope
+func decodeCopyData(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+	d := &decoder{buf: data[1:]}
+	switch data[0] {
+	case 'w':
+		msg := xLogData{walStart: LSN(d.uint64())}
+		d.uint64() // wal end
+		d.uint64() // server time
+		// the buffer is reused by the next receive, while the changes of a
+		// transaction are kept until it commits
+		msg.data = bytes.Clone(d.buf)
+		return msg, d.err
+	case 'k':
+		msg := keepalive{walEnd: LSN(d.uint64())}
+		d.uint64() // server time
+		msg.replyRequested = d.byte() == 1
+		return msg, d.err
+	}
+	return nil, fmt.Errorf("unexpected replication message %q", data[0])
+}
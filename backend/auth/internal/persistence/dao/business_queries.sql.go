@@ -139,9 +139,54 @@ func (q *Queries) FindBusinessByOwner(ctx context.Context, ownerID uuid.UUID) (B
 	return i, err
 }
 
+const listBusinessesAfterID = `-- name: ListBusinessesAfterID :many
+SELECT id, name, description, logo, industry, primary_currency, owner_id, currencies, created_at, created_by, updated_at, updated_by, deleted_at, deleted_by FROM "businesses" WHERE id > $1 ORDER BY id LIMIT $2
+`
+
+type ListBusinessesAfterIDParams struct {
+	ID    uuid.UUID `json:"id"`
+	Limit int32     `json:"limit"`
+}
+
+func (q *Queries) ListBusinessesAfterID(ctx context.Context, arg ListBusinessesAfterIDParams) ([]Business, error) {
+	rows, err := q.db.Query(ctx, listBusinessesAfterID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Business
+	for rows.Next() {
+		var i Business
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Logo,
+			&i.Industry,
+			&i.PrimaryCurrency,
+			&i.OwnerID,
+			&i.Currencies,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.UpdatedAt,
+			&i.UpdatedBy,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setBusinessLogo = `-- name: SetBusinessLogo :one
 UPDATE "businesses" SET
     logo = $2,
+    updated_at = CURRENT_TIMESTAMP,
     updated_by = $3
 WHERE id = $1 AND deleted_at IS NULL
 RETURNING id, name, description, logo, industry, primary_currency, owner_id, currencies, created_at, created_by, updated_at, updated_by, deleted_at, deleted_by
@@ -183,6 +228,7 @@ UPDATE "businesses" SET
     industry = $5,
     primary_currency = $6,
     currencies = $7,
+    updated_at = CURRENT_TIMESTAMP,
     updated_by = $8
 WHERE id = $1 AND deleted_at IS NULL
 RETURNING id, name, description, logo, industry, primary_currency, owner_id, currencies, created_at, created_by, updated_at, updated_by, deleted_at, deleted_by
@@ -130,7 +130,7 @@ func (q *Queries) FindBusinessesByUserID(ctx context.Context, userID uuid.UUID)
 }
 
 const findUsersByBusinessID = `-- name: FindUsersByBusinessID :many
-SELECT bu.user_id, bu.business_id, bu.role, bu.created_at, bu.created_by, bu.updated_at, bu.updated_by, bu.deleted_at, bu.deleted_by, u.id, u.human_id, u.name, u.email, u.dp, u.email_verified, u.phone, u.phone_verified, u.created_at, u.created_by, u.updated_at, u.updated_by, u.deactivated_at, u.deactivated_by, u.deleted_at, u.deleted_by FROM "business_users" AS bu
+SELECT bu.user_id, bu.business_id, bu.role, bu.created_at, bu.created_by, bu.updated_at, bu.updated_by, bu.deleted_at, bu.deleted_by, u.id, u.human_id, u.name, u.email, u.dp, u.email_verified, u.phone, u.phone_verified, u.created_at, u.created_by, u.updated_at, u.updated_by, u.deactivated_at, u.deactivated_by, u.deleted_at, u.deleted_by, u.locale FROM "business_users" AS bu
 LEFT JOIN "users" AS u ON bu.user_id = u.id AND u.deleted_at IS NULL
 WHERE bu.business_id = $1 AND bu.deleted_at IS NULL
 `
@@ -175,6 +175,49 @@ func (q *Queries) FindUsersByBusinessID(ctx context.Context, businessID uuid.UUI
 			&i.User.DeactivatedBy,
 			&i.User.DeletedAt,
 			&i.User.DeletedBy,
+			&i.User.Locale,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBusinessUsersAfter = `-- name: ListBusinessUsersAfter :many
+SELECT user_id, business_id, role, created_at, created_by, updated_at, updated_by, deleted_at, deleted_by FROM "business_users"
+WHERE business_id > $1 OR (business_id = $1 AND user_id > $2)
+ORDER BY business_id, user_id LIMIT $3
+`
+
+type ListBusinessUsersAfterParams struct {
+	BusinessID uuid.UUID `json:"business_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Limit      int32     `json:"limit"`
+}
+
+func (q *Queries) ListBusinessUsersAfter(ctx context.Context, arg ListBusinessUsersAfterParams) ([]BusinessUser, error) {
+	rows, err := q.db.Query(ctx, listBusinessUsersAfter, arg.BusinessID, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BusinessUser
+	for rows.Next() {
+		var i BusinessUser
+		if err := rows.Scan(
+			&i.UserID,
+			&i.BusinessID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.UpdatedAt,
+			&i.UpdatedBy,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -36,6 +36,9 @@ type Querier interface {
 	FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 	FindUsersByBusinessID(ctx context.Context, businessID uuid.UUID) ([]FindUsersByBusinessIDRow, error)
 	FindVerificationRequestByUserIdAndType(ctx context.Context, arg FindVerificationRequestByUserIdAndTypeParams) (VerificationRequest, error)
+	ListBusinessUsersAfter(ctx context.Context, arg ListBusinessUsersAfterParams) ([]BusinessUser, error)
+	ListBusinessesAfterID(ctx context.Context, arg ListBusinessesAfterIDParams) ([]Business, error)
+	ListUsersAfterID(ctx context.Context, arg ListUsersAfterIDParams) ([]User, error)
 	SetBusinessLogo(ctx context.Context, arg SetBusinessLogoParams) (Business, error)
 	SetInvitationAcceptedAt(ctx context.Context, id uuid.UUID) error
 	SetUserEmailVerified(ctx context.Context, id uuid.UUID) (User, error)
@@ -43,6 +46,7 @@ type Querier interface {
 	SetVerificationRequestConsumedAt(ctx context.Context, id uuid.UUID) error
 	UpdateBusiness(ctx context.Context, arg UpdateBusinessParams) (Business, error)
 	UpdateUserDP(ctx context.Context, arg UpdateUserDPParams) (User, error)
+	UpdateUserLocale(ctx context.Context, arg UpdateUserLocaleParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -12,7 +12,7 @@ import (
 )
 
 const activateUser = `-- name: ActivateUser :one
-UPDATE "users" SET deactivated_at = NULL AND updated_by = $2 WHERE id = $1 AND deactivated_at IS NOT NULL AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET deactivated_at = NULL AND updated_by = $2 WHERE id = $1 AND deactivated_at IS NOT NULL AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 type ActivateUserParams struct {
@@ -40,6 +40,7 @@ func (q *Queries) ActivateUser(ctx context.Context, arg ActivateUserParams) (Use
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
@@ -49,7 +50,7 @@ INSERT INTO "users" (
    human_id, name, email, email_verified,  phone, created_by
 ) VALUES (
    $1, $2, $3, $4, $5, $6
-) RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+) RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 type CreateUserParams struct {
@@ -88,12 +89,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const deactivateUser = `-- name: DeactivateUser :one
-UPDATE "users" SET deactivated_at = CURRENT_TIMESTAMP AND deactivated_by = $2 WHERE id = $1 AND deactivated_at IS NULL AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET deactivated_at = CURRENT_TIMESTAMP AND deactivated_by = $2 WHERE id = $1 AND deactivated_at IS NULL AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 type DeactivateUserParams struct {
@@ -121,12 +123,13 @@ func (q *Queries) DeactivateUser(ctx context.Context, arg DeactivateUserParams)
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const deleteUser = `-- name: DeleteUser :one
-UPDATE "users" SET deleted_at = CURRENT_TIMESTAMP AND deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET deleted_at = CURRENT_TIMESTAMP AND deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 type DeleteUserParams struct {
@@ -154,12 +157,13 @@ func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (User, e
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const findUserByEmail = `-- name: FindUserByEmail :one
-SELECT id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by FROM "users" WHERE email = $1 AND deleted_at IS NULL
+SELECT id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale FROM "users" WHERE email = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, error) {
@@ -182,12 +186,13 @@ func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, erro
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const findUserById = `-- name: FindUserById :one
-SELECT id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by FROM "users" WHERE id = $1 AND deleted_at IS NULL
+SELECT id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale FROM "users" WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -210,12 +215,60 @@ func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
+const listUsersAfterID = `-- name: ListUsersAfterID :many
+SELECT id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale FROM "users" WHERE id > $1 ORDER BY id LIMIT $2
+`
+
+type ListUsersAfterIDParams struct {
+	ID    uuid.UUID `json:"id"`
+	Limit int32     `json:"limit"`
+}
+
+func (q *Queries) ListUsersAfterID(ctx context.Context, arg ListUsersAfterIDParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersAfterID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.HumanID,
+			&i.Name,
+			&i.Email,
+			&i.Dp,
+			&i.EmailVerified,
+			&i.Phone,
+			&i.PhoneVerified,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.UpdatedAt,
+			&i.UpdatedBy,
+			&i.DeactivatedAt,
+			&i.DeactivatedBy,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.Locale,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setUserEmailVerified = `-- name: SetUserEmailVerified :one
-UPDATE "users" SET email_verified = true WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET email_verified = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 func (q *Queries) SetUserEmailVerified(ctx context.Context, id uuid.UUID) (User, error) {
@@ -238,12 +291,13 @@ func (q *Queries) SetUserEmailVerified(ctx context.Context, id uuid.UUID) (User,
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const setUserPhoneVerified = `-- name: SetUserPhoneVerified :one
-UPDATE "users" SET phone_verified = true WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET phone_verified = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 func (q *Queries) SetUserPhoneVerified(ctx context.Context, id uuid.UUID) (User, error) {
@@ -266,12 +320,13 @@ func (q *Queries) SetUserPhoneVerified(ctx context.Context, id uuid.UUID) (User,
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
 
 const updateUserDP = `-- name: UpdateUserDP :one
-UPDATE "users" SET dp = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by
+UPDATE "users" SET dp = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
 `
 
 type UpdateUserDPParams struct {
@@ -299,6 +354,41 @@ func (q *Queries) UpdateUserDP(ctx context.Context, arg UpdateUserDPParams) (Use
 		&i.DeactivatedBy,
 		&i.DeletedAt,
 		&i.DeletedBy,
+		&i.Locale,
+	)
+	return i, err
+}
+
+const updateUserLocale = `-- name: UpdateUserLocale :one
+UPDATE "users" SET locale = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING id, human_id, name, email, dp, email_verified, phone, phone_verified, created_at, created_by, updated_at, updated_by, deactivated_at, deactivated_by, deleted_at, deleted_by, locale
+`
+
+type UpdateUserLocaleParams struct {
+	ID     uuid.UUID `json:"id"`
+	Locale string    `json:"locale"`
+}
+
+func (q *Queries) UpdateUserLocale(ctx context.Context, arg UpdateUserLocaleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserLocale, arg.ID, arg.Locale)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.HumanID,
+		&i.Name,
+		&i.Email,
+		&i.Dp,
+		&i.EmailVerified,
+		&i.Phone,
+		&i.PhoneVerified,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.UpdatedAt,
+		&i.UpdatedBy,
+		&i.DeactivatedAt,
+		&i.DeactivatedBy,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.Locale,
 	)
 	return i, err
 }
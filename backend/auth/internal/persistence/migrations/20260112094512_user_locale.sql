@@ -0,0 +1,2 @@
+-- Modify "users" table
+ALTER TABLE "public"."users" ADD COLUMN "locale" character varying(8) NOT NULL DEFAULT 'en';
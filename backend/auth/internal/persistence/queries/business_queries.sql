@@ -19,6 +19,7 @@ UPDATE "businesses" SET
     industry = $5,
     primary_currency = $6,
     currencies = $7,
+    updated_at = CURRENT_TIMESTAMP,
     updated_by = $8
 WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
@@ -26,9 +27,13 @@ RETURNING *;
 -- name: SetBusinessLogo :one
 UPDATE "businesses" SET
     logo = $2,
+    updated_at = CURRENT_TIMESTAMP,
     updated_by = $3
 WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;    
 
 -- name: DeleteBusiness :one
-DELETE FROM "businesses" WHERE id = $1 AND deleted_at IS NULL RETURNING *;
\ No newline at end of file
+DELETE FROM "businesses" WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+
+-- name: ListBusinessesAfterID :many
+SELECT * FROM "businesses" WHERE id > $1 ORDER BY id LIMIT $2;
@@ -17,4 +17,9 @@ LEFT JOIN "users" AS u ON bu.user_id = u.id AND u.deleted_at IS NULL
 WHERE bu.business_id = $1 AND bu.deleted_at IS NULL;
 
 -- name: DeleteBusinessUser :one
-DELETE FROM "business_users" WHERE user_id = $1 AND business_id = $2 AND deleted_at IS NULL RETURNING *;
\ No newline at end of file
+DELETE FROM "business_users" WHERE user_id = $1 AND business_id = $2 AND deleted_at IS NULL RETURNING *;
+
+-- name: ListBusinessUsersAfter :many
+SELECT * FROM "business_users"
+WHERE business_id > $1 OR (business_id = $1 AND user_id > $2)
+ORDER BY business_id, user_id LIMIT $3;
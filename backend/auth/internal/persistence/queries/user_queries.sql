@@ -21,10 +21,16 @@ UPDATE "users" SET deactivated_at = CURRENT_TIMESTAMP AND deactivated_by = $2 WH
 UPDATE "users" SET deactivated_at = NULL AND updated_by = $2 WHERE id = $1 AND deactivated_at IS NOT NULL AND deleted_at IS NULL RETURNING *;
 
 -- name: SetUserEmailVerified :one
-UPDATE "users" SET email_verified = true WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+UPDATE "users" SET email_verified = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING *;
 
 -- name: SetUserPhoneVerified :one
-UPDATE "users" SET phone_verified = true WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+UPDATE "users" SET phone_verified = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING *;
 
 -- name: UpdateUserDP :one
-UPDATE "users" SET dp = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+UPDATE "users" SET dp = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+
+-- name: UpdateUserLocale :one
+UPDATE "users" SET locale = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL RETURNING *;
+
+-- name: ListUsersAfterID :many
+SELECT * FROM "users" WHERE id > $1 ORDER BY id LIMIT $2;
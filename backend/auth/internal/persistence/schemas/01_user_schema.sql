@@ -15,6 +15,7 @@ CREATE TABLE "users" (
   deactivated_by uuid,
   deleted_at timestamptz,
   deleted_by uuid,
+  locale VARCHAR(8) NOT NULL DEFAULT 'en',
   FOREIGN KEY (created_by) REFERENCES "users"("id"),
   FOREIGN KEY (updated_by) REFERENCES "users"("id"),
   FOREIGN KEY (deactivated_by) REFERENCES "users"("id"),
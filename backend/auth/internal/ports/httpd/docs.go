@@ -0,0 +1,118 @@
+package httpd
+
+import (
+	"github.com/aritradevelops/billbharat/backend/auth/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/openapi"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiSpec documents the routes of SetupRoutes, served at /api/v1/auth-srv/openapi.json
+// and merged by the broker
+func (s *Server) apiSpec() *openapi.Spec {
+	spec := openapi.New("auth", "v1", "/api/v1/auth-srv")
+	for _, e := range service.Errors {
+		spec.Errors(openapi.Error(*e))
+	}
+
+	spec.Describe(fiber.MethodGet, "/health", openapi.Operation{
+		Summary: "Health of the service and its database", Data: handlers.HealthResponse{}, Unwrapped: true,
+	})
+
+	spec.Describe(fiber.MethodPost, "/auth/register", openapi.Operation{
+		Summary:     "Register a user",
+		Description: "The email and the phone are to be verified before the login.",
+		Body:        handlers.RegisterPayload{}, Rules: service.RegisterPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/login", openapi.Operation{
+		Summary:     "Log in with the email and the password",
+		Description: "Also sets the access_token and refresh_token cookies.",
+		Body:        handlers.LoginPayload{}, Rules: service.LoginPayload{}, Data: service.LoginResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/forgot-password", openapi.Operation{
+		Summary: "Send the code to reset the password",
+		Body:    handlers.ForgotPasswordPayload{}, Rules: service.ForgotPasswordPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/reset-password", openapi.Operation{
+		Summary: "Reset the password with the code sent",
+		Body:    handlers.ResetPasswordPayload{}, Rules: service.ResetPasswordPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/verify-email", openapi.Operation{
+		Summary: "Verify the email with the code sent",
+		Body:    handlers.VerifyEmailPayload{}, Rules: service.VerifyEmailPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/verify-phone", openapi.Operation{
+		Summary: "Verify the phone with the code sent",
+		Body:    handlers.VerifyPhonePayload{}, Rules: service.VerifyPhonePayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/send-email-verification-request", openapi.Operation{
+		Summary: "Send a new code to verify the email",
+		Body:    handlers.SendEmailVerificationRequestPayload{}, Rules: service.SendEmailVerificationRequestPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/send-phone-verification-request", openapi.Operation{
+		Summary: "Send a new code to verify the phone",
+		Body:    handlers.SendPhoneVerificationRequestPayload{}, Rules: service.SendPhoneVerificationRequestPayload{},
+	})
+	spec.Describe(fiber.MethodPost, "/auth/change-password", openapi.Operation{
+		Summary:  "Change the password of the user",
+		Security: openapi.Bearer,
+		Body:     handlers.ChangePasswordPayload{}, Rules: service.ChangePasswordPayload{},
+	})
+
+	spec.Describe(fiber.MethodGet, "/users/profile/:id", openapi.Operation{
+		Summary: "Profile of a user", Security: openapi.Bearer, Data: service.ProfileResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/users/change-profile-picture", openapi.Operation{
+		Summary:  "Change the profile picture of the user, null removes it",
+		Security: openapi.Bearer,
+		Body:     handlers.UpdateDPPayload{}, Rules: service.UpdateDPPayload{}, Data: service.ProfileResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/users/change-locale", openapi.Operation{
+		Summary:  "Change the language the user is notified in",
+		Security: openapi.Bearer,
+		Body:     handlers.ChangeLocalePayload{}, Rules: service.ChangeLocalePayload{}, Data: service.ProfileResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/users/invite", openapi.Operation{
+		Summary:  "Invite a user to the business selected",
+		Security: openapi.Bearer,
+		Body:     handlers.InvitePayload{}, Rules: service.InvitePayload{}, Data: service.InviteResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/users/accept-invitation/:hash", openapi.Operation{
+		Summary:  "Accept an invitation to a business",
+		Security: openapi.Bearer, Data: service.AcceptInvitationResponse{},
+	})
+
+	spec.Describe(fiber.MethodPost, "/businesses/create", openapi.Operation{
+		Summary:  "Create a business owned by the user",
+		Security: openapi.Bearer,
+		Body:     service.CreateBusinessPayload{}, Data: service.CreateBusinessResponse{},
+	})
+	spec.Describe(fiber.MethodGet, "/businesses/list", openapi.Operation{
+		Summary: "List the businesses of the user", Security: openapi.Bearer, Data: service.ListBusinessesResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/businesses/select/:business_id", openapi.Operation{
+		Summary:     "Select the business the user works in",
+		Description: "Issues new tokens for the business, like the login.",
+		Security:    openapi.Bearer, Data: service.LoginResponse{},
+	})
+
+	snapshotDescription := "Pages of the full state, used by the other services to backfill their copies."
+	spec.Describe(fiber.MethodGet, "/internal/snapshot/users", openapi.Operation{
+		Summary: "Snapshot of the users", Description: snapshotDescription, Tags: []string{"internal"},
+		Security: openapi.InternalApiKey, Query: service.SnapshotPayload{},
+		Data: snapshot.Page[events.ManageUserEventPayload]{},
+	})
+	spec.Describe(fiber.MethodGet, "/internal/snapshot/businesses", openapi.Operation{
+		Summary: "Snapshot of the businesses", Description: snapshotDescription, Tags: []string{"internal"},
+		Security: openapi.InternalApiKey, Query: service.SnapshotPayload{},
+		Data: snapshot.Page[events.MangageBusinessEventPayload]{},
+	})
+	spec.Describe(fiber.MethodGet, "/internal/snapshot/business-users", openapi.Operation{
+		Summary: "Snapshot of the members of the businesses", Description: snapshotDescription, Tags: []string{"internal"},
+		Security: openapi.InternalApiKey, Query: service.SnapshotPayload{},
+		Data: snapshot.Page[events.MangageBusinessUserEventPayload]{},
+	})
+	return spec
+}
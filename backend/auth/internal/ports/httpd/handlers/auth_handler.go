@@ -72,7 +72,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.Register(c.Context(), service.RegisterPayload{
+	_, err := h.authService.Register(c.UserContext(), service.RegisterPayload{
 		Name:        payload.Name,
 		Email:       payload.Email,
 		CountryCode: payload.CountryCode,
@@ -90,7 +90,7 @@ func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.VerifyEmail(c.Context(), service.VerifyEmailPayload{
+	_, err := h.authService.VerifyEmail(c.UserContext(), service.VerifyEmailPayload{
 		Email: payload.Email,
 		Code:  payload.Code,
 	})
@@ -104,7 +104,7 @@ func (h *AuthHandler) VerifyPhone(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.VerifyPhone(c.Context(), service.VerifyPhonePayload{
+	_, err := h.authService.VerifyPhone(c.UserContext(), service.VerifyPhonePayload{
 		Email: payload.Email,
 		Code:  payload.Code,
 	})
@@ -119,7 +119,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	response, err := h.authService.Login(c.Context(), service.LoginPayload{
+	response, err := h.authService.Login(c.UserContext(), service.LoginPayload{
 		Email:     payload.Email,
 		Password:  payload.Password,
 		UserIP:    c.IP(),
@@ -162,7 +162,7 @@ func (h *AuthHandler) SendEmailVerificationRequest(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.SendEmailVerificationRequest(c.Context(), service.SendEmailVerificationRequestPayload{
+	_, err := h.authService.SendEmailVerificationRequest(c.UserContext(), service.SendEmailVerificationRequestPayload{
 		Email: payload.Email,
 	})
 	if err != nil {
@@ -176,7 +176,7 @@ func (h *AuthHandler) SendPhoneVerificationRequest(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.SendPhoneVerificationRequest(c.Context(), service.SendPhoneVerificationRequestPayload{
+	_, err := h.authService.SendPhoneVerificationRequest(c.UserContext(), service.SendPhoneVerificationRequestPayload{
 		Email: payload.Email,
 	})
 	if err != nil {
@@ -190,7 +190,7 @@ func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.ForgotPassword(c.Context(), service.ForgotPasswordPayload{
+	_, err := h.authService.ForgotPassword(c.UserContext(), service.ForgotPasswordPayload{
 		Email: payload.Email,
 	})
 	if err != nil {
@@ -204,7 +204,7 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	_, err := h.authService.ResetPassword(c.Context(), service.ResetPasswordPayload{
+	_, err := h.authService.ResetPassword(c.UserContext(), service.ResetPasswordPayload{
 		Email:           payload.Email,
 		Code:            payload.Code,
 		Password:        payload.Password,
@@ -225,7 +225,7 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	_, err = h.authService.ChangePassword(c.Context(), user.UserID, service.ChangePasswordPayload{
+	_, err = h.authService.ChangePassword(c.UserContext(), user.UserID, service.ChangePasswordPayload{
 		Email:           user.Email,
 		CurrentPassword: payload.CurrentPassword,
 		NewPassword:     payload.NewPassword,
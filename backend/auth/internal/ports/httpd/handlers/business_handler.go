@@ -28,7 +28,7 @@ func (h *BusinessHandler) Create(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	resp, err := h.businessSrv.Create(c.Context(), user.UserID, payload)
+	resp, err := h.businessSrv.Create(c.UserContext(), user.UserID, payload)
 	if err != nil {
 		return err
 	}
@@ -40,7 +40,7 @@ func (h *BusinessHandler) List(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	resp, err := h.businessSrv.List(c.Context(), user.UserID)
+	resp, err := h.businessSrv.List(c.UserContext(), user.UserID)
 	if err != nil {
 		return err
 	}
@@ -52,7 +52,7 @@ func (h *BusinessHandler) Select(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	response, err := h.businessSrv.Select(c.Context(), user.UserID, c.Params("business_id"), service.SwitchBusinessPayload{
+	response, err := h.businessSrv.Select(c.UserContext(), user.UserID, c.Params("business_id"), service.SwitchBusinessPayload{
 		UserIP:    c.IP(),
 		UserAgent: c.Get("User-Agent"),
 	})
@@ -10,6 +10,7 @@ type Handler struct {
 	Auth     *AuthHandler
 	User     *UserHandler
 	Business *BusinessHandler
+	Snapshot *SnapshotHandler
 }
 
 func New(db database.Database, service *service.Service, environment string) *Handler {
@@ -18,5 +19,6 @@ func New(db database.Database, service *service.Service, environment string) *Ha
 		Auth:     NewAuthHandler(service.Auth, environment),
 		User:     NewUserHandler(service.User),
 		Business: NewBusinessHandler(service.Business, environment),
+		Snapshot: NewSnapshotHandler(service.Snapshot),
 	}
 }
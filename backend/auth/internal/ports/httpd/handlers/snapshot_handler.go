@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/auth/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SnapshotHandler struct {
+	snapshotSrv service.SnapshotService
+}
+
+func NewSnapshotHandler(snapshotSrv service.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{
+		snapshotSrv: snapshotSrv,
+	}
+}
+
+func (h *SnapshotHandler) Users(c *fiber.Ctx) error {
+	var payload service.SnapshotPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.snapshotSrv.Users(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "User"}), resp, nil))
+}
+
+func (h *SnapshotHandler) Businesses(c *fiber.Ctx) error {
+	var payload service.SnapshotPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.snapshotSrv.Businesses(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Business"}), resp, nil))
+}
+
+func (h *SnapshotHandler) BusinessUsers(c *fiber.Ctx) error {
+	var payload service.SnapshotPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.snapshotSrv.BusinessUsers(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Business User"}), resp, nil))
+}
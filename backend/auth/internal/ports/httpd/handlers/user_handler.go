@@ -15,6 +15,10 @@ type UpdateDPPayload struct {
 	Dp *string `json:"dp"`
 }
 
+type ChangeLocalePayload struct {
+	Locale string `json:"locale"`
+}
+
 type InvitePayload struct {
 	Name        string `json:"name"`
 	Email       string `json:"email"`
@@ -31,7 +35,7 @@ func (h *UserHandler) Profile(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	response, err := h.userSrv.Profile(c.Context(), user.UserID, service.ProfilePayload{
+	response, err := h.userSrv.Profile(c.UserContext(), user.UserID, service.ProfilePayload{
 		ID: c.Params("id"),
 	})
 	if err != nil {
@@ -49,7 +53,7 @@ func (h *UserHandler) UpdateDP(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	response, err := h.userSrv.UpdateDP(c.Context(), user.UserID, service.UpdateDPPayload{
+	response, err := h.userSrv.UpdateDP(c.UserContext(), user.UserID, service.UpdateDPPayload{
 		Dp: payload.Dp,
 	})
 	if err != nil {
@@ -58,6 +62,24 @@ func (h *UserHandler) UpdateDP(c *fiber.Ctx) error {
 	return c.JSON(NewResponse(translation.Localize(c, "user.update_dp", nil), response, nil))
 }
 
+func (h *UserHandler) ChangeLocale(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload ChangeLocalePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	response, err := h.userSrv.ChangeLocale(c.UserContext(), user.UserID, service.ChangeLocalePayload{
+		Locale: payload.Locale,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "user.change_locale", nil), response, nil))
+}
+
 func (h *UserHandler) Invite(c *fiber.Ctx) error {
 	user, err := authn.GetUserFromContext(c)
 	if err != nil {
@@ -67,7 +89,7 @@ func (h *UserHandler) Invite(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	response, err := h.userSrv.Invite(c.Context(), user.UserID, user.BusinessID, service.InvitePayload{
+	response, err := h.userSrv.Invite(c.UserContext(), user.UserID, user.BusinessID, service.InvitePayload{
 		Name:        payload.Name,
 		Email:       payload.Email,
 		CountryCode: payload.CountryCode,
@@ -87,7 +109,7 @@ func (h *UserHandler) AcceptInvitation(c *fiber.Ctx) error {
 		return err
 	}
 	hash := c.Params("hash")
-	response, err := h.userSrv.AcceptInvitation(c.Context(), user.UserID, hash)
+	response, err := h.userSrv.AcceptInvitation(c.UserContext(), user.UserID, hash)
 	if err != nil {
 		return err
 	}
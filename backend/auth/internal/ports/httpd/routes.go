@@ -1,11 +1,17 @@
 package httpd
 
-import "github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd/authn"
+import (
+	"github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+)
 
 func (s *Server) SetupRoutes() {
 	router := s.app
-	authMiddleware := authn.Middleware(s.jwtManager)
+	authMiddleware := authn.Middleware(s.jwtManager, s.gateway, s.verifyToken)
+	internalMiddleware := authn.InternalMiddleware(s.internalApiKey)
 	router.Get("/api/v1/auth-srv/health", s.handlers.Health)
+	// scraped by prometheus and the broker admin, the broker does not route it
+	router.Get("/metrics", metrics.Handler())
 
 	// Authentication routes
 	router.Post("/api/v1/auth-srv/auth/register", s.handlers.Auth.Register)
@@ -21,6 +27,7 @@ func (s *Server) SetupRoutes() {
 	// User routes
 	router.Get("/api/v1/auth-srv/users/profile/:id", authMiddleware, s.handlers.User.Profile)
 	router.Post("/api/v1/auth-srv/users/change-profile-picture", authMiddleware, s.handlers.User.UpdateDP)
+	router.Post("/api/v1/auth-srv/users/change-locale", authMiddleware, s.handlers.User.ChangeLocale)
 	router.Post("/api/v1/auth-srv/users/invite", authMiddleware, s.handlers.User.Invite)
 	router.Post("/api/v1/auth-srv/users/accept-invitation/:hash", authMiddleware, s.handlers.User.AcceptInvitation)
 
@@ -28,4 +35,12 @@ func (s *Server) SetupRoutes() {
 	router.Post("/api/v1/auth-srv/businesses/create", authMiddleware, s.handlers.Business.Create)
 	router.Get("/api/v1/auth-srv/businesses/list", authMiddleware, s.handlers.Business.List)
 	router.Post("/api/v1/auth-srv/businesses/select/:business_id", authMiddleware, s.handlers.Business.Select)
+
+	// Internal routes, used by the other services to backfill their copies
+	router.Get("/api/v1/auth-srv/internal/snapshot/users", internalMiddleware, s.handlers.Snapshot.Users)
+	router.Get("/api/v1/auth-srv/internal/snapshot/businesses", internalMiddleware, s.handlers.Snapshot.Businesses)
+	router.Get("/api/v1/auth-srv/internal/snapshot/business-users", internalMiddleware, s.handlers.Snapshot.BusinessUsers)
+
+	// the spec of the routes above, merged by the broker
+	s.apiSpec().Route(router)
 }
@@ -5,9 +5,11 @@ import (
 
 	"github.com/aritradevelops/billbharat/backend/auth/internal/core/jwtutil"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/aritradevelops/billbharat/backend/shared/translation"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
@@ -18,29 +20,38 @@ type Server struct {
 	app        *fiber.App
 	handlers   *handlers.Handler
 	jwtManager *jwtutil.JwtManager
+	// gateway verifies the identity the broker forwards, nil to only accept access tokens
+	gateway     *identity.Signer
+	verifyToken bool
+	// internalApiKey guards the service to service routes
+	internalApiKey string
 }
 
-func NewServer(host string, port int, handlers *handlers.Handler, jwtManager *jwtutil.JwtManager) *Server {
+func NewServer(host string, port int, handlers *handlers.Handler, jwtManager *jwtutil.JwtManager, gateway *identity.Signer, verifyToken bool, internalApiKey string) *Server {
 	app := fiber.New(fiber.Config{
 		ErrorHandler: ErrorHandler(),
 	})
-	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "http://localhost:3000",
-		AllowCredentials: true,
-	}))
 	app.Use(recover.New(
 		recover.Config{
 			EnableStackTrace: true,
 		},
 	))
-	app.Use(logger.New())
+	app.Use(tracing.New())
+	app.Use(metrics.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${respHeader:X-Request-ID} | ${error}\n",
+	}))
 	app.Use(translation.New())
 	server := &Server{
-		host:       host,
-		port:       port,
-		app:        app,
-		handlers:   handlers,
-		jwtManager: jwtManager,
+		host:        host,
+		port:        port,
+		app:         app,
+		handlers:    handlers,
+		jwtManager:  jwtManager,
+		gateway:     gateway,
+		verifyToken: verifyToken,
+
+		internalApiKey: internalApiKey,
 	}
 	return server
 }
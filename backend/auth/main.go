@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/auth/internal/config"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/core/jwtutil"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/cdc"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/database"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/persistence/repository"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd"
 	"github.com/aritradevelops/billbharat/backend/auth/internal/ports/httpd/handlers"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/common-nighthawk/go-figure"
 )
 
@@ -21,9 +31,28 @@ func main() {
 		return
 	}
 
+	if err := logger.Configure(conf.Log.Level, conf.Log.Levels); err != nil {
+		fmt.Println("failed to configure logger", err)
+		return
+	}
+
 	logo := figure.NewColorFigure(conf.Service.Name, "", "blue", true)
 	logo.Print()
 
+	shutdownTracing, err := tracing.Init(context.Background(), conf.Service.Name, conf.Tracing)
+	if err != nil {
+		fmt.Println("failed to init tracing", err)
+		return
+	}
+	// flush the spans still buffered before exiting
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error().Err(err).Msg("failed to shutdown tracing")
+		}
+	}()
+
 	db := database.NewPostgres(conf.Database.Uri, conf.Database.Timeout)
 
 	err = db.Connect()
@@ -32,6 +61,10 @@ func main() {
 		return
 	}
 	defer db.Disconnect()
+	if err := metrics.RegisterPool("postgres", db.Stats); err != nil {
+		fmt.Println("failed to register pool metrics", err)
+		return
+	}
 
 	repo, err := repository.New(db)
 	if err != nil {
@@ -40,20 +73,73 @@ func main() {
 	}
 	jwtManager := jwtutil.NewJwtManager(conf.Jwt.Secret, conf.Jwt.Lifetime.Duration())
 
-	eventManager := events.NewKafkaEventManager(events.KafkaOpts{
+	eventManager := events.New(conf.EventBroker.Driver, events.KafkaOpts{
 		Servers: conf.EventBroker.Servers,
 		GroupId: conf.EventBroker.GroupID,
-	})
+	}, events.MemoryOpts{})
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer stop()
+
+	// with cdc the user and business events come from the replication stream,
+	// the services must not publish them a second time
+	serviceEventManager := eventManager
+	if conf.Cdc.Enabled {
+		// the lsn is stored right after publishing, so the writes must be acknowledged by then
+		cdcEventManager := eventManager
+		if conf.EventBroker.Driver != events.MemoryDriver {
+			cdcEventManager = events.NewKafkaEventManager(events.KafkaOpts{
+				Servers: conf.EventBroker.Servers,
+				GroupId: conf.EventBroker.GroupID,
+				Sync:    true,
+			})
+			defer cdcEventManager.Close(context.Background())
+		}
+		publisher, err := cdc.NewPublisher(cdcEventManager, cdc.PublisherOpts{
+			Uri:         conf.Database.Uri,
+			Slot:        conf.Cdc.Slot,
+			Publication: conf.Cdc.Publication,
+			Store:       cdc.NewFileLSNStore(conf.Cdc.LsnFile),
+		})
+		if err != nil {
+			fmt.Println("failed to create cdc publisher", err)
+			return
+		}
+		go publisher.Start(ctx)
+		serviceEventManager = cdc.WithoutOwnedTopics(eventManager)
+	}
 
-	srv := service.New(repo, jwtManager, eventManager)
+	srv := service.New(repo, jwtManager, serviceEventManager)
 
 	handler := handlers.New(db, srv, conf.Deployment.Env)
 
-	server := httpd.NewServer(conf.Http.Host, conf.Http.Port, handler, jwtManager)
+	var gateway *identity.Signer
+	if conf.Gateway.Secret != "" {
+		gateway = identity.NewSigner(conf.Gateway.Secret)
+	}
+	server := httpd.NewServer(conf.Http.Host, conf.Http.Port, handler, jwtManager, gateway, conf.Gateway.VerifyToken, conf.Internal.ApiKey)
 	server.SetupRoutes()
 
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(); err != nil {
+			fmt.Println("server failed to shutdown", err)
+		}
+	}()
+
 	if err := server.Start(); err != nil {
 		fmt.Println("server failed to start", err)
 		return
 	}
+
+	// flush the pending events before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := eventManager.Close(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("failed to close event manager")
+	}
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+)
+
+// serverCounters count the requests of a server since the start, across the reloads
+type serverCounters struct {
+	requests    atomic.Int64
+	statuses    [6]atomic.Int64
+	retries     atomic.Int64
+	ejections   atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+}
+
+func (s *serverCounters) record(status int) {
+	s.requests.Add(1)
+	if class := status / 100; class > 0 && class < len(s.statuses) {
+		s.statuses[class].Add(1)
+	}
+}
+
+type counters struct {
+	mu             sync.Mutex
+	servers        map[string]*serverCounters
+	reloads        atomic.Int64
+	reloadFailures atomic.Int64
+}
+
+func newCounters() *counters {
+	return &counters{servers: map[string]*serverCounters{}}
+}
+
+func (c *counters) server(name string) *serverCounters {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.servers[name]
+	if !ok {
+		s = &serverCounters{}
+		c.servers[name] = s
+	}
+	return s
+}
+
+type serverCountersResponse struct {
+	Requests    int64            `json:"requests"`
+	Statuses    map[string]int64 `json:"statuses"`
+	Retries     int64            `json:"retries"`
+	Ejections   int64            `json:"ejections"`
+	CacheHits   int64            `json:"cache_hits"`
+	CacheMisses int64            `json:"cache_misses"`
+}
+
+type countersResponse struct {
+	Reloads        int64                             `json:"reloads"`
+	ReloadFailures int64                             `json:"reload_failures"`
+	Servers        map[string]serverCountersResponse `json:"servers"`
+}
+
+func (c *counters) snapshot() countersResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := countersResponse{
+		Reloads:        c.reloads.Load(),
+		ReloadFailures: c.reloadFailures.Load(),
+		Servers:        map[string]serverCountersResponse{},
+	}
+	for name, s := range c.servers {
+		statuses := map[string]int64{}
+		for class := 1; class < len(s.statuses); class++ {
+			statuses[fmt.Sprintf("%dxx", class)] = s.statuses[class].Load()
+		}
+		resp.Servers[name] = serverCountersResponse{
+			Requests:    s.requests.Load(),
+			Statuses:    statuses,
+			Retries:     s.retries.Load(),
+			Ejections:   s.ejections.Load(),
+			CacheHits:   s.cacheHits.Load(),
+			CacheMisses: s.cacheMisses.Load(),
+		}
+	}
+	return resp
+}
+
+type routeResponse struct {
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Public []string `json:"public"`
+	Deny   []string `json:"deny"`
+}
+
+// adminHandler serves the state of the broker to the operators, guarded by the api key when it is set
+func (b *broker) adminHandler(apiKey string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /routes", func(w http.ResponseWriter, r *http.Request) {
+		var routes []routeResponse
+		for _, rt := range b.router.Load().routes {
+			routes = append(routes, routeResponse{Name: rt.name, Prefix: rt.prefix, Public: rt.publicRoutes, Deny: rt.deniedRoutes})
+		}
+		respond(w, routes)
+	})
+	mux.HandleFunc("GET /upstreams", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		var upstreams []upstreamStatus
+		for _, u := range b.router.Load().upstreams {
+			upstreams = append(upstreams, u.status(now))
+		}
+		respond(w, upstreams)
+	})
+	mux.HandleFunc("GET /counters", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, b.counters.snapshot())
+	})
+	mux.Handle("GET /metrics", b.metricsHandler())
+	// the spec of the docs with the excluded routes too
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		b.router.Load().docs.serveSpec(r.Context(), w, true)
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := b.reload(); err != nil {
+			logger.Error().Err(err).Msg("failed to reload config, keeping the current one")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]any{
+				"message": "invalid config",
+				"data":    nil,
+				"error":   err.Error(),
+			})
+			return
+		}
+		respond(w, nil)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(snapshot.ApiKeyHeader)), []byte(apiKey)) != 1 {
+			respondError(w, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// respond writes the data in the response of the services
+func respond(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": http.StatusText(http.StatusOK),
+		"data":    data,
+		"error":   nil,
+	})
+}
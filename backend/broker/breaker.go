@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker stops sending to a server failing in a row, it opens after threshold failed
+// requests and lets a single probe through after openTime, closing on its success
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	openTime  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newBreaker(threshold int, openTime time.Duration) *breaker {
+	return &breaker{threshold: threshold, openTime: openTime}
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen {
+		if now.Sub(b.openedAt) < b.openTime {
+			return false
+		}
+		b.state = breakerHalfOpen
+	}
+	if b.state == breakerHalfOpen {
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+func (b *breaker) report(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		// the requests let through before it opened
+		return
+	case breakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.open(now)
+			return
+		}
+		b.state = breakerClosed
+		b.failures = 0
+	case breakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open(now)
+		}
+	}
+}
+
+// release frees the probe of a request that tells nothing of the server, e.g. canceled by the client
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = breakerOpen
+	b.openedAt = now
+	b.failures = 0
+}
+
+func (b *breaker) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
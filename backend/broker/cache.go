@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
+)
+
+const (
+	// responses bigger than this are served but not cached
+	maxCachedBody = 1 << 20
+	cacheHeader   = "X-Cache"
+)
+
+// CacheConfig bounds the responses cached for the routes with a cache, and reads the events
+// invalidating them. without kafka servers the responses only expire
+type CacheConfig struct {
+	MaxEntries int         `yaml:"max_entries"`
+	Kafka      KafkaConfig `yaml:"kafka"`
+}
+
+// KafkaConfig is the bus of the events, every broker needs its own group id to get them all
+type KafkaConfig struct {
+	Servers []string `yaml:"servers"`
+	GroupID string   `yaml:"group_id"`
+}
+
+func (c CacheConfig) validate() error {
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("cache.max_entries %d can not be negative", c.MaxEntries)
+	}
+	if len(c.Kafka.Servers) > 0 && c.Kafka.GroupID == "" {
+		return errors.New("cache.kafka.group_id is required with servers")
+	}
+	return nil
+}
+
+// RouteCacheConfig caches the GET responses of a route per business, disabled without a ttl
+type RouteCacheConfig struct {
+	TTL string `yaml:"ttl"`
+	// InvalidatedBy are the events dropping the responses of the business in their data
+	InvalidatedBy []string `yaml:"invalidated_by"`
+}
+
+type routeCache struct {
+	path   string
+	ttl    time.Duration
+	topics []string
+}
+
+func (u *upstream) cacheOf(path string) (routeCache, bool) {
+	path = strings.TrimPrefix(path, strings.TrimSuffix(u.prefix, "/"))
+	for _, rc := range u.caches {
+		if matchRoute(rc.path, path) {
+			return rc, true
+		}
+	}
+	return routeCache{}, false
+}
+
+type cacheEntry struct {
+	business string
+	topics   []string
+	stored   time.Time
+	expires  time.Time
+	header   http.Header
+	body     []byte
+}
+
+// responseCache keeps the responses of the cached routes across the reloads, a route
+// without a cache anymore just stops reading them
+type responseCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	// generation changes on every invalidation, a response fetched across one is stale
+	generation atomic.Uint64
+}
+
+func newResponseCache(maxEntries int) *responseCache {
+	if maxEntries == 0 {
+		maxEntries = 10000
+	}
+	return &responseCache{maxEntries: maxEntries, entries: map[string]*cacheEntry{}}
+}
+
+func (c *responseCache) get(key string, now time.Time) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *responseCache) put(key string, entry *cacheEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation.Load() != generation {
+		return
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !entry.stored.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// still full, any entry goes
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// invalidate drops the responses of the routes invalidated by the topic, only the ones of
+// the business unless it is empty
+func (c *responseCache) invalidate(topic, business string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation.Add(1)
+	dropped := 0
+	for key, entry := range c.entries {
+		if (business == "" || entry.business == business) && slices.Contains(entry.topics, topic) {
+			delete(c.entries, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// wrap serves the GET requests of the cached routes of the upstream from the cache, the
+// others go through untouched. the responses are keyed by the business and the role of the
+// identity, so only the routes answering the same to every member of a business with the
+// same role are to be cached
+func (c *responseCache) wrap(u *upstream, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc, ok := u.cacheOf(routePath(r))
+		if !ok || r.Method != http.MethodGet || strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		verified, ok := identityFromContext(r.Context())
+		if !ok || verified.BusinessID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := strings.Join([]string{u.name, verified.BusinessID, verified.Role, r.URL.RequestURI(), r.Header.Get("Accept-Language")}, "\x00")
+		now := time.Now()
+		if entry, ok := c.get(key, now); ok {
+			u.counters.cacheHits.Add(1)
+			entry.serve(w, r, now)
+			return
+		}
+		u.counters.cacheMisses.Add(1)
+
+		generation := c.generation.Load()
+		tee := &teeRecorder{ResponseWriter: w, status: http.StatusOK, before: w.Header().Clone()}
+		w.Header().Set(cacheHeader, "MISS")
+		next.ServeHTTP(tee, r)
+		if tee.status != http.StatusOK || tee.overflow || !sharedCacheable(tee.header) {
+			return
+		}
+		c.put(key, &cacheEntry{
+			business: verified.BusinessID,
+			topics:   rc.topics,
+			stored:   now,
+			expires:  now.Add(rc.ttl),
+			header:   tee.header,
+			body:     tee.body.Bytes(),
+		}, generation)
+	})
+}
+
+// serve answers with the cached response, or 304 when the client has it already
+func (e *cacheEntry) serve(w http.ResponseWriter, r *http.Request, now time.Time) {
+	header := w.Header()
+	for key, values := range e.header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	header.Set(cacheHeader, "HIT")
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+	if e.notModified(r) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.body)
+}
+
+// sharedCacheable tells whether the server lets a shared cache keep the response
+func sharedCacheable(header http.Header) bool {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "private") || strings.EqualFold(name, "no-store") {
+			return false
+		}
+	}
+	return true
+}
+
+// notModified checks the validators of the request against the cached response,
+// If-None-Match winning over If-Modified-Since like the services do
+func (e *cacheEntry) notModified(r *http.Request) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := e.header.Get("ETag")
+		return etag != "" && etagMatches(ifNoneMatch, etag)
+	}
+	lastModified, err := http.ParseTime(e.header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	modifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(modifiedSince)
+}
+
+// etagMatches compares the etags weakly, like the services do for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// teeRecorder copies the response of the server on its way to the client. the header kept
+// is the one the server added, not the ones of the broker set before
+type teeRecorder struct {
+	http.ResponseWriter
+	status   int
+	wrote    bool
+	before   http.Header
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (t *teeRecorder) WriteHeader(status int) {
+	if !t.wrote {
+		t.capture(status)
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *teeRecorder) Write(body []byte) (int, error) {
+	if !t.wrote {
+		t.capture(http.StatusOK)
+	}
+	if !t.overflow {
+		if t.body.Len()+len(body) > maxCachedBody {
+			t.overflow = true
+			t.body.Reset()
+		} else {
+			t.body.Write(body)
+		}
+	}
+	return t.ResponseWriter.Write(body)
+}
+
+func (t *teeRecorder) capture(status int) {
+	t.wrote = true
+	t.status = status
+	t.header = http.Header{}
+	for key, values := range t.ResponseWriter.Header() {
+		// the request id is the one of the request filling the cache
+		switch key {
+		case cacheHeader, http.CanonicalHeaderKey(tracing.RequestIDHeader), "Date", "Set-Cookie", "Content-Length":
+			continue
+		}
+		for _, value := range values {
+			if !slices.Contains(t.before[key], value) {
+				t.header.Add(key, value)
+			}
+		}
+	}
+}
+
+func (t *teeRecorder) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// cacheEvent is the part of the events the cache reads, whatever the rest of their data
+type cacheEvent = events.EventPayload[struct {
+	BusinessID string `json:"business_id"`
+}]
+
+// subscribeInvalidations reads the events invalidating the caches of the routes, the ones
+// a reload adds included. the subscriptions last until the event manager is closed
+func (b *broker) subscribeInvalidations(config Config) {
+	if b.events == nil {
+		return
+	}
+	for _, server := range config.Servers {
+		for _, rt := range server.Routes {
+			for _, topic := range rt.Cache.InvalidatedBy {
+				if b.subscribed[topic] {
+					continue
+				}
+				b.subscribed[topic] = true
+				events.NewTopic[cacheEvent](b.events, events.Event(topic), events.SubscribeOpts{}).
+					Subscribe(context.Background(), func(ctx context.Context, event cacheEvent) error {
+						dropped := b.cache.invalidate(topic, event.Data.BusinessID)
+						logger.Ctx(ctx).Debug().Str("event", topic).Str("business_id", event.Data.BusinessID).
+							Int("dropped", dropped).Msg("cache invalidated")
+						return nil
+					})
+				logger.Info().Str("event", topic).Msg("cache invalidated by event")
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+)
+
+func TestCacheKeysTheRole(t *testing.T) {
+	u, err := newUpstream(ServerConfig{Name: "product", Prefix: "/api/v1/product-srv", Host: "localhost", Port: 9002, Routes: []RouteConfig{
+		{Path: "/products", Cache: RouteCacheConfig{TTL: "1m"}},
+	}}, &serverCounters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newResponseCache(0).wrap(u, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified, _ := identityFromContext(r.Context())
+		w.Write([]byte(verified.Role))
+	}))
+	get := func(role string) string {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/product-srv/products", nil)
+		r = r.WithContext(context.WithValue(r.Context(), identityKey{}, identity.Identity{UserID: "user", BusinessID: "business", Role: role}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	for _, role := range []string{"owner", "staff", "owner"} {
+		if got := get(role); got != role {
+			t.Errorf("the %s got the response of the %s", role, got)
+		}
+	}
+}
+
+func TestCacheHonoursTheServer(t *testing.T) {
+	u, err := newUpstream(ServerConfig{Name: "product", Prefix: "/api/v1/product-srv", Host: "localhost", Port: 9002, Routes: []RouteConfig{
+		{Path: "/products", Cache: RouteCacheConfig{TTL: "1m"}},
+	}}, &serverCounters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastModified := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	cacheControl, calls := "private, no-cache", 0
+	handler := newResponseCache(0).wrap(u, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Write([]byte("products"))
+	}))
+	get := func(modifiedSince time.Time) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/product-srv/products", nil)
+		r = r.WithContext(context.WithValue(r.Context(), identityKey{}, identity.Identity{UserID: "user", BusinessID: "business", Role: "owner"}))
+		if !modifiedSince.IsZero() {
+			r.Header.Set("If-Modified-Since", modifiedSince.Format(http.TimeFormat))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	get(time.Time{})
+	get(time.Time{})
+	if calls != 2 {
+		t.Fatalf("a private response was cached, %d calls", calls)
+	}
+
+	cacheControl = "no-cache"
+	get(time.Time{})
+	if w := get(lastModified); w.Code != http.StatusNotModified || w.Header().Get(cacheHeader) != "HIT" {
+		t.Fatalf("status = %d %s, want a 304 from the cache", w.Code, w.Header().Get(cacheHeader))
+	}
+	if w := get(lastModified.Add(-time.Hour)); w.Code != http.StatusOK || w.Body.String() != "products" {
+		t.Fatalf("status = %d, want the cached response", w.Code)
+	}
+	if calls != 3 {
+		t.Fatalf("calls = %d, want the cached response served", calls)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// JwtSecret verifies the access tokens the auth service issues
+	JwtSecret string `yaml:"jwt_secret"`
+	// IdentitySecret signs the identity forwarded to the services
+	IdentitySecret string          `yaml:"identity_secret"`
+	RateLimit      RateLimitConfig `yaml:"rate_limit"`
+	Servers        []ServerConfig  `yaml:"servers"`
+	Admin          AdminConfig     `yaml:"admin"`
+	// Watch is how often the file is checked for changes, 0s to only reload on SIGHUP
+	Watch string `yaml:"watch"`
+	// ShutdownTimeout bounds the draining of the connections on SIGTERM
+	ShutdownTimeout string                `yaml:"shutdown_timeout"`
+	Tracing         tracing.Config        `yaml:"tracing"`
+	Log             LogConfig             `yaml:"log"`
+	TLS             TLSConfig             `yaml:"tls"`
+	Cors            CorsConfig            `yaml:"cors"`
+	SecurityHeaders SecurityHeadersConfig `yaml:"security_headers"`
+	Docs            DocsConfig            `yaml:"docs"`
+	Cache           CacheConfig           `yaml:"cache"`
+}
+
+// LogConfig is the level of the logs, levels sets apart the packages, e.g. events: warn
+type LogConfig struct {
+	Level  string            `yaml:"level"`
+	Levels map[string]string `yaml:"levels"`
+}
+
+// AdminConfig is the listener of the admin routes, disabled without a port.
+// host, port, admin, watch, shutdown_timeout, tracing, log, tls and cache are only read at the start
+type AdminConfig struct {
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	ApiKey string `yaml:"api_key"`
+}
+
+// loadConfig reads the config, the secrets come from the environment, e.g.
+// jwt_secret: ${JWT_SECRET}. the unknown fields are errors, not typos silently ignored
+func loadConfig(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(content)))))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// validate reports every problem of the config at once, the values of the servers and the
+// rate limits are checked again when they are built
+func (c Config) validate() error {
+	var errs []error
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is invalid", c.Port))
+	}
+	if c.JwtSecret == "" {
+		errs = append(errs, errors.New("jwt_secret is required"))
+	}
+	if c.IdentitySecret == "" {
+		errs = append(errs, errors.New("identity_secret is required"))
+	}
+	if c.Admin.Port < 0 || c.Admin.Port > 65535 {
+		errs = append(errs, fmt.Errorf("admin.port %d is invalid", c.Admin.Port))
+	}
+	if _, err := parseDuration(c.Watch, 0); err != nil {
+		errs = append(errs, fmt.Errorf("watch %q is invalid", c.Watch))
+	}
+	if _, err := parseDuration(c.ShutdownTimeout, 0); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown_timeout %q is invalid", c.ShutdownTimeout))
+	}
+	switch c.Tracing.Exporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOtlp:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is invalid", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio %v is not between 0 and 1", c.Tracing.SampleRatio))
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q is invalid", c.Log.Level))
+	}
+	for pkg, level := range c.Log.Levels {
+		if _, err := zerolog.ParseLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("log.levels.%s %q is invalid", pkg, level))
+		}
+	}
+	if err := c.TLS.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newCors(c.Cors); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newSecurityHeaders(c.SecurityHeaders); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Docs.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Cache.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.Servers) == 0 {
+		errs = append(errs, errors.New("servers are required"))
+	}
+	names := map[string]bool{}
+	prefixes := map[string]bool{}
+	for i, server := range c.Servers {
+		field := fmt.Sprintf("servers[%d] (%s)", i, server.Name)
+		if server.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", field))
+		} else if names[server.Name] {
+			errs = append(errs, fmt.Errorf("%s: name is repeated", field))
+		}
+		names[server.Name] = true
+		if strings.Trim(server.Prefix, "/") == "" {
+			errs = append(errs, fmt.Errorf("%s: prefix is required", field))
+		} else if prefixes[normalizePrefix(server.Prefix)] {
+			errs = append(errs, fmt.Errorf("%s: prefix %s is repeated", field, server.Prefix))
+		}
+		prefixes[normalizePrefix(server.Prefix)] = true
+		instances := server.Instances
+		if len(instances) == 0 {
+			instances = []InstanceConfig{{Host: server.Host, Port: server.Port}}
+		}
+		for j, inst := range instances {
+			if inst.Host == "" || inst.Port <= 0 || inst.Port > 65535 {
+				errs = append(errs, fmt.Errorf("%s: instances[%d] %s:%d is invalid", field, j, inst.Host, inst.Port))
+			}
+		}
+		// the requests are matched lowercased, a pattern with capitals would never match
+		patterns := append([]string{server.Prefix}, append(server.Public, server.Deny...)...)
+		for _, route := range server.Routes {
+			patterns = append(patterns, route.Path)
+		}
+		for _, pattern := range patterns {
+			if pattern != strings.ToLower(pattern) {
+				errs = append(errs, fmt.Errorf("%s: path %s is not lowercase", field, pattern))
+			}
+		}
+		if server.Retries < 0 {
+			errs = append(errs, fmt.Errorf("%s: retries can not be negative", field))
+		}
+		if _, err := newUpstream(server, &serverCounters{}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+	for i, rule := range c.RateLimit.Rules {
+		if period, err := time.ParseDuration(rule.Period); err != nil || period <= 0 || rule.Rate <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.rules[%d] (%s): rate and period are required", i, rule.Prefix))
+		}
+		if rule.Prefix != strings.ToLower(rule.Prefix) {
+			errs = append(errs, fmt.Errorf("rate_limit.rules[%d] (%s): prefix is not lowercase", i, rule.Prefix))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func statConfig(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
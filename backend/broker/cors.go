@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsConfig is the cors policy of every server, none without allow_origins. an origin
+// is exact, * or a wildcard subdomain, e.g. https://*.billbharat.in
+type CorsConfig struct {
+	AllowOrigins     []string `yaml:"allow_origins"`
+	AllowMethods     []string `yaml:"allow_methods"`
+	AllowHeaders     []string `yaml:"allow_headers"`
+	ExposeHeaders    []string `yaml:"expose_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	// MaxAge is how long the browsers cache the preflight requests
+	MaxAge string `yaml:"max_age"`
+}
+
+type cors struct {
+	origins       []string
+	any           bool
+	credentials   bool
+	methods       string
+	headers       string
+	exposeHeaders string
+	maxAge        string
+}
+
+func newCors(conf CorsConfig) (*cors, error) {
+	c := &cors{
+		credentials:   conf.AllowCredentials,
+		methods:       strings.Join(conf.AllowMethods, ", "),
+		headers:       strings.Join(conf.AllowHeaders, ", "),
+		exposeHeaders: strings.Join(conf.ExposeHeaders, ", "),
+	}
+	for _, origin := range conf.AllowOrigins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		switch {
+		case origin == "":
+		case origin == "*":
+			c.any = true
+		case strings.Count(origin, "*") > 1 || (strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")):
+			return nil, fmt.Errorf("invalid cors origin %q", origin)
+		default:
+			c.origins = append(c.origins, strings.ToLower(origin))
+		}
+	}
+	if c.methods == "" {
+		c.methods = "GET, POST, PUT, PATCH, DELETE"
+	}
+	maxAge, err := parseDuration(conf.MaxAge, 10*time.Minute)
+	if err != nil || maxAge < 0 {
+		return nil, fmt.Errorf("invalid cors max_age %q", conf.MaxAge)
+	}
+	c.maxAge = strconv.Itoa(int(maxAge.Seconds()))
+	return c, nil
+}
+
+func (c *cors) allowed(origin string) bool {
+	if c.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.origins {
+		if allowed == origin {
+			return true
+		}
+		// https://*.billbharat.in takes https://app.billbharat.in, not https://billbharat.in
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok &&
+			strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle sets the cors headers of the response, answering the preflight requests itself.
+// it reports whether the request is done
+func (c *cors) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if origin == "" || !c.allowed(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+	// the credentials do not go with *, the browsers need the origin itself
+	if c.any && !c.credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if c.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+		}
+		return false
+	}
+	header.Set("Access-Control-Allow-Methods", c.methods)
+	if c.headers != "" {
+		header.Set("Access-Control-Allow-Headers", c.headers)
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	header.Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/openapi"
+)
+
+const (
+	docsTimeout = 5 * time.Second
+	// a merge missing servers is retried soon, they are likely starting
+	partialDocsCacheFor = 5 * time.Second
+)
+
+// uiAssets are the files of swagger-ui-dist the ui loads
+var uiAssets = []string{"swagger-ui-bundle.js", "swagger-ui.css"}
+
+// DocsConfig serves the openapi specs of the servers merged under their prefixes, and a
+// ui to browse them. disabled without a path, the admin serves the spec regardless
+type DocsConfig struct {
+	// Path serves the ui, the spec is at path/openapi.json
+	Path  string `yaml:"path"`
+	Title string `yaml:"title"`
+	// CacheFor is how long the merged spec is served before the servers are asked again
+	CacheFor string `yaml:"cache_for"`
+	// Exclude are the routes left out of the spec, patterns like the public routes but
+	// with the prefix, e.g. /api/v1/auth-srv/internal/*. the admin serves them all
+	Exclude []string `yaml:"exclude"`
+	// Assets are the swagger-ui-dist files of the ui, a directory the broker serves them
+	// from or an https base url, which needs their Integrity. no ui without them
+	Assets    string        `yaml:"assets"`
+	Integrity DocsIntegrity `yaml:"integrity"`
+}
+
+// DocsIntegrity are the subresource integrity of the files at a base url, e.g. sha384-...
+type DocsIntegrity struct {
+	Script string `yaml:"script"`
+	Style  string `yaml:"style"`
+}
+
+func (c DocsConfig) validate() error {
+	if c.Path != "" && (!strings.HasPrefix(c.Path, "/") || c.Path == "/") {
+		return fmt.Errorf("docs.path %q is invalid", c.Path)
+	}
+	if cacheFor, err := parseDuration(c.CacheFor, 0); err != nil || cacheFor < 0 {
+		return fmt.Errorf("docs.cache_for %q is invalid", c.CacheFor)
+	}
+	if remoteAssets(c.Assets) {
+		if !strings.HasPrefix(c.Assets, "https://") {
+			return fmt.Errorf("docs.assets %q is not https", c.Assets)
+		}
+		for name, integrity := range map[string]string{"script": c.Integrity.Script, "style": c.Integrity.Style} {
+			if !strings.HasPrefix(integrity, "sha256-") && !strings.HasPrefix(integrity, "sha384-") && !strings.HasPrefix(integrity, "sha512-") {
+				return fmt.Errorf("docs.integrity.%s %q is invalid, the assets of a url are pinned by it", name, integrity)
+			}
+		}
+	}
+	return nil
+}
+
+func remoteAssets(assets string) bool {
+	return strings.HasPrefix(assets, "https://") || strings.HasPrefix(assets, "http://")
+}
+
+// docs merges the specs the servers serve at their prefix + /openapi.json
+type docs struct {
+	path      string
+	title     string
+	cacheFor  time.Duration
+	exclude   []string
+	assets    string
+	integrity DocsIntegrity
+	upstreams []*upstream
+
+	mu      sync.Mutex
+	merged  map[string]any
+	expires time.Time
+}
+
+func newDocs(conf DocsConfig, upstreams []*upstream) (*docs, error) {
+	cacheFor, err := parseDuration(conf.CacheFor, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	title := conf.Title
+	if title == "" {
+		title = "billbharat"
+	}
+	return &docs{
+		path:      strings.TrimSuffix(conf.Path, "/"),
+		title:     title,
+		cacheFor:  cacheFor,
+		exclude:   conf.Exclude,
+		assets:    strings.TrimSuffix(conf.Assets, "/"),
+		integrity: conf.Integrity,
+		upstreams: upstreams,
+	}, nil
+}
+
+// handle serves the ui and the spec, it reports whether the request was one of theirs
+func (d *docs) handle(w http.ResponseWriter, r *http.Request) bool {
+	if d.path == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	switch r.URL.Path {
+	case d.path, d.path + "/":
+		if d.assets == "" {
+			return false
+		}
+		d.serveUI(w)
+	case d.path + openapi.Path:
+		d.serveSpec(r.Context(), w, false)
+	default:
+		name, ok := strings.CutPrefix(r.URL.Path, d.path+"/assets/")
+		if !ok || !slices.Contains(uiAssets, name) || d.assets == "" || remoteAssets(d.assets) {
+			return false
+		}
+		http.ServeFile(w, r, filepath.Join(d.assets, name))
+	}
+	return true
+}
+
+// serveSpec serves the merged spec, without the excluded routes unless all
+func (d *docs) serveSpec(ctx context.Context, w http.ResponseWriter, all bool) {
+	spec := d.spec(ctx)
+	if !all {
+		spec = d.excluded(spec)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(spec)
+}
+
+var uiTemplate = template.Must(template.New("docs").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.UI}}/swagger-ui.css"{{with .StyleIntegrity}} integrity="{{.}}" crossorigin="anonymous"{{end}}>
+</head>
+<body>
+<div id="docs"></div>
+<script src="{{.UI}}/swagger-ui-bundle.js"{{with .ScriptIntegrity}} integrity="{{.}}" crossorigin="anonymous"{{end}}></script>
+<script nonce="{{.Nonce}}">
+SwaggerUIBundle({url: {{.Spec}}, dom_id: "#docs", deepLinking: true, withCredentials: true});
+</script>
+</body>
+</html>
+`))
+
+func (d *docs) serveUI(w http.ResponseWriter) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	encoded := base64.StdEncoding.EncodeToString(nonce)
+	// the broker serves the files of a directory, the ones of a url are pinned by
+	// their integrity
+	ui, source, integrity := d.path+"/assets", "'self'", DocsIntegrity{}
+	if remoteAssets(d.assets) {
+		parsed, _ := url.Parse(d.assets)
+		ui, source, integrity = d.assets, parsed.Scheme+"://"+parsed.Host, d.integrity
+	}
+	// set before the default policy of the broker, which renders nothing
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(
+		"default-src 'none'; script-src 'nonce-%s' %s; style-src 'unsafe-inline' %s; "+
+			"img-src 'self' data: %s; connect-src 'self'; frame-ancestors 'none'", encoded, source, source, source))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	uiTemplate.Execute(w, map[string]string{
+		"Title": d.title, "UI": ui, "Nonce": encoded, "Spec": d.path + openapi.Path,
+		"ScriptIntegrity": integrity.Script, "StyleIntegrity": integrity.Style,
+	})
+}
+
+// spec is the merged spec, cached for cacheFor
+func (d *docs) spec(ctx context.Context) map[string]any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if d.merged != nil && now.Before(d.expires) {
+		return d.merged
+	}
+	merged, complete := d.merge(ctx)
+	d.merged = merged
+	d.expires = now.Add(d.cacheFor)
+	if !complete {
+		d.expires = now.Add(min(d.cacheFor, partialDocsCacheFor))
+	}
+	return merged
+}
+
+type fetched struct {
+	spec map[string]any
+	err  error
+}
+
+// merge puts the paths of the servers under their prefixes and their components under
+// their names, e.g. #/components/schemas/auth.LoginPayload
+func (d *docs) merge(ctx context.Context) (map[string]any, bool) {
+	ctx, cancel := context.WithTimeout(ctx, docsTimeout)
+	defer cancel()
+	results := make([]fetched, len(d.upstreams))
+	var wg sync.WaitGroup
+	for i, u := range d.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].spec, results[i].err = u.fetchSpec(ctx)
+		}()
+	}
+	wg.Wait()
+
+	paths := map[string]any{}
+	components := map[string]map[string]any{"schemas": {}, "responses": {}, "securitySchemes": {}}
+	tags := map[string]string{}
+	var unavailable []string
+	for i, result := range results {
+		u := d.upstreams[i]
+		if result.err != nil {
+			logger.Warn().Err(result.err).Str("server", u.name).Msg("failed to fetch openapi spec")
+			unavailable = append(unavailable, u.name)
+			continue
+		}
+		spec := renameRefs(result.spec, u.name).(map[string]any)
+		if specComponents, ok := spec["components"].(map[string]any); ok {
+			for kind, merged := range components {
+				entries, _ := specComponents[kind].(map[string]any)
+				for name, entry := range entries {
+					// the security schemes are referenced by their names, shared by the servers
+					if kind != "securitySchemes" {
+						name = u.name + "." + name
+					}
+					merged[name] = entry
+				}
+			}
+		}
+		prefix := strings.TrimSuffix(u.prefix, "/")
+		specPaths, _ := spec["paths"].(map[string]any)
+		for path, item := range specPaths {
+			operations, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, operation := range operations {
+				operation, ok := operation.(map[string]any)
+				if !ok {
+					continue
+				}
+				if id, ok := operation["operationId"].(string); ok && id != "" {
+					operation["operationId"] = u.name + strings.ToUpper(id[:1]) + id[1:]
+				}
+				// the tags are grouped by server, every server has its health
+				operationTags, _ := operation["tags"].([]any)
+				for j, tag := range operationTags {
+					name := fmt.Sprintf("%s/%v", u.name, tag)
+					operationTags[j] = name
+					tags[name] = fmt.Sprintf("The %v routes of %s", tag, u.name)
+				}
+			}
+			paths[strings.TrimSuffix(prefix+path, "/")] = operations
+		}
+	}
+
+	var tagList []map[string]any
+	for name, description := range tags {
+		tagList = append(tagList, map[string]any{"name": name, "description": description})
+	}
+	sort.Slice(tagList, func(i, j int) bool { return tagList[i]["name"].(string) < tagList[j]["name"].(string) })
+	description := "The routes of the servers behind the broker, the errors of each server are in its Error response."
+	if len(unavailable) > 0 {
+		description += " Unavailable, their routes are missing: " + strings.Join(unavailable, ", ") + "."
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title": d.title, "version": "v1", "description": description,
+		},
+		"servers":    []any{map[string]any{"url": "/"}},
+		"tags":       tagList,
+		"paths":      paths,
+		"components": components,
+	}, len(unavailable) == 0
+}
+
+// excluded is the spec without the excluded routes, the components stay
+func (d *docs) excluded(spec map[string]any) map[string]any {
+	if len(d.exclude) == 0 {
+		return spec
+	}
+	paths := map[string]any{}
+	for path, item := range spec["paths"].(map[string]any) {
+		if !slices.ContainsFunc(d.exclude, func(pattern string) bool { return matchRoute(pattern, path) }) {
+			paths[path] = item
+		}
+	}
+	copied := map[string]any{}
+	for key, value := range spec {
+		copied[key] = value
+	}
+	copied["paths"] = paths
+	return copied
+}
+
+// fetchSpec reads the spec of an available instance
+func (u *upstream) fetchSpec(ctx context.Context) (map[string]any, error) {
+	inst := u.pick(nil)
+	if inst == nil {
+		return nil, errNoInstance
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url.String()+strings.TrimSuffix(u.prefix, "/")+openapi.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var spec map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// renameRefs points the $refs of the spec of a server at its renamed components
+func renameRefs(value any, server string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				if kind, name, ok := strings.Cut(strings.TrimPrefix(ref, "#/components/"), "/"); ok {
+					value[key] = "#/components/" + kind + "/" + server + "." + name
+				}
+				continue
+			}
+			value[key] = renameRefs(child, server)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = renameRefs(child, server)
+		}
+	}
+	return value
+}
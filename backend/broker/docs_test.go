@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocsConfigPinsTheRemoteAssets(t *testing.T) {
+	conf := DocsConfig{Path: "/api/docs", Assets: "https://unpkg.com/swagger-ui-dist@5.17.14"}
+	if err := conf.validate(); err == nil {
+		t.Fatal("assets of a url without the integrity are valid")
+	}
+	conf.Integrity = DocsIntegrity{Script: "sha384-script", Style: "sha384-style"}
+	if err := conf.validate(); err != nil {
+		t.Fatal(err)
+	}
+	conf.Assets = "http://unpkg.com/swagger-ui-dist@5.17.14"
+	if err := conf.validate(); err == nil {
+		t.Fatal("assets of an http url are valid")
+	}
+}
+
+func TestDocsServeTheUI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "swagger-ui-bundle.js"), []byte("bundle"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDocs(DocsConfig{Path: "/api/docs", Assets: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(target string) (*httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		return w, d.handle(w, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	w, ok := serve("/api/docs")
+	if csp := w.Header().Get("Content-Security-Policy"); !ok || strings.Contains(csp, "https://") || !strings.Contains(w.Body.String(), `src="/api/docs/assets/swagger-ui-bundle.js"`) {
+		t.Fatalf("ui = %s, csp %s", w.Body, csp)
+	}
+	if w, ok := serve("/api/docs/assets/swagger-ui-bundle.js"); !ok || w.Body.String() != "bundle" {
+		t.Fatalf("bundle = %q", w.Body)
+	}
+	if _, ok := serve("/api/docs/assets/secret.txt"); ok {
+		t.Fatal("a file other than the assets was served")
+	}
+
+	d.assets, d.integrity = "https://unpkg.com/swagger-ui-dist@5.17.14", DocsIntegrity{Script: "sha384-script", Style: "sha384-style"}
+	w, _ = serve("/api/docs")
+	if !strings.Contains(w.Body.String(), `integrity="sha384-script"`) || !strings.Contains(w.Body.String(), `integrity="sha384-style"`) {
+		t.Fatalf("ui = %s, want the assets pinned", w.Body)
+	}
+	if _, ok := serve("/api/docs/assets/swagger-ui-bundle.js"); ok {
+		t.Fatal("the assets of a url were served")
+	}
+}
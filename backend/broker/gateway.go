@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+)
+
+var errNoAccessToken = errors.New("access token not found")
+
+type identityKey struct{}
+
+// gateway authenticates the requests once at the edge, the services get the
+// identity of the verified access token in the signed identity headers
+type gateway struct {
+	tokens *identity.TokenVerifier
+	signer *identity.Signer
+}
+
+func newGateway(jwtSecret string, identitySecret string) *gateway {
+	return &gateway{
+		tokens: identity.NewTokenVerifier(jwtSecret),
+		signer: identity.NewSigner(identitySecret),
+	}
+}
+
+func (g *gateway) authenticate(r *http.Request) (identity.Identity, error) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if accessToken == "" {
+		if cookie, err := r.Cookie("access_token"); err == nil {
+			accessToken = cookie.Value
+		}
+	}
+	if accessToken == "" {
+		return identity.Identity{}, errNoAccessToken
+	}
+	return g.tokens.Verify(accessToken)
+}
+
+// forward sets the signed identity headers on the request to the service
+func (g *gateway) forward(r *http.Request, verified identity.Identity) error {
+	encoded, signature, err := g.signer.Sign(verified, time.Now())
+	if err != nil {
+		return err
+	}
+	r.Header.Set(identity.Header, encoded)
+	r.Header.Set(identity.SignatureHeader, signature)
+	return nil
+}
+
+// protect strips the identity headers the clients sent, the protected routes need a
+// valid access token and the public ones get the identity only when there is one
+func (g *gateway) protect(rt route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity.Strip(r.Header)
+		verified, err := g.authenticate(r)
+		if err != nil {
+			if !rt.public(routePath(r)) {
+				logger.Ctx(r.Context()).Info().Err(err).Str("path", r.URL.Path).Msg("unauthorized request")
+				respondError(w, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := g.forward(r, verified); err != nil {
+			logger.Ctx(r.Context()).Error().Err(err).Msg("failed to sign identity")
+			respondError(w, http.StatusInternalServerError)
+			return
+		}
+		ctx := logger.WithContext(r.Context(), map[string]any{
+			"user_id": verified.UserID, "business_id": verified.BusinessID,
+		})
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, identityKey{}, verified)))
+	})
+}
+
+// identityFromContext is the identity the gateway verified for the request, if any
+func identityFromContext(ctx context.Context) (identity.Identity, bool) {
+	verified, ok := ctx.Value(identityKey{}).(identity.Identity)
+	return verified, ok
+}
+
+// respondError responds the way the services do
+func respondError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": http.StatusText(status),
+		"data":    nil,
+		"error": map[string]any{
+			"code":    status,
+			"message": http.StatusText(status),
+		},
+	})
+}
+
+// matchRoute matches the path against a pattern of the config, a :param matches a
+// single segment and a trailing * everything after it
+func matchRoute(pattern string, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") && pathSegments[i] != "" {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(pathSegments) == len(patternSegments)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SecurityHeadersConfig are the headers added to every response, the ones a server
+// sets itself win. hsts only goes over tls
+type SecurityHeadersConfig struct {
+	Hsts                  HstsConfig `yaml:"hsts"`
+	ContentSecurityPolicy string     `yaml:"content_security_policy"`
+	FrameOptions          string     `yaml:"frame_options"`
+	ReferrerPolicy        string     `yaml:"referrer_policy"`
+}
+
+// HstsConfig is disabled without a max_age
+type HstsConfig struct {
+	MaxAge            string `yaml:"max_age"`
+	IncludeSubdomains bool   `yaml:"include_subdomains"`
+	Preload           bool   `yaml:"preload"`
+}
+
+type securityHeaders struct {
+	headers http.Header
+	hsts    string
+}
+
+func newSecurityHeaders(conf SecurityHeadersConfig) (*securityHeaders, error) {
+	s := &securityHeaders{headers: http.Header{}}
+	s.headers.Set("X-Content-Type-Options", "nosniff")
+	// the services only answer json, nothing of theirs is to be rendered or framed
+	csp := conf.ContentSecurityPolicy
+	if csp == "" {
+		csp = "default-src 'none'; frame-ancestors 'none'"
+	}
+	s.headers.Set("Content-Security-Policy", csp)
+	frameOptions := conf.FrameOptions
+	if frameOptions == "" {
+		frameOptions = "DENY"
+	}
+	s.headers.Set("X-Frame-Options", frameOptions)
+	referrerPolicy := conf.ReferrerPolicy
+	if referrerPolicy == "" {
+		referrerPolicy = "no-referrer"
+	}
+	s.headers.Set("Referrer-Policy", referrerPolicy)
+
+	maxAge, err := parseDuration(conf.Hsts.MaxAge, 0)
+	if err != nil || maxAge < 0 {
+		return nil, fmt.Errorf("invalid hsts max_age %q", conf.Hsts.MaxAge)
+	}
+	if maxAge > 0 {
+		s.hsts = "max-age=" + strconv.Itoa(int(maxAge/time.Second))
+		if conf.Hsts.IncludeSubdomains {
+			s.hsts += "; includeSubDomains"
+		}
+		if conf.Hsts.Preload {
+			s.hsts += "; preload"
+		}
+	}
+	return s, nil
+}
+
+// apply adds the headers missing from the response
+func (s *securityHeaders) apply(header http.Header, tls bool) {
+	for key, values := range s.headers {
+		if header.Get(key) == "" {
+			header[key] = values
+		}
+	}
+	if tls && s.hsts != "" && header.Get("Strict-Transport-Security") == "" {
+		header.Set("Strict-Transport-Security", s.hsts)
+	}
+}
@@ -1,39 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/common-nighthawk/go-figure"
-	"gopkg.in/yaml.v3"
 )
 
-type Config struct {
-	Host    string `yaml:"host"`
-	Port    int    `yaml:"port"`
-	Servers []struct {
-		Name   string `yaml:"name"`
-		Host   string `yaml:"host"`
-		Port   int    `yaml:"port"`
-		Prefix string `yaml:"prefix"`
-	} `yaml:"servers"`
-}
-
-func newProxy(target *url.URL) *httputil.ReverseProxy {
-	return &httputil.ReverseProxy{
-		Director: func(req *http.Request) {
-			req.URL.Scheme = target.Scheme
-			req.URL.Host = target.Host
-			req.Host = target.Host
-			// Path and query untouched
-		},
-	}
-}
+const configPath = "config.yml"
 
 func normalizePrefix(p string) string {
 	p = "/" + strings.Trim(p, "/")
@@ -43,58 +26,117 @@ func normalizePrefix(p string) string {
 func main() {
 
 	figure.NewColorFigure("Broker Service", "", "blue", true).Print()
-	fmt.Println("\n")
+	fmt.Print("\n\n")
 
-	configContent, err := os.ReadFile("config.yml")
+	config, err := loadConfig(configPath)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("failed to read config file")
+		logger.Fatal().Err(err).Msg("invalid config")
 	}
-
-	var config Config
-	if err := yaml.Unmarshal(configContent, &config); err != nil {
-		logger.Fatal().Err(err).Msg("failed to unmarshal config file")
+	if err := logger.Configure(config.Log.Level, config.Log.Levels); err != nil {
+		logger.Fatal().Err(err).Msg("invalid config")
+	}
+	shutdownTracing, err := tracing.Init(context.Background(), "broker", config.Tracing)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to init tracing")
 	}
+	watch, _ := parseDuration(config.Watch, 0)
+	shutdownTimeout, _ := parseDuration(config.ShutdownTimeout, 30*time.Second)
 
-	type route struct {
-		prefix string
-		proxy  *httputil.ReverseProxy
+	broker, err := newBroker(configPath, config)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid config")
 	}
 
-	var routes []route
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	for _, server := range config.Servers {
-		target, err := url.Parse(
-			fmt.Sprintf("http://%s:%d", server.Host, server.Port),
-		)
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Handler: broker,
+	}
+	var redirect *http.Server
+	if config.TLS.enabled() {
+		tlsConfig, redirectHandler, err := serverTLS(config.TLS, config.Port)
 		if err != nil {
-			logger.Fatal().Err(err).Msg("invalid target url")
+			logger.Fatal().Err(err).Msg("invalid tls")
 		}
+		server.TLSConfig = tlsConfig
+		if config.TLS.RedirectPort != 0 {
+			redirect = &http.Server{
+				Addr:    fmt.Sprintf("%s:%d", config.Host, config.TLS.RedirectPort),
+				Handler: redirectHandler,
+			}
+			go func() {
+				logger.Info().Msgf("redirecting to https from %s:%d", config.Host, config.TLS.RedirectPort)
+				if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Fatal().Err(err).Msg("failed to start redirect")
+				}
+			}()
+		}
+	}
+	go func() {
+		logger.Info().Bool("tls", server.TLSConfig != nil).Msgf("broker started on %s:%d", config.Host, config.Port)
+		serve := server.ListenAndServe
+		if server.TLSConfig != nil {
+			// the certificates come from the tls config
+			serve = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal().Err(err).Msg("failed to start broker")
+		}
+	}()
 
-		prefix := normalizePrefix(server.Prefix)
-
-		routes = append(routes, route{
-			prefix: prefix,
-			proxy:  newProxy(target),
-		})
-
-		logger.Info().
-			Msgf("proxy %s → %s%s", server.Name, target, prefix)
+	var admin *http.Server
+	if config.Admin.Port != 0 {
+		admin = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", config.Admin.Host, config.Admin.Port),
+			Handler: broker.adminHandler(config.Admin.ApiKey),
+		}
+		go func() {
+			logger.Info().Msgf("admin started on %s:%d", config.Admin.Host, config.Admin.Port)
+			if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Fatal().Err(err).Msg("failed to start admin")
+			}
+		}()
 	}
 
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, rt := range routes {
-			if strings.HasPrefix(r.URL.Path, rt.prefix) {
-				rt.proxy.ServeHTTP(w, r)
-				return
+	// SIGHUP reloads the config, so does a change of the file when it is watched
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := broker.reload(); err != nil {
+				logger.Error().Err(err).Msg("failed to reload config, keeping the current one")
 			}
 		}
-		http.NotFound(w, r)
-	})
-
-	logger.Info().
-		Msgf("broker started on %s:%d", config.Host, config.Port)
+	}()
+	if watch > 0 {
+		go broker.watch(ctx, watch)
+	}
 
-	logger.Fatal().
-		Err(http.ListenAndServe(fmt.Sprintf(":%d", config.Port), handler)).
-		Msg("failed to start broker")
+	<-ctx.Done()
+	logger.Info().Msg("shutting down, draining the connections")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if admin != nil {
+		admin.Shutdown(shutdownCtx)
+	}
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// the event streams outlive any timeout
+		logger.Warn().Err(err).Msg("connections still open, closing them")
+		server.Close()
+	}
+	broker.router.Load().close()
+	if broker.events != nil {
+		if err := broker.events.Close(shutdownCtx); err != nil {
+			logger.Error().Err(err).Msg("failed to close event manager")
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("failed to shutdown tracing")
+	}
+	logger.Info().Msg("broker stopped")
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+const scrapeTimeout = 5 * time.Second
+
+var (
+	requestsDesc = prometheus.NewDesc("broker_requests_total",
+		"Requests proxied, by server and status class.", []string{"server", "class"}, nil)
+	retriesDesc = prometheus.NewDesc("broker_retries_total",
+		"Requests retried on another instance, by server.", []string{"server"}, nil)
+	ejectionsDesc = prometheus.NewDesc("broker_ejections_total",
+		"Instances ejected for failing in a row, by server.", []string{"server"}, nil)
+	cacheDesc = prometheus.NewDesc("broker_cache_requests_total",
+		"Requests of the cached routes, by server and result.", []string{"server", "result"}, nil)
+	reloadsDesc = prometheus.NewDesc("broker_reloads_total",
+		"Configs reloaded, by result.", []string{"result"}, nil)
+	availableDesc = prometheus.NewDesc("broker_instance_available",
+		"1 when the instance is healthy and not ejected.", []string{"server", "instance"}, nil)
+	activeDesc = prometheus.NewDesc("broker_instance_active_requests",
+		"Requests in flight on the instance.", []string{"server", "instance"}, nil)
+	circuitOpenDesc = prometheus.NewDesc("broker_circuit_open",
+		"1 when the circuit of the server is open or half open.", []string{"server"}, nil)
+)
+
+// brokerCollector reports the counters and the state of the upstreams of the admin in the
+// prometheus format, read on every scrape so they do not need to be kept twice
+type brokerCollector struct {
+	b *broker
+}
+
+func (c brokerCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{requestsDesc, retriesDesc, ejectionsDesc, cacheDesc, reloadsDesc, availableDesc, activeDesc, circuitOpenDesc} {
+		ch <- desc
+	}
+}
+
+func (c brokerCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.b.counters.snapshot()
+	for name, server := range snapshot.Servers {
+		for class, count := range server.Statuses {
+			ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(count), name, class)
+		}
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(server.Retries), name)
+		ch <- prometheus.MustNewConstMetric(ejectionsDesc, prometheus.CounterValue, float64(server.Ejections), name)
+		ch <- prometheus.MustNewConstMetric(cacheDesc, prometheus.CounterValue, float64(server.CacheHits), name, "hit")
+		ch <- prometheus.MustNewConstMetric(cacheDesc, prometheus.CounterValue, float64(server.CacheMisses), name, "miss")
+	}
+	ch <- prometheus.MustNewConstMetric(reloadsDesc, prometheus.CounterValue, float64(snapshot.Reloads), "ok")
+	ch <- prometheus.MustNewConstMetric(reloadsDesc, prometheus.CounterValue, float64(snapshot.ReloadFailures), "error")
+
+	now := time.Now()
+	for _, u := range c.b.router.Load().upstreams {
+		status := u.status(now)
+		circuitOpen := 0.0
+		if status.Circuit != "closed" {
+			circuitOpen = 1
+		}
+		ch <- prometheus.MustNewConstMetric(circuitOpenDesc, prometheus.GaugeValue, circuitOpen, status.Name)
+		for _, inst := range status.Instances {
+			available := 0.0
+			if inst.Healthy && !inst.Ejected {
+				available = 1
+			}
+			ch <- prometheus.MustNewConstMetric(availableDesc, prometheus.GaugeValue, available, status.Name, inst.Url)
+			ch <- prometheus.MustNewConstMetric(activeDesc, prometheus.GaugeValue, float64(inst.Active), status.Name, inst.Url)
+		}
+	}
+}
+
+// metricsHandler serves the metrics of the broker along with the ones of every instance of
+// the upstreams, scraped on the fly and labelled with their server and instance
+func (b *broker) metricsHandler() http.Handler {
+	gatherers := prometheus.Gatherers{metrics.Registry, upstreamGatherer{b: b}}
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{
+		// an instance failing to be scraped must not hide the others
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+type upstreamGatherer struct {
+	b *broker
+}
+
+type scraped struct {
+	families []*dto.MetricFamily
+	up       *dto.Metric
+}
+
+func (g upstreamGatherer) Gather() ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	var results []scraped
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, u := range g.b.router.Load().upstreams {
+		for _, inst := range u.instances {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				labels := []*dto.LabelPair{
+					{Name: proto.String("instance"), Value: proto.String(inst.url.String())},
+					{Name: proto.String("server"), Value: proto.String(u.name)},
+				}
+				families, err := scrape(ctx, u.transport, inst.url.String()+"/metrics", labels)
+				up := 1.0
+				if err != nil {
+					logger.Warn().Err(err).Str("server", u.name).Str("instance", inst.url.Host).Msg("failed to scrape instance")
+					up = 0
+				}
+				mu.Lock()
+				results = append(results, scraped{
+					families: families,
+					up:       &dto.Metric{Label: labels, Gauge: &dto.Gauge{Value: proto.Float64(up)}},
+				})
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+
+	up := &dto.MetricFamily{
+		Name: proto.String("broker_scrape_up"),
+		Help: proto.String("1 when the metrics of the instance could be scraped."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	families := []*dto.MetricFamily{up}
+	for _, result := range results {
+		families = append(families, result.families...)
+		up.Metric = append(up.Metric, result.up)
+	}
+	return families, nil
+}
+
+// scrape reads the metrics of an instance and adds the labels to all of them
+func scrape(ctx context.Context, transport http.RoundTripper, url string, labels []*dto.LabelPair) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeProtoDelim)))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var families []*dto.MetricFamily
+	decoder := expfmt.NewDecoder(resp.Body, expfmt.ResponseFormat(resp.Header))
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); err != nil {
+			if errors.Is(err, io.EOF) {
+				return families, nil
+			}
+			return nil, err
+		}
+		for _, metric := range family.Metric {
+			// the labels of the instance win, as the ones of the targets do in prometheus
+			for _, label := range metric.Label {
+				for _, added := range labels {
+					if label.GetName() == added.GetName() {
+						label.Name = proto.String("exported_" + label.GetName())
+					}
+				}
+			}
+			metric.Label = append(metric.Label, labels...)
+		}
+		families = append(families, family)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxLimitedBody bounds how much of the body is read for the body keys
+const maxLimitedBody = 1 << 20
+
+// errBodyTooLarge is a body over maxLimitedBody, it is rejected rather than forwarded cut
+var errBodyTooLarge = errors.New("request body too large")
+
+// errUnkeyedBody is a body of a type the key can not be read from, it is rejected rather
+// than limited by the ip, the services may still parse it
+var errUnkeyedBody = errors.New("request body can not be keyed")
+
+type RateLimitConfig struct {
+	// Backend is memory, buckets per replica, or redis, buckets shared by the replicas
+	Backend  string `yaml:"backend"`
+	RedisUrl string `yaml:"redis_url"`
+	// TrustProxy takes the client ip from the entry of X-Forwarded-For the load balancer
+	// appended, the rightmost, only for a broker behind one
+	TrustProxy bool            `yaml:"trust_proxy"`
+	Rules      []RateLimitRule `yaml:"rules"`
+}
+
+type RateLimitRule struct {
+	// Prefix is the prefix of the paths the rule limits
+	Prefix string `yaml:"prefix"`
+	// Key is ip, user or body.<field>, the user falls back to the ip for the anonymous
+	// requests and the field to the ip when the body does not have it
+	Key string `yaml:"key"`
+	// Rate is the requests allowed every Period, Burst the ones allowed at once
+	Rate   int    `yaml:"rate"`
+	Period string `yaml:"period"`
+	Burst  int    `yaml:"burst"`
+}
+
+// bucket is a token bucket, refilled with rate tokens per second up to burst
+type bucket struct {
+	rate  float64
+	burst float64
+}
+
+// limitStore keeps the buckets, take takes a token from the bucket of the key and
+// tells how long until there is one when there is none
+type limitStore interface {
+	take(ctx context.Context, key string, b bucket) (bool, time.Duration, error)
+	close() error
+}
+
+type rateLimitRule struct {
+	prefix string
+	key    string
+	bucket bucket
+}
+
+type rateLimiter struct {
+	store      limitStore
+	rules      []rateLimitRule
+	trustProxy bool
+}
+
+// newRateLimiter keeps the memory buckets of the previous limiter, a reload
+// must not refill them
+func newRateLimiter(config RateLimitConfig, previous *rateLimiter) (*rateLimiter, error) {
+	limiter := &rateLimiter{trustProxy: config.TrustProxy}
+	switch config.Backend {
+	case "", "memory":
+		if previous != nil {
+			if store, ok := previous.store.(*memoryStore); ok {
+				limiter.store = store
+				break
+			}
+		}
+		limiter.store = newMemoryStore()
+	case "redis":
+		opts, err := redis.ParseURL(config.RedisUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis url: %w", err)
+		}
+		limiter.store = newRedisStore(redis.NewClient(opts))
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", config.Backend)
+	}
+	for _, rule := range config.Rules {
+		period, err := time.ParseDuration(rule.Period)
+		if err != nil || period <= 0 || rule.Rate <= 0 {
+			return nil, fmt.Errorf("invalid rate limit of %s", rule.Prefix)
+		}
+		if rule.Key != "ip" && rule.Key != "user" && !strings.HasPrefix(rule.Key, "body.") {
+			return nil, fmt.Errorf("invalid rate limit key %q of %s", rule.Key, rule.Prefix)
+		}
+		burst := rule.Burst
+		if burst <= 0 {
+			burst = rule.Rate
+		}
+		limiter.rules = append(limiter.rules, rateLimitRule{
+			prefix: rule.Prefix,
+			key:    rule.Key,
+			bucket: bucket{
+				rate:  float64(rule.Rate) / period.Seconds(),
+				burst: float64(burst),
+			},
+		})
+	}
+	return limiter, nil
+}
+
+// limit applies every rule matching the path, the request is rejected with 429 when
+// any of the buckets is empty. the limits fail open when the backend is down.
+func (l *rateLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range l.rules {
+			if !strings.HasPrefix(routePath(r), rule.prefix) {
+				continue
+			}
+			key, err := l.key(r, rule)
+			if errors.Is(err, errBodyTooLarge) {
+				respondError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if errors.Is(err, errUnkeyedBody) {
+				respondError(w, http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				logger.Ctx(r.Context()).Error().Err(err).Msg("failed to read request body")
+				respondError(w, http.StatusBadRequest)
+				return
+			}
+			ok, wait, err := l.store.take(r.Context(), key, rule.bucket)
+			if err != nil {
+				logger.Ctx(r.Context()).Error().Err(err).Str("prefix", rule.prefix).Msg("failed to take rate limit token")
+				continue
+			}
+			if !ok {
+				logger.Ctx(r.Context()).Info().Str("prefix", rule.prefix).Str("key", rule.key).Msg("rate limited request")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				respondError(w, http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// key is the bucket of the request for the rule, the values are hashed to keep
+// the emails and the phones out of the backend
+func (l *rateLimiter) key(r *http.Request, rule rateLimitRule) (string, error) {
+	kind, value := "ip", l.clientIP(r)
+	switch {
+	case rule.key == "user":
+		if verified, ok := identityFromContext(r.Context()); ok {
+			kind, value = "user", verified.UserID
+		}
+	case strings.HasPrefix(rule.key, "body."):
+		field, err := bodyField(r, strings.TrimPrefix(rule.key, "body."))
+		if err != nil {
+			return "", err
+		}
+		if field != "" {
+			kind, value = "body", strings.ToLower(strings.TrimSpace(field))
+		}
+	}
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("ratelimit:%s:%s:%s:%s", rule.prefix, rule.key, kind, hex.EncodeToString(sum[:])), nil
+}
+
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		// the entries before are the ones the client sent
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bodyField reads the string field of the body of every type the services parse, the
+// json ones, the forms and the multipart forms. the body is put back for the service
+func bodyField(r *http.Request, field string) (string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLimitedBody+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxLimitedBody {
+		return "", errBodyTooLarge
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		return "", nil
+	}
+	// the services take the vendor types and text/json as json
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		return jsonField(body, field), nil
+	case mediaType == "application/x-www-form-urlencoded":
+		return formField(string(body), field), nil
+	case mediaType == "multipart/form-data":
+		return multipartField(body, params["boundary"], field)
+	}
+	return "", errUnkeyedBody
+}
+
+// jsonField decodes the field into a struct like the services do, the keys match case
+// insensitively and the last one wins
+func jsonField(body []byte, field string) string {
+	value := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: reflect.TypeFor[string](),
+		Tag:  reflect.StructTag(fmt.Sprintf(`json:%q`, field)),
+	}}))
+	if err := json.Unmarshal(body, value.Interface()); err != nil {
+		return ""
+	}
+	return value.Elem().Field(0).String()
+}
+
+// formField is the last value of the field in the form, matched case insensitively like
+// the services decode the forms
+func formField(body string, field string) string {
+	value := ""
+	for _, pair := range strings.Split(body, "&") {
+		key, v, _ := strings.Cut(pair, "=")
+		if key, err := url.QueryUnescape(key); err != nil || !strings.EqualFold(key, field) {
+			continue
+		}
+		if v, err := url.QueryUnescape(v); err == nil {
+			value = v
+		}
+	}
+	return value
+}
+
+// multipartField is the last value of the field in the multipart form, the files are skipped
+func multipartField(body []byte, boundary string, field string) (string, error) {
+	if boundary == "" {
+		return "", errUnkeyedBody
+	}
+	value := ""
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return value, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if part.FileName() != "" || !strings.EqualFold(part.FormName(), field) {
+			continue
+		}
+		v, err := io.ReadAll(part)
+		if err != nil {
+			return "", err
+		}
+		value = string(v)
+	}
+}
+
+type memoryBucket struct {
+	bucket
+	tokens float64
+	last   time.Time
+}
+
+// close releases the connections of the backend, the limiter is not used after
+func (l *rateLimiter) close() error {
+	return l.store.close()
+}
+
+// memoryStore keeps the buckets of a single replica
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: map[string]*memoryBucket{}, swept: time.Now()}
+}
+
+func (s *memoryStore) take(ctx context.Context, key string, b bucket) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) > time.Minute {
+		s.sweep(now)
+	}
+	stored, ok := s.buckets[key]
+	if !ok {
+		stored = &memoryBucket{bucket: b, tokens: b.burst, last: now}
+		s.buckets[key] = stored
+	}
+	stored.tokens = math.Min(b.burst, stored.tokens+now.Sub(stored.last).Seconds()*b.rate)
+	stored.last = now
+	if stored.tokens < 1 {
+		return false, time.Duration((1 - stored.tokens) / b.rate * float64(time.Second)), nil
+	}
+	stored.tokens--
+	return true, 0, nil
+}
+
+// sweep drops the buckets refilled by now, an unknown key starts with a full bucket anyway
+func (s *memoryStore) sweep(now time.Time) {
+	for key, stored := range s.buckets {
+		if stored.tokens+now.Sub(stored.last).Seconds()*stored.rate >= stored.burst {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
+
+// close keeps the buckets, they move to the limiter of the reloaded config
+func (s *memoryStore) close() error {
+	return nil
+}
+
+// takeScript refills and takes from the bucket atomically, with the clock of redis
+// so the replicas agree on it. returns 1 or 0 and the wait in milliseconds.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local stored = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(stored[1]) or burst
+local last = tonumber(stored[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate / 1000)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, wait}
+`)
+
+// redisStore keeps the buckets in redis, shared by all the replicas of the broker
+type redisStore struct {
+	client *redis.Client
+}
+
+func newRedisStore(client *redis.Client) *redisStore {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) close() error {
+	return s.client.Close()
+}
+
+func (s *redisStore) take(ctx context.Context, key string, b bucket) (bool, time.Duration, error) {
+	result, err := takeScript.Run(ctx, s.client, []string{key}, b.rate, b.burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
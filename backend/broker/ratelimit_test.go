@@ -0,0 +1,101 @@
+package main
+
+import (
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestLimiter(t *testing.T, key string) http.Handler {
+	t.Helper()
+	limiter, err := newRateLimiter(RateLimitConfig{Rules: []RateLimitRule{
+		{Prefix: "/api/v1/auth-srv/auth/", Key: key, Rate: 1, Period: "1m"},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func TestLimitIgnoresTheCaseOfThePath(t *testing.T) {
+	handler := newTestLimiter(t, "ip")
+	for i, target := range []string{"/api/v1/auth-srv/auth/login", "/API/V1/AUTH-SRV/AUTH/LOGIN"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+			t.Fatalf("%s: status = %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func TestLimitKeysTheBodyFieldLikeTheServices(t *testing.T) {
+	handler := newTestLimiter(t, "body.email")
+	for i, body := range []string{`{"email":"a@example.com"}`, `{"EMAIL":"a@example.com"}`} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth-srv/auth/login", strings.NewReader(body))
+		r.Header.Set("Content-Type", "Application/JSON; charset=utf-8")
+		// from another ip, only the body key is the same
+		r.RemoteAddr = []string{"10.0.0.1:1234", "10.0.0.2:1234"}[i]
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+			t.Fatalf("%s: status = %d, want %d", body, w.Code, want)
+		}
+	}
+}
+
+func TestLimitRejectsTheLargeBodies(t *testing.T) {
+	handler := newTestLimiter(t, "body.email")
+	body := `{"email":"a@example.com","name":"` + strings.Repeat("a", maxLimitedBody) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth-srv/auth/login", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestLimitKeysEveryBodyTheServicesParse(t *testing.T) {
+	var multipartBody strings.Builder
+	form := multipart.NewWriter(&multipartBody)
+	form.WriteField("Email", "a@example.com")
+	form.Close()
+	for _, body := range []struct{ contentType, body string }{
+		{"application/x-www-form-urlencoded", "email=a%40example.com&password=secret"},
+		{"application/vnd.x+json", `{"email":"a@example.com"}`},
+		{"text/json", `{"Email":"a@example.com"}`},
+		{form.FormDataContentType(), multipartBody.String()},
+	} {
+		handler := newTestLimiter(t, "body.email")
+		for i, ip := range []string{"10.0.0.1:1234", "10.0.0.2:1234"} {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/auth-srv/auth/login", strings.NewReader(body.body))
+			r.Header.Set("Content-Type", body.contentType)
+			r.RemoteAddr = ip
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if want := []int{http.StatusOK, http.StatusTooManyRequests}[i]; w.Code != want {
+				t.Fatalf("%s: status = %d, want %d", body.contentType, w.Code, want)
+			}
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth-srv/auth/login", strings.NewReader(`<LoginPayload><Email>a@example.com</Email></LoginPayload>`))
+	r.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	newTestLimiter(t, "body.email").ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("xml: status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestClientIPIsTheOneTheProxyAppended(t *testing.T) {
+	limiter := &rateLimiter{trustProxy: true}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth-srv/auth/login", nil)
+	r.Header.Add("X-Forwarded-For", "1.2.3.4")
+	r.Header.Add("X-Forwarded-For", "5.6.7.8, 203.0.113.7")
+	if got := limiter.clientIP(r); got != "203.0.113.7" {
+		t.Fatalf("clientIP = %q, want the entry of the proxy", got)
+	}
+}
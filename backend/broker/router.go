@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+)
+
+type route struct {
+	name         string
+	prefix       string
+	publicRoutes []string
+	deniedRoutes []string
+	proxy        http.Handler
+}
+
+func (rt route) public(path string) bool {
+	return rt.matches(rt.publicRoutes, path)
+}
+
+// denied tells whether the path is one the broker leaves unrouted
+func (rt route) denied(path string) bool {
+	return rt.matches(rt.deniedRoutes, path)
+}
+
+func (rt route) matches(patterns []string, path string) bool {
+	path = strings.TrimPrefix(path, strings.TrimSuffix(rt.prefix, "/"))
+	for _, pattern := range patterns {
+		if matchRoute(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath tells whether the path is clean, the services would route the dot
+// segments, the doubled and the encoded slashes to what the rules of the broker miss
+func canonicalPath(u *url.URL) bool {
+	if !strings.HasPrefix(u.Path, "/") || strings.Contains(u.Path, "\\") {
+		return false
+	}
+	if raw := strings.ToLower(u.RawPath); strings.Contains(raw, "%2f") || strings.Contains(raw, "%5c") {
+		return false
+	}
+	clean := path.Clean(u.Path)
+	if clean != "/" && strings.HasSuffix(u.Path, "/") {
+		clean += "/"
+	}
+	return clean == u.Path
+}
+
+// routePath is the path the routes and the rules match, the services route the paths
+// case insensitively
+func routePath(r *http.Request) string {
+	return strings.ToLower(r.URL.Path)
+}
+
+// router is the routing table of a config, a reload builds a new one and swaps it in
+type router struct {
+	config    Config
+	routes    []route
+	upstreams []*upstream
+	limiter   *rateLimiter
+	cors      *cors
+	headers   *securityHeaders
+	docs      *docs
+	cancel    context.CancelFunc
+}
+
+// newRouter builds the routes of the config, the memory rate limits carry over from the
+// previous router and the cached responses are kept by the broker. the health checks run
+// until the router is closed
+func newRouter(config Config, previous *router, counters *counters, cache *responseCache) (*router, error) {
+	cors, err := newCors(config.Cors)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := newSecurityHeaders(config.SecurityHeaders)
+	if err != nil {
+		return nil, err
+	}
+	var previousLimiter *rateLimiter
+	if previous != nil {
+		previousLimiter = previous.limiter
+	}
+	limiter, err := newRateLimiter(config.RateLimit, previousLimiter)
+	if err != nil {
+		return nil, err
+	}
+	gateway := newGateway(config.JwtSecret, config.IdentitySecret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rtr := &router{config: config, limiter: limiter, cors: cors, headers: headers, cancel: cancel}
+	for _, server := range config.Servers {
+		upstream, err := newUpstream(server, counters.server(server.Name))
+		if err != nil {
+			cancel()
+			limiter.close()
+			return nil, err
+		}
+		rt := route{
+			name:         server.Name,
+			prefix:       upstream.prefix,
+			publicRoutes: server.Public,
+			deniedRoutes: server.Deny,
+		}
+		rt.proxy = gateway.protect(rt, limiter.limit(cache.wrap(upstream, upstream)))
+		rtr.routes = append(rtr.routes, rt)
+		rtr.upstreams = append(rtr.upstreams, upstream)
+	}
+	// the denied routes are left out of the public spec as well
+	docsConfig := config.Docs
+	docsConfig.Exclude = slices.Clone(docsConfig.Exclude)
+	for _, rt := range rtr.routes {
+		for _, pattern := range rt.deniedRoutes {
+			docsConfig.Exclude = append(docsConfig.Exclude, strings.TrimSuffix(rt.prefix, "/")+pattern)
+		}
+	}
+	if rtr.docs, err = newDocs(docsConfig, rtr.upstreams); err != nil {
+		cancel()
+		limiter.close()
+		return nil, err
+	}
+	for _, upstream := range rtr.upstreams {
+		upstream.startHealthChecks(ctx)
+		logger.Info().
+			Msgf("proxy %s → %s (%d instances)", upstream.name, upstream.prefix, len(upstream.instances))
+	}
+	return rtr, nil
+}
+
+func (rtr *router) match(path string) (route, bool) {
+	for _, rt := range rtr.routes {
+		if strings.HasPrefix(path, rt.prefix) {
+			return rt, true
+		}
+	}
+	return route{}, false
+}
+
+// close stops the health checks, the requests in flight finish on the old upstreams
+func (rtr *router) close() {
+	rtr.cancel()
+	for _, upstream := range rtr.upstreams {
+		if transport, ok := upstream.transport.(*http.Transport); ok && transport != http.DefaultTransport {
+			transport.CloseIdleConnections()
+		}
+	}
+	if err := rtr.limiter.close(); err != nil {
+		logger.Error().Err(err).Msg("failed to close rate limiter")
+	}
+}
+
+// broker serves the requests with the current router
+type broker struct {
+	path     string
+	router   atomic.Pointer[router]
+	counters *counters
+	cache    *responseCache
+	// events invalidate the cache, nil without kafka servers
+	events     events.EventManager
+	subscribed map[string]bool
+	// reloading serializes the reloads of the signals and the watcher
+	reloading sync.Mutex
+	modTime   time.Time
+}
+
+func newBroker(path string, config Config) (*broker, error) {
+	b := &broker{
+		path:       path,
+		counters:   newCounters(),
+		cache:      newResponseCache(config.Cache.MaxEntries),
+		subscribed: map[string]bool{},
+	}
+	rtr, err := newRouter(config, nil, b.counters, b.cache)
+	if err != nil {
+		return nil, err
+	}
+	b.router.Store(rtr)
+	if len(config.Cache.Kafka.Servers) > 0 {
+		b.events = events.NewKafkaEventManager(events.KafkaOpts{
+			Servers: config.Cache.Kafka.Servers, GroupId: config.Cache.Kafka.GroupID,
+		})
+	}
+	b.subscribeInvalidations(config)
+	if info, err := statConfig(path); err == nil {
+		b.modTime = info
+	}
+	if err := metrics.Registry.Register(brokerCollector{b: b}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, span := startRequest(w, r)
+	rtr := b.router.Load()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	recorder.before = func(header http.Header) {
+		rtr.headers.apply(header, r.TLS != nil)
+	}
+	if rtr.cors.handle(recorder, r) || rtr.docs.handle(recorder, r) {
+		endRequest(span, "", recorder.status)
+		return
+	}
+	if !canonicalPath(r.URL) {
+		respondError(recorder, http.StatusBadRequest)
+		endRequest(span, "", http.StatusBadRequest)
+		return
+	}
+	rt, ok := rtr.match(routePath(r))
+	if !ok || rt.denied(routePath(r)) {
+		respondError(recorder, http.StatusNotFound)
+		endRequest(span, "", http.StatusNotFound)
+		return
+	}
+	span.SetName(r.Method + " " + rt.prefix)
+	rt.proxy.ServeHTTP(recorder, r)
+	endRequest(span, rt.name, recorder.status)
+	b.counters.server(rt.name).record(recorder.status)
+}
+
+// reload swaps in the router of the config on the disk, a config with errors keeps the current one
+func (b *broker) reload() error {
+	b.reloading.Lock()
+	defer b.reloading.Unlock()
+	if info, err := statConfig(b.path); err == nil {
+		b.modTime = info
+	}
+	config, err := loadConfig(b.path)
+	if err != nil {
+		b.counters.reloadFailures.Add(1)
+		return err
+	}
+	current := b.router.Load()
+	if config.Host != current.config.Host || config.Port != current.config.Port || config.Admin != current.config.Admin ||
+		config.Watch != current.config.Watch || config.ShutdownTimeout != current.config.ShutdownTimeout ||
+		config.Tracing != current.config.Tracing || config.Log.Level != current.config.Log.Level ||
+		!maps.Equal(config.Log.Levels, current.config.Log.Levels) || !reflect.DeepEqual(config.TLS, current.config.TLS) ||
+		!reflect.DeepEqual(config.Cache, current.config.Cache) {
+		logger.Warn().Msg("host, port, admin, watch, shutdown_timeout, tracing, log, tls and cache need a restart")
+	}
+	rtr, err := newRouter(config, current, b.counters, b.cache)
+	if err != nil {
+		b.counters.reloadFailures.Add(1)
+		return err
+	}
+	b.router.Store(rtr)
+	current.close()
+	b.subscribeInvalidations(config)
+	b.counters.reloads.Add(1)
+	logger.Info().Msg("config reloaded")
+	return nil
+}
+
+// watch reloads the config when the file changes, checking it every interval
+func (b *broker) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modTime, err := statConfig(b.path)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to stat config file")
+			continue
+		}
+		b.reloading.Lock()
+		changed := !modTime.Equal(b.modTime)
+		b.reloading.Unlock()
+		if !changed {
+			continue
+		}
+		if err := b.reload(); err != nil {
+			logger.Error().Err(err).Msg("failed to reload config, keeping the current one")
+		}
+	}
+}
+
+// statusRecorder keeps the status of the response for the counters, Unwrap lets the
+// proxy flush the event streams through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+	// before completes the header once the proxy copied the one of the server
+	before func(http.Header)
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.writing()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(body []byte) (int, error) {
+	if !r.wrote {
+		r.writing()
+	}
+	return r.ResponseWriter.Write(body)
+}
+
+func (r *statusRecorder) writing() {
+	r.wrote = true
+	if r.before != nil {
+		r.before(r.Header())
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRouteDenied(t *testing.T) {
+	rt := route{prefix: "/api/v1/auth-srv", publicRoutes: []string{"/auth/login"}, deniedRoutes: []string{"/internal/*"}}
+	for path, want := range map[string]bool{
+		"/api/v1/auth-srv/internal/snapshot/users": true,
+		"/api/v1/auth-srv/internal":                true,
+		"/api/v1/auth-srv/auth/login":              false,
+		"/api/v1/auth-srv/users/internal":          false,
+	} {
+		if got := rt.denied(path); got != want {
+			t.Errorf("denied(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	for target, want := range map[string]bool{
+		"/api/v1/auth-srv/auth/login":              true,
+		"/api/v1/auth-srv/auth/login/":             true,
+		"/":                                        true,
+		"/api/v1/auth-srv/./internal/snapshot":     false,
+		"/api/v1/auth-srv/users/../internal":       false,
+		"/api/v1/auth-srv//internal/snapshot":      false,
+		"/api/v1/auth-srv/internal%2fsnapshot":     false,
+		"/api/v1/auth-srv/internal%5Csnapshot":     false,
+		"/api/v1/notification-srv/admin\\template": false,
+	} {
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := canonicalPath(u); got != want {
+			t.Errorf("canonicalPath(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
+
+func TestRouteDeniedIgnoresTheCase(t *testing.T) {
+	rt := route{prefix: "/api/v1/auth-srv", deniedRoutes: []string{"/internal/*"}}
+	r := httptest.NewRequest(http.MethodGet, "/API/v1/Auth-Srv/Internal/snapshot/users", nil)
+	if !rt.denied(routePath(r)) {
+		t.Errorf("denied(%q) = false, want true", r.URL.Path)
+	}
+}
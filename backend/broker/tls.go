@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval is how often the files of the certificate are checked for a renewal
+const certCheckInterval = 10 * time.Second
+
+// TLSConfig terminates tls on the port of the broker, with the certificate of the files
+// or one issued by an acme directory. disabled without either
+type TLSConfig struct {
+	CertFile string     `yaml:"cert_file"`
+	KeyFile  string     `yaml:"key_file"`
+	Acme     AcmeConfig `yaml:"acme"`
+	// MinVersion is 1.2 or 1.3
+	MinVersion string `yaml:"min_version"`
+	// RedirectPort redirects the plain http requests to https, it also answers the
+	// http-01 challenges of acme
+	RedirectPort int `yaml:"redirect_port"`
+}
+
+// AcmeConfig issues and renews the certificates of the domains, from let's encrypt
+// without a directory_url
+type AcmeConfig struct {
+	Domains      []string `yaml:"domains"`
+	Email        string   `yaml:"email"`
+	DirectoryURL string   `yaml:"directory_url"`
+	// CaFile is trusted to reach the directory, e.g. the root of a local pebble
+	CaFile string `yaml:"ca_file"`
+	// CacheDir keeps the account and the certificates across the restarts
+	CacheDir string `yaml:"cache_dir"`
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.CertFile != "" || len(c.Acme.Domains) > 0
+}
+
+func (c TLSConfig) validate() error {
+	var errs []error
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file go together"))
+	}
+	if c.CertFile != "" && len(c.Acme.Domains) > 0 {
+		errs = append(errs, errors.New("tls.cert_file and tls.acme are exclusive"))
+	}
+	if _, err := tlsVersion(c.MinVersion); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RedirectPort < 0 || c.RedirectPort > 65535 {
+		errs = append(errs, fmt.Errorf("tls.redirect_port %d is invalid", c.RedirectPort))
+	}
+	return errors.Join(errs...)
+}
+
+// serverTLS is the tls of the listener and the handler of the redirect port
+func serverTLS(conf TLSConfig, port int) (*tls.Config, http.Handler, error) {
+	minVersion, err := tlsVersion(conf.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	redirect := redirectHandler(port)
+	if len(conf.Acme.Domains) == 0 {
+		cert := &certificate{certFile: conf.CertFile, keyFile: conf.KeyFile}
+		if err := cert.reload(); err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{GetCertificate: cert.get, MinVersion: minVersion}, redirect, nil
+	}
+
+	client := &acme.Client{DirectoryURL: conf.Acme.DirectoryURL}
+	if conf.Acme.CaFile != "" {
+		roots, err := loadCertPool(conf.Acme.CaFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+	cacheDir := conf.Acme.CacheDir
+	if cacheDir == "" {
+		cacheDir = "certs"
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(conf.Acme.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      conf.Acme.Email,
+		Client:     client,
+	}
+	// the tls-alpn-01 challenges are answered on the port of the broker itself
+	tlsConfig := manager.TLSConfig()
+	tlsConfig.MinVersion = minVersion
+	return tlsConfig, manager.HTTPHandler(redirect), nil
+}
+
+func redirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("tls version %q is invalid", version)
+}
+
+// certificate serves the certificate of the files, loaded again when they change so
+// the renewals need no restart
+type certificate struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= certCheckInterval {
+		c.checked = now
+		if err := c.load(); err != nil {
+			logger.Error().Err(err).Msg("failed to reload certificate, keeping the current one")
+		}
+	}
+	return c.cert, nil
+}
+
+func (c *certificate) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = time.Now()
+	return c.load()
+}
+
+func (c *certificate) load() error {
+	var modTime time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		changed, err := statConfig(file)
+		if err != nil {
+			return err
+		}
+		if changed.After(modTime) {
+			modTime = changed
+		}
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.modTime = &cert, modTime
+	logger.Info().Str("cert_file", c.certFile).Msg("certificate loaded")
+	return nil
+}
+
+// UpstreamTLSConfig reaches the instances of a server over https, presenting the
+// certificate for mtls. the files are read again on the reloads of the config
+type UpstreamTLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CaFile verifies the instances instead of the roots of the system
+	CaFile   string `yaml:"ca_file"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ServerName is verified instead of the host of the instances
+	ServerName string `yaml:"server_name"`
+}
+
+func upstreamTransport(conf UpstreamTLSConfig) (http.RoundTripper, error) {
+	if !conf.Enabled {
+		return http.DefaultTransport, nil
+	}
+	tlsConfig := &tls.Config{ServerName: conf.ServerName, MinVersion: tls.VersionTLS12}
+	if conf.CaFile != "" {
+		roots, err := loadCertPool(conf.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return nil, errors.New("tls.cert_file and tls.key_file go together")
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("%s has no certificate", file)
+	}
+	return pool, nil
+}
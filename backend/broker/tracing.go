@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequest continues the trace of the client or starts one and gives the request an
+// id, the services continue both from the headers of the proxied request
+func startRequest(w http.ResponseWriter, r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	requestID := r.Header.Get(tracing.RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+	ctx = tracing.WithRequestID(ctx, requestID)
+	ctx, span := tracing.Tracer().Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer))
+	ctx = logger.WithContext(ctx, tracing.LogFields(ctx))
+
+	r = r.WithContext(ctx)
+	tracing.Inject(ctx, propagation.HeaderCarrier(r.Header))
+	w.Header().Set(tracing.RequestIDHeader, requestID)
+	return r, span
+}
+
+func endRequest(span trace.Span, server string, status int) {
+	span.SetAttributes(
+		attribute.String("billbharat.server", server),
+		attribute.Int("http.response.status_code", status),
+	)
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// validRequestID keeps the ids of the clients that are safe to log and forward
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+)
+
+// maxRetriedBody bounds the bodies kept in memory to be sent again, the larger ones are not retried
+const maxRetriedBody = 1 << 20
+
+var errNoInstance = errors.New("no healthy instance")
+
+type ServerConfig struct {
+	Name string `yaml:"name"`
+	// Host and Port are the instance of the servers without Instances
+	Host      string           `yaml:"host"`
+	Port      int              `yaml:"port"`
+	Instances []InstanceConfig `yaml:"instances"`
+	Prefix    string           `yaml:"prefix"`
+	// Public are the routes, relative to the prefix, that work without an access token
+	Public []string `yaml:"public"`
+	// Deny are the routes, relative to the prefix, the broker never forwards, the ones
+	// only the other services call on the server itself
+	Deny []string `yaml:"deny"`
+	// Balancer is round_robin or least_connections
+	Balancer       string               `yaml:"balancer"`
+	HealthCheck    HealthCheckConfig    `yaml:"health_check"`
+	Outlier        OutlierConfig        `yaml:"outlier"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	// Timeout bounds the requests to the server, 0s for none, the Routes override it
+	Timeout string        `yaml:"timeout"`
+	Routes  []RouteConfig `yaml:"routes"`
+	// Retries are the attempts on the other instances for the idempotent methods
+	Retries int               `yaml:"retries"`
+	TLS     UpstreamTLSConfig `yaml:"tls"`
+}
+
+type InstanceConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+type HealthCheckConfig struct {
+	// Path is relative to the prefix of the server
+	Path     string `yaml:"path"`
+	Interval string `yaml:"interval"`
+	Timeout  string `yaml:"timeout"`
+	// UnhealthyThreshold failed checks in a row take the instance out,
+	// HealthyThreshold passed ones bring it back
+	HealthyThreshold   int `yaml:"healthy_threshold"`
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"`
+}
+
+// OutlierConfig ejects the instances failing the requests in a row, before the health checks notice
+type OutlierConfig struct {
+	ConsecutiveFailures int    `yaml:"consecutive_failures"`
+	EjectionTime        string `yaml:"ejection_time"`
+}
+
+type CircuitBreakerConfig struct {
+	// Failures are the failed requests in a row opening the circuit for OpenTime
+	Failures int    `yaml:"failures"`
+	OpenTime string `yaml:"open_time"`
+}
+
+type RouteConfig struct {
+	// Path is a pattern relative to the prefix, like the public routes
+	Path    string           `yaml:"path"`
+	Timeout string           `yaml:"timeout"`
+	Cache   RouteCacheConfig `yaml:"cache"`
+}
+
+// parseDuration parses the durations of the config, empty is the fallback
+func parseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// instance is a replica of a server, it takes requests while it passes the
+// health checks and is not ejected
+type instance struct {
+	url    *url.URL
+	active atomic.Int64
+
+	mu           sync.Mutex
+	healthy      bool
+	checks       int
+	failures     int
+	ejectedUntil time.Time
+}
+
+func (i *instance) available(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.healthy && !now.Before(i.ejectedUntil)
+}
+
+// report counts the failed requests in a row, ejecting the instance at the threshold
+func (i *instance) report(failed bool, outlier outlier, now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !failed {
+		i.failures = 0
+		return false
+	}
+	i.failures++
+	if i.failures < outlier.consecutiveFailures {
+		return false
+	}
+	i.failures = 0
+	i.ejectedUntil = now.Add(outlier.ejectionTime)
+	logger.Warn().Str("instance", i.url.Host).Dur("for", outlier.ejectionTime).Msg("instance ejected")
+	return true
+}
+
+// reportCheck flips the health once the checks agree threshold times in a row
+func (i *instance) reportCheck(passed bool, check healthCheck) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if passed == i.healthy {
+		i.checks = 0
+		return
+	}
+	i.checks++
+	threshold := check.unhealthyThreshold
+	if passed {
+		threshold = check.healthyThreshold
+	}
+	if i.checks < threshold {
+		return
+	}
+	i.checks = 0
+	i.healthy = passed
+	if passed {
+		logger.Info().Str("instance", i.url.Host).Msg("instance healthy")
+	} else {
+		logger.Warn().Str("instance", i.url.Host).Msg("instance unhealthy")
+	}
+}
+
+type healthCheck struct {
+	path               string
+	interval           time.Duration
+	timeout            time.Duration
+	healthyThreshold   int
+	unhealthyThreshold int
+}
+
+type outlier struct {
+	consecutiveFailures int
+	ejectionTime        time.Duration
+}
+
+type routeTimeout struct {
+	path    string
+	timeout time.Duration
+}
+
+// upstream balances the requests of a server over its instances, retrying the idempotent
+// ones on the failures of the instances and failing fast while its circuit is open
+type upstream struct {
+	name             string
+	prefix           string
+	instances        []*instance
+	leastConnections bool
+	next             atomic.Uint64
+	healthCheck      healthCheck
+	outlier          outlier
+	breaker          *breaker
+	timeout          time.Duration
+	routes           []routeTimeout
+	caches           []routeCache
+	retries          int
+	transport        http.RoundTripper
+	proxy            *httputil.ReverseProxy
+	counters         *serverCounters
+}
+
+func newUpstream(server ServerConfig, counters *serverCounters) (*upstream, error) {
+	u := &upstream{
+		name:     server.Name,
+		prefix:   normalizePrefix(server.Prefix),
+		retries:  server.Retries,
+		counters: counters,
+	}
+	var err error
+	if u.transport, err = upstreamTransport(server.TLS); err != nil {
+		return nil, fmt.Errorf("invalid tls of %s: %w", server.Name, err)
+	}
+	scheme := "http"
+	if server.TLS.Enabled {
+		scheme = "https"
+	}
+	switch server.Balancer {
+	case "", "round_robin":
+	case "least_connections":
+		u.leastConnections = true
+	default:
+		return nil, fmt.Errorf("unknown balancer %q of %s", server.Balancer, server.Name)
+	}
+
+	instances := server.Instances
+	if len(instances) == 0 {
+		instances = []InstanceConfig{{Host: server.Host, Port: server.Port}}
+	}
+	for _, conf := range instances {
+		target, err := url.Parse(fmt.Sprintf("%s://%s:%d", scheme, conf.Host, conf.Port))
+		if err != nil {
+			return nil, fmt.Errorf("invalid instance of %s: %w", server.Name, err)
+		}
+		// the instances take requests until the health checks say otherwise
+		u.instances = append(u.instances, &instance{url: target, healthy: true})
+	}
+
+	u.healthCheck = healthCheck{
+		path:               server.HealthCheck.Path,
+		healthyThreshold:   server.HealthCheck.HealthyThreshold,
+		unhealthyThreshold: server.HealthCheck.UnhealthyThreshold,
+	}
+	if u.healthCheck.path == "" {
+		u.healthCheck.path = "/health"
+	}
+	if u.healthCheck.healthyThreshold <= 0 {
+		u.healthCheck.healthyThreshold = 1
+	}
+	if u.healthCheck.unhealthyThreshold <= 0 {
+		u.healthCheck.unhealthyThreshold = 2
+	}
+	if u.healthCheck.interval, err = parseDuration(server.HealthCheck.Interval, 10*time.Second); err != nil || u.healthCheck.interval <= 0 {
+		return nil, fmt.Errorf("invalid health check interval %q of %s", server.HealthCheck.Interval, server.Name)
+	}
+	if u.healthCheck.timeout, err = parseDuration(server.HealthCheck.Timeout, 2*time.Second); err != nil {
+		return nil, fmt.Errorf("invalid health check timeout of %s: %w", server.Name, err)
+	}
+
+	u.outlier.consecutiveFailures = server.Outlier.ConsecutiveFailures
+	if u.outlier.consecutiveFailures <= 0 {
+		u.outlier.consecutiveFailures = 5
+	}
+	if u.outlier.ejectionTime, err = parseDuration(server.Outlier.EjectionTime, 30*time.Second); err != nil {
+		return nil, fmt.Errorf("invalid ejection time of %s: %w", server.Name, err)
+	}
+
+	failures := server.CircuitBreaker.Failures
+	if failures <= 0 {
+		failures = 20
+	}
+	openTime, err := parseDuration(server.CircuitBreaker.OpenTime, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid circuit breaker open time of %s: %w", server.Name, err)
+	}
+	u.breaker = newBreaker(failures, openTime)
+
+	if u.timeout, err = parseDuration(server.Timeout, 30*time.Second); err != nil {
+		return nil, fmt.Errorf("invalid timeout of %s: %w", server.Name, err)
+	}
+	for _, rt := range server.Routes {
+		timeout, err := parseDuration(rt.Timeout, u.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of %s%s: %w", server.Name, rt.Path, err)
+		}
+		u.routes = append(u.routes, routeTimeout{path: rt.Path, timeout: timeout})
+		ttl, err := parseDuration(rt.Cache.TTL, 0)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid cache ttl %q of %s%s", rt.Cache.TTL, server.Name, rt.Path)
+		}
+		if ttl > 0 {
+			u.caches = append(u.caches, routeCache{path: rt.Path, ttl: ttl, topics: rt.Cache.InvalidatedBy})
+		}
+	}
+
+	u.proxy = &httputil.ReverseProxy{
+		// the instance is picked by the transport, path and query untouched
+		Director:     func(req *http.Request) {},
+		Transport:    u,
+		ErrorHandler: u.handleError,
+	}
+	return u, nil
+}
+
+// ServeHTTP bounds the request with the timeout of its route
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if timeout := u.timeoutOf(routePath(r)); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	u.proxy.ServeHTTP(w, r)
+}
+
+func (u *upstream) timeoutOf(path string) time.Duration {
+	path = strings.TrimPrefix(path, strings.TrimSuffix(u.prefix, "/"))
+	for _, rt := range u.routes {
+		if matchRoute(rt.path, path) {
+			return rt.timeout
+		}
+	}
+	return u.timeout
+}
+
+// RoundTrip sends the request to an instance, the idempotent requests go to another
+// one when it fails. only the failures of the instances count, not the errors of the
+// services: the transport errors, 502, 503 and 504. a PUT or a DELETE is sent again
+// only when the instance could not be dialed, one it got may have been applied.
+func (u *upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !u.breaker.allow(time.Now()) {
+		return nil, errCircuitOpen
+	}
+	attempts := 1
+	if idempotent(req.Method) && rewindable(req) {
+		attempts += u.retries
+	}
+	tried := map[*instance]bool{}
+	var resp *http.Response
+	var err error
+	failed := true
+	for attempt := 0; attempt < attempts; attempt++ {
+		inst := u.pick(tried)
+		if inst == nil {
+			resp, err = nil, errNoInstance
+			break
+		}
+		tried[inst] = true
+		out := req.Clone(req.Context())
+		if attempt > 0 && req.GetBody != nil {
+			if out.Body, err = req.GetBody(); err != nil {
+				break
+			}
+		}
+		resp, err = u.send(inst, out)
+		failed = err != nil || unavailable(resp.StatusCode)
+		if errors.Is(err, context.Canceled) {
+			// the client went away
+			u.breaker.release()
+			return nil, err
+		}
+		if inst.report(failed, u.outlier, time.Now()) {
+			u.counters.ejections.Add(1)
+		}
+		if !failed || req.Context().Err() != nil || attempt == attempts-1 || !retriable(req.Method, err) {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		u.counters.retries.Add(1)
+		logger.Ctx(req.Context()).Warn().Err(err).Str("server", u.name).Str("instance", inst.url.Host).Msg("retrying request")
+	}
+	u.breaker.report(failed, time.Now())
+	return resp, err
+}
+
+// send counts the request as active on the instance until its body is closed
+func (u *upstream) send(inst *instance, req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = inst.url.Scheme
+	req.URL.Host = inst.url.Host
+	req.Host = inst.url.Host
+	inst.active.Add(1)
+	resp, err := u.transport.RoundTrip(req)
+	// the upgraded connections need the body as it is
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		inst.active.Add(-1)
+		return resp, err
+	}
+	resp.Body = &activeBody{ReadCloser: resp.Body, done: func() { inst.active.Add(-1) }}
+	return resp, nil
+}
+
+// pick balances over the available instances, preferring the ones not tried yet
+func (u *upstream) pick(tried map[*instance]bool) *instance {
+	now := time.Now()
+	var available, untried []*instance
+	for _, inst := range u.instances {
+		if !inst.available(now) {
+			continue
+		}
+		available = append(available, inst)
+		if !tried[inst] {
+			untried = append(untried, inst)
+		}
+	}
+	candidates := untried
+	if len(candidates) == 0 {
+		candidates = available
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	start := int(u.next.Add(1) % uint64(len(candidates)))
+	if !u.leastConnections {
+		return candidates[start]
+	}
+	var picked *instance
+	for k := range candidates {
+		inst := candidates[(start+k)%len(candidates)]
+		if picked == nil || inst.active.Load() < picked.active.Load() {
+			picked = inst
+		}
+	}
+	return picked
+}
+
+func (u *upstream) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case errors.Is(err, errCircuitOpen), errors.Is(err, errNoInstance):
+		logger.Ctx(r.Context()).Warn().Err(err).Str("server", u.name).Msg("server unavailable")
+		respondError(w, http.StatusServiceUnavailable)
+	case errors.Is(err, context.DeadlineExceeded):
+		logger.Ctx(r.Context()).Warn().Err(err).Str("server", u.name).Str("path", r.URL.Path).Msg("request timed out")
+		respondError(w, http.StatusGatewayTimeout)
+	default:
+		logger.Ctx(r.Context()).Error().Err(err).Str("server", u.name).Str("path", r.URL.Path).Msg("failed to proxy request")
+		respondError(w, http.StatusBadGateway)
+	}
+}
+
+// startHealthChecks checks the health route of every instance of the server until the context is done
+func (u *upstream) startHealthChecks(ctx context.Context) {
+	for _, inst := range u.instances {
+		go func() {
+			ticker := time.NewTicker(u.healthCheck.interval)
+			defer ticker.Stop()
+			for {
+				inst.reportCheck(u.check(ctx, inst), u.healthCheck)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func (u *upstream) check(ctx context.Context, inst *instance) bool {
+	ctx, cancel := context.WithTimeout(ctx, u.healthCheck.timeout)
+	defer cancel()
+	target := inst.url.String() + strings.TrimSuffix(u.prefix, "/") + u.healthCheck.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := u.transport.RoundTrip(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+type upstreamStatus struct {
+	Name      string           `json:"name"`
+	Prefix    string           `json:"prefix"`
+	Circuit   string           `json:"circuit"`
+	Instances []instanceStatus `json:"instances"`
+}
+
+type instanceStatus struct {
+	Url     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	Ejected bool   `json:"ejected"`
+	Active  int64  `json:"active"`
+}
+
+func (u *upstream) status(now time.Time) upstreamStatus {
+	status := upstreamStatus{
+		Name:    u.name,
+		Prefix:  u.prefix,
+		Circuit: u.breaker.String(),
+	}
+	for _, inst := range u.instances {
+		inst.mu.Lock()
+		status.Instances = append(status.Instances, instanceStatus{
+			Url:     inst.url.String(),
+			Healthy: inst.healthy,
+			Ejected: now.Before(inst.ejectedUntil),
+			Active:  inst.active.Load(),
+		})
+		inst.mu.Unlock()
+	}
+	return status
+}
+
+type activeBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *activeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retriable tells whether the failed attempt can go to another instance, the safe methods
+// always and the other idempotent ones only when the request never left the broker
+func retriable(method string, err error) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func unavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// rewindable keeps the body in memory so the retries can send it again
+func rewindable(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true
+	}
+	if req.ContentLength < 0 || req.ContentLength > maxRetriedBody {
+		return false
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestUpstream(t *testing.T, addrs ...string) *upstream {
+	t.Helper()
+	server := ServerConfig{Name: "test", Prefix: "/test", Retries: 1}
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := strconv.Atoi(port)
+		server.Instances = append(server.Instances, InstanceConfig{Host: host, Port: p})
+	}
+	u, err := newUpstream(server, &serverCounters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRoundTripRetriesThePutsOnlyWhenNotSent(t *testing.T) {
+	var hits atomic.Int64
+	unavailable := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	first, second := httptest.NewServer(unavailable), httptest.NewServer(unavailable)
+	defer first.Close()
+	defer second.Close()
+	u := newTestUpstream(t, first.Listener.Addr().String(), second.Listener.Addr().String())
+
+	for method, want := range map[string]int64{http.MethodGet: 2, http.MethodPut: 1, http.MethodDelete: 1} {
+		hits.Store(0)
+		resp, err := u.RoundTrip(httptest.NewRequest(method, "/test/items/1", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := hits.Load(); got != want {
+			t.Errorf("%s: sent %d times, want %d", method, got, want)
+		}
+	}
+
+	// an instance which can not be dialed never got the put
+	closed := httptest.NewServer(unavailable)
+	closed.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	u = newTestUpstream(t, closed.Listener.Addr().String(), ok.Listener.Addr().String())
+	for range 2 {
+		resp, err := u.RoundTrip(httptest.NewRequest(http.MethodPut, "/test/items/1", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	}
+}
@@ -6,18 +6,30 @@ import (
 
 	// autoload the environment variables
 	"github.com/aritradevelops/billbharat/backend/shared/timex"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/caarlos0/env/v10"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type Config struct {
-	Http        Http        `envPrefix:"HTTP_"`
-	Database    Database    `envPrefix:"DATABASE_"`
-	Service     Service     `envPrefix:"SERVICE_"`
-	Deployment  Deployment  `envPrefix:"DEPLOYMENT_"`
-	Jwt         Jwt         `envPrefix:"JWT_"`
-	EventBroker EventBroker `envPrefix:"EVENT_BROKER_"`
-	Mailer      Mailer      `envPrefix:"MAILER_"`
+	Http        Http           `envPrefix:"HTTP_"`
+	Database    Database       `envPrefix:"DATABASE_"`
+	Service     Service        `envPrefix:"SERVICE_"`
+	Deployment  Deployment     `envPrefix:"DEPLOYMENT_"`
+	Jwt         Jwt            `envPrefix:"JWT_"`
+	Gateway     Gateway        `envPrefix:"GATEWAY_"`
+	EventBroker EventBroker    `envPrefix:"EVENT_BROKER_"`
+	Tracing     tracing.Config `envPrefix:"TRACING_"`
+	Log         Log            `envPrefix:"LOG_"`
+	Snapshot    Snapshot       `envPrefix:"SNAPSHOT_"`
+	Mailer      Mailer         `envPrefix:"MAILER_"`
+	Sms         Sms            `envPrefix:"SMS_"`
+	Whatsapp    Whatsapp       `envPrefix:"WHATSAPP_"`
+	Push        Push           `envPrefix:"PUSH_"`
+	Webhook     Webhook        `envPrefix:"WEBHOOK_"`
+	Internal    Internal       `envPrefix:"INTERNAL_"`
+	Unsubscribe Unsubscribe    `envPrefix:"UNSUBSCRIBE_"`
+	Broadcast   Broadcast      `envPrefix:"BROADCAST_"`
 }
 
 type Http struct {
@@ -43,19 +55,127 @@ type Jwt struct {
 	Lifetime timex.Duration `env:"LIFETIME,required"`
 }
 
+// Gateway is the trust in the identity the broker forwards after verifying the access token
+type Gateway struct {
+	// Secret is shared with the broker, the forwarded identity is ignored when it is empty
+	Secret string `env:"SECRET"`
+	// VerifyToken verifies the access token along with the forwarded identity
+	VerifyToken bool `env:"VERIFY_TOKEN" envDefault:"false"`
+}
+
+// Log sets the levels of the logs, Levels sets apart packages, e.g. events:warn,notifier:debug
+type Log struct {
+	Level  string            `env:"LEVEL" envDefault:"info"`
+	Levels map[string]string `env:"LEVELS"`
+}
+
 type EventBroker struct {
+	// Driver is either kafka or memory, memory keeps the events in process
+	// and is only meant for running a single service without a broker
+	Driver  string   `env:"DRIVER" envDefault:"kafka"`
 	Servers []string `env:"SERVERS,required" envSeparator:","`
 	GroupID string   `env:"GROUP_ID,required"`
+	// Concurrency is the number of partitions of a topic handled in parallel
+	Concurrency int `env:"CONCURRENCY" envDefault:"4"`
 }
 
 type Mailer struct {
+	// Provider is either smtp or sendgrid
+	Provider string `env:"PROVIDER" envDefault:"smtp"`
 	Domain   string `env:"DOMAIN,required"`
-	Host     string `env:"HOST,required"`
-	Port     int    `env:"PORT,required"`
-	Username string `env:"USERNAME,required"`
-	Password string `env:"PASSWORD,required"`
 	From     string `env:"FROM,required"`
 	FromName string `env:"FROM_NAME,required"`
+	// smtp
+	Host     string `env:"HOST"`
+	Port     int    `env:"PORT"`
+	Username string `env:"USERNAME"`
+	Password string `env:"PASSWORD"`
+	// Encryption is none, starttls or tls (implicit, usually on port 465)
+	Encryption string        `env:"ENCRYPTION" envDefault:"none"`
+	Timeout    time.Duration `env:"TIMEOUT" envDefault:"10s"`
+	// PoolSize is the number of idle connections kept open to the smtp server
+	PoolSize int `env:"POOL_SIZE" envDefault:"4"`
+	// DKIM signing is enabled when the selector is set, the key is a pem encoded rsa private key
+	DkimSelector       string `env:"DKIM_SELECTOR"`
+	DkimPrivateKeyFile string `env:"DKIM_PRIVATE_KEY_FILE"`
+	// http api providers
+	ApiKey string `env:"API_KEY"`
+	ApiUrl string `env:"API_URL"`
+}
+
+type Sms struct {
+	// Provider is either log or msg91, log only logs the messages
+	Provider string        `env:"PROVIDER" envDefault:"log"`
+	ApiUrl   string        `env:"API_URL"`
+	ApiKey   string        `env:"API_KEY"`
+	Timeout  time.Duration `env:"TIMEOUT" envDefault:"10s"`
+	// SenderID is the DLT registered header, e.g. BILBRT
+	SenderID string `env:"SENDER_ID"`
+	// EntityID is the principal entity id of the business registered on the DLT platform
+	EntityID string `env:"ENTITY_ID"`
+	Route    string `env:"ROUTE" envDefault:"4"`
+	Country  string `env:"COUNTRY" envDefault:"91"`
+}
+
+type Whatsapp struct {
+	// Provider is either log or cloud (the whatsapp business cloud api), log only logs the messages
+	Provider      string        `env:"PROVIDER" envDefault:"log"`
+	ApiUrl        string        `env:"API_URL" envDefault:"https://graph.facebook.com/v21.0"`
+	PhoneNumberID string        `env:"PHONE_NUMBER_ID"`
+	AccessToken   string        `env:"ACCESS_TOKEN"`
+	Timeout       time.Duration `env:"TIMEOUT" envDefault:"10s"`
+	// VerifyToken is the one entered when registering the webhook, whatsapp echoes it to verify the url
+	VerifyToken string `env:"VERIFY_TOKEN"`
+	// AppSecret of the meta app, the webhook payloads are signed with it
+	AppSecret string `env:"APP_SECRET"`
+}
+
+type Push struct {
+	// Provider is either log or vapid (web push), log only logs the messages
+	Provider string `env:"PROVIDER" envDefault:"log"`
+	// VapidPrivateKey is the base64url encoded P-256 private key, the public key the
+	// browsers subscribe with is derived from it
+	VapidPrivateKey string `env:"VAPID_PRIVATE_KEY"`
+	// VapidSubject is the contact of the operator, a mailto: or https: url
+	VapidSubject string `env:"VAPID_SUBJECT"`
+	// TTL is how long the push services keep a message for an offline browser
+	TTL     time.Duration `env:"TTL" envDefault:"24h"`
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"10s"`
+}
+
+type Webhook struct {
+	// Token is set by the providers as the token query parameter of the webhook urls
+	Token string `env:"TOKEN,required"`
+}
+
+type Internal struct {
+	// ApiKey guards the admin routes, e.g. the delivery log
+	ApiKey string `env:"API_KEY,required"`
+}
+
+type Unsubscribe struct {
+	// Secret signs the one-click unsubscribe links of the emails
+	Secret string `env:"SECRET,required"`
+	// Url is the public url of the unsubscribe route, the token is added as a query parameter
+	Url string `env:"URL,required"`
+	// Lifetime of the links, the old mails stop offering them after it
+	Lifetime timex.Duration `env:"LIFETIME" envDefault:"180d"`
+}
+
+type Broadcast struct {
+	// BatchSize is the number of members of the business loaded at a time
+	BatchSize int `env:"BATCH_SIZE" envDefault:"500"`
+	// Rate is the number of messages a broadcast sends per second on every channel,
+	// the providers throttle the bursts
+	Rate int `env:"RATE" envDefault:"10"`
+}
+
+type Snapshot struct {
+	// Bootstrap backfills users and businesses from the auth service on startup,
+	// meant for a new deployment or after the local copies were wiped
+	Bootstrap bool   `env:"BOOTSTRAP" envDefault:"false"`
+	Url       string `env:"URL"`
+	ApiKey    string `env:"API_KEY"`
 }
 
 func Load() (Config, error) {
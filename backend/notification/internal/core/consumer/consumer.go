@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
@@ -9,79 +10,109 @@ import (
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
 )
 
 type Consumer struct {
 	ctx          context.Context
+	opts         events.SubscribeOpts
 	eventManager events.EventManager
 	repository   repository.Repository
 	notifier     notifier.Notifier
 }
 
-func New(ctx context.Context, eventManager events.EventManager, repository repository.Repository, notifier notifier.Notifier) *Consumer {
+func New(ctx context.Context, eventManager events.EventManager, opts events.SubscribeOpts, repository repository.Repository, notifier notifier.Notifier) *Consumer {
 	return &Consumer{
 		eventManager: eventManager,
 		repository:   repository,
 		ctx:          ctx,
+		opts:         opts,
 		notifier:     notifier,
 	}
 }
 
 func (c *Consumer) Start() {
-	c.eventManager.OnManageNotificationEvent(c.ctx, c.handleNotificationEvent)
-	c.eventManager.OnManageUserEvent(c.ctx, c.handleUserEvent)
-	c.eventManager.OnManageBusinessEvent(c.ctx, c.handleBusinessEvent)
-	c.eventManager.OnManageBusinessUserEvent(c.ctx, c.handleBusinessUserEvent)
+	events.NewManageNotificationTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleNotificationEvent)
+	events.NewManageUserTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleUserEvent)
+	events.NewManageBusinessTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleBusinessEvent)
+	events.NewManageBusinessUserTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleBusinessUserEvent)
 }
 
-func (c *Consumer) handleNotificationEvent(payload events.EventPayload[events.ManageNotificationEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage notification event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+// Bootstrap backfills the local copies from the snapshot of the auth service,
+// it must be called before Start. The events published in the meantime stay in
+// the topics and are consumed right after, and since every sync skips data older
+// than the stored copy, the order in which both arrive does not matter.
+func (c *Consumer) Bootstrap(client *snapshot.Client) error {
+	users, err := snapshot.Fetch(c.ctx, client, snapshot.Users, func(ctx context.Context, user events.ManageUserEventPayload) error {
+		return c.repository.SyncUser(ctx, dao.User(user))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill users: %w", err)
+	}
+	businesses, err := snapshot.Fetch(c.ctx, client, snapshot.Businesses, func(ctx context.Context, business events.MangageBusinessEventPayload) error {
+		return c.repository.SyncBusiness(ctx, dao.Business(business))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill businesses: %w", err)
+	}
+	businessUsers, err := snapshot.Fetch(c.ctx, client, snapshot.BusinessUsers, func(ctx context.Context, businessUser events.MangageBusinessUserEventPayload) error {
+		return c.repository.SyncBusinessUser(ctx, dao.BusinessUser(businessUser))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill business users: %w", err)
+	}
+	logger.Info().Int("users", users).Int("businesses", businesses).Int("business_users", businessUsers).Msg("backfill completed")
+	return nil
+}
+
+func (c *Consumer) handleNotificationEvent(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload]) error {
+	logger.Ctx(ctx).Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage notification event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.notifier.Notify(ctx, payload)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to notify")
+		logger.Ctx(ctx).Error().Err(err).Msg("failed to notify")
 		return err
 	}
-	logger.Info().Msg("notifications sent successfully")
+	logger.Ctx(ctx).Info().Msg("notifications sent successfully")
 	return nil
 }
 
-func (c *Consumer) handleUserEvent(payload events.EventPayload[events.ManageUserEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage user event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+func (c *Consumer) handleUserEvent(ctx context.Context, payload events.EventPayload[events.ManageUserEventPayload]) error {
+	logger.Ctx(ctx).Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage user event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncUser(ctx, dao.User(payload.Data))
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to sync user")
+		logger.Ctx(ctx).Error().Err(err).Msg("failed to sync user")
 		return err
 	}
-	logger.Info().Msg("user synced successfully")
+	logger.Ctx(ctx).Info().Msg("user synced successfully")
 	return nil
 }
 
-func (c *Consumer) handleBusinessEvent(payload events.EventPayload[events.MangageBusinessEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage business event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+func (c *Consumer) handleBusinessEvent(ctx context.Context, payload events.EventPayload[events.MangageBusinessEventPayload]) error {
+	logger.Ctx(ctx).Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage business event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncBusiness(ctx, dao.Business(payload.Data))
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to sync business")
+		logger.Ctx(ctx).Error().Err(err).Msg("failed to sync business")
 		return err
 	}
-	logger.Info().Msg("business synced successfully")
+	logger.Ctx(ctx).Info().Msg("business synced successfully")
 	return nil
 }
 
-func (c *Consumer) handleBusinessUserEvent(payload events.EventPayload[events.MangageBusinessUserEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage business user event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+func (c *Consumer) handleBusinessUserEvent(ctx context.Context, payload events.EventPayload[events.MangageBusinessUserEventPayload]) error {
+	logger.Ctx(ctx).Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage business user event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncBusinessUser(ctx, dao.BusinessUser(payload.Data))
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to sync business user")
+		logger.Ctx(ctx).Error().Err(err).Msg("failed to sync business user")
 		return err
 	}
-	logger.Info().Msg("business user synced successfully")
+	logger.Ctx(ctx).Info().Msg("business user synced successfully")
 	return nil
 }
@@ -0,0 +1,140 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+// fakeRepository keeps the synced copies, the other queries are not reached
+type fakeRepository struct {
+	repository.Repository
+
+	mu            sync.Mutex
+	users         []dao.User
+	businesses    []dao.Business
+	businessUsers []dao.BusinessUser
+	err           error
+}
+
+func (r *fakeRepository) SyncUser(ctx context.Context, user dao.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, user)
+	return r.err
+}
+
+func (r *fakeRepository) SyncBusiness(ctx context.Context, business dao.Business) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.businesses = append(r.businesses, business)
+	return r.err
+}
+
+func (r *fakeRepository) SyncBusinessUser(ctx context.Context, businessUser dao.BusinessUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.businessUsers = append(r.businessUsers, businessUser)
+	return r.err
+}
+
+type fakeNotifier struct {
+	notifier.Notifier
+
+	mu       sync.Mutex
+	notified []events.ManageNotificationEventPayload
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload]) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notified = append(n.notified, payload.Data)
+	return nil
+}
+
+func startConsumer(t *testing.T, repo *fakeRepository, notifier *fakeNotifier) events.EventManager {
+	t.Helper()
+	eventManager := events.New(events.MemoryDriver, events.KafkaOpts{}, events.MemoryOpts{Sync: true, MaxRedeliveries: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		eventManager.Close(context.Background())
+	})
+	New(ctx, eventManager, events.SubscribeOpts{}, repo, notifier).Start()
+	return eventManager
+}
+
+func TestConsumerSyncsTheCopiesOfAuth(t *testing.T) {
+	repo := &fakeRepository{}
+	eventManager := startConsumer(t, repo, &fakeNotifier{})
+	ctx := context.Background()
+	userID, businessID := uuid.New(), uuid.New()
+
+	err := events.NewManageUserTopic(eventManager, events.SubscribeOpts{}).
+		Publish(ctx, events.NewUserManageEvent("create", events.ManageUserEventPayload{ID: userID, Name: "Asha"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = events.NewManageBusinessTopic(eventManager, events.SubscribeOpts{}).
+		Publish(ctx, events.NewBusinessManageEvent("create", events.MangageBusinessEventPayload{ID: businessID, OwnerID: userID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = events.NewManageBusinessUserTopic(eventManager, events.SubscribeOpts{}).
+		Publish(ctx, events.NewBusinessUserManageEvent("create", events.MangageBusinessUserEventPayload{UserID: userID, BusinessID: businessID, Role: "Owner"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.users) != 1 || repo.users[0].ID != userID || repo.users[0].Name != "Asha" {
+		t.Fatalf("users = %+v", repo.users)
+	}
+	if len(repo.businesses) != 1 || repo.businesses[0].ID != businessID {
+		t.Fatalf("businesses = %+v", repo.businesses)
+	}
+	if len(repo.businessUsers) != 1 || repo.businessUsers[0].Role != "Owner" {
+		t.Fatalf("business users = %+v", repo.businessUsers)
+	}
+}
+
+func TestConsumerRedeliversTheFailedSyncs(t *testing.T) {
+	repo := &fakeRepository{err: errors.New("mongo down")}
+	eventManager := startConsumer(t, repo, &fakeNotifier{})
+
+	err := events.NewManageUserTopic(eventManager, events.SubscribeOpts{}).
+		Publish(context.Background(), events.NewUserManageEvent("update", events.ManageUserEventPayload{ID: uuid.New()}))
+	if err == nil {
+		t.Fatal("the failure of the sync was swallowed")
+	}
+	if len(repo.users) != 2 {
+		t.Fatalf("synced %d times, want the delivery and one redelivery", len(repo.users))
+	}
+}
+
+func TestConsumerHandsTheNotificationsToTheNotifier(t *testing.T) {
+	notifier := &fakeNotifier{}
+	eventManager := startConsumer(t, &fakeRepository{}, notifier)
+
+	err := events.NewManageNotificationTopic(eventManager, events.SubscribeOpts{}).
+		Publish(context.Background(), events.NewNotificationManageEvent(events.ManageNotificationEventPayload{
+			Event: notification.EMAIL_VERIFIED,
+			Kind:  notification.P2P,
+			Payload: []events.NotificationChannelPayload{
+				{Channel: notification.EMAIL, Data: notification.NewEmail("asha@example.com")},
+			},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.notified) != 1 || notifier.notified[0].Event != notification.EMAIL_VERIFIED {
+		t.Fatalf("notified = %+v", notifier.notified)
+	}
+}
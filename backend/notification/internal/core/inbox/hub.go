@@ -0,0 +1,81 @@
+package inbox
+
+import (
+	"sync"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/google/uuid"
+)
+
+// streamBuffer is how many items a slow stream can fall behind before they are dropped,
+// the inbox still has them
+const streamBuffer = 16
+
+// Hub fans the new inbox items out to the live streams of their users. it only
+// knows the streams connected to this instance, with more instances behind a load
+// balancer a user only gets live the items delivered by the instance they are on.
+type Hub struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]map[chan dao.InboxItem]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{streams: map[uuid.UUID]map[chan dao.InboxItem]struct{}{}}
+}
+
+// Subscribe opens a stream of the items of the user, the channel is closed
+// by unsubscribe or when the hub is closed
+func (h *Hub) Subscribe(userID uuid.UUID) (<-chan dao.InboxItem, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream := make(chan dao.InboxItem, streamBuffer)
+	if h.closed {
+		close(stream)
+		return stream, func() {}
+	}
+	if h.streams[userID] == nil {
+		h.streams[userID] = map[chan dao.InboxItem]struct{}{}
+	}
+	h.streams[userID][stream] = struct{}{}
+	var once sync.Once
+	return stream, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if _, ok := h.streams[userID][stream]; !ok {
+				return
+			}
+			delete(h.streams[userID], stream)
+			if len(h.streams[userID]) == 0 {
+				delete(h.streams, userID)
+			}
+			close(stream)
+		})
+	}
+}
+
+// Publish never blocks, a stream which is full misses the item
+func (h *Hub) Publish(item dao.InboxItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for stream := range h.streams[item.UserID] {
+		select {
+		case stream <- item:
+		default:
+		}
+	}
+}
+
+// Close ends every stream, so that the server can shut down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for userID, streams := range h.streams {
+		for stream := range streams {
+			close(stream)
+		}
+		delete(h.streams, userID)
+	}
+}
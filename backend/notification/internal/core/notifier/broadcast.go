@@ -0,0 +1,266 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	// broadcastInterval is how often the queued broadcasts are looked for, the
+	// instance which queued one looks right away
+	broadcastInterval = 30 * time.Second
+	// broadcastLease is how long a claimed broadcast is left to the instance between
+	// two batches, another instance takes it over after
+	broadcastLease = 5 * time.Minute
+	// broadcastAttempts bounds the fan outs of a broadcast whose members keep failing
+	broadcastAttempts   = 3
+	broadcastRetryDelay = time.Minute
+)
+
+// queueBroadcast stores the broadcast for sendBroadcasts, the event is done once it is
+// stored so the partition moves on while the members are sent to
+func (n *NotifierImpl) queueBroadcast(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload]) error {
+	if payload.Data.BusinessID == nil {
+		// redelivering the event would not give it a business
+		log.Error().Str("event", payload.ID.String()).Msg("broadcast has no business, skipping")
+		return nil
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to encode broadcast")
+		return err
+	}
+	now := time.Now()
+	err = n.repository.QueueBroadcast(ctx, dao.Broadcast{
+		ID:        payload.ID,
+		Payload:   encoded,
+		Status:    dao.BroadcastQueued,
+		NotBefore: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to queue broadcast")
+		return err
+	}
+	select {
+	case n.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// sendBroadcasts claims the queued broadcasts and fans them out one at a time
+func (n *NotifierImpl) sendBroadcasts() {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.queued:
+		}
+		for n.ctx.Err() == nil {
+			broadcast, err := n.repository.ClaimBroadcast(n.ctx, time.Now(), broadcastLease)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				break
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("failed to claim broadcast")
+				break
+			}
+			n.runBroadcast(broadcast)
+		}
+	}
+}
+
+// runBroadcast fans the broadcast out and records how it went, the one whose members
+// failed is fanned out again from the first member, the members sent to are skipped
+func (n *NotifierImpl) runBroadcast(broadcast dao.Broadcast) {
+	var payload events.EventPayload[events.ManageNotificationEventPayload]
+	err := json.Unmarshal(broadcast.Payload, &payload)
+	if err != nil {
+		// it would not decode the next time either
+		broadcast.Attempts = broadcastAttempts
+	} else {
+		err = n.fanOut(n.ctx, payload, &broadcast)
+	}
+	if n.ctx.Err() != nil {
+		// stopping, another instance takes over once the lease is over
+		return
+	}
+	now := time.Now()
+	broadcast.UpdatedAt = now
+	switch {
+	case err == nil:
+		broadcast.Status = dao.BroadcastSent
+	case broadcast.Attempts >= broadcastAttempts:
+		broadcast.Status = dao.BroadcastFailed
+	default:
+		broadcast.After = nil
+		broadcast.NotBefore = now.Add(broadcastRetryDelay)
+	}
+	if err != nil {
+		reason := err.Error()
+		broadcast.Error = &reason
+		log.Error().Err(err).Str("event", broadcast.ID.String()).Str("status", string(broadcast.Status)).Msg("failed to send broadcast")
+	}
+	if err := n.repository.UpdateBroadcast(n.ctx, broadcast); err != nil {
+		log.Error().Err(err).Str("event", broadcast.ID.String()).Msg("failed to update broadcast")
+	}
+}
+
+// fanOut sends the notification to the members of the business of the event, all of
+// them or the ones with the roles of its audience. the members are loaded in batches,
+// the claim of the broadcast is renewed after every one along with the last member sent
+// to, and every member gets a delivery of their own, addressed to them and with the
+// Recipient token. the channels send at most the rate of the broadcasts per second
+// across all the broadcasts.
+func (n *NotifierImpl) fanOut(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload], broadcast *dao.Broadcast) error {
+	params := repository.ListBusinessMembersParams{
+		BusinessID: *payload.Data.BusinessID,
+		Limit:      n.broadcast.BatchSize,
+		After:      broadcast.After,
+	}
+	if payload.Data.Audience != nil {
+		params.Roles = payload.Data.Audience.Roles
+	}
+	// the failures of before a take over are only known from the error
+	var errs []error
+	if broadcast.After == nil {
+		broadcast.Error = nil
+	} else if broadcast.Error != nil {
+		errs = append(errs, errors.New(*broadcast.Error))
+	}
+
+	total := 0
+	for {
+		members, next, err := n.repository.ListBusinessMembers(ctx, params)
+		if err != nil {
+			log.Error().Err(err).Str("business_id", params.BusinessID.String()).Msg("failed to list business members")
+			return errors.Join(append(errs, err)...)
+		}
+		for _, member := range members {
+			for _, msg := range payload.Data.Payload {
+				delivery, ok, err := newBroadcastDelivery(payload, msg, member)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to create broadcast delivery")
+					return err
+				}
+				// the member has no verified address on the channel
+				if !ok {
+					continue
+				}
+				if err := n.send(ctx, delivery, n.paces[msg.Channel]); err != nil {
+					if ctx.Err() != nil {
+						return errors.Join(append(errs, err)...)
+					}
+					errs = append(errs, err)
+				}
+			}
+			total++
+		}
+		if next == nil {
+			break
+		}
+		params.After = next
+		now := time.Now()
+		broadcast.After, broadcast.NotBefore, broadcast.UpdatedAt = next, now.Add(broadcastLease), now
+		if err := errors.Join(errs...); err != nil {
+			reason := err.Error()
+			broadcast.Error = &reason
+		}
+		if err := n.repository.UpdateBroadcast(ctx, *broadcast); err != nil {
+			log.Error().Err(err).Str("event", broadcast.ID.String()).Msg("failed to renew broadcast")
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	log.Info().Str("event", payload.ID.String()).Int("members", total).Msg("broadcast sent")
+	return errors.Join(errs...)
+}
+
+// newBroadcastDelivery is the delivery of the message to the member, the recipients of the
+// channel data are replaced by the address of the member and the tokens get the Recipient
+func newBroadcastDelivery(payload events.EventPayload[events.ManageNotificationEventPayload], msg events.NotificationChannelPayload, member dao.User) (dao.Delivery, bool, error) {
+	address := memberAddress(msg.Channel, member)
+	if address == "" {
+		return dao.Delivery{}, false, nil
+	}
+	var data map[string]any
+	if msg.Data != nil {
+		encoded, err := json.Marshal(msg.Data)
+		if err != nil {
+			return dao.Delivery{}, false, err
+		}
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			return dao.Delivery{}, false, err
+		}
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	// the copies would go to every member otherwise
+	delete(data, "cc")
+	delete(data, "bcc")
+	data["to"] = []string{address}
+	msg.Data = data
+
+	var tokens map[string]any
+	if payload.Data.Tokens != nil {
+		encoded, err := json.Marshal(payload.Data.Tokens)
+		if err != nil {
+			return dao.Delivery{}, false, err
+		}
+		// the tokens of a broadcast have to be an object to take the Recipient
+		if err := json.Unmarshal(encoded, &tokens); err != nil {
+			return dao.Delivery{}, false, err
+		}
+	}
+	if tokens == nil {
+		tokens = map[string]any{}
+	}
+	tokens[templatestore.RecipientToken] = templatestore.Recipient{
+		Name:  member.Name,
+		Email: member.Email,
+		Phone: member.Phone,
+	}
+	payload.Data.Tokens = tokens
+
+	delivery, err := newDelivery(payload, msg)
+	if err != nil {
+		return dao.Delivery{}, false, err
+	}
+	delivery.UserID = &member.ID
+	return delivery, true, nil
+}
+
+// memberAddress is where the channel reaches the member, the broadcasts only go
+// to the verified emails and phones
+func memberAddress(channel notification.Channel, member dao.User) string {
+	switch channel {
+	case notification.EMAIL:
+		if member.EmailVerified {
+			return member.Email
+		}
+	case notification.SMS, notification.WHATSAPP:
+		if member.PhoneVerified {
+			return member.Phone
+		}
+	case notification.PUSH:
+		if member.ID != uuid.Nil {
+			return member.ID.String()
+		}
+	}
+	return ""
+}
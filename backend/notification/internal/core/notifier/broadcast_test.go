@@ -0,0 +1,214 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// fakeRepository keeps the broadcasts and pages the members, the members have no
+// verified address so nothing is sent to them
+type fakeRepository struct {
+	repository.Repository
+
+	mu         sync.Mutex
+	broadcasts map[uuid.UUID]dao.Broadcast
+	updates    []dao.Broadcast
+	members    []dao.User
+	// failAfter fails the listing of the members after the member
+	failAfter *uuid.UUID
+}
+
+func (r *fakeRepository) QueueBroadcast(ctx context.Context, broadcast dao.Broadcast) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.broadcasts[broadcast.ID]; !ok {
+		r.broadcasts[broadcast.ID] = broadcast
+	}
+	return nil
+}
+
+func (r *fakeRepository) ClaimBroadcast(ctx context.Context, now time.Time, lease time.Duration) (dao.Broadcast, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, broadcast := range r.broadcasts {
+		if broadcast.Status == dao.BroadcastQueued && !broadcast.NotBefore.After(now) {
+			broadcast.NotBefore = now.Add(lease)
+			broadcast.Attempts++
+			r.broadcasts[id] = broadcast
+			return broadcast, nil
+		}
+	}
+	return dao.Broadcast{}, mongo.ErrNoDocuments
+}
+
+func (r *fakeRepository) UpdateBroadcast(ctx context.Context, broadcast dao.Broadcast) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.broadcasts[broadcast.ID] = broadcast
+	r.updates = append(r.updates, broadcast)
+	return nil
+}
+
+func (r *fakeRepository) ListBusinessMembers(ctx context.Context, params repository.ListBusinessMembersParams) ([]dao.User, *uuid.UUID, error) {
+	start := 0
+	if params.After != nil {
+		if r.failAfter != nil && *params.After == *r.failAfter {
+			return nil, nil, errors.New("connection reset")
+		}
+		start = slices.IndexFunc(r.members, func(u dao.User) bool { return u.ID == *params.After }) + 1
+	}
+	end := min(start+params.Limit, len(r.members))
+	var next *uuid.UUID
+	if end < len(r.members) {
+		next = &r.members[end-1].ID
+	}
+	return r.members[start:end], next, nil
+}
+
+func newTestNotifier(t *testing.T, members int) (*NotifierImpl, *fakeRepository) {
+	t.Helper()
+	repo := &fakeRepository{broadcasts: map[uuid.UUID]dao.Broadcast{}}
+	for range members {
+		repo.members = append(repo.members, dao.User{ID: uuid.New()})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &NotifierImpl{
+		ctx:        ctx,
+		repository: repo,
+		broadcast:  config.Broadcast{BatchSize: 2, Rate: 10},
+		queued:     make(chan struct{}, 1),
+	}, repo
+}
+
+func testBroadcast() events.EventPayload[events.ManageNotificationEventPayload] {
+	businessID := uuid.New()
+	return events.EventPayload[events.ManageNotificationEventPayload]{
+		ID: uuid.New(),
+		Data: events.ManageNotificationEventPayload{
+			Event:      notification.USER_INVITED,
+			Kind:       notification.BROADCAST,
+			BusinessID: &businessID,
+			Payload:    []events.NotificationChannelPayload{{Channel: notification.EMAIL}},
+		},
+	}
+}
+
+func TestNotifyQueuesTheBroadcast(t *testing.T) {
+	n, repo := newTestNotifier(t, 0)
+	payload := testBroadcast()
+
+	// a redelivered event is queued once
+	for range 2 {
+		if err := n.Notify(context.Background(), payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.broadcasts) != 1 || repo.broadcasts[payload.ID].Status != dao.BroadcastQueued {
+		t.Fatalf("broadcasts = %+v", repo.broadcasts)
+	}
+	select {
+	case <-n.queued:
+	default:
+		t.Fatal("the worker was not woken")
+	}
+
+	payload = testBroadcast()
+	payload.Data.BusinessID = nil
+	if err := n.Notify(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.broadcasts) != 1 {
+		t.Fatal("a broadcast without a business was queued")
+	}
+}
+
+func TestRunBroadcastRenewsTheClaimAfterEveryBatch(t *testing.T) {
+	n, repo := newTestNotifier(t, 5)
+	payload := testBroadcast()
+	if err := n.Notify(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcast, err := repo.ClaimBroadcast(context.Background(), time.Now(), broadcastLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.runBroadcast(broadcast)
+
+	var after []uuid.UUID
+	for _, update := range repo.updates[:len(repo.updates)-1] {
+		after = append(after, *update.After)
+	}
+	if want := []uuid.UUID{repo.members[1].ID, repo.members[3].ID}; !slices.Equal(after, want) {
+		t.Fatalf("renewed after %v, want %v", after, want)
+	}
+	if got := repo.broadcasts[payload.ID]; got.Status != dao.BroadcastSent || got.Error != nil {
+		t.Fatalf("broadcast = %+v, want it sent", got)
+	}
+}
+
+func TestRunBroadcastRetriesFromTheFirstMember(t *testing.T) {
+	n, repo := newTestNotifier(t, 5)
+	repo.failAfter = &repo.members[1].ID
+	payload := testBroadcast()
+	if err := n.Notify(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= broadcastAttempts; attempt++ {
+		broadcast, err := repo.ClaimBroadcast(context.Background(), time.Now().Add(time.Duration(attempt)*time.Hour), broadcastLease)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.runBroadcast(broadcast)
+
+		got := repo.broadcasts[payload.ID]
+		if got.Error == nil || got.Attempts != attempt {
+			t.Fatalf("attempt %d: broadcast = %+v", attempt, got)
+		}
+		if attempt < broadcastAttempts && (got.Status != dao.BroadcastQueued || got.After != nil) {
+			t.Fatalf("attempt %d: broadcast = %+v, want it queued from the first member", attempt, got)
+		}
+	}
+	if got := repo.broadcasts[payload.ID]; got.Status != dao.BroadcastFailed {
+		t.Fatalf("broadcast = %+v, want it failed", got)
+	}
+}
+
+func TestRunBroadcastResumesAfterATakeOver(t *testing.T) {
+	n, repo := newTestNotifier(t, 5)
+	payload := testBroadcast()
+	if err := n.Notify(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	// the instance which claimed it stopped after the second member
+	broadcast := repo.broadcasts[payload.ID]
+	broadcast.After = &repo.members[1].ID
+	repo.broadcasts[payload.ID] = broadcast
+
+	broadcast, err := repo.ClaimBroadcast(context.Background(), time.Now(), broadcastLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.runBroadcast(broadcast)
+
+	if len(repo.updates) != 2 || *repo.updates[0].After != repo.members[3].ID {
+		t.Fatalf("updates = %+v, want it resumed after the second member", repo.updates)
+	}
+	if got := repo.broadcasts[payload.ID]; got.Status != dao.BroadcastSent {
+		t.Fatalf("broadcast = %+v, want it sent", got)
+	}
+}
@@ -0,0 +1,19 @@
+package notifier
+
+import (
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// deliveries counts the attempts by the status they left the delivery in,
+	// sent, failed, or suppressed and deferred by the preferences
+	deliveries = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_deliveries_total",
+		Help: "Delivery attempts, by channel and resulting status.",
+	}, []string{"channel", "status"})
+	deliveryReports = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_delivery_reports_total",
+		Help: "Delivered and bounced reports of the providers, by channel.",
+	}, []string{"channel", "status"})
+)
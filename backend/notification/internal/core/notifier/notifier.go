@@ -1,178 +1,897 @@
 package notifier
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"text/template"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/inbox"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/preference"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/mailer"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/smsprovider"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/webpush"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/whatsapp"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
 	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
+var log = logger.Package("notifier")
+
 type Notifier interface {
 	Notify(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload]) error
+	// Resend sends the delivery again whatever its status, unless its secret tokens were not stored
+	Resend(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error)
+	// HandleDeliveryReports records the statuses the provider of the channel reported through its webhook,
+	// signature is the one of the body sent along by the providers signing their webhooks
+	HandleDeliveryReports(ctx context.Context, channel notification.Channel, body []byte, signature string) error
+	// VerifyWebhook checks the token a provider sends when the webhook of the channel is registered
+	VerifyWebhook(ctx context.Context, channel notification.Channel, token string) error
+	// PushPublicKey is the key the browsers subscribe to web push with, empty when web push is off
+	PushPublicKey() string
+	// Start sends the deferred deliveries once they are due and fans out the queued broadcasts,
+	// until the context of the notifier is done
+	Start()
 }
 
 type NotifierImpl struct {
 	ctx           context.Context
 	mailer        mailer.Mailer
 	smsProvider   smsprovider.SMSProvider
+	whatsapp      whatsapp.Provider
+	webpush       webpush.Provider
+	hub           *inbox.Hub
 	templateStore templatestore.TemplateStorage
+	repository    repository.Repository
+	unsubscribe   *preference.Signer
+	// unsubscribeUrl is the public url the unsubscribe tokens are sent to
+	unsubscribeUrl string
+	broadcast      config.Broadcast
+	// paces are the tickers every channel of the broadcasts waits for, shared by all of them
+	paces map[notification.Channel]<-chan time.Time
+	// queued wakes sendBroadcasts when this instance queues a broadcast
+	queued chan struct{}
 }
 
-func New(ctx context.Context, env string, repo repository.Repository, config config.Mailer) Notifier {
-	return &NotifierImpl{
-		ctx:           ctx,
-		mailer:        mailer.New(env, config),
-		smsProvider:   smsprovider.New(env),
-		templateStore: templatestore.New(env, repo),
+func New(ctx context.Context, templateStore templatestore.TemplateStorage, repo repository.Repository, hub *inbox.Hub, mailerConfig config.Mailer, smsConfig config.Sms, whatsappConfig config.Whatsapp, pushConfig config.Push, unsubscribeConfig config.Unsubscribe, broadcastConfig config.Broadcast) (Notifier, error) {
+	mailer, err := mailer.New(mailerConfig)
+	if err != nil {
+		return nil, err
+	}
+	smsProvider, err := smsprovider.New(smsConfig)
+	if err != nil {
+		return nil, err
+	}
+	whatsappProvider, err := whatsapp.New(whatsappConfig)
+	if err != nil {
+		return nil, err
+	}
+	pushProvider, err := webpush.New(pushConfig)
+	if err != nil {
+		return nil, err
+	}
+	if broadcastConfig.BatchSize <= 0 || broadcastConfig.Rate <= 0 {
+		return nil, fmt.Errorf("broadcast batch size and rate must be positive")
 	}
+	paces := map[notification.Channel]<-chan time.Time{}
+	for _, channel := range []notification.Channel{notification.EMAIL, notification.SMS, notification.PUSH, notification.WHATSAPP} {
+		paces[channel] = time.NewTicker(time.Second / time.Duration(broadcastConfig.Rate)).C
+	}
+	return &NotifierImpl{
+		ctx:            ctx,
+		mailer:         mailer,
+		smsProvider:    smsProvider,
+		whatsapp:       whatsappProvider,
+		webpush:        pushProvider,
+		hub:            hub,
+		templateStore:  templateStore,
+		repository:     repo,
+		unsubscribe:    preference.NewSigner(unsubscribeConfig.Secret, unsubscribeConfig.Lifetime.Duration()),
+		unsubscribeUrl: unsubscribeConfig.Url,
+		broadcast:      broadcastConfig,
+		paces:          paces,
+		queued:         make(chan struct{}, 1),
+	}, nil
 }
 
+// deferredInterval is how often the deferred deliveries which are due are looked for
+const deferredInterval = time.Minute
+
+var (
+	// errUndeliverable marks the failures a retry can not fix, they are recorded
+	// on the delivery without failing the event
+	errUndeliverable = errors.New("undeliverable")
+	// ErrReportsNotSupported is returned for the channels without delivery reports
+	ErrReportsNotSupported = errors.New("delivery reports are not supported")
+	// ErrUnverifiedWebhook is returned for a webhook request the provider did not send
+	ErrUnverifiedWebhook = errors.New("webhook could not be verified")
+	// ErrTokensRedacted is returned when resending a delivery whose secret tokens were not stored
+	ErrTokensRedacted = errors.New("the secret tokens of the delivery were not stored")
+)
+
+// sentMessage is what a channel handler rendered and sent
+type sentMessage struct {
+	ids     []string
+	subject string
+	body    string
+	// recipients maps the recipients sent to to their message ids, for the channels
+	// sending a message per recipient. it is kept when the send fails half way.
+	recipients map[string]string
+}
+
+// Notify sends the notification through every channel and records a delivery for each.
+// a failed channel fails the event so that it is redelivered, the channels which were
+// sent already are skipped on the redelivery.
 func (n *NotifierImpl) Notify(ctx context.Context, payload events.EventPayload[events.ManageNotificationEventPayload]) error {
+	if payload.Data.Kind == notification.BROADCAST {
+		return n.queueBroadcast(ctx, payload)
+	}
+	var errs []error
 	for _, msg := range payload.Data.Payload {
-		switch msg.Channel {
-		case notification.EMAIL:
-			err := n.handleEmailNotification(ctx, payload.Data.Event, payload.Data.Kind, msg, payload.Data.Tokens)
+		delivery, err := newDelivery(payload, msg)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to create delivery")
+			return err
+		}
+		if err := n.send(ctx, delivery, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send delivers the delivery unless an earlier delivery of the event did already, waiting
+// for the pace when it is set
+func (n *NotifierImpl) send(ctx context.Context, delivery dao.Delivery, pace <-chan time.Time) error {
+	// the secret tokens are not stored, a failed send gets them back with the redelivered event
+	tokens := delivery.Tokens
+	var err error
+	delivery.Tokens, delivery.TokensRedacted, err = notification.RedactTokens(tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to redact tokens")
+		return err
+	}
+	delivery, err = n.repository.FindOrCreateDelivery(ctx, delivery)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find or create delivery")
+		return err
+	}
+	delivery.Tokens = tokens
+	// a deferred delivery is sent by the scheduler, see Start
+	switch delivery.Status {
+	case dao.DeliverySent, dao.DeliverySuppressed, dao.DeliveryDeferred:
+		log.Ctx(ctx).Info().Str("delivery", delivery.ID.String()).Str("status", string(delivery.Status)).Msg("delivery already handled, skipping")
+		return nil
+	}
+	if pace != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pace:
+		}
+	}
+	_, err = n.deliver(ctx, delivery)
+	return err
+}
+
+func (n *NotifierImpl) Resend(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error) {
+	if delivery.TokensRedacted {
+		return delivery, ErrTokensRedacted
+	}
+	// a resend goes to every recipient again
+	delivery.RecipientMessageIDs = nil
+	return n.deliver(ctx, delivery)
+}
+
+func (n *NotifierImpl) PushPublicKey() string {
+	return n.webpush.PublicKey()
+}
+
+func (n *NotifierImpl) Start() {
+	go n.sendDeferred()
+	go n.sendBroadcasts()
+}
+
+// sendDeferred claims the deliveries whose quiet hours are over and sends them. a failed
+// one is not retried as its event was handled already, it can be resent from the log.
+func (n *NotifierImpl) sendDeferred() {
+	ticker := time.NewTicker(deferredInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for n.ctx.Err() == nil {
+			delivery, err := n.repository.ClaimDeferredDelivery(n.ctx, time.Now())
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				break
+			}
 			if err != nil {
+				log.Error().Err(err).Msg("failed to claim deferred delivery")
+				break
+			}
+			if _, err := n.sendDeferredDelivery(delivery); err != nil {
+				log.Error().Err(err).Str("delivery", delivery.ID.String()).Msg("failed to send deferred delivery")
+			}
+		}
+	}
+}
+
+// sendDeferredDelivery sends a claimed deferred delivery, one deferred with its secret tokens
+// redacted fails rather than going out without them
+func (n *NotifierImpl) sendDeferredDelivery(delivery dao.Delivery) (dao.Delivery, error) {
+	if !delivery.TokensRedacted {
+		return n.deliver(n.ctx, delivery)
+	}
+	reason := ErrTokensRedacted.Error()
+	delivery.Status, delivery.Error, delivery.UpdatedAt = dao.DeliveryFailed, &reason, time.Now()
+	if err := n.repository.UpdateDelivery(n.ctx, delivery); err != nil {
+		return delivery, err
+	}
+	deliveries.WithLabelValues(string(delivery.Channel), string(delivery.Status)).Inc()
+	return delivery, ErrTokensRedacted
+}
+
+func (n *NotifierImpl) HandleDeliveryReports(ctx context.Context, channel notification.Channel, body []byte, signature string) error {
+	switch channel {
+	case notification.SMS:
+		reports, err := n.smsProvider.ParseDeliveryReports(body)
+		if errors.Is(err, smsprovider.ErrReportsNotSupported) {
+			return ErrReportsNotSupported
+		}
+		if err != nil {
+			return err
+		}
+		for _, report := range reports {
+			if err := n.setDeliveryStatus(ctx, channel, report.MessageID, report.Delivered, report.Reason); err != nil {
 				return err
 			}
-		case notification.SMS:
-			err := n.handleSMSSNotification(ctx, payload.Data.Event, payload.Data.Kind, msg, payload.Data.Tokens)
-			if err != nil {
+		}
+		return nil
+	case notification.WHATSAPP:
+		webhook, err := n.whatsapp.ParseWebhook(body, signature)
+		if err != nil {
+			return whatsappWebhookError(err)
+		}
+		for _, status := range webhook.Statuses {
+			if err := n.setDeliveryStatus(ctx, channel, status.MessageID, status.Delivered, status.Reason); err != nil {
 				return err
 			}
 		}
+		// recipients opt out by replying stop and back in by replying start
+		if err := n.setOptIns(ctx, channel, webhook.OptOuts, false); err != nil {
+			return err
+		}
+		return n.setOptIns(ctx, channel, webhook.OptIns, true)
+	}
+	return ErrReportsNotSupported
+}
+
+func (n *NotifierImpl) VerifyWebhook(ctx context.Context, channel notification.Channel, token string) error {
+	if channel != notification.WHATSAPP {
+		return ErrReportsNotSupported
+	}
+	return whatsappWebhookError(n.whatsapp.VerifyWebhook(token))
+}
+
+func whatsappWebhookError(err error) error {
+	switch {
+	case errors.Is(err, whatsapp.ErrWebhooksNotSupported):
+		return ErrReportsNotSupported
+	case errors.Is(err, whatsapp.ErrUnverifiedWebhook):
+		return ErrUnverifiedWebhook
+	}
+	return err
+}
+
+func (n *NotifierImpl) setOptIns(ctx context.Context, channel notification.Channel, recipients []string, optedIn bool) error {
+	for _, recipient := range recipients {
+		now := time.Now()
+		_, err := n.repository.SetOptIn(ctx, dao.OptIn{
+			Channel:   channel,
+			Recipient: whatsapp.NormalizePhone(recipient),
+			OptedIn:   optedIn,
+			Source:    "webhook",
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("recipient", recipient).Msg("failed to set opt-in")
+			return err
+		}
 	}
 	return nil
 }
 
-func (n *NotifierImpl) handleEmailNotification(ctx context.Context, event notification.Event, kind notification.Kind, data events.NotificationChannelPayload, tokens any) error {
-	var emailData notification.EmailData
-	if data.Data == nil {
-		logger.Error().Msg("email data is nil")
-		return nil
+func (n *NotifierImpl) setDeliveryStatus(ctx context.Context, channel notification.Channel, messageID string, delivered bool, reason string) error {
+	params := repository.SetDeliveryStatusParams{
+		Channel:           channel,
+		ProviderMessageID: messageID,
+		Status:            dao.DeliveryDelivered,
 	}
-	dbByte, _ := json.Marshal(data.Data)
-	err := json.Unmarshal(dbByte, &emailData)
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to unmarshal email channel")
+	if !delivered {
+		params.Status, params.Error = dao.DeliveryBounced, &reason
+	}
+	if err := n.repository.SetDeliveryStatus(ctx, params); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("message", messageID).Msg("failed to set delivery status")
 		return err
 	}
+	deliveryReports.WithLabelValues(string(channel), string(params.Status)).Inc()
+	return nil
+}
 
-	htmlTemplate, err := n.templateStore.FindTemplate(n.ctx, templatestore.FindTemplateParams{
-		Event:    event,
-		Channel:  data.Channel,
-		Locale:   "en",
-		Scope:    "default",
-		Mimetype: "text/html",
-	})
+func newDelivery(payload events.EventPayload[events.ManageNotificationEventPayload], msg events.NotificationChannelPayload) (dao.Delivery, error) {
+	data, err := json.Marshal(msg.Data)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to find html template")
-		return err
+		return dao.Delivery{}, err
+	}
+	tokens, err := json.Marshal(payload.Data.Tokens)
+	if err != nil {
+		return dao.Delivery{}, err
+	}
+	// every channel data carries the recipients the same way
+	var recipients struct {
+		To  []string `json:"to"`
+		CC  []string `json:"cc"`
+		BCC []string `json:"bcc"`
+	}
+	if msg.Data != nil {
+		if err := json.Unmarshal(data, &recipients); err != nil {
+			return dao.Delivery{}, err
+		}
+	}
+	now := time.Now()
+	return dao.Delivery{
+		ID:         uuid.New(),
+		EventID:    payload.ID,
+		Event:      payload.Data.Event,
+		Kind:       payload.Data.Kind,
+		Channel:    msg.Channel,
+		BusinessID: payload.Data.BusinessID,
+		Recipients: append(append(recipients.To, recipients.CC...), recipients.BCC...),
+		Data:       data,
+		Tokens:     tokens,
+		Status:     dao.DeliveryQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// deliver renders and sends the delivery, then records the outcome.
+// the returned error is the one of the send.
+func (n *NotifierImpl) deliver(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error) {
+	// the preferences are checked on every attempt, they may have changed in the meantime
+	if delivery.Event.Category() != notification.TRANSACTIONAL {
+		held, err := n.applyPreferences(ctx, &delivery)
+		if held {
+			deliveries.WithLabelValues(string(delivery.Channel), string(delivery.Status)).Inc()
+		}
+		if err != nil || held {
+			return delivery, err
+		}
+	}
+	var (
+		sent sentMessage
+		err  error
+	)
+	var tokens any
+	if err = json.Unmarshal(delivery.Tokens, &tokens); err == nil {
+		tokens = n.withBranding(ctx, delivery, tokens)
+		target := n.templateTarget(ctx, delivery)
+		switch delivery.Channel {
+		case notification.EMAIL:
+			sent, err = n.handleEmailNotification(ctx, delivery, target, tokens)
+		case notification.SMS:
+			sent, err = n.handleSMSSNotification(ctx, delivery, target, tokens)
+		case notification.WHATSAPP:
+			sent, err = n.handleWhatsappNotification(ctx, delivery, target, tokens)
+		case notification.PUSH:
+			sent, err = n.handlePushNotification(ctx, delivery, target, tokens)
+		default:
+			log.Ctx(ctx).Warn().Str("channel", string(delivery.Channel)).Msg("unsupported channel")
+			err = fmt.Errorf("%w: unsupported channel %s", errUndeliverable, delivery.Channel)
+		}
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	if sent.subject != "" || sent.body != "" {
+		hash := sha256.Sum256([]byte(sent.body))
+		delivery.Subject, delivery.BodyHash = sent.subject, hex.EncodeToString(hash[:])
+	}
+	if sent.recipients != nil {
+		delivery.RecipientMessageIDs = sent.recipients
+		// the reports of the messages sent before a failure are recorded as well
+		delivery.ProviderMessageIDs = slices.Collect(maps.Values(sent.recipients))
+	}
+	if err != nil {
+		reason := err.Error()
+		delivery.Status, delivery.Error = dao.DeliveryFailed, &reason
+	} else {
+		delivery.Status, delivery.Error = dao.DeliverySent, nil
+		delivery.ProviderMessageIDs, delivery.SentAt = sent.ids, &now
+	}
+	// the message is out at this point, failing here would only send it twice
+	if uerr := n.repository.UpdateDelivery(ctx, delivery); uerr != nil {
+		log.Ctx(ctx).Error().Err(uerr).Str("delivery", delivery.ID.String()).Msg("failed to update delivery")
+	}
+	deliveries.WithLabelValues(string(delivery.Channel), string(delivery.Status)).Inc()
+	if errors.Is(err, errUndeliverable) {
+		return delivery, nil
+	}
+	return delivery, err
+}
+
+// applyPreferences leaves the recipients who turned the notification off out of the channel
+// data, which is not stored. the delivery is suppressed when no recipient is left, and an sms
+// or whatsapp is deferred until the quiet hours of all its recipients are over, unless its
+// secret tokens were redacted, the scheduler would send it without them.
+func (n *NotifierImpl) applyPreferences(ctx context.Context, delivery *dao.Delivery) (bool, error) {
+	now := time.Now()
+	category := delivery.Event.Category()
+	muted := map[string]bool{}
+	var notBefore time.Time
+	for _, recipient := range delivery.Recipients {
+		user, err := n.repository.FindUserByRecipient(ctx, delivery.Channel, recipient)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// only the users have preferences
+			continue
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("recipient", recipient).Msg("failed to find user of recipient")
+			return false, err
+		}
+		preferences, err := n.repository.FindPreferences(ctx, user.ID, delivery.BusinessID)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("user", user.ID.String()).Msg("failed to find preferences")
+			return false, err
+		}
+		resolved := preference.Resolve(preferences, delivery.BusinessID)
+		if !resolved.Allowed(category, delivery.Channel) {
+			muted[recipient] = true
+			continue
+		}
+		if !preference.QuietChannel(delivery.Channel) || delivery.TokensRedacted {
+			continue
+		}
+		if until, quiet := preference.QuietUntil(resolved.QuietHours(), now); quiet && until.After(notBefore) {
+			notBefore = until
+		}
+	}
+
+	switch {
+	case len(delivery.Recipients) > 0 && !slices.ContainsFunc(delivery.Recipients, func(r string) bool { return !muted[r] }):
+		delivery.Status = dao.DeliverySuppressed
+	case !notBefore.IsZero():
+		delivery.Status, delivery.NotBefore = dao.DeliveryDeferred, &notBefore
+	default:
+		if len(muted) > 0 {
+			data, err := withoutRecipients(delivery.Data, muted)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("failed to remove muted recipients")
+				return false, err
+			}
+			delivery.Data = data
+		}
+		return false, nil
+	}
+	delivery.Error, delivery.UpdatedAt = nil, now
+	if err := n.repository.UpdateDelivery(ctx, *delivery); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("delivery", delivery.ID.String()).Msg("failed to update delivery")
+		return true, err
+	}
+	log.Ctx(ctx).Info().Str("delivery", delivery.ID.String()).Str("status", string(delivery.Status)).Msg("delivery held by the preferences")
+	return true, nil
+}
+
+// withoutRecipients removes the recipients from the channel data, every channel
+// data carries them the same way
+func withoutRecipients(data []byte, recipients map[string]bool) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"to", "cc", "bcc"} {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		list = slices.DeleteFunc(list, func(r string) bool { return recipients[r] })
+		encoded, err := json.Marshal(list)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = encoded
 	}
+	return json.Marshal(fields)
+}
 
-	textTemplate, err := n.templateStore.FindTemplate(n.ctx, templatestore.FindTemplateParams{
-		Event:    event,
-		Channel:  data.Channel,
-		Locale:   "en",
-		Scope:    "default",
-		Mimetype: "text/plain",
-	})
+// unsubscribeURL is the one-click unsubscribe link of the only recipient of the email when
+// they are a user. it is empty for the notifications which can't be turned off and for the
+// emails to several recipients, every one of them would get the link of the same user.
+func (n *NotifierImpl) unsubscribeURL(ctx context.Context, delivery dao.Delivery, email notification.EmailData) string {
+	category := delivery.Event.Category()
+	if category == notification.TRANSACTIONAL || len(email.To) != 1 || len(email.CC) > 0 || len(email.BCC) > 0 {
+		return ""
+	}
+	user, err := n.repository.FindUserByRecipient(ctx, delivery.Channel, email.To[0])
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to find text template")
+		return ""
 	}
-	body, err := n.compileTemplate(htmlTemplate.Body, tokens)
+	token, err := n.unsubscribe.Sign(preference.Unsubscribe{
+		UserID:     user.ID,
+		BusinessID: delivery.BusinessID,
+		Category:   category,
+		Channel:    delivery.Channel,
+	}, time.Now())
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to compile html template")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to sign unsubscribe token")
+		return ""
+	}
+	return n.unsubscribeUrl + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// templateTarget picks the templates of the business of the delivery, in the language of
+// its first recipient who is a user. a message to several recipients is rendered once.
+func (n *NotifierImpl) templateTarget(ctx context.Context, delivery dao.Delivery) templatestore.FindTemplateParams {
+	target := templatestore.FindTemplateParams{
+		Event:   delivery.Event,
+		Channel: delivery.Channel,
+		Locale:  templatestore.DefaultLocale,
+		Scope:   templatestore.DefaultScope,
+	}
+	if delivery.BusinessID != nil {
+		target.Scope = delivery.BusinessID.String()
+	}
+	for _, recipient := range delivery.Recipients {
+		user, err := n.repository.FindUserByRecipient(ctx, delivery.Channel, recipient)
+		if err == nil && user.Locale != "" {
+			target.Locale = user.Locale
+			break
+		}
+	}
+	return target
+}
+
+// withBranding adds the business the notification is sent on behalf of to the tokens
+func (n *NotifierImpl) withBranding(ctx context.Context, delivery dao.Delivery, tokens any) any {
+	values, ok := tokens.(map[string]any)
+	if !ok {
+		return tokens
+	}
+	if _, ok := values[templatestore.BrandingToken]; ok {
+		return tokens
+	}
+	branding := templatestore.Branding{Name: "BillBharat"}
+	if delivery.BusinessID != nil {
+		business, err := n.repository.FindBusiness(ctx, *delivery.BusinessID)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("business_id", delivery.BusinessID.String()).Msg("failed to find business for branding")
+		} else {
+			branding.Name = business.Name
+			if business.Logo != nil {
+				branding.Logo = *business.Logo
+			}
+		}
+	}
+	values[templatestore.BrandingToken] = branding
+	return values
+}
+
+// findTemplate resolves the template of the target, falling back to the default scope and locale
+func (n *NotifierImpl) findTemplate(ctx context.Context, target templatestore.FindTemplateParams, mimetype string) (dao.Template, error) {
+	target.Mimetype = mimetype
+	return templatestore.Resolve(ctx, n.templateStore, target)
+}
+
+func (n *NotifierImpl) handleEmailNotification(ctx context.Context, delivery dao.Delivery, target templatestore.FindTemplateParams, tokens any) (sentMessage, error) {
+	var emailData notification.EmailData
+	err := json.Unmarshal(delivery.Data, &emailData)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to unmarshal email channel")
+		return sentMessage{}, err
+	}
+	if len(emailData.To) == 0 {
+		log.Ctx(ctx).Error().Msg("email has no recipient")
+		return sentMessage{}, fmt.Errorf("%w: email has no recipient", errUndeliverable)
+	}
+	// rfc 8058, the mail clients show an unsubscribe button which posts to the link
+	var headers map[string]string
+	if link := n.unsubscribeURL(ctx, delivery, emailData); link != "" {
+		if values, ok := tokens.(map[string]any); ok {
+			values[templatestore.UnsubscribeToken] = link
+		}
+		headers = map[string]string{
+			"List-Unsubscribe":      "<" + link + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+
+	htmlTemplate, err := n.findTemplate(ctx, target, "text/html")
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find html template")
+		return sentMessage{}, err
+	}
+
+	layouts, err := templatestore.Layouts(ctx, n.templateStore, target)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find email layouts")
+		return sentMessage{}, err
+	}
+	body, err := templatestore.RenderHTML(layouts, htmlTemplate.Body, tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile html template")
+		return sentMessage{}, err
 	}
 
 	subject, err := n.compileTemplate(htmlTemplate.Subject, tokens)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to compile html template")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile html template")
+		return sentMessage{}, err
+	}
+	// the text alternative is generated from the html when the template has none
+	textTemplate, err := n.findTemplate(ctx, target, "text/plain")
+	if err != nil && !errors.Is(err, templatestore.ErrTemplateNotFound) {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find text template")
+		return sentMessage{}, err
 	}
-	var textBody *string = nil
-	if textTemplate.Body != "" {
-		tb, err := n.compileTemplate(textTemplate.Body, tokens)
+	textBody := templatestore.HTMLToText(body)
+	if err == nil {
+		textBody, err = n.compileTemplate(textTemplate.Body, tokens)
 		if err != nil {
-			logger.Error().Err(err).Msg("failed to compile text template")
-			return err
+			log.Ctx(ctx).Error().Err(err).Msg("failed to compile text template")
+			return sentMessage{}, err
 		}
-		textBody = &tb
 	}
 
-	err = n.mailer.Send(emailData, subject, body, textBody)
+	id, err := n.mailer.Send(ctx, emailData, subject, body, &textBody, headers)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to send email")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to send email")
+		return sentMessage{subject: subject, body: body}, err
 	}
-	return nil
+	return sentMessage{ids: []string{id}, subject: subject, body: body}, nil
 }
 
-func (n *NotifierImpl) handleSMSSNotification(ctx context.Context, event notification.Event, kind notification.Kind, data events.NotificationChannelPayload, tokens any) error {
+func (n *NotifierImpl) handleSMSSNotification(ctx context.Context, delivery dao.Delivery, target templatestore.FindTemplateParams, tokens any) (sentMessage, error) {
 	var smsData notification.SMSData
-	if data.Data == nil {
-		logger.Error().Msg("sms data is nil")
-		return nil
-	}
-	dbByte, _ := json.Marshal(data.Data)
-	err := json.Unmarshal(dbByte, &smsData)
+	err := json.Unmarshal(delivery.Data, &smsData)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to unmarshal sms channel")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to unmarshal sms channel")
+		return sentMessage{}, err
+	}
+	if len(smsData.To) == 0 {
+		log.Ctx(ctx).Error().Msg("sms has no recipient")
+		return sentMessage{}, fmt.Errorf("%w: sms has no recipient", errUndeliverable)
 	}
 
-	smsTemplate, err := n.templateStore.FindTemplate(n.ctx, templatestore.FindTemplateParams{
-		Event:    event,
-		Channel:  data.Channel,
-		Locale:   "en",
-		Scope:    "default",
-		Mimetype: "text/plain",
-	})
+	smsTemplate, err := n.findTemplate(ctx, target, "text/plain")
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to find sms template")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find sms template")
+		return sentMessage{}, err
 	}
 
 	body, err := n.compileTemplate(smsTemplate.Body, tokens)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to compile sms template")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile sms template")
+		return sentMessage{}, err
 	}
 	subject, err := n.compileTemplate(smsTemplate.Subject, tokens)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to compile sms subject")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile sms subject")
+		return sentMessage{}, err
 	}
 
-	err = n.smsProvider.Send(smsData, subject, body)
+	ids, err := n.smsProvider.Send(ctx, smsData, body, smsTemplate.DltTemplateID)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to send sms")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to send sms")
+		return sentMessage{subject: subject, body: body}, err
 	}
-	return nil
+	return sentMessage{ids: ids, subject: subject, body: body}, nil
 }
 
-func (n *NotifierImpl) compileTemplate(tmpl string, tokens any) (string, error) {
-	template, err := template.New("any").Parse(tmpl)
+// handleWhatsappNotification sends the approved template to every recipient who opted in,
+// whatsapp only takes a single recipient per message
+func (n *NotifierImpl) handleWhatsappNotification(ctx context.Context, delivery dao.Delivery, target templatestore.FindTemplateParams, tokens any) (sentMessage, error) {
+	var whatsappData notification.WhatsappMessageData
+	err := json.Unmarshal(delivery.Data, &whatsappData)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to parse sms template")
-		return "", err
+		log.Ctx(ctx).Error().Err(err).Msg("failed to unmarshal whatsapp channel")
+		return sentMessage{}, err
+	}
+	recipients := make([]string, 0, len(whatsappData.To))
+	for _, to := range whatsappData.To {
+		recipients = append(recipients, whatsapp.NormalizePhone(to))
+	}
+	optedIn, err := n.repository.FindOptedIn(ctx, delivery.Channel, recipients)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find opted in recipients")
+		return sentMessage{}, err
+	}
+	if len(optedIn) == 0 {
+		log.Ctx(ctx).Warn().Strs("to", whatsappData.To).Msg("no whatsapp recipient opted in")
+		return sentMessage{}, fmt.Errorf("%w: no whatsapp recipient opted in", errUndeliverable)
+	}
+
+	whatsappTemplate, err := n.findTemplate(ctx, target, "text/plain")
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find whatsapp template")
+		return sentMessage{}, err
+	}
+	if whatsappTemplate.WhatsappTemplate == "" {
+		return sentMessage{}, fmt.Errorf("%w: template of %s has no approved whatsapp template", errUndeliverable, delivery.Event)
+	}
+
+	body, err := n.compileTemplate(whatsappTemplate.Body, tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile whatsapp template")
+		return sentMessage{}, err
+	}
+	subject, err := n.compileTemplate(whatsappTemplate.Subject, tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile whatsapp subject")
+		return sentMessage{}, err
 	}
-	body := bytes.Buffer{}
-	err = template.Execute(&body, tokens)
+	parameters := make([]string, 0, len(whatsappTemplate.WhatsappParameters))
+	for _, parameter := range whatsappTemplate.WhatsappParameters {
+		p, err := n.compileTemplate(parameter, tokens)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to compile whatsapp parameter")
+			return sentMessage{}, err
+		}
+		parameters = append(parameters, p)
+	}
+
+	message := whatsapp.Template{
+		Name:       whatsappTemplate.WhatsappTemplate,
+		Language:   whatsappTemplate.Locale,
+		Parameters: parameters,
+		Document:   whatsappData.Document,
+	}
+	// the recipients sent to by an earlier attempt are skipped
+	sent := sentMessage{subject: subject, body: body, recipients: maps.Clone(delivery.RecipientMessageIDs)}
+	if sent.recipients == nil {
+		sent.recipients = map[string]string{}
+	}
+	for _, to := range optedIn {
+		id, ok := sent.recipients[to]
+		if !ok {
+			id, err = n.whatsapp.Send(ctx, to, message)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("to", to).Msg("failed to send whatsapp message")
+				return sent, err
+			}
+			sent.recipients[to] = id
+		}
+		sent.ids = append(sent.ids, id)
+	}
+	return sent, nil
+}
+
+// handlePushNotification puts the notification in the inbox of every user, streams it to
+// their open tabs and pushes it to their subscribed browsers. the inbox is the record,
+// a failed web push is only logged as retrying would push it again to the others.
+func (n *NotifierImpl) handlePushNotification(ctx context.Context, delivery dao.Delivery, target templatestore.FindTemplateParams, tokens any) (sentMessage, error) {
+	var pushData notification.PushMessageData
+	err := json.Unmarshal(delivery.Data, &pushData)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to unmarshal push channel")
+		return sentMessage{}, err
+	}
+	userIDs := make([]uuid.UUID, 0, len(pushData.To))
+	for _, to := range pushData.To {
+		userID, err := uuid.Parse(to)
+		if err != nil {
+			log.Ctx(ctx).Warn().Str("to", to).Msg("push recipient is not a user id")
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) == 0 {
+		log.Ctx(ctx).Error().Msg("push has no recipient")
+		return sentMessage{}, fmt.Errorf("%w: push has no recipient", errUndeliverable)
+	}
+
+	pushTemplate, err := n.findTemplate(ctx, target, "text/plain")
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to find push template")
+		return sentMessage{}, err
+	}
+	body, err := n.compileTemplate(pushTemplate.Body, tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile push template")
+		return sentMessage{}, err
+	}
+	title, err := n.compileTemplate(pushTemplate.Subject, tokens)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to compile push title")
+		return sentMessage{}, err
+	}
+
+	sent := sentMessage{subject: title, body: body}
+	for _, userID := range userIDs {
+		item, inserted, err := n.repository.CreateInboxItem(ctx, dao.InboxItem{
+			ID:         uuid.New(),
+			DeliveryID: delivery.ID,
+			UserID:     userID,
+			BusinessID: delivery.BusinessID,
+			Event:      delivery.Event,
+			Title:      title,
+			Body:       body,
+			Link:       pushData.Link,
+			CreatedAt:  time.Now(),
+		})
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("user", userID.String()).Msg("failed to create inbox item")
+			return sent, err
+		}
+		sent.ids = append(sent.ids, item.ID.String())
+		// the users pushed to by an earlier attempt have the item already
+		if !inserted {
+			continue
+		}
+		n.hub.Publish(item)
+		n.sendWebPush(ctx, item)
+	}
+	return sent, nil
+}
+
+func (n *NotifierImpl) sendWebPush(ctx context.Context, item dao.InboxItem) {
+	subscriptions, err := n.repository.FindPushSubscriptions(ctx, item.UserID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("user", item.UserID.String()).Msg("failed to find push subscriptions")
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	payload, err := json.Marshal(item)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal web push")
+		return
+	}
+	for _, subscription := range subscriptions {
+		err := n.webpush.Send(webpush.Subscription{
+			Endpoint: subscription.Endpoint,
+			P256dh:   subscription.P256dh,
+			Auth:     subscription.Auth,
+		}, payload)
+		if errors.Is(err, webpush.ErrSubscriptionGone) {
+			if err := n.repository.DeletePushSubscription(ctx, subscription.Endpoint); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("failed to delete push subscription")
+			}
+			continue
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("subscription", subscription.ID.String()).Msg("failed to send web push")
+		}
+	}
+}
+
+func (n *NotifierImpl) compileTemplate(tmpl string, tokens any) (string, error) {
+	body, err := templatestore.Render(tmpl, tokens)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to execute sms template")
+		log.Error().Err(err).Msg("failed to render template")
 		return "", err
 	}
-	return body.String(), nil
+	return body, nil
 }
@@ -0,0 +1,190 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/inbox"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/webpush"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/whatsapp"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// quietRepository keeps the deliveries of a user whose quiet hours are now
+type quietRepository struct {
+	repository.Repository
+
+	user       dao.User
+	quiet      *dao.QuietHours
+	deliveries map[uuid.UUID]dao.Delivery
+}
+
+func (r *quietRepository) FindOrCreateDelivery(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error) {
+	for _, stored := range r.deliveries {
+		if stored.EventID == delivery.EventID && stored.Channel == delivery.Channel {
+			return stored, nil
+		}
+	}
+	r.deliveries[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (r *quietRepository) UpdateDelivery(ctx context.Context, delivery dao.Delivery) error {
+	r.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (r *quietRepository) ClaimDeferredDelivery(ctx context.Context, now time.Time) (dao.Delivery, error) {
+	for id, delivery := range r.deliveries {
+		if delivery.Status == dao.DeliveryDeferred {
+			delivery.Status = dao.DeliveryQueued
+			r.deliveries[id] = delivery
+			return delivery, nil
+		}
+	}
+	return dao.Delivery{}, mongo.ErrNoDocuments
+}
+
+func (r *quietRepository) FindUserByRecipient(ctx context.Context, channel notification.Channel, recipient string) (dao.User, error) {
+	return r.user, nil
+}
+
+func (r *quietRepository) FindPreferences(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) ([]dao.Preference, error) {
+	return []dao.Preference{{UserID: userID, QuietHours: r.quiet}}, nil
+}
+
+func (r *quietRepository) FindOptedIn(ctx context.Context, channel notification.Channel, recipients []string) ([]string, error) {
+	return recipients, nil
+}
+
+func (r *quietRepository) FindBusiness(ctx context.Context, id uuid.UUID) (dao.Business, error) {
+	return dao.Business{}, mongo.ErrNoDocuments
+}
+
+type invitationTemplates struct{}
+
+func (invitationTemplates) CreateTemplate(ctx context.Context, template dao.Template) (dao.Template, error) {
+	return template, nil
+}
+
+func (invitationTemplates) FindTemplate(ctx context.Context, params templatestore.FindTemplateParams) (dao.Template, error) {
+	return dao.Template{Body: "{{.InvitationURL}}", WhatsappTemplate: "user_invited", WhatsappParameters: []string{"{{.InvitationURL}}"}}, nil
+}
+
+type recordingWhatsapp struct {
+	whatsapp.Provider
+	sent []whatsapp.Template
+}
+
+func (p *recordingWhatsapp) Send(ctx context.Context, to string, template whatsapp.Template) (string, error) {
+	p.sent = append(p.sent, template)
+	return uuid.NewString(), nil
+}
+
+func TestSendDoesNotDeferTheRedactedDeliveries(t *testing.T) {
+	// quiet from an hour ago to an hour from now
+	now := time.Now().UTC()
+	repo := &quietRepository{
+		user:       dao.User{ID: uuid.New()},
+		quiet:      &dao.QuietHours{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), Timezone: "UTC"},
+		deliveries: map[uuid.UUID]dao.Delivery{},
+	}
+	provider := &recordingWhatsapp{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := &NotifierImpl{ctx: ctx, repository: repo, templateStore: invitationTemplates{}, whatsapp: provider}
+
+	data, _ := json.Marshal(notification.NewWhatsappMessage("919876543210"))
+	tokens, _ := json.Marshal(map[string]string{"InvitationURL": "https://app.example.com/invitations/secret"})
+	delivery := dao.Delivery{
+		ID: uuid.New(), EventID: uuid.New(), Event: notification.USER_INVITED, Channel: notification.WHATSAPP,
+		Recipients: []string{"919876543210"}, Data: data, Tokens: tokens, Status: dao.DeliveryQueued,
+	}
+	if err := n.send(ctx, delivery, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.sent) != 1 || provider.sent[0].Parameters[0] != "https://app.example.com/invitations/secret" {
+		t.Fatalf("sent %+v, want the invitation with its url", provider.sent)
+	}
+	if got := repo.deliveries[delivery.ID]; got.Status != dao.DeliverySent {
+		t.Fatalf("delivery = %+v, want it sent", got)
+	}
+
+	// one deferred before the fix fails rather than going out without its url
+	delivery = repo.deliveries[delivery.ID]
+	delivery.Status = dao.DeliveryDeferred
+	repo.deliveries[delivery.ID] = delivery
+	claimed, err := repo.ClaimDeferredDelivery(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.sendDeferredDelivery(claimed); !errors.Is(err, ErrTokensRedacted) {
+		t.Fatalf("err = %v, want %v", err, ErrTokensRedacted)
+	}
+	if got := repo.deliveries[delivery.ID]; len(provider.sent) != 1 || got.Status != dao.DeliveryFailed {
+		t.Fatalf("delivery = %+v, want it failed", got)
+	}
+}
+
+// inboxRepository keeps the inbox items, failing the creation for failUser
+type inboxRepository struct {
+	repository.Repository
+
+	items    map[[2]uuid.UUID]dao.InboxItem
+	failUser *uuid.UUID
+}
+
+func (r *inboxRepository) CreateInboxItem(ctx context.Context, item dao.InboxItem) (dao.InboxItem, bool, error) {
+	if r.failUser != nil && *r.failUser == item.UserID {
+		return dao.InboxItem{}, false, errors.New("connection reset")
+	}
+	key := [2]uuid.UUID{item.DeliveryID, item.UserID}
+	if stored, ok := r.items[key]; ok {
+		return stored, false, nil
+	}
+	r.items[key] = item
+	return item, true, nil
+}
+
+func (r *inboxRepository) FindPushSubscriptions(ctx context.Context, userID uuid.UUID) ([]dao.PushSubscription, error) {
+	return []dao.PushSubscription{{UserID: userID, Endpoint: "https://fcm.googleapis.com/fcm/send/" + userID.String()}}, nil
+}
+
+type recordingWebpush struct {
+	webpush.Provider
+	sent []string
+}
+
+func (p *recordingWebpush) Send(subscription webpush.Subscription, payload []byte) error {
+	p.sent = append(p.sent, subscription.Endpoint)
+	return nil
+}
+
+func TestPushSkipsTheUsersPushedToBefore(t *testing.T) {
+	users := []uuid.UUID{uuid.New(), uuid.New()}
+	repo := &inboxRepository{items: map[[2]uuid.UUID]dao.InboxItem{}, failUser: &users[1]}
+	provider := &recordingWebpush{}
+	n := &NotifierImpl{ctx: context.Background(), repository: repo, templateStore: invitationTemplates{}, webpush: provider, hub: inbox.NewHub()}
+
+	data, _ := json.Marshal(notification.PushMessageData{To: []string{users[0].String(), users[1].String()}})
+	delivery := dao.Delivery{ID: uuid.New(), Event: notification.USER_INVITED, Channel: notification.PUSH, Data: data}
+	if _, err := n.handlePushNotification(context.Background(), delivery, templatestore.FindTemplateParams{}, map[string]any{}); err == nil {
+		t.Fatal("the failed inbox item did not fail the push")
+	}
+	// the redelivered event
+	repo.failUser = nil
+	if _, err := n.handlePushNotification(context.Background(), delivery, templatestore.FindTemplateParams{}, map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.sent) != 2 {
+		t.Fatalf("pushed %v, want every user once", provider.sent)
+	}
+}
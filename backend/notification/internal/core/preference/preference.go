@@ -0,0 +1,129 @@
+package preference
+
+import (
+	"errors"
+	"time"
+	// the containers may not have the zoneinfo of the timezones of the quiet hours
+	_ "time/tzdata"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+// DefaultTimezone is the one of the quiet hours without a timezone
+const DefaultTimezone = "Asia/Kolkata"
+
+// ErrInvalidQuietHours is returned for the quiet hours which are not HH:MM in a known timezone
+var ErrInvalidQuietHours = errors.New("invalid quiet hours")
+
+// Resolved is what applies to a notification of a business, the preference of the
+// business over the one of the user over the defaults
+type Resolved struct {
+	business *dao.Preference
+	user     *dao.Preference
+}
+
+// Resolve picks the preferences of the user which apply to the notifications of the business,
+// a nil business only takes the one of the user
+func Resolve(preferences []dao.Preference, businessID *uuid.UUID) Resolved {
+	var resolved Resolved
+	for i := range preferences {
+		p := &preferences[i]
+		switch {
+		case p.BusinessID == nil:
+			resolved.user = p
+		case businessID != nil && *p.BusinessID == *businessID:
+			resolved.business = p
+		}
+	}
+	return resolved
+}
+
+// Allowed tells whether the channel of the category is on, transactional notifications always are
+func (r Resolved) Allowed(category notification.Category, channel notification.Channel) bool {
+	if category == notification.TRANSACTIONAL {
+		return true
+	}
+	for _, p := range []*dao.Preference{r.business, r.user} {
+		if p == nil {
+			continue
+		}
+		if enabled, ok := p.Channels[category][channel]; ok {
+			return enabled
+		}
+	}
+	return true
+}
+
+// QuietHours are the ones of the business, or else of the user
+func (r Resolved) QuietHours() *dao.QuietHours {
+	for _, p := range []*dao.Preference{r.business, r.user} {
+		if p != nil && p.QuietHours != nil {
+			return p.QuietHours
+		}
+	}
+	return nil
+}
+
+// QuietChannel tells whether the channel is held back during the quiet hours,
+// emails and the inbox don't wake anyone up
+func QuietChannel(channel notification.Channel) bool {
+	return channel == notification.SMS || channel == notification.WHATSAPP
+}
+
+// ValidateQuietHours checks the times and the timezone of the quiet hours
+func ValidateQuietHours(q dao.QuietHours) error {
+	_, _, _, err := parseQuietHours(q)
+	return err
+}
+
+// QuietUntil returns when the quiet hours which now falls in end, the quiet hours
+// span midnight when they start after they end, e.g. from 22:00 to 08:00
+func QuietUntil(q *dao.QuietHours, now time.Time) (time.Time, bool) {
+	if q == nil {
+		return time.Time{}, false
+	}
+	start, end, location, err := parseQuietHours(*q)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	local := now.In(location)
+	at := func(day time.Time, clock time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+	}
+	from, until := at(local, start), at(local, end)
+	if start.Before(end) {
+		if !local.Before(from) && local.Before(until) {
+			return until, true
+		}
+		return time.Time{}, false
+	}
+	if !local.Before(from) {
+		return at(local.AddDate(0, 0, 1), end), true
+	}
+	if local.Before(until) {
+		return until, true
+	}
+	return time.Time{}, false
+}
+
+func parseQuietHours(q dao.QuietHours) (time.Time, time.Time, *time.Location, error) {
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, ErrInvalidQuietHours
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, ErrInvalidQuietHours
+	}
+	timezone := q.Timezone
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, ErrInvalidQuietHours
+	}
+	return start, end, location, nil
+}
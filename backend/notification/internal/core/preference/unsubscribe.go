@@ -0,0 +1,80 @@
+package preference
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned for the unsubscribe tokens which were not signed by the signer
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Unsubscribe is what a one-click unsubscribe link turns off
+type Unsubscribe struct {
+	UserID     uuid.UUID             `json:"user_id"`
+	BusinessID *uuid.UUID            `json:"business_id,omitempty"`
+	Category   notification.Category `json:"category"`
+	Channel    notification.Channel  `json:"channel"`
+	// ExpiresAt is set by Sign, in unix seconds
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Signer signs the unsubscribe links so that they work without a login. the mailbox
+// providers keep offering the link as long as the mail is around, the lifetime is long
+// but a leaked link does not work forever.
+type Signer struct {
+	secret   []byte
+	lifetime time.Duration
+}
+
+func NewSigner(secret string, lifetime time.Duration) *Signer {
+	return &Signer{secret: []byte(secret), lifetime: lifetime}
+}
+
+// Sign returns the token of the unsubscribe, the payload and its hmac in base64url
+func (s *Signer) Sign(unsubscribe Unsubscribe, now time.Time) (string, error) {
+	unsubscribe.ExpiresAt = now.Add(s.lifetime).Unix()
+	payload, err := json.Marshal(unsubscribe)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify rejects the expired tokens and the ones signed before they expired at all
+func (s *Signer) Verify(token string, now time.Time) (Unsubscribe, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Unsubscribe{}, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return Unsubscribe{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Unsubscribe{}, ErrInvalidToken
+	}
+	var unsubscribe Unsubscribe
+	if err := json.Unmarshal(payload, &unsubscribe); err != nil {
+		return Unsubscribe{}, ErrInvalidToken
+	}
+	if !now.Before(time.Unix(unsubscribe.ExpiresAt, 0)) {
+		return Unsubscribe{}, ErrInvalidToken
+	}
+	return unsubscribe, nil
+}
+
+func (s *Signer) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
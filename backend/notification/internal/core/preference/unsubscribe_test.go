@@ -0,0 +1,55 @@
+package preference
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer := NewSigner("secret", time.Hour)
+	now := time.Now()
+	unsubscribe := Unsubscribe{UserID: uuid.New(), Category: notification.TEAM, Channel: notification.EMAIL}
+	token, err := signer.Sign(unsubscribe, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := signer.Verify(token, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.UserID != unsubscribe.UserID || verified.Category != notification.TEAM || verified.Channel != notification.EMAIL {
+		t.Fatalf("verified = %+v", verified)
+	}
+
+	// a token of before the expiry, with a valid signature
+	payload, _ := json.Marshal(unsubscribe)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	unexpiring := encoded + "." + base64.RawURLEncoding.EncodeToString(signer.mac(encoded))
+
+	encodedToken, _, _ := strings.Cut(token, ".")
+	for name, test := range map[string]struct {
+		token string
+		at    time.Time
+	}{
+		"expired":      {token, now.Add(2 * time.Hour)},
+		"other secret": {token, now},
+		"no expiry":    {unexpiring, now},
+		"no signature": {encodedToken, now},
+	} {
+		verifier := signer
+		if name == "other secret" {
+			verifier = NewSigner("other", time.Hour)
+		}
+		if _, err := verifier.Verify(test.token, test.at); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
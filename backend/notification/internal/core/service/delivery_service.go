@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	DeliveryNotFoundErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "delivery_001", Short: "delivery.not_found", Long: "delivery not found",
+	}
+	InvalidDeliveryQueryErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "delivery_002", Short: "delivery.invalid_query", Long: "invalid delivery query",
+	}
+	DeliveryResendFailedErr = &ServiceError{
+		HttpErrorCode: 502, DevErrorCode: "delivery_003", Short: "delivery.resend_failed", Long: "delivery could not be resent",
+	}
+	DeliveryReportsNotSupportedErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "delivery_004", Short: "delivery.reports_not_supported", Long: "delivery reports are not supported for the channel",
+	}
+	DeliveryWebhookUnverifiedErr = &ServiceError{
+		HttpErrorCode: 403, DevErrorCode: "delivery_006", Short: "delivery.webhook_unverified", Long: "webhook request could not be verified",
+	}
+	DeliveryTokensRedactedErr = &ServiceError{
+		HttpErrorCode: 409, DevErrorCode: "delivery_005", Short: "delivery.tokens_redacted", Long: "delivery carried one-time values which were not stored, it can not be resent",
+	}
+)
+
+const (
+	defaultDeliveriesLimit = 20
+	maxDeliveriesLimit     = 100
+)
+
+// DeliveryService gives access to the log of the notifications sent
+type DeliveryService interface {
+	List(ctx context.Context, payload ListDeliveriesPayload) ([]dao.Delivery, error)
+	View(ctx context.Context, id uuid.UUID) (dao.Delivery, error)
+	Resend(ctx context.Context, id uuid.UUID) (dao.Delivery, error)
+	// HandleReports records the statuses posted by a provider to the webhook of the channel
+	HandleReports(ctx context.Context, channel notification.Channel, body []byte, signature string) error
+	// VerifyWebhook checks the token a provider sends when registering the webhook of the channel
+	VerifyWebhook(ctx context.Context, channel notification.Channel, token string) error
+}
+
+type ListDeliveriesPayload struct {
+	Recipient  string `query:"recipient"`
+	BusinessID string `query:"business_id"`
+	Status     string `query:"status"`
+	Page       int    `query:"page"`
+	Limit      int    `query:"limit"`
+}
+
+type deliveryService struct {
+	repository repository.Repository
+	notifier   notifier.Notifier
+}
+
+func NewDeliveryService(repository repository.Repository, notifier notifier.Notifier) DeliveryService {
+	return &deliveryService{
+		repository: repository,
+		notifier:   notifier,
+	}
+}
+
+// List needs a recipient or a business, the log is not meant to be browsed as a whole
+func (s *deliveryService) List(ctx context.Context, payload ListDeliveriesPayload) ([]dao.Delivery, error) {
+	if payload.Recipient == "" && payload.BusinessID == "" {
+		return nil, InvalidDeliveryQueryErr
+	}
+	params := repository.ListDeliveriesParams{
+		Recipient: payload.Recipient,
+		Status:    dao.DeliveryStatus(payload.Status),
+		Page:      max(payload.Page, 1),
+		Limit:     payload.Limit,
+	}
+	switch params.Status {
+	case "", dao.DeliveryQueued, dao.DeliverySent, dao.DeliveryFailed, dao.DeliveryDelivered, dao.DeliveryBounced,
+		dao.DeliverySuppressed, dao.DeliveryDeferred:
+	default:
+		return nil, InvalidDeliveryQueryErr
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultDeliveriesLimit
+	}
+	params.Limit = min(params.Limit, maxDeliveriesLimit)
+	if payload.BusinessID != "" {
+		businessID, err := uuid.Parse(payload.BusinessID)
+		if err != nil {
+			return nil, InvalidDeliveryQueryErr
+		}
+		params.BusinessID = &businessID
+	}
+	deliveries, err := s.repository.ListDeliveries(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list deliveries")
+		return nil, InternalError
+	}
+	return deliveries, nil
+}
+
+func (s *deliveryService) View(ctx context.Context, id uuid.UUID) (dao.Delivery, error) {
+	delivery, err := s.repository.FindDelivery(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Delivery{}, DeliveryNotFoundErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find delivery")
+		return dao.Delivery{}, InternalError
+	}
+	return delivery, nil
+}
+
+// Resend sends the delivery again whatever its status, e.g. after a bounce
+// once the recipient fixed their mailbox. an otp or an invitation has to be
+// requested again instead, their values are not stored.
+func (s *deliveryService) Resend(ctx context.Context, id uuid.UUID) (dao.Delivery, error) {
+	delivery, err := s.View(ctx, id)
+	if err != nil {
+		return delivery, err
+	}
+	delivery, err = s.notifier.Resend(ctx, delivery)
+	if errors.Is(err, notifier.ErrTokensRedacted) {
+		return delivery, DeliveryTokensRedactedErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to resend delivery")
+		return delivery, DeliveryResendFailedErr
+	}
+	if delivery.Status != dao.DeliverySent {
+		return delivery, DeliveryResendFailedErr
+	}
+	return delivery, nil
+}
+
+func (s *deliveryService) HandleReports(ctx context.Context, channel notification.Channel, body []byte, signature string) error {
+	err := s.notifier.HandleDeliveryReports(ctx, channel, body, signature)
+	if errors.Is(err, notifier.ErrReportsNotSupported) {
+		return DeliveryReportsNotSupportedErr
+	}
+	if errors.Is(err, notifier.ErrUnverifiedWebhook) {
+		return DeliveryWebhookUnverifiedErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("channel", string(channel)).Msg("failed to handle delivery reports")
+		return InternalError
+	}
+	return nil
+}
+
+func (s *deliveryService) VerifyWebhook(ctx context.Context, channel notification.Channel, token string) error {
+	err := s.notifier.VerifyWebhook(ctx, channel, token)
+	switch {
+	case errors.Is(err, notifier.ErrReportsNotSupported):
+		return DeliveryReportsNotSupportedErr
+	case errors.Is(err, notifier.ErrUnverifiedWebhook):
+		return DeliveryWebhookUnverifiedErr
+	case err != nil:
+		logger.Error().Err(err).Str("channel", string(channel)).Msg("failed to verify webhook")
+		return InternalError
+	}
+	return nil
+}
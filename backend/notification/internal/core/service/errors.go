@@ -0,0 +1,50 @@
+package service
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ServiceError struct {
+	HttpErrorCode int    `json:"http_error_code"`
+	DevErrorCode  string `json:"dev_error_code"`
+	Short         string `json:"short"`
+	Long          string `json:"long"`
+}
+
+func (e *ServiceError) Error() string {
+	return e.Long
+}
+
+const (
+	GeneralErrorCode = 1000
+)
+
+var (
+	InternalError = &ServiceError{HttpErrorCode: fiber.StatusInternalServerError,
+		DevErrorCode: "general_internal_error", Short: "Internal server error", Long: "Internal server error"}
+	InvalidInitiatorErr = &ServiceError{HttpErrorCode: fiber.StatusBadRequest,
+		DevErrorCode: "general_invalid_initiator", Short: "errors.400", Long: "invalid initiator"}
+)
+
+// Errors is the catalog of the errors of the service, documented in its openapi spec
+var Errors = []*ServiceError{
+	InternalError, InvalidInitiatorErr,
+	DeliveryNotFoundErr, InvalidDeliveryQueryErr, DeliveryResendFailedErr, DeliveryReportsNotSupportedErr, DeliveryTokensRedactedErr,
+	DeliveryWebhookUnverifiedErr,
+	InvalidInboxQueryErr,
+	OptInNotFoundErr, InvalidOptInErr,
+	InvalidPreferenceErr, InvalidUnsubscribeTokenErr,
+	InvalidPushSubscriptionErr, PushSubscriptionNotFoundErr, PushDisabledErr,
+	TemplateNotFoundErr, InvalidTemplateErr, TemplateAlreadyExistsErr, TemplateVersionNotFoundErr, TemplateConflictErr,
+}
+
+// parseInitiator parses the id of the authenticated user, the identity comes
+// from outside the service so it is not trusted to be one
+func parseInitiator(initiator string) (uuid.UUID, error) {
+	id, err := uuid.Parse(initiator)
+	if err != nil {
+		return uuid.Nil, InvalidInitiatorErr
+	}
+	return id, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/inbox"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/google/uuid"
+)
+
+var (
+	InvalidInboxQueryErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "inbox_001", Short: "inbox.invalid_query", Long: "invalid inbox query",
+	}
+)
+
+const (
+	defaultInboxLimit = 20
+	maxInboxLimit     = 100
+)
+
+// InboxService serves the in-app notifications of the authenticated user
+type InboxService interface {
+	List(ctx context.Context, initiator string, payload ListInboxPayload) ([]dao.InboxItem, error)
+	UnreadCount(ctx context.Context, initiator string, payload UnreadCountPayload) (UnreadCountResponse, error)
+	Read(ctx context.Context, initiator string, payload ReadInboxPayload) (ReadInboxResponse, error)
+	ReadAll(ctx context.Context, initiator string) (ReadInboxResponse, error)
+	// Stream delivers the new items of the user live until stop is called
+	Stream(initiator string) (items <-chan dao.InboxItem, stop func(), err error)
+}
+
+type ListInboxPayload struct {
+	BusinessID string `query:"business_id"`
+	Unread     bool   `query:"unread"`
+	Page       int    `query:"page"`
+	Limit      int    `query:"limit"`
+}
+
+type UnreadCountPayload struct {
+	BusinessID string `query:"business_id"`
+}
+
+type UnreadCountResponse struct {
+	Unread int64 `json:"unread"`
+}
+
+type ReadInboxPayload struct {
+	IDs []string `json:"ids"`
+}
+
+type ReadInboxResponse struct {
+	Read int64 `json:"read"`
+}
+
+type inboxService struct {
+	repository repository.Repository
+	hub        *inbox.Hub
+}
+
+func NewInboxService(repository repository.Repository, hub *inbox.Hub) InboxService {
+	return &inboxService{
+		repository: repository,
+		hub:        hub,
+	}
+}
+
+func parseBusinessID(businessID string) (*uuid.UUID, error) {
+	if businessID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(businessID)
+	if err != nil {
+		return nil, InvalidInboxQueryErr
+	}
+	return &id, nil
+}
+
+func (s *inboxService) List(ctx context.Context, initiator string, payload ListInboxPayload) ([]dao.InboxItem, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return nil, err
+	}
+	businessID, err := parseBusinessID(payload.BusinessID)
+	if err != nil {
+		return nil, err
+	}
+	params := repository.ListInboxItemsParams{
+		UserID:     userID,
+		BusinessID: businessID,
+		Unread:     payload.Unread,
+		Page:       max(payload.Page, 1),
+		Limit:      payload.Limit,
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultInboxLimit
+	}
+	params.Limit = min(params.Limit, maxInboxLimit)
+	items, err := s.repository.ListInboxItems(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list inbox items")
+		return nil, InternalError
+	}
+	return items, nil
+}
+
+func (s *inboxService) UnreadCount(ctx context.Context, initiator string, payload UnreadCountPayload) (UnreadCountResponse, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return UnreadCountResponse{}, err
+	}
+	businessID, err := parseBusinessID(payload.BusinessID)
+	if err != nil {
+		return UnreadCountResponse{}, err
+	}
+	unread, err := s.repository.CountUnreadInboxItems(ctx, userID, businessID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to count unread inbox items")
+		return UnreadCountResponse{}, InternalError
+	}
+	return UnreadCountResponse{Unread: unread}, nil
+}
+
+func (s *inboxService) Read(ctx context.Context, initiator string, payload ReadInboxPayload) (ReadInboxResponse, error) {
+	if len(payload.IDs) == 0 {
+		return ReadInboxResponse{}, InvalidInboxQueryErr
+	}
+	ids := make([]uuid.UUID, 0, len(payload.IDs))
+	for _, id := range payload.IDs {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return ReadInboxResponse{}, InvalidInboxQueryErr
+		}
+		ids = append(ids, parsed)
+	}
+	return s.markRead(ctx, initiator, ids)
+}
+
+func (s *inboxService) ReadAll(ctx context.Context, initiator string) (ReadInboxResponse, error) {
+	return s.markRead(ctx, initiator, nil)
+}
+
+func (s *inboxService) markRead(ctx context.Context, initiator string, ids []uuid.UUID) (ReadInboxResponse, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return ReadInboxResponse{}, err
+	}
+	read, err := s.repository.MarkInboxItemsRead(ctx, userID, ids)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to mark inbox items read")
+		return ReadInboxResponse{}, InternalError
+	}
+	return ReadInboxResponse{Read: read}, nil
+}
+
+func (s *inboxService) Stream(initiator string) (<-chan dao.InboxItem, func(), error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, stop := s.hub.Subscribe(userID)
+	return items, stop, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/whatsapp"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	OptInNotFoundErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "opt_in_001", Short: "opt_in.not_found", Long: "opt-in not found",
+	}
+	InvalidOptInErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "opt_in_002", Short: "opt_in.invalid", Long: "invalid opt-in",
+	}
+)
+
+// OptInService keeps the consent of the recipients for the channels which need one
+type OptInService interface {
+	Update(ctx context.Context, payload UpdateOptInPayload) (dao.OptIn, error)
+	View(ctx context.Context, payload ViewOptInPayload) (dao.OptIn, error)
+}
+
+type UpdateOptInPayload struct {
+	Channel   notification.Channel `json:"channel"`
+	Recipient string               `json:"recipient"`
+	OptedIn   bool                 `json:"opted_in"`
+	// Source is where the consent was collected, e.g. signup or settings
+	Source string `json:"source"`
+}
+
+type ViewOptInPayload struct {
+	Channel   notification.Channel `query:"channel"`
+	Recipient string               `query:"recipient"`
+}
+
+type optInService struct {
+	repository repository.Repository
+}
+
+func NewOptInService(repository repository.Repository) OptInService {
+	return &optInService{
+		repository: repository,
+	}
+}
+
+// only whatsapp needs an opt-in as of now
+func normalizeRecipient(channel notification.Channel, recipient string) (string, bool) {
+	if channel != notification.WHATSAPP {
+		return "", false
+	}
+	recipient = whatsapp.NormalizePhone(recipient)
+	return recipient, recipient != ""
+}
+
+func (s *optInService) Update(ctx context.Context, payload UpdateOptInPayload) (dao.OptIn, error) {
+	recipient, ok := normalizeRecipient(payload.Channel, payload.Recipient)
+	if !ok || payload.Source == "" {
+		return dao.OptIn{}, InvalidOptInErr
+	}
+	now := time.Now()
+	optIn, err := s.repository.SetOptIn(ctx, dao.OptIn{
+		Channel:   payload.Channel,
+		Recipient: recipient,
+		OptedIn:   payload.OptedIn,
+		Source:    payload.Source,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to set opt-in")
+		return dao.OptIn{}, InternalError
+	}
+	return optIn, nil
+}
+
+func (s *optInService) View(ctx context.Context, payload ViewOptInPayload) (dao.OptIn, error) {
+	recipient, ok := normalizeRecipient(payload.Channel, payload.Recipient)
+	if !ok {
+		return dao.OptIn{}, InvalidOptInErr
+	}
+	optIn, err := s.repository.FindOptIn(ctx, payload.Channel, recipient)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.OptIn{}, OptInNotFoundErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find opt-in")
+		return dao.OptIn{}, InternalError
+	}
+	return optIn, nil
+}
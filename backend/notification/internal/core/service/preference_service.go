@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/preference"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	InvalidPreferenceErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "preference_001", Short: "preference.invalid", Long: "invalid preference",
+	}
+	InvalidUnsubscribeTokenErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "preference_002", Short: "preference.invalid_token", Long: "invalid unsubscribe token",
+	}
+)
+
+// PreferenceService keeps what the users want to be notified of, for all the businesses
+// or for a single one
+type PreferenceService interface {
+	View(ctx context.Context, initiator string, payload ViewPreferencePayload) (dao.Preference, error)
+	Update(ctx context.Context, initiator string, payload UpdatePreferencePayload) (dao.Preference, error)
+	// ViewUnsubscribe tells what the link turns off without doing it, the link
+	// scanners of the mail servers open every link of the emails
+	ViewUnsubscribe(ctx context.Context, payload UnsubscribePayload) (UnsubscribeResponse, error)
+	Unsubscribe(ctx context.Context, payload UnsubscribePayload) (UnsubscribeResponse, error)
+}
+
+type ViewPreferencePayload struct {
+	BusinessID string `query:"business_id"`
+}
+
+type UpdatePreferencePayload struct {
+	// BusinessID is empty for the preference of all the businesses
+	BusinessID string                                                  `json:"business_id"`
+	Channels   map[notification.Category]map[notification.Channel]bool `json:"channels"`
+	QuietHours *dao.QuietHours                                         `json:"quiet_hours"`
+}
+
+type UnsubscribePayload struct {
+	Token string `query:"token"`
+}
+
+type UnsubscribeResponse struct {
+	BusinessID *uuid.UUID            `json:"business_id"`
+	Category   notification.Category `json:"category"`
+	Channel    notification.Channel  `json:"channel"`
+}
+
+type preferenceService struct {
+	repository repository.Repository
+	signer     *preference.Signer
+}
+
+func NewPreferenceService(repository repository.Repository, signer *preference.Signer) PreferenceService {
+	return &preferenceService{
+		repository: repository,
+		signer:     signer,
+	}
+}
+
+func parsePreferenceBusinessID(businessID string) (*uuid.UUID, error) {
+	if businessID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(businessID)
+	if err != nil {
+		return nil, InvalidPreferenceErr
+	}
+	return &id, nil
+}
+
+// View returns the defaults, everything on, when the user has not set any
+func (s *preferenceService) View(ctx context.Context, initiator string, payload ViewPreferencePayload) (dao.Preference, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return dao.Preference{}, err
+	}
+	businessID, err := parsePreferenceBusinessID(payload.BusinessID)
+	if err != nil {
+		return dao.Preference{}, err
+	}
+	stored, err := s.repository.FindPreference(ctx, userID, businessID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Preference{
+			UserID:     userID,
+			BusinessID: businessID,
+			Channels:   map[notification.Category]map[notification.Channel]bool{},
+		}, nil
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find preference")
+		return dao.Preference{}, InternalError
+	}
+	return stored, nil
+}
+
+// Update replaces the channels and the quiet hours, the transactional notifications can't be turned off
+func (s *preferenceService) Update(ctx context.Context, initiator string, payload UpdatePreferencePayload) (dao.Preference, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return dao.Preference{}, err
+	}
+	businessID, err := parsePreferenceBusinessID(payload.BusinessID)
+	if err != nil {
+		return dao.Preference{}, err
+	}
+	for category, channels := range payload.Channels {
+		switch category {
+		case notification.ACCOUNT, notification.TEAM:
+		default:
+			return dao.Preference{}, InvalidPreferenceErr
+		}
+		for channel := range channels {
+			switch channel {
+			case notification.EMAIL, notification.SMS, notification.PUSH, notification.WHATSAPP:
+			default:
+				return dao.Preference{}, InvalidPreferenceErr
+			}
+		}
+	}
+	if payload.QuietHours != nil {
+		if err := preference.ValidateQuietHours(*payload.QuietHours); err != nil {
+			return dao.Preference{}, InvalidPreferenceErr
+		}
+	}
+	if payload.Channels == nil {
+		payload.Channels = map[notification.Category]map[notification.Channel]bool{}
+	}
+	now := time.Now()
+	stored, err := s.repository.SavePreference(ctx, dao.Preference{
+		ID:         uuid.New(),
+		UserID:     userID,
+		BusinessID: businessID,
+		Channels:   payload.Channels,
+		QuietHours: payload.QuietHours,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to save preference")
+		return dao.Preference{}, InternalError
+	}
+	return stored, nil
+}
+
+func (s *preferenceService) ViewUnsubscribe(ctx context.Context, payload UnsubscribePayload) (UnsubscribeResponse, error) {
+	unsubscribe, err := s.signer.Verify(payload.Token, time.Now())
+	if err != nil {
+		return UnsubscribeResponse{}, InvalidUnsubscribeTokenErr
+	}
+	return UnsubscribeResponse{
+		BusinessID: unsubscribe.BusinessID,
+		Category:   unsubscribe.Category,
+		Channel:    unsubscribe.Channel,
+	}, nil
+}
+
+// Unsubscribe turns off the channel of the category the link was sent for, only for its business
+func (s *preferenceService) Unsubscribe(ctx context.Context, payload UnsubscribePayload) (UnsubscribeResponse, error) {
+	unsubscribe, err := s.signer.Verify(payload.Token, time.Now())
+	if err != nil {
+		return UnsubscribeResponse{}, InvalidUnsubscribeTokenErr
+	}
+	err = s.repository.SetPreferenceChannel(ctx, repository.SetPreferenceChannelParams{
+		UserID:     unsubscribe.UserID,
+		BusinessID: unsubscribe.BusinessID,
+		Category:   unsubscribe.Category,
+		Channel:    unsubscribe.Channel,
+		Enabled:    false,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to unsubscribe")
+		return UnsubscribeResponse{}, InternalError
+	}
+	return UnsubscribeResponse{
+		BusinessID: unsubscribe.BusinessID,
+		Category:   unsubscribe.Category,
+		Channel:    unsubscribe.Channel,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/webpush"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/google/uuid"
+)
+
+var (
+	InvalidPushSubscriptionErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "push_subscription_001", Short: "push_subscription.invalid", Long: "invalid push subscription",
+	}
+	PushSubscriptionNotFoundErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "push_subscription_002", Short: "push_subscription.not_found", Long: "push subscription not found",
+	}
+	PushDisabledErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "push_subscription_003", Short: "push_subscription.disabled", Long: "web push is not enabled",
+	}
+)
+
+// PushSubscriptionService keeps the browsers of the users subscribed to web push
+type PushSubscriptionService interface {
+	VapidKey(ctx context.Context) (VapidKeyResponse, error)
+	Subscribe(ctx context.Context, initiator string, payload SubscribePushPayload) (dao.PushSubscription, error)
+	Unsubscribe(ctx context.Context, initiator string, payload UnsubscribePushPayload) error
+}
+
+type VapidKeyResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+// SubscribePushPayload is the PushSubscription.toJSON() of the browser
+type SubscribePushPayload struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	UserAgent string `json:"-"`
+}
+
+type UnsubscribePushPayload struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type pushSubscriptionService struct {
+	repository repository.Repository
+	notifier   notifier.Notifier
+}
+
+func NewPushSubscriptionService(repository repository.Repository, notifier notifier.Notifier) PushSubscriptionService {
+	return &pushSubscriptionService{
+		repository: repository,
+		notifier:   notifier,
+	}
+}
+
+func (s *pushSubscriptionService) VapidKey(ctx context.Context) (VapidKeyResponse, error) {
+	publicKey := s.notifier.PushPublicKey()
+	if publicKey == "" {
+		return VapidKeyResponse{}, PushDisabledErr
+	}
+	return VapidKeyResponse{PublicKey: publicKey}, nil
+}
+
+func (s *pushSubscriptionService) Subscribe(ctx context.Context, initiator string, payload SubscribePushPayload) (dao.PushSubscription, error) {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return dao.PushSubscription{}, err
+	}
+	// the notifier posts to the endpoint, only the push services of the browsers are taken
+	if !webpush.ValidEndpoint(payload.Endpoint) || payload.Keys.P256dh == "" || payload.Keys.Auth == "" {
+		return dao.PushSubscription{}, InvalidPushSubscriptionErr
+	}
+	now := time.Now()
+	subscription, err := s.repository.SavePushSubscription(ctx, dao.PushSubscription{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Endpoint:  payload.Endpoint,
+		P256dh:    payload.Keys.P256dh,
+		Auth:      payload.Keys.Auth,
+		UserAgent: payload.UserAgent,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to save push subscription")
+		return dao.PushSubscription{}, InternalError
+	}
+	return subscription, nil
+}
+
+// Unsubscribe only removes a subscription of the user
+func (s *pushSubscriptionService) Unsubscribe(ctx context.Context, initiator string, payload UnsubscribePushPayload) error {
+	userID, err := parseInitiator(initiator)
+	if err != nil {
+		return err
+	}
+	subscriptions, err := s.repository.FindPushSubscriptions(ctx, userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find push subscriptions")
+		return InternalError
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Endpoint != payload.Endpoint {
+			continue
+		}
+		if err := s.repository.DeletePushSubscription(ctx, subscription.Endpoint); err != nil {
+			logger.Error().Err(err).Msg("failed to delete push subscription")
+			return InternalError
+		}
+		return nil
+	}
+	return PushSubscriptionNotFoundErr
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/inbox"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/preference"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
+)
+
+type Service struct {
+	Template         TemplateService
+	Delivery         DeliveryService
+	OptIn            OptInService
+	Inbox            InboxService
+	PushSubscription PushSubscriptionService
+	Preference       PreferenceService
+}
+
+func New(repository repository.Repository, templateStore templatestore.TemplateStorage, notifier notifier.Notifier, hub *inbox.Hub, signer *preference.Signer) *Service {
+	return &Service{
+		Template:         NewTemplateService(repository, templateStore),
+		Delivery:         NewDeliveryService(repository, notifier),
+		OptIn:            NewOptInService(repository),
+		Inbox:            NewInboxService(repository, hub),
+		PushSubscription: NewPushSubscriptionService(repository, notifier),
+		Preference:       NewPreferenceService(repository, signer),
+	}
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	TemplateNotFoundErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "template_001", Short: "template.not_found", Long: "template not found",
+	}
+	InvalidTemplateErr = &ServiceError{
+		HttpErrorCode: 400, DevErrorCode: "template_002", Short: "template.invalid", Long: "invalid template",
+	}
+	TemplateAlreadyExistsErr = &ServiceError{
+		HttpErrorCode: 409, DevErrorCode: "template_003", Short: "template.already_exists", Long: "template already exists",
+	}
+	TemplateVersionNotFoundErr = &ServiceError{
+		HttpErrorCode: 404, DevErrorCode: "template_004", Short: "template.version_not_found", Long: "template version not found",
+	}
+	TemplateConflictErr = &ServiceError{
+		HttpErrorCode: 409, DevErrorCode: "template_005", Short: "template.conflict", Long: "template was changed in the meantime",
+	}
+)
+
+const (
+	defaultTemplatesLimit = 20
+	maxTemplatesLimit     = 100
+)
+
+// TemplateService manages the templates in the database, every change of the
+// content is kept as a version which can be rolled back to
+type TemplateService interface {
+	Create(ctx context.Context, payload CreateTemplatePayload) (dao.Template, error)
+	Update(ctx context.Context, id uuid.UUID, payload TemplateContentPayload) (dao.Template, error)
+	List(ctx context.Context, payload ListTemplatesPayload) ([]dao.Template, error)
+	View(ctx context.Context, id uuid.UUID) (dao.Template, error)
+	Versions(ctx context.Context, id uuid.UUID) ([]dao.TemplateVersion, error)
+	Rollback(ctx context.Context, id uuid.UUID, payload RollbackTemplatePayload) (dao.Template, error)
+	// Preview renders a stored template, or the one in the payload, with sample tokens
+	Preview(ctx context.Context, payload PreviewTemplatePayload) (TemplatePreview, error)
+	// Seed creates the embedded templates which are not in the database yet
+	Seed(ctx context.Context) (int, error)
+}
+
+type TemplateContentPayload struct {
+	Subject            string   `json:"subject"`
+	Body               string   `json:"body"`
+	DltTemplateID      string   `json:"dlt_template_id"`
+	WhatsappTemplate   string   `json:"whatsapp_template"`
+	WhatsappParameters []string `json:"whatsapp_parameters"`
+}
+
+type CreateTemplatePayload struct {
+	Event    notification.Event   `json:"event"`
+	Channel  notification.Channel `json:"channel"`
+	Locale   string               `json:"locale"`
+	Scope    string               `json:"scope"`
+	Mimetype string               `json:"mimetype"`
+	TemplateContentPayload
+}
+
+type ListTemplatesPayload struct {
+	Event   string `query:"event"`
+	Channel string `query:"channel"`
+	Locale  string `query:"locale"`
+	Scope   string `query:"scope"`
+	Page    int    `query:"page"`
+	Limit   int    `query:"limit"`
+}
+
+type RollbackTemplatePayload struct {
+	Version int `json:"version"`
+}
+
+type PreviewTemplatePayload struct {
+	// TemplateID previews a stored template, otherwise the template in the payload
+	TemplateID *uuid.UUID `json:"template_id"`
+	CreateTemplatePayload
+	// Tokens are the sample values, the missing ones show up as [Token]
+	Tokens map[string]any `json:"tokens"`
+}
+
+type TemplatePreview struct {
+	Subject            string   `json:"subject"`
+	Body               string   `json:"body"`
+	WhatsappParameters []string `json:"whatsapp_parameters"`
+}
+
+type templateService struct {
+	repository    repository.Repository
+	templateStore templatestore.TemplateStorage
+}
+
+func NewTemplateService(repository repository.Repository, templateStore templatestore.TemplateStorage) TemplateService {
+	return &templateService{
+		repository:    repository,
+		templateStore: templateStore,
+	}
+}
+
+// invalidTemplate tells what is wrong with the template
+func invalidTemplate(err error) error {
+	e := *InvalidTemplateErr
+	e.Long = err.Error()
+	return &e
+}
+
+func (s *templateService) Create(ctx context.Context, payload CreateTemplatePayload) (dao.Template, error) {
+	if payload.Scope == "" {
+		payload.Scope = templatestore.DefaultScope
+	}
+	if payload.Event == "" || payload.Channel == "" || payload.Locale == "" || templatestore.PreferredExt(payload.Mimetype) == "" {
+		return dao.Template{}, InvalidTemplateErr
+	}
+	now := time.Now()
+	template := dao.Template{
+		ID:        uuid.New(),
+		Event:     payload.Event,
+		Channel:   payload.Channel,
+		Locale:    payload.Locale,
+		Mimetype:  payload.Mimetype,
+		Scope:     payload.Scope,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	setContent(&template, payload.TemplateContentPayload)
+	if err := s.validate(ctx, template); err != nil {
+		return dao.Template{}, err
+	}
+	_, err := s.repository.FindTemplate(ctx, repository.FindTemplateParams{
+		Event:    template.Event,
+		Channel:  template.Channel,
+		Locale:   template.Locale,
+		Scope:    template.Scope,
+		Mimetype: template.Mimetype,
+	})
+	if err == nil {
+		return dao.Template{}, TemplateAlreadyExistsErr
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Error().Err(err).Msg("failed to find template")
+		return dao.Template{}, InternalError
+	}
+	template.Version = 1
+	template, err = s.repository.CreateTemplate(ctx, template)
+	if mongo.IsDuplicateKeyError(err) {
+		return dao.Template{}, TemplateAlreadyExistsErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create template")
+		return dao.Template{}, InternalError
+	}
+	if err := s.createVersion(ctx, template); err != nil {
+		return dao.Template{}, err
+	}
+	return template, nil
+}
+
+func (s *templateService) Update(ctx context.Context, id uuid.UUID, payload TemplateContentPayload) (dao.Template, error) {
+	template, err := s.View(ctx, id)
+	if err != nil {
+		return dao.Template{}, err
+	}
+	setContent(&template, payload)
+	return s.save(ctx, template)
+}
+
+func (s *templateService) List(ctx context.Context, payload ListTemplatesPayload) ([]dao.Template, error) {
+	params := repository.ListTemplatesParams{
+		Event:   notification.Event(payload.Event),
+		Channel: notification.Channel(payload.Channel),
+		Locale:  payload.Locale,
+		Scope:   payload.Scope,
+		Page:    max(payload.Page, 1),
+		Limit:   payload.Limit,
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultTemplatesLimit
+	}
+	params.Limit = min(params.Limit, maxTemplatesLimit)
+	templates, err := s.repository.ListTemplates(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list templates")
+		return nil, InternalError
+	}
+	return templates, nil
+}
+
+func (s *templateService) View(ctx context.Context, id uuid.UUID) (dao.Template, error) {
+	template, err := s.repository.FindTemplateByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Template{}, TemplateNotFoundErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find template")
+		return dao.Template{}, InternalError
+	}
+	return template, nil
+}
+
+func (s *templateService) Versions(ctx context.Context, id uuid.UUID) ([]dao.TemplateVersion, error) {
+	if _, err := s.View(ctx, id); err != nil {
+		return nil, err
+	}
+	versions, err := s.repository.ListTemplateVersions(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list template versions")
+		return nil, InternalError
+	}
+	return versions, nil
+}
+
+// Rollback restores the content of an older version as a new version,
+// so the history is never rewritten
+func (s *templateService) Rollback(ctx context.Context, id uuid.UUID, payload RollbackTemplatePayload) (dao.Template, error) {
+	template, err := s.View(ctx, id)
+	if err != nil {
+		return dao.Template{}, err
+	}
+	version, err := s.repository.FindTemplateVersion(ctx, id, payload.Version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Template{}, TemplateVersionNotFoundErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find template version")
+		return dao.Template{}, InternalError
+	}
+	setContent(&template, TemplateContentPayload{
+		Subject:            version.Subject,
+		Body:               version.Body,
+		DltTemplateID:      version.DltTemplateID,
+		WhatsappTemplate:   version.WhatsappTemplate,
+		WhatsappParameters: version.WhatsappParameters,
+	})
+	return s.save(ctx, template)
+}
+
+func (s *templateService) Preview(ctx context.Context, payload PreviewTemplatePayload) (TemplatePreview, error) {
+	template := dao.Template{
+		Event:    payload.Event,
+		Channel:  payload.Channel,
+		Locale:   cmp.Or(payload.Locale, templatestore.DefaultLocale),
+		Scope:    cmp.Or(payload.Scope, templatestore.DefaultScope),
+		Mimetype: payload.Mimetype,
+	}
+	setContent(&template, payload.TemplateContentPayload)
+	if payload.TemplateID != nil {
+		var err error
+		template, err = s.View(ctx, *payload.TemplateID)
+		if err != nil {
+			return TemplatePreview{}, err
+		}
+	}
+	if err := s.validate(ctx, template); err != nil {
+		return TemplatePreview{}, err
+	}
+	tokens := map[string]any{
+		templatestore.BrandingToken: templatestore.Branding{Name: "BillBharat"},
+		templatestore.RecipientToken: templatestore.Recipient{
+			Name: "[Recipient.Name]", Email: "[Recipient.Email]", Phone: "[Recipient.Phone]",
+		},
+	}
+	// only the notifications which can be turned off have an unsubscribe link
+	if template.Event.Category() != notification.TRANSACTIONAL {
+		tokens[templatestore.UnsubscribeToken] = fmt.Sprintf("[%s]", templatestore.UnsubscribeToken)
+	}
+	for _, token := range notification.Tokens[template.Event] {
+		tokens[token] = fmt.Sprintf("[%s]", token)
+	}
+	for token, value := range payload.Tokens {
+		tokens[token] = value
+	}
+	render := func(text string) (string, error) {
+		rendered, err := templatestore.Render(text, tokens)
+		if err != nil {
+			return "", invalidTemplate(err)
+		}
+		return rendered, nil
+	}
+	var preview TemplatePreview
+	var err error
+	if preview.Subject, err = render(template.Subject); err != nil {
+		return TemplatePreview{}, err
+	}
+	if template.Mimetype == "text/html" {
+		layouts, err := templatestore.Layouts(ctx, s.templateStore, templatestore.FindTemplateParams{
+			Channel: template.Channel,
+			Locale:  template.Locale,
+			Scope:   template.Scope,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to find layouts")
+			return TemplatePreview{}, InternalError
+		}
+		if preview.Body, err = templatestore.RenderHTML(layouts, template.Body, tokens); err != nil {
+			return TemplatePreview{}, invalidTemplate(err)
+		}
+	} else if preview.Body, err = render(template.Body); err != nil {
+		return TemplatePreview{}, err
+	}
+	for _, parameter := range template.WhatsappParameters {
+		rendered, err := render(parameter)
+		if err != nil {
+			return TemplatePreview{}, err
+		}
+		preview.WhatsappParameters = append(preview.WhatsappParameters, rendered)
+	}
+	return preview, nil
+}
+
+func (s *templateService) Seed(ctx context.Context) (int, error) {
+	templates, err := templatestore.Embedded()
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	for _, template := range templates {
+		_, err := s.Create(ctx, CreateTemplatePayload{
+			Event:    template.Event,
+			Channel:  template.Channel,
+			Locale:   template.Locale,
+			Scope:    template.Scope,
+			Mimetype: template.Mimetype,
+			TemplateContentPayload: TemplateContentPayload{
+				Subject:            template.Subject,
+				Body:               template.Body,
+				DltTemplateID:      template.DltTemplateID,
+				WhatsappTemplate:   template.WhatsappTemplate,
+				WhatsappParameters: template.WhatsappParameters,
+			},
+		})
+		// the ones already there may have been edited, they are left alone
+		if errors.Is(err, TemplateAlreadyExistsErr) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to seed %s/%s/%s%s: %w", template.Event, template.Channel, template.Locale, templatestore.PreferredExt(template.Mimetype), err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+func setContent(template *dao.Template, content TemplateContentPayload) {
+	template.Subject = content.Subject
+	template.Body = content.Body
+	template.DltTemplateID = content.DltTemplateID
+	template.WhatsappTemplate = content.WhatsappTemplate
+	template.WhatsappParameters = content.WhatsappParameters
+}
+
+// validate checks the template against the layouts it is rendered with
+func (s *templateService) validate(ctx context.Context, template dao.Template) error {
+	layouts, err := templatestore.HTMLLayouts(ctx, s.templateStore, template)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to find layouts")
+		return InternalError
+	}
+	if err := templatestore.Validate(template, layouts); err != nil {
+		return invalidTemplate(err)
+	}
+	return nil
+}
+
+// save stores the changed content as the next version of the template
+func (s *templateService) save(ctx context.Context, template dao.Template) (dao.Template, error) {
+	if err := s.validate(ctx, template); err != nil {
+		return dao.Template{}, err
+	}
+	template.Version++
+	template.UpdatedAt = time.Now()
+	err := s.repository.UpdateTemplate(ctx, template)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Template{}, TemplateConflictErr
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to update template")
+		return dao.Template{}, InternalError
+	}
+	if err := s.createVersion(ctx, template); err != nil {
+		return dao.Template{}, err
+	}
+	return template, nil
+}
+
+func (s *templateService) createVersion(ctx context.Context, template dao.Template) error {
+	err := s.repository.CreateTemplateVersion(ctx, dao.TemplateVersion{
+		ID:                 uuid.New(),
+		TemplateID:         template.ID,
+		Version:            template.Version,
+		Subject:            template.Subject,
+		Body:               template.Body,
+		DltTemplateID:      template.DltTemplateID,
+		WhatsappTemplate:   template.WhatsappTemplate,
+		WhatsappParameters: template.WhatsappParameters,
+		CreatedAt:          template.UpdatedAt,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create template version")
+		return InternalError
+	}
+	return nil
+}
@@ -17,8 +17,15 @@ type Template struct {
 	Mimetype string               `bson:"mimetype" json:"mimetype"`
 	// scope can be default or some custom target like for a business the business id
 	Scope string `bson:"scope" json:"scope"`
-	// we don't need version as of now
-	// Version string `bson:"version" json:"version"`
+	// DltTemplateID is the id of the template registered on the DLT platform, sms only
+	DltTemplateID string `bson:"dlt_template_id" json:"dlt_template_id"`
+	// WhatsappTemplate is the name of the template approved by whatsapp, the body is only
+	// kept for reference. WhatsappParameters are rendered with the tokens and fill the
+	// placeholders of the approved template in order, e.g. ["{{.Name}}", "{{.Business}}"]
+	WhatsappTemplate   string   `bson:"whatsapp_template" json:"whatsapp_template"`
+	WhatsappParameters []string `bson:"whatsapp_parameters" json:"whatsapp_parameters"`
+	// Version goes up on every change of the content, the history is in template_versions
+	Version   int        `bson:"version" json:"version"`
 	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
 	CreatedBy uuid.UUID  `bson:"created_by" json:"created_by"`
 	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
@@ -27,6 +34,19 @@ type Template struct {
 	DeletedBy *uuid.UUID `bson:"deleted_by" json:"deleted_by"`
 }
 
+// TemplateVersion is a snapshot of the content of a template, one per version
+type TemplateVersion struct {
+	ID                 uuid.UUID `bson:"_id" json:"id"`
+	TemplateID         uuid.UUID `bson:"template_id" json:"template_id"`
+	Version            int       `bson:"version" json:"version"`
+	Subject            string    `bson:"subject" json:"subject"`
+	Body               string    `bson:"body" json:"body"`
+	DltTemplateID      string    `bson:"dlt_template_id" json:"dlt_template_id"`
+	WhatsappTemplate   string    `bson:"whatsapp_template" json:"whatsapp_template"`
+	WhatsappParameters []string  `bson:"whatsapp_parameters" json:"whatsapp_parameters"`
+	CreatedAt          time.Time `bson:"created_at" json:"created_at"`
+}
+
 type User struct {
 	ID            uuid.UUID  `bson:"_id" json:"id"`
 	HumanID       string     `bson:"human_id" json:"human_id"`
@@ -44,23 +64,24 @@ type User struct {
 	DeactivatedBy *uuid.UUID `bson:"deactivated_by" json:"deactivated_by"`
 	DeletedAt     *time.Time `bson:"deleted_at" json:"deleted_at"`
 	DeletedBy     *uuid.UUID `bson:"deleted_by" json:"deleted_by"`
+	Locale        string     `bson:"locale" json:"locale"`
 }
 
 type Business struct {
 	ID              uuid.UUID  `bson:"_id" json:"id"`
-	Name            string     `json:"name"`
-	Description     *string    `json:"description"`
-	Logo            *string    `json:"logo"`
-	Industry        string     `json:"industry"`
-	PrimaryCurrency string     `json:"primary_currency"`
-	OwnerID         uuid.UUID  `json:"owner_id"`
-	Currencies      []string   `json:"currencies"`
-	CreatedAt       time.Time  `json:"created_at"`
-	CreatedBy       uuid.UUID  `json:"created_by"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	UpdatedBy       *uuid.UUID `json:"updated_by"`
-	DeletedAt       *time.Time `json:"deleted_at"`
-	DeletedBy       *uuid.UUID `json:"deleted_by"`
+	Name            string     `bson:"name" json:"name"`
+	Description     *string    `bson:"description" json:"description"`
+	Logo            *string    `bson:"logo" json:"logo"`
+	Industry        string     `bson:"industry" json:"industry"`
+	PrimaryCurrency string     `bson:"primary_currency" json:"primary_currency"`
+	OwnerID         uuid.UUID  `bson:"owner_id" json:"owner_id"`
+	Currencies      []string   `bson:"currencies" json:"currencies"`
+	CreatedAt       time.Time  `bson:"created_at" json:"created_at"`
+	CreatedBy       uuid.UUID  `bson:"created_by" json:"created_by"`
+	UpdatedAt       time.Time  `bson:"updated_at" json:"updated_at"`
+	UpdatedBy       *uuid.UUID `bson:"updated_by" json:"updated_by"`
+	DeletedAt       *time.Time `bson:"deleted_at" json:"deleted_at"`
+	DeletedBy       *uuid.UUID `bson:"deleted_by" json:"deleted_by"`
 }
 type BusinessUser struct {
 	UserID     uuid.UUID  `bson:"user_id" json:"user_id"`
@@ -73,3 +94,142 @@ type BusinessUser struct {
 	DeletedAt  *time.Time `bson:"deleted_at" json:"deleted_at"`
 	DeletedBy  *uuid.UUID `bson:"deleted_by" json:"deleted_by"`
 }
+
+type DeliveryStatus string
+
+const (
+	DeliveryQueued DeliveryStatus = "queued"
+	DeliverySent   DeliveryStatus = "sent"
+	DeliveryFailed DeliveryStatus = "failed"
+	// reported by the provider after the message was sent
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryBounced   DeliveryStatus = "bounced"
+	// every recipient turned the notifications of the event off
+	DeliverySuppressed DeliveryStatus = "suppressed"
+	// a recipient is in the quiet hours, it is sent once they are over, see NotBefore
+	DeliveryDeferred DeliveryStatus = "deferred"
+)
+
+// Delivery is the record of a notification sent through one channel, there is
+// one per notification event and channel. the request is kept as received so
+// that the delivery can be rendered and sent again.
+type Delivery struct {
+	ID         uuid.UUID            `bson:"_id" json:"id"`
+	EventID    uuid.UUID            `bson:"event_id" json:"event_id"`
+	Event      notification.Event   `bson:"event" json:"event"`
+	Kind       notification.Kind    `bson:"kind" json:"kind"`
+	Channel    notification.Channel `bson:"channel" json:"channel"`
+	BusinessID *uuid.UUID           `bson:"business_id" json:"business_id"`
+	// UserID is the member a broadcast was fanned out to, a broadcast has a delivery per member
+	UserID     *uuid.UUID `bson:"user_id" json:"user_id"`
+	Recipients []string   `bson:"recipients" json:"recipients"`
+	// Data and Tokens are the json encoded channel data and template tokens
+	Data   []byte `bson:"data" json:"-"`
+	Tokens []byte `bson:"tokens" json:"-"`
+	// TokensRedacted is set when the secret tokens were left out, the delivery can not be resent
+	TokensRedacted bool `bson:"tokens_redacted" json:"tokens_redacted"`
+	// Subject is the rendered subject, the body is only kept as a hash
+	Subject  string `bson:"subject" json:"subject"`
+	BodyHash string `bson:"body_hash" json:"body_hash"`
+	// ProviderMessageIDs has an id per message, some channels send a message per recipient
+	ProviderMessageIDs []string `bson:"provider_message_ids" json:"provider_message_ids"`
+	// RecipientMessageIDs has the id of the message of every recipient sent to, so that the
+	// retry of a delivery which failed half way skips them
+	RecipientMessageIDs map[string]string `bson:"recipient_message_ids" json:"recipient_message_ids"`
+	Status              DeliveryStatus    `bson:"status" json:"status"`
+	Attempts            int               `bson:"attempts" json:"attempts"`
+	Error               *string           `bson:"error" json:"error"`
+	SentAt              *time.Time        `bson:"sent_at" json:"sent_at"`
+	// NotBefore is when a deferred delivery is sent
+	NotBefore *time.Time `bson:"not_before" json:"not_before"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+type BroadcastStatus string
+
+const (
+	BroadcastQueued BroadcastStatus = "queued"
+	BroadcastSent   BroadcastStatus = "sent"
+	BroadcastFailed BroadcastStatus = "failed"
+)
+
+// Broadcast is a broadcast event fanned out to the members of its business by the
+// notifier, apart from the consumer so that a large business does not hold up the
+// events of the partition. the instance which claimed it keeps pushing NotBefore
+// ahead while it sends, another one takes over once it stops.
+type Broadcast struct {
+	// ID is the one of the event, a redelivered event is not queued twice
+	ID uuid.UUID `bson:"_id" json:"id"`
+	// Payload is the json encoded event
+	Payload []byte          `bson:"payload" json:"-"`
+	Status  BroadcastStatus `bson:"status" json:"status"`
+	// After is the last member sent to, a broadcast taken over resumes after it
+	After     *uuid.UUID `bson:"after" json:"after"`
+	Attempts  int        `bson:"attempts" json:"attempts"`
+	Error     *string    `bson:"error" json:"error"`
+	NotBefore time.Time  `bson:"not_before" json:"not_before"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// OptIn is the consent of a recipient to receive messages on a channel,
+// channels like whatsapp only deliver to the recipients who opted in
+type OptIn struct {
+	Channel   notification.Channel `bson:"channel" json:"channel"`
+	Recipient string               `bson:"recipient" json:"recipient"`
+	OptedIn   bool                 `bson:"opted_in" json:"opted_in"`
+	// Source is where the consent was given or withdrawn, e.g. signup or webhook
+	Source    string    `bson:"source" json:"source"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// InboxItem is an in-app notification of a user, those sent in the context
+// of a business carry its id
+type InboxItem struct {
+	ID         uuid.UUID          `bson:"_id" json:"id"`
+	DeliveryID uuid.UUID          `bson:"delivery_id" json:"delivery_id"`
+	UserID     uuid.UUID          `bson:"user_id" json:"user_id"`
+	BusinessID *uuid.UUID         `bson:"business_id" json:"business_id"`
+	Event      notification.Event `bson:"event" json:"event"`
+	Title      string             `bson:"title" json:"title"`
+	Body       string             `bson:"body" json:"body"`
+	// Link is opened when the notification is clicked
+	Link      string     `bson:"link" json:"link"`
+	ReadAt    *time.Time `bson:"read_at" json:"read_at"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+}
+
+// PushSubscription is a browser of a user subscribed to web push
+type PushSubscription struct {
+	ID        uuid.UUID `bson:"_id" json:"id"`
+	UserID    uuid.UUID `bson:"user_id" json:"user_id"`
+	Endpoint  string    `bson:"endpoint" json:"endpoint"`
+	P256dh    string    `bson:"p256dh" json:"-"`
+	Auth      string    `bson:"auth" json:"-"`
+	UserAgent string    `bson:"user_agent" json:"user_agent"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Preference is what a user wants to be notified of, the one of a business applies
+// to the notifications sent on its behalf and the one without to all the others.
+// transactional notifications are always sent.
+type Preference struct {
+	ID         uuid.UUID  `bson:"_id" json:"id"`
+	UserID     uuid.UUID  `bson:"user_id" json:"user_id"`
+	BusinessID *uuid.UUID `bson:"business_id" json:"business_id"`
+	// Channels turns a channel of a category on or off, the missing ones are on
+	Channels   map[notification.Category]map[notification.Channel]bool `bson:"channels" json:"channels"`
+	QuietHours *QuietHours                                             `bson:"quiet_hours" json:"quiet_hours"`
+	CreatedAt  time.Time                                               `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time                                               `bson:"updated_at" json:"updated_at"`
+}
+
+// QuietHours holds the sms and whatsapp messages back, e.g. from 22:00 to 08:00
+type QuietHours struct {
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+	Timezone string `bson:"timezone" json:"timezone"`
+}
@@ -7,10 +7,29 @@ import (
 	"path"
 
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+var (
+	mongoConnections = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongo_pool_connections",
+		Help: "Connections of the mongo pools, open or in use.",
+	}, []string{"state"})
+	mongoCheckoutFailures = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "mongo_pool_checkout_failures_total",
+		Help: "Connections that could not be checked out of the pools.",
+	})
+	mongoCommands = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "Time taken by the mongo commands, by command and result.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"command", "result"})
+)
+
 func NotInitializedErr(what string) error {
 	return fmt.Errorf("%s is not initialized, have you forgot to call Connect() ?", what)
 }
@@ -49,7 +68,16 @@ func (m *MongoDB) Connect() error {
 	}
 	m.dbName = dbName
 
-	opts := options.Client().ApplyURI(m.connString)
+	opts := options.Client().ApplyURI(m.connString).
+		SetPoolMonitor(&event.PoolMonitor{Event: observePool}).
+		SetMonitor(&event.CommandMonitor{
+			Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+				mongoCommands.WithLabelValues(e.CommandName, "ok").Observe(e.Duration.Seconds())
+			},
+			Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+				mongoCommands.WithLabelValues(e.CommandName, "error").Observe(e.Duration.Seconds())
+			},
+		})
 	client, err := mongo.Connect(opts)
 	if err != nil {
 		return err
@@ -81,3 +109,19 @@ func (m *MongoDB) Collection(name string) *mongo.Collection {
 	}
 	return m.client.Database(m.dbName).Collection(name)
 }
+
+// observePool keeps the connection gauges of the pools of every server of the deployment
+func observePool(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		mongoConnections.WithLabelValues("open").Inc()
+	case event.ConnectionClosed:
+		mongoConnections.WithLabelValues("open").Dec()
+	case event.ConnectionCheckedOut:
+		mongoConnections.WithLabelValues("in_use").Inc()
+	case event.ConnectionCheckedIn:
+		mongoConnections.WithLabelValues("in_use").Dec()
+	case event.ConnectionCheckOutFailed:
+		mongoCheckoutFailures.Inc()
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/database"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// migration changes the stored documents once, the applied ones are recorded by name
+// in the migrations collection
+type migration struct {
+	name string
+	up   func(ctx context.Context, db database.Database) error
+}
+
+// migrations run in order, a new one goes at the end
+var migrations = []migration{
+	// the businesses were stored with the default keys of the driver before they got bson
+	// tags, the syncs missed them since they filter on updated_at
+	{name: "0001_business_field_names", up: renameFields("businesses", map[string]string{
+		"primarycurrency": "primary_currency",
+		"ownerid":         "owner_id",
+		"createdat":       "created_at",
+		"createdby":       "created_by",
+		"updatedat":       "updated_at",
+		"updatedby":       "updated_by",
+		"deletedat":       "deleted_at",
+		"deletedby":       "deleted_by",
+	})},
+	// the otps and invitation urls were stored along the other tokens
+	{name: "0002_redact_delivery_tokens", up: redactDeliveryTokens},
+	// a delivery got an id per message, e.g. a whatsapp per recipient
+	{name: "0003_delivery_provider_message_ids", up: providerMessageIDs},
+}
+
+// Migrate applies the migrations not applied yet, before the indexes are created on their fields
+func (r *repository) Migrate(ctx context.Context) error {
+	applied := r.db.Collection("migrations")
+	for _, m := range migrations {
+		err := applied.FindOne(ctx, bson.M{"_id": m.name}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if err := m.up(ctx, r.db); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		if _, err := applied.InsertOne(ctx, bson.M{"_id": m.name, "applied_at": time.Now()}); err != nil {
+			return err
+		}
+		logger.Info().Str("migration", m.name).Msg("migration applied")
+	}
+	return nil
+}
+
+// renameFields renames the fields of the documents, a document already having the new field
+// keeps it and loses the old one
+func renameFields(collection string, fields map[string]string) func(context.Context, database.Database) error {
+	return func(ctx context.Context, db database.Database) error {
+		c := db.Collection(collection)
+		for old, renamed := range fields {
+			_, err := c.UpdateMany(ctx,
+				bson.M{old: bson.M{"$exists": true}, renamed: bson.M{"$exists": false}},
+				bson.M{"$rename": bson.M{old: renamed}})
+			if err != nil {
+				return err
+			}
+			_, err = c.UpdateMany(ctx, bson.M{old: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{old: ""}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func redactDeliveryTokens(ctx context.Context, db database.Database) error {
+	c := db.Collection("deliveries")
+	cursor, err := c.Find(ctx, bson.M{"tokens_redacted": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var delivery dao.Delivery
+		if err := cursor.Decode(&delivery); err != nil {
+			return err
+		}
+		tokens, redacted, err := notification.RedactTokens(delivery.Tokens)
+		if err != nil {
+			return err
+		}
+		if !redacted {
+			continue
+		}
+		_, err = c.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": bson.M{"tokens": tokens, "tokens_redacted": true}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func providerMessageIDs(ctx context.Context, db database.Database) error {
+	c := db.Collection("deliveries")
+	err := c.Indexes().DropOne(ctx, "provider_message_id_1")
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err = c.UpdateMany(ctx,
+		bson.M{"provider_message_id": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			bson.D{{Key: "$set", Value: bson.M{"provider_message_ids": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$provider_message_id", ""}}, ""}},
+				bson.A{},
+				bson.A{"$provider_message_id"},
+			}}}}},
+			bson.D{{Key: "$unset", Value: "provider_message_id"}},
+		})
+	return err
+}
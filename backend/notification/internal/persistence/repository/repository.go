@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/database"
 	"github.com/aritradevelops/billbharat/backend/shared/notification"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
@@ -19,12 +22,94 @@ type FindTemplateParams struct {
 	Mimetype string               `bson:"mimetype" json:"mimetype"`
 }
 
+type ListTemplatesParams struct {
+	Event   notification.Event
+	Channel notification.Channel
+	Locale  string
+	Scope   string
+	Page    int
+	Limit   int
+}
+
+type ListDeliveriesParams struct {
+	Recipient  string
+	BusinessID *uuid.UUID
+	Status     dao.DeliveryStatus
+	Page       int
+	Limit      int
+}
+
+type SetDeliveryStatusParams struct {
+	Channel           notification.Channel
+	ProviderMessageID string
+	Status            dao.DeliveryStatus
+	Error             *string
+}
+
+type ListInboxItemsParams struct {
+	UserID     uuid.UUID
+	BusinessID *uuid.UUID
+	Unread     bool
+	Page       int
+	Limit      int
+}
+
+type ListBusinessMembersParams struct {
+	BusinessID uuid.UUID
+	Roles      []string
+	// After is the last member of the previous batch, the members come by id
+	After *uuid.UUID
+	Limit int
+}
+
+type SetPreferenceChannelParams struct {
+	UserID     uuid.UUID
+	BusinessID *uuid.UUID
+	Category   notification.Category
+	Channel    notification.Channel
+	Enabled    bool
+}
+
 type Repository interface {
 	CreateTemplate(ctx context.Context, template dao.Template) (dao.Template, error)
 	FindTemplate(ctx context.Context, params FindTemplateParams) (dao.Template, error)
+	FindTemplateByID(ctx context.Context, id uuid.UUID) (dao.Template, error)
+	ListTemplates(ctx context.Context, params ListTemplatesParams) ([]dao.Template, error)
+	UpdateTemplate(ctx context.Context, template dao.Template) error
+	CreateTemplateVersion(ctx context.Context, version dao.TemplateVersion) error
+	FindTemplateVersion(ctx context.Context, templateID uuid.UUID, version int) (dao.TemplateVersion, error)
+	ListTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]dao.TemplateVersion, error)
 	SyncUser(ctx context.Context, user dao.User) error
+	FindUserByRecipient(ctx context.Context, channel notification.Channel, recipient string) (dao.User, error)
 	SyncBusiness(ctx context.Context, business dao.Business) error
+	FindBusiness(ctx context.Context, id uuid.UUID) (dao.Business, error)
 	SyncBusinessUser(ctx context.Context, businessUser dao.BusinessUser) error
+	ListBusinessMembers(ctx context.Context, params ListBusinessMembersParams) ([]dao.User, *uuid.UUID, error)
+	FindOrCreateDelivery(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error)
+	FindDelivery(ctx context.Context, id uuid.UUID) (dao.Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery dao.Delivery) error
+	ListDeliveries(ctx context.Context, params ListDeliveriesParams) ([]dao.Delivery, error)
+	SetDeliveryStatus(ctx context.Context, params SetDeliveryStatusParams) error
+	SetOptIn(ctx context.Context, optIn dao.OptIn) (dao.OptIn, error)
+	FindOptIn(ctx context.Context, channel notification.Channel, recipient string) (dao.OptIn, error)
+	FindOptedIn(ctx context.Context, channel notification.Channel, recipients []string) ([]string, error)
+	CreateInboxItem(ctx context.Context, item dao.InboxItem) (dao.InboxItem, bool, error)
+	ListInboxItems(ctx context.Context, params ListInboxItemsParams) ([]dao.InboxItem, error)
+	CountUnreadInboxItems(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) (int64, error)
+	MarkInboxItemsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	SavePushSubscription(ctx context.Context, subscription dao.PushSubscription) (dao.PushSubscription, error)
+	FindPushSubscriptions(ctx context.Context, userID uuid.UUID) ([]dao.PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, endpoint string) error
+	ClaimDeferredDelivery(ctx context.Context, now time.Time) (dao.Delivery, error)
+	QueueBroadcast(ctx context.Context, broadcast dao.Broadcast) error
+	ClaimBroadcast(ctx context.Context, now time.Time, lease time.Duration) (dao.Broadcast, error)
+	UpdateBroadcast(ctx context.Context, broadcast dao.Broadcast) error
+	FindPreferences(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) ([]dao.Preference, error)
+	FindPreference(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) (dao.Preference, error)
+	SavePreference(ctx context.Context, preference dao.Preference) (dao.Preference, error)
+	SetPreferenceChannel(ctx context.Context, params SetPreferenceChannelParams) error
+	Migrate(ctx context.Context) error
+	EnsureIndexes(ctx context.Context) error
 }
 
 type repository struct {
@@ -60,29 +145,672 @@ func (r *repository) FindTemplate(ctx context.Context, params FindTemplateParams
 	return template, nil
 }
 
-func (r *repository) SyncUser(ctx context.Context, user dao.User) error {
-	collection := r.db.Collection("users")
-	_, err := collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": user}, options.UpdateOne().SetUpsert(true))
+func (r *repository) FindTemplateByID(ctx context.Context, id uuid.UUID) (dao.Template, error) {
+	collection := r.db.Collection("templates")
+	var template dao.Template
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&template); err != nil {
+		return dao.Template{}, err
+	}
+	return template, nil
+}
+
+func (r *repository) ListTemplates(ctx context.Context, params ListTemplatesParams) ([]dao.Template, error) {
+	collection := r.db.Collection("templates")
+	filter := bson.M{}
+	if params.Event != "" {
+		filter["event"] = params.Event
+	}
+	if params.Channel != "" {
+		filter["channel"] = params.Channel
+	}
+	if params.Locale != "" {
+		filter["locale"] = params.Locale
+	}
+	if params.Scope != "" {
+		filter["scope"] = params.Scope
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "event", Value: 1}, {Key: "channel", Value: 1}, {Key: "locale", Value: 1}}).
+		SetSkip(int64((params.Page - 1) * params.Limit)).
+		SetLimit(int64(params.Limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	templates := []dao.Template{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateTemplate replaces the template if it is still at the previous version,
+// a concurrent change makes it return mongo.ErrNoDocuments
+func (r *repository) UpdateTemplate(ctx context.Context, template dao.Template) error {
+	collection := r.db.Collection("templates")
+	result, err := collection.ReplaceOne(ctx, bson.M{"_id": template.ID, "version": template.Version - 1}, template)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
 	return nil
 }
 
+func (r *repository) CreateTemplateVersion(ctx context.Context, version dao.TemplateVersion) error {
+	collection := r.db.Collection("template_versions")
+	_, err := collection.InsertOne(ctx, version)
+	return err
+}
+
+func (r *repository) FindTemplateVersion(ctx context.Context, templateID uuid.UUID, version int) (dao.TemplateVersion, error) {
+	collection := r.db.Collection("template_versions")
+	var templateVersion dao.TemplateVersion
+	err := collection.FindOne(ctx, bson.M{"template_id": templateID, "version": version}).Decode(&templateVersion)
+	if err != nil {
+		return dao.TemplateVersion{}, err
+	}
+	return templateVersion, nil
+}
+
+// ListTemplateVersions lists the history of the template, latest first
+func (r *repository) ListTemplateVersions(ctx context.Context, templateID uuid.UUID) ([]dao.TemplateVersion, error) {
+	collection := r.db.Collection("template_versions")
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{"template_id": templateID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	versions := []dao.TemplateVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *repository) SyncUser(ctx context.Context, user dao.User) error {
+	collection := r.db.Collection("users")
+	return syncIfNewer(ctx, collection, bson.M{"_id": user.ID}, user.UpdatedAt, user)
+}
+
+// FindUserByRecipient finds the user a recipient of the channel belongs to,
+// email goes by the email, sms and whatsapp by the phone and push by the id
+func (r *repository) FindUserByRecipient(ctx context.Context, channel notification.Channel, recipient string) (dao.User, error) {
+	collection := r.db.Collection("users")
+	filter := bson.M{"deleted_at": nil}
+	switch channel {
+	case notification.EMAIL:
+		filter["email"] = recipient
+	case notification.SMS, notification.WHATSAPP:
+		filter["phone"] = recipient
+	case notification.PUSH:
+		id, err := uuid.Parse(recipient)
+		if err != nil {
+			return dao.User{}, mongo.ErrNoDocuments
+		}
+		filter["_id"] = id
+	default:
+		return dao.User{}, mongo.ErrNoDocuments
+	}
+	var user dao.User
+	if err := collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return dao.User{}, err
+	}
+	return user, nil
+}
+
 func (r *repository) SyncBusiness(ctx context.Context, business dao.Business) error {
 	collection := r.db.Collection("businesses")
-	_, err := collection.UpdateOne(ctx, bson.M{"_id": business.ID}, bson.M{"$set": business}, options.UpdateOne().SetUpsert(true))
-	if err != nil {
-		return err
+	return syncIfNewer(ctx, collection, bson.M{"_id": business.ID}, business.UpdatedAt, business)
+}
+
+func (r *repository) FindBusiness(ctx context.Context, id uuid.UUID) (dao.Business, error) {
+	collection := r.db.Collection("businesses")
+	var business dao.Business
+	if err := collection.FindOne(ctx, bson.M{"_id": id, "deleted_at": nil}).Decode(&business); err != nil {
+		return dao.Business{}, err
 	}
-	return nil
+	return business, nil
 }
 
 func (r *repository) SyncBusinessUser(ctx context.Context, businessUser dao.BusinessUser) error {
 	collection := r.db.Collection("business_users")
-	_, err := collection.UpdateOne(ctx, bson.M{"user_id": businessUser.UserID, "business_id": businessUser.BusinessID}, bson.M{"$set": businessUser}, options.UpdateOne().SetUpsert(true))
+	return syncIfNewer(ctx, collection, bson.M{"user_id": businessUser.UserID, "business_id": businessUser.BusinessID}, businessUser.UpdatedAt, businessUser)
+}
+
+// ListBusinessMembers returns a batch of the active users who are members of the business, and
+// the member the next batch comes after, which is nil after the last batch
+func (r *repository) ListBusinessMembers(ctx context.Context, params ListBusinessMembersParams) ([]dao.User, *uuid.UUID, error) {
+	filter := bson.M{"business_id": params.BusinessID, "deleted_at": nil}
+	if len(params.Roles) > 0 {
+		filter["role"] = bson.M{"$in": params.Roles}
+	}
+	if params.After != nil {
+		filter["user_id"] = bson.M{"$gt": *params.After}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}}).SetLimit(int64(params.Limit))
+	cursor, err := r.db.Collection("business_users").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var businessUsers []dao.BusinessUser
+	if err := cursor.All(ctx, &businessUsers); err != nil {
+		return nil, nil, err
+	}
+	if len(businessUsers) == 0 {
+		return []dao.User{}, nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(businessUsers))
+	for _, businessUser := range businessUsers {
+		ids = append(ids, businessUser.UserID)
+	}
+	// the deactivated and deleted users are left out of the batch, not out of the paging
+	cursor, err = r.db.Collection("users").Find(ctx, bson.M{
+		"_id":            bson.M{"$in": ids},
+		"deleted_at":     nil,
+		"deactivated_at": nil,
+	}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	users := []dao.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, nil, err
+	}
+	var next *uuid.UUID
+	if len(businessUsers) == params.Limit {
+		next = &ids[len(ids)-1]
+	}
+	return users, next, nil
+}
+
+// syncIfNewer upserts the document unless the stored copy is newer, so that
+// replayed events and snapshots can arrive in any order. when the stored copy
+// is newer the filter does not match and the upsert hits the unique key.
+func syncIfNewer(ctx context.Context, collection *mongo.Collection, key bson.M, updatedAt time.Time, doc any) error {
+	filter := bson.M{"updated_at": bson.M{"$lte": updatedAt}}
+	for k, v := range key {
+		filter[k] = v
+	}
+	_, err := collection.UpdateOne(ctx, filter, bson.M{"$set": doc}, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// FindOrCreateDelivery returns the delivery of the event on the channel, and of the member
+// for a broadcast, creating it when the event is seen for the first time. on a redelivery
+// of the event the stored one is returned as is, so the channels already sent can be skipped.
+func (r *repository) FindOrCreateDelivery(ctx context.Context, delivery dao.Delivery) (dao.Delivery, error) {
+	collection := r.db.Collection("deliveries")
+	filter := bson.M{"event_id": delivery.EventID, "channel": delivery.Channel, "user_id": delivery.UserID}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored dao.Delivery
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": delivery}, opts).Decode(&stored)
+	if err != nil {
+		return dao.Delivery{}, err
+	}
+	return stored, nil
+}
+
+func (r *repository) FindDelivery(ctx context.Context, id uuid.UUID) (dao.Delivery, error) {
+	collection := r.db.Collection("deliveries")
+	var delivery dao.Delivery
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&delivery); err != nil {
+		return dao.Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// UpdateDelivery stores the outcome of a send attempt
+func (r *repository) UpdateDelivery(ctx context.Context, delivery dao.Delivery) error {
+	collection := r.db.Collection("deliveries")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": bson.M{
+		"subject":               delivery.Subject,
+		"body_hash":             delivery.BodyHash,
+		"provider_message_ids":  delivery.ProviderMessageIDs,
+		"recipient_message_ids": delivery.RecipientMessageIDs,
+		"status":                delivery.Status,
+		"attempts":              delivery.Attempts,
+		"error":                 delivery.Error,
+		"sent_at":               delivery.SentAt,
+		"not_before":            delivery.NotBefore,
+		"updated_at":            delivery.UpdatedAt,
+	}})
+	return err
+}
+
+// ClaimDeferredDelivery queues a deferred delivery which is due, so that only one
+// instance sends it. it returns mongo.ErrNoDocuments when none is due.
+func (r *repository) ClaimDeferredDelivery(ctx context.Context, now time.Time) (dao.Delivery, error) {
+	collection := r.db.Collection("deliveries")
+	filter := bson.M{"status": dao.DeliveryDeferred, "not_before": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"status": dao.DeliveryQueued, "updated_at": now}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "not_before", Value: 1}}).SetReturnDocument(options.After)
+	var delivery dao.Delivery
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery); err != nil {
+		return dao.Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// QueueBroadcast stores the broadcast for the notifier to fan out, a broadcast which
+// is there already is left as it is
+func (r *repository) QueueBroadcast(ctx context.Context, broadcast dao.Broadcast) error {
+	_, err := r.db.Collection("broadcasts").InsertOne(ctx, broadcast)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimBroadcast takes a queued broadcast which is due for the lease, the ones of an
+// instance which stopped are due once their lease is over. it returns mongo.ErrNoDocuments
+// when none is due.
+func (r *repository) ClaimBroadcast(ctx context.Context, now time.Time, lease time.Duration) (dao.Broadcast, error) {
+	collection := r.db.Collection("broadcasts")
+	filter := bson.M{"status": dao.BroadcastQueued, "not_before": bson.M{"$lte": now}}
+	update := bson.M{
+		"$set": bson.M{"not_before": now.Add(lease), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "not_before", Value: 1}}).SetReturnDocument(options.After)
+	var broadcast dao.Broadcast
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&broadcast); err != nil {
+		return dao.Broadcast{}, err
+	}
+	return broadcast, nil
+}
+
+// UpdateBroadcast records the progress or the outcome of the fan out
+func (r *repository) UpdateBroadcast(ctx context.Context, broadcast dao.Broadcast) error {
+	_, err := r.db.Collection("broadcasts").UpdateOne(ctx, bson.M{"_id": broadcast.ID}, bson.M{"$set": bson.M{
+		"status":     broadcast.Status,
+		"after":      broadcast.After,
+		"error":      broadcast.Error,
+		"not_before": broadcast.NotBefore,
+		"updated_at": broadcast.UpdatedAt,
+	}})
+	return err
+}
+
+// SetDeliveryStatus records the status reported by the provider for a message. a delivery
+// with several messages stays bounced once one of them bounced
+func (r *repository) SetDeliveryStatus(ctx context.Context, params SetDeliveryStatusParams) error {
+	collection := r.db.Collection("deliveries")
+	filter := bson.M{
+		"channel":              params.Channel,
+		"provider_message_ids": params.ProviderMessageID,
+	}
+	if params.Status == dao.DeliveryDelivered {
+		filter["status"] = bson.M{"$ne": dao.DeliveryBounced}
+	}
+	_, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"status":     params.Status,
+		"error":      params.Error,
+		"updated_at": time.Now(),
+	}})
+	return err
+}
+
+// ListDeliveries returns the latest deliveries first
+func (r *repository) ListDeliveries(ctx context.Context, params ListDeliveriesParams) ([]dao.Delivery, error) {
+	collection := r.db.Collection("deliveries")
+	filter := bson.M{}
+	if params.Recipient != "" {
+		filter["recipients"] = params.Recipient
+	}
+	if params.BusinessID != nil {
+		filter["business_id"] = *params.BusinessID
+	}
+	if params.Status != "" {
+		filter["status"] = params.Status
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((params.Page - 1) * params.Limit)).
+		SetLimit(int64(params.Limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []dao.Delivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// SetOptIn records the consent of the recipient, the first one keeps its created_at
+func (r *repository) SetOptIn(ctx context.Context, optIn dao.OptIn) (dao.OptIn, error) {
+	collection := r.db.Collection("opt_ins")
+	filter := bson.M{"channel": optIn.Channel, "recipient": optIn.Recipient}
+	update := bson.M{
+		"$set": bson.M{
+			"opted_in":   optIn.OptedIn,
+			"source":     optIn.Source,
+			"updated_at": optIn.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{"created_at": optIn.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored dao.OptIn
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&stored); err != nil {
+		return dao.OptIn{}, err
+	}
+	return stored, nil
+}
+
+func (r *repository) FindOptIn(ctx context.Context, channel notification.Channel, recipient string) (dao.OptIn, error) {
+	collection := r.db.Collection("opt_ins")
+	var optIn dao.OptIn
+	if err := collection.FindOne(ctx, bson.M{"channel": channel, "recipient": recipient}).Decode(&optIn); err != nil {
+		return dao.OptIn{}, err
+	}
+	return optIn, nil
+}
+
+// FindOptedIn returns the recipients who opted in to the channel
+func (r *repository) FindOptedIn(ctx context.Context, channel notification.Channel, recipients []string) ([]string, error) {
+	collection := r.db.Collection("opt_ins")
+	cursor, err := collection.Find(ctx, bson.M{
+		"channel":   channel,
+		"recipient": bson.M{"$in": recipients},
+		"opted_in":  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var optIns []dao.OptIn
+	if err := cursor.All(ctx, &optIns); err != nil {
+		return nil, err
+	}
+	optedIn := make([]string, 0, len(optIns))
+	for _, optIn := range optIns {
+		optedIn = append(optedIn, optIn.Recipient)
+	}
+	return optedIn, nil
+}
+
+// CreateInboxItem stores the item once per delivery and user, so a resent
+// delivery does not show up twice in the inbox. inserted is false for the
+// item stored already
+func (r *repository) CreateInboxItem(ctx context.Context, item dao.InboxItem) (_ dao.InboxItem, inserted bool, _ error) {
+	collection := r.db.Collection("inbox_items")
+	filter := bson.M{"delivery_id": item.DeliveryID, "user_id": item.UserID}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored dao.InboxItem
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": item}, opts).Decode(&stored)
+	if err != nil {
+		return dao.InboxItem{}, false, err
+	}
+	return stored, stored.ID == item.ID, nil
+}
+
+func inboxFilter(userID uuid.UUID, businessID *uuid.UUID) bson.M {
+	filter := bson.M{"user_id": userID}
+	if businessID != nil {
+		filter["business_id"] = *businessID
+	}
+	return filter
+}
+
+// ListInboxItems returns the latest items first
+func (r *repository) ListInboxItems(ctx context.Context, params ListInboxItemsParams) ([]dao.InboxItem, error) {
+	collection := r.db.Collection("inbox_items")
+	filter := inboxFilter(params.UserID, params.BusinessID)
+	if params.Unread {
+		filter["read_at"] = nil
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((params.Page - 1) * params.Limit)).
+		SetLimit(int64(params.Limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := []dao.InboxItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *repository) CountUnreadInboxItems(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) (int64, error) {
+	collection := r.db.Collection("inbox_items")
+	filter := inboxFilter(userID, businessID)
+	filter["read_at"] = nil
+	return collection.CountDocuments(ctx, filter)
+}
+
+// MarkInboxItemsRead marks the unread items of the user as read, all of them when ids is empty
+func (r *repository) MarkInboxItemsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	collection := r.db.Collection("inbox_items")
+	filter := bson.M{"user_id": userID, "read_at": nil}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// SavePushSubscription upserts by endpoint, a browser resubscribing with
+// a new key or after another user logged in takes the subscription over
+func (r *repository) SavePushSubscription(ctx context.Context, subscription dao.PushSubscription) (dao.PushSubscription, error) {
+	collection := r.db.Collection("push_subscriptions")
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    subscription.UserID,
+			"p256dh":     subscription.P256dh,
+			"auth":       subscription.Auth,
+			"user_agent": subscription.UserAgent,
+			"updated_at": subscription.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{"_id": subscription.ID, "created_at": subscription.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored dao.PushSubscription
+	err := collection.FindOneAndUpdate(ctx, bson.M{"endpoint": subscription.Endpoint}, update, opts).Decode(&stored)
+	if err != nil {
+		return dao.PushSubscription{}, err
+	}
+	return stored, nil
+}
+
+func (r *repository) FindPushSubscriptions(ctx context.Context, userID uuid.UUID) ([]dao.PushSubscription, error) {
+	collection := r.db.Collection("push_subscriptions")
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := []dao.PushSubscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *repository) DeletePushSubscription(ctx context.Context, endpoint string) error {
+	collection := r.db.Collection("push_subscriptions")
+	_, err := collection.DeleteOne(ctx, bson.M{"endpoint": endpoint})
+	return err
+}
+
+func preferenceFilter(userID uuid.UUID, businessID *uuid.UUID) bson.M {
+	filter := bson.M{"user_id": userID, "business_id": nil}
+	if businessID != nil {
+		filter["business_id"] = *businessID
+	}
+	return filter
+}
+
+// FindPreferences returns the preference of the user and the one for the business if any
+func (r *repository) FindPreferences(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) ([]dao.Preference, error) {
+	collection := r.db.Collection("preferences")
+	filter := bson.M{"user_id": userID, "business_id": nil}
+	if businessID != nil {
+		filter["business_id"] = bson.M{"$in": bson.A{nil, *businessID}}
+	}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	preferences := []dao.Preference{}
+	if err := cursor.All(ctx, &preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (r *repository) FindPreference(ctx context.Context, userID uuid.UUID, businessID *uuid.UUID) (dao.Preference, error) {
+	collection := r.db.Collection("preferences")
+	var preference dao.Preference
+	if err := collection.FindOne(ctx, preferenceFilter(userID, businessID)).Decode(&preference); err != nil {
+		return dao.Preference{}, err
+	}
+	return preference, nil
+}
+
+// SavePreference upserts by user and business, the first one keeps its id and created_at
+func (r *repository) SavePreference(ctx context.Context, preference dao.Preference) (dao.Preference, error) {
+	collection := r.db.Collection("preferences")
+	update := bson.M{
+		"$set": bson.M{
+			"channels":    preference.Channels,
+			"quiet_hours": preference.QuietHours,
+			"updated_at":  preference.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{"_id": preference.ID, "created_at": preference.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored dao.Preference
+	err := collection.FindOneAndUpdate(ctx, preferenceFilter(preference.UserID, preference.BusinessID), update, opts).Decode(&stored)
+	if err != nil {
+		return dao.Preference{}, err
+	}
+	return stored, nil
+}
+
+// SetPreferenceChannel turns a single channel of a category on or off, leaving the rest of the preference as is
+func (r *repository) SetPreferenceChannel(ctx context.Context, params SetPreferenceChannelParams) error {
+	collection := r.db.Collection("preferences")
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"channels." + string(params.Category) + "." + string(params.Channel): params.Enabled,
+			"updated_at": now,
+		},
+		"$setOnInsert": bson.M{"_id": uuid.New(), "quiet_hours": nil, "created_at": now},
+	}
+	_, err := collection.UpdateOne(ctx, preferenceFilter(params.UserID, params.BusinessID), update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// EnsureIndexes creates the unique keys the syncs rely on and the indexes of the lookups
+func (r *repository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("templates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "event", Value: 1}, {Key: "channel", Value: 1}, {Key: "locale", Value: 1},
+			{Key: "scope", Value: 1}, {Key: "mimetype", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = r.db.Collection("template_versions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "template_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("business_users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "business_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// the members of a broadcast
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "phone", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	// the key of the deliveries got the member of the broadcasts, the old one
+	// would stop a broadcast at its first member
+	err = r.db.Collection("deliveries").Indexes().DropOne(ctx, "event_id_1_channel_1")
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err = r.db.Collection("deliveries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "event_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "provider_message_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "recipients", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "not_before", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("opt_ins").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "channel", Value: 1}, {Key: "recipient", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("inbox_items").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "delivery_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("broadcasts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "not_before", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("preferences").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "business_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("push_subscriptions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "endpoint", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// isIndexNotFound is true when the index, or the whole collection, does not exist
+func isIndexNotFound(err error) bool {
+	var serverErr mongo.ServerError
+	// 26 is NamespaceNotFound and 27 IndexNotFound
+	return errors.As(err, &serverErr) && (serverErr.HasErrorCode(26) || serverErr.HasErrorCode(27))
 }
@@ -0,0 +1,114 @@
+package authn
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/gofiber/fiber/v2"
+)
+
+const authUserKey = "auth_user"
+
+// Middleware authenticates the users with the identity the broker forwards when the
+// gateway is set, the requests that do not come through the broker need an access token.
+// verifyToken checks the access token of the forwarded identities too.
+func Middleware(tokens *identity.TokenVerifier, gateway *identity.Signer, verifyToken bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		encoded := c.Get(identity.Header)
+		if gateway == nil || encoded == "" {
+			payload, err := verifyAccessToken(c, tokens)
+			if err != nil {
+				return err
+			}
+			return authenticated(c, payload)
+		}
+		forwarded, err := gateway.Verify(encoded, c.Get(identity.SignatureHeader), time.Now())
+		if err != nil {
+			logger.Ctx(c.UserContext()).Info().Err(err).Msg("Forwarded identity verification failed")
+			return fiber.ErrUnauthorized
+		}
+		if verifyToken {
+			payload, err := verifyAccessToken(c, tokens)
+			if err != nil {
+				return err
+			}
+			if payload.UserID != forwarded.UserID || payload.BusinessID != forwarded.BusinessID {
+				logger.Ctx(c.UserContext()).Info().Msg("Forwarded identity does not match the access token")
+				return fiber.ErrUnauthorized
+			}
+		}
+		return authenticated(c, &forwarded)
+	}
+}
+
+// authenticated keeps the user for the handlers, the lines logged for the rest
+// of the request carry the user and the business
+func authenticated(c *fiber.Ctx, payload *identity.Identity) error {
+	c.Locals(authUserKey, payload)
+	c.SetUserContext(logger.WithContext(c.UserContext(), map[string]any{
+		"user_id":     payload.UserID,
+		"business_id": payload.BusinessID,
+	}))
+	return c.Next()
+}
+
+func verifyAccessToken(c *fiber.Ctx, tokens *identity.TokenVerifier) (*identity.Identity, error) {
+	bearer := c.Get("Authorization")
+	accessToken := strings.TrimPrefix(bearer, "Bearer ")
+	if accessToken == "" {
+		// the browsers can not set headers on an event source
+		accessToken = c.Cookies("access_token")
+	}
+	if accessToken == "" {
+		logger.Ctx(c.UserContext()).Info().Msg("Access token not found")
+		return nil, fiber.ErrUnauthorized
+	}
+	payload, err := tokens.Verify(accessToken)
+	if err != nil {
+		logger.Ctx(c.UserContext()).Info().Err(err).Msg("Access token verification failed")
+		return nil, fiber.ErrUnauthorized
+	}
+	return &payload, nil
+}
+
+func GetUserFromContext(c *fiber.Ctx) (*identity.Identity, error) {
+	userIn := c.Locals(authUserKey)
+	if userIn == nil {
+		return nil, fmt.Errorf("AuthenticatedUser is only available for protected routes")
+	}
+	payload, ok := userIn.(*identity.Identity)
+	if !ok {
+		return nil, fmt.Errorf("AuthenticatedUser is only available for protected routes")
+	}
+	return payload, nil
+}
+
+// InternalMiddleware guards the routes only meant for the operators and the other services
+func InternalMiddleware(apiKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(snapshot.ApiKeyHeader)
+		if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			logger.Ctx(c.UserContext()).Info().Msg("Internal api key missing or invalid")
+			return fiber.ErrUnauthorized
+		}
+		return c.Next()
+	}
+}
+
+// WebhookMiddleware guards the webhooks, the providers can not set headers
+// so the token is passed in the query of the url registered with them
+func WebhookMiddleware(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		given := c.Query("token")
+		if given == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			logger.Ctx(c.UserContext()).Info().Msg("Webhook token missing or invalid")
+			return fiber.ErrUnauthorized
+		}
+		return c.Next()
+	}
+}
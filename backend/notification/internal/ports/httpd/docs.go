@@ -0,0 +1,136 @@
+package httpd
+
+import (
+	"net/http"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiSpec documents the routes of SetupRoutes, served at /api/v1/notification-srv/openapi.json
+// and merged by the broker
+func (s *Server) apiSpec() *openapi.Spec {
+	spec := openapi.New("notification", "v1", "/api/v1/notification-srv")
+	for _, e := range service.Errors {
+		spec.Errors(openapi.Error(*e))
+	}
+
+	spec.Describe(fiber.MethodGet, "/health", openapi.Operation{
+		Summary: "Health of the service and its database", Data: handlers.HealthResponse{}, Unwrapped: true,
+	})
+
+	spec.Describe(fiber.MethodGet, "/inbox/list", openapi.Operation{
+		Summary: "List the in-app notifications of the user", Security: openapi.Bearer,
+		Query: service.ListInboxPayload{}, Data: []dao.InboxItem{},
+	})
+	spec.Describe(fiber.MethodGet, "/inbox/unread-count", openapi.Operation{
+		Summary: "Count the unread notifications of the user", Security: openapi.Bearer,
+		Query: service.UnreadCountPayload{}, Data: service.UnreadCountResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/inbox/read", openapi.Operation{
+		Summary: "Mark notifications read", Security: openapi.Bearer,
+		Body: service.ReadInboxPayload{}, Data: service.ReadInboxResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/inbox/read-all", openapi.Operation{
+		Summary: "Mark all the notifications read", Security: openapi.Bearer, Data: service.ReadInboxResponse{},
+	})
+	spec.Describe(fiber.MethodGet, "/inbox/stream", openapi.Operation{
+		Summary:     "Stream the new notifications of the user",
+		Description: "Server-sent events named notification, their data is an InboxItem.",
+		Security:    openapi.Bearer, ContentType: "text/event-stream",
+	})
+
+	spec.Describe(fiber.MethodGet, "/push-subscriptions/vapid-key", openapi.Operation{
+		Summary: "The public key the browsers subscribe to web push with", Data: service.VapidKeyResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/push-subscriptions/subscribe", openapi.Operation{
+		Summary: "Subscribe a browser of the user to web push", Security: openapi.Bearer,
+		Body: service.SubscribePushPayload{}, Data: dao.PushSubscription{}, Status: http.StatusCreated,
+	})
+	spec.Describe(fiber.MethodPost, "/push-subscriptions/unsubscribe", openapi.Operation{
+		Summary: "Unsubscribe a browser of the user from web push", Security: openapi.Bearer,
+		Body: service.UnsubscribePushPayload{},
+	})
+
+	spec.Describe(fiber.MethodGet, "/preferences/view", openapi.Operation{
+		Summary: "What the user wants to be notified of", Security: openapi.Bearer,
+		Query: service.ViewPreferencePayload{}, Data: dao.Preference{},
+	})
+	spec.Describe(fiber.MethodPut, "/preferences/update", openapi.Operation{
+		Summary: "Change what the user wants to be notified of", Security: openapi.Bearer,
+		Body: service.UpdatePreferencePayload{}, Data: dao.Preference{},
+	})
+	spec.Describe(fiber.MethodGet, "/preferences/unsubscribe", openapi.Operation{
+		Summary:     "What the unsubscribe link of an email unsubscribes from",
+		Description: "The token is the signed one of the link, no login needed.",
+		Query:       service.UnsubscribePayload{}, Data: service.UnsubscribeResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/preferences/unsubscribe", openapi.Operation{
+		Summary:     "Unsubscribe with the link of an email",
+		Description: "The one-click unsubscribe of rfc 8058, no login needed.",
+		Query:       service.UnsubscribePayload{}, Data: service.UnsubscribeResponse{},
+	})
+
+	admin := []string{"admin"}
+	spec.Describe(fiber.MethodPost, "/admin/templates/create", openapi.Operation{
+		Summary: "Create a template", Tags: admin, Security: openapi.InternalApiKey,
+		Body: service.CreateTemplatePayload{}, Data: dao.Template{}, Status: http.StatusCreated,
+	})
+	spec.Describe(fiber.MethodPut, "/admin/templates/update/:id", openapi.Operation{
+		Summary: "Change a template, keeping the previous version", Tags: admin, Security: openapi.InternalApiKey,
+		Body: service.TemplateContentPayload{}, Data: dao.Template{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/templates/list", openapi.Operation{
+		Summary: "List the templates", Tags: admin, Security: openapi.InternalApiKey,
+		Query: service.ListTemplatesPayload{}, Data: []dao.Template{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/templates/view/:id", openapi.Operation{
+		Summary: "View a template", Tags: admin, Security: openapi.InternalApiKey, Data: dao.Template{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/templates/versions/:id", openapi.Operation{
+		Summary: "List the versions of a template", Tags: admin, Security: openapi.InternalApiKey,
+		Data: []dao.TemplateVersion{},
+	})
+	spec.Describe(fiber.MethodPost, "/admin/templates/rollback/:id", openapi.Operation{
+		Summary: "Roll a template back to a version", Tags: admin, Security: openapi.InternalApiKey,
+		Body: service.RollbackTemplatePayload{}, Data: dao.Template{},
+	})
+	spec.Describe(fiber.MethodPost, "/admin/templates/preview", openapi.Operation{
+		Summary: "Render a template with sample tokens", Tags: admin, Security: openapi.InternalApiKey,
+		Body: service.PreviewTemplatePayload{}, Data: service.TemplatePreview{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/deliveries/list", openapi.Operation{
+		Summary: "List the deliveries", Tags: admin, Security: openapi.InternalApiKey,
+		Query: service.ListDeliveriesPayload{}, Data: []dao.Delivery{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/deliveries/view/:id", openapi.Operation{
+		Summary: "View a delivery", Tags: admin, Security: openapi.InternalApiKey, Data: dao.Delivery{},
+	})
+	spec.Describe(fiber.MethodPost, "/admin/deliveries/resend/:id", openapi.Operation{
+		Summary: "Send a delivery again", Tags: admin, Security: openapi.InternalApiKey, Data: dao.Delivery{},
+	})
+	spec.Describe(fiber.MethodPost, "/admin/opt-ins/update", openapi.Operation{
+		Summary: "Record the opt-in of a recipient to a channel", Tags: admin, Security: openapi.InternalApiKey,
+		Body: service.UpdateOptInPayload{}, Data: dao.OptIn{},
+	})
+	spec.Describe(fiber.MethodGet, "/admin/opt-ins/view", openapi.Operation{
+		Summary: "View the opt-in of a recipient to a channel", Tags: admin, Security: openapi.InternalApiKey,
+		Query: service.ViewOptInPayload{}, Data: dao.OptIn{},
+	})
+
+	webhooks := []string{"webhooks"}
+	spec.Describe(fiber.MethodGet, "/webhooks/:channel/reports", openapi.Operation{
+		Summary:     "Answer the challenge of a provider registering the webhook",
+		Description: "Echoes hub.challenge when hub.mode is subscribe and hub.verify_token is the one configured for the channel.",
+		Tags:        webhooks, Security: openapi.WebhookToken, ContentType: fiber.MIMETextPlain,
+	})
+	spec.Describe(fiber.MethodPost, "/webhooks/:channel/reports", openapi.Operation{
+		Summary:     "Delivery reports of the provider of the channel",
+		Description: "The body is the one of the provider, whatsapp signs it in X-Hub-Signature-256.",
+		Tags:        webhooks, Security: openapi.WebhookToken,
+	})
+	return spec
+}
@@ -0,0 +1,29 @@
+package httpd
+
+import (
+	"fmt"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ErrorHandler() fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		logger.Ctx(c.UserContext()).Error().Type("type", err).Err(err).Msg("request failed")
+
+		if e, ok := err.(*fiber.Error); ok {
+			c.Status(e.Code)
+			return c.JSON(handlers.NewResponse(translation.Localize(c, fmt.Sprintf("errors.%d", e.Code)), nil, err))
+		}
+
+		if e, ok := err.(*service.ServiceError); ok {
+			c.Status(e.HttpErrorCode)
+			return c.JSON(handlers.NewResponse(translation.Localize(c, e.Short), nil, err))
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(handlers.NewResponse(translation.Localize(c, "errors.500"), nil, err))
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type DeliveryHandler struct {
+	deliverySrv service.DeliveryService
+}
+
+func NewDeliveryHandler(deliverySrv service.DeliveryService) *DeliveryHandler {
+	return &DeliveryHandler{
+		deliverySrv: deliverySrv,
+	}
+}
+
+func (h *DeliveryHandler) List(c *fiber.Ctx) error {
+	var payload service.ListDeliveriesPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.deliverySrv.List(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Delivery"}), resp, nil))
+}
+
+func (h *DeliveryHandler) View(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.DeliveryNotFoundErr
+	}
+	resp, err := h.deliverySrv.View(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.view", fiber.Map{"Entity": "Delivery"}), resp, nil))
+}
+
+func (h *DeliveryHandler) Resend(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.DeliveryNotFoundErr
+	}
+	resp, err := h.deliverySrv.Resend(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "delivery.resend"), resp, nil))
+}
+
+// Reports is the webhook the providers post the delivery reports of a channel to
+func (h *DeliveryHandler) Reports(c *fiber.Ctx) error {
+	channel := notification.Channel(c.Params("channel"))
+	// whatsapp signs the body with the app secret
+	signature := c.Get("X-Hub-Signature-256")
+	if err := h.deliverySrv.HandleReports(c.UserContext(), channel, c.Body(), signature); err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "delivery.reports"), nil, nil))
+}
+
+// Verify answers the challenge a provider sends when the webhook is registered
+func (h *DeliveryHandler) Verify(c *fiber.Ctx) error {
+	if c.Query("hub.mode") != "subscribe" {
+		return fiber.ErrBadRequest
+	}
+	channel := notification.Channel(c.Params("channel"))
+	if err := h.deliverySrv.VerifyWebhook(c.UserContext(), channel, c.Query("hub.verify_token")); err != nil {
+		return err
+	}
+	return c.SendString(c.Query("hub.challenge"))
+}
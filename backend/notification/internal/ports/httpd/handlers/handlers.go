@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/database"
+)
+
+type Handler struct {
+	db               database.Database
+	Template         *TemplateHandler
+	Delivery         *DeliveryHandler
+	OptIn            *OptInHandler
+	Inbox            *InboxHandler
+	PushSubscription *PushSubscriptionHandler
+	Preference       *PreferenceHandler
+}
+
+func New(db database.Database, service *service.Service) *Handler {
+	return &Handler{
+		db:               db,
+		Template:         NewTemplateHandler(service.Template),
+		Delivery:         NewDeliveryHandler(service.Delivery),
+		OptIn:            NewOptInHandler(service.OptIn),
+		Inbox:            NewInboxHandler(service.Inbox),
+		PushSubscription: NewPushSubscriptionHandler(service.PushSubscription),
+		Preference:       NewPreferenceHandler(service.Preference),
+	}
+}
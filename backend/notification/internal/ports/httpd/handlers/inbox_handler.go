@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamKeepAlive keeps the proxies from closing an idle stream
+const streamKeepAlive = 25 * time.Second
+
+type InboxHandler struct {
+	inboxSrv service.InboxService
+}
+
+func NewInboxHandler(inboxSrv service.InboxService) *InboxHandler {
+	return &InboxHandler{
+		inboxSrv: inboxSrv,
+	}
+}
+
+func (h *InboxHandler) List(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.ListInboxPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.inboxSrv.List(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Notification"}), resp, nil))
+}
+
+func (h *InboxHandler) UnreadCount(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.UnreadCountPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.inboxSrv.UnreadCount(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "inbox.unread_count"), resp, nil))
+}
+
+func (h *InboxHandler) Read(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.ReadInboxPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.inboxSrv.Read(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "inbox.read"), resp, nil))
+}
+
+func (h *InboxHandler) ReadAll(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.inboxSrv.ReadAll(c.UserContext(), user.UserID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "inbox.read"), resp, nil))
+}
+
+// Stream sends the new notifications of the user as server-sent events
+func (h *InboxHandler) Stream(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	items, stop, err := h.inboxSrv.Stream(user.UserID)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// nginx buffers the responses otherwise
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stop()
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+		// sends the headers right away, and tells the browser when to reconnect
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(item)
+				if err != nil {
+					logger.Error().Err(err).Msg("failed to marshal inbox item")
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", item.ID, data)
+			case <-ticker.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			// a failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type OptInHandler struct {
+	optInSrv service.OptInService
+}
+
+func NewOptInHandler(optInSrv service.OptInService) *OptInHandler {
+	return &OptInHandler{
+		optInSrv: optInSrv,
+	}
+}
+
+func (h *OptInHandler) Update(c *fiber.Ctx) error {
+	var payload service.UpdateOptInPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.optInSrv.Update(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.update", fiber.Map{"Entity": "Opt-in"}), resp, nil))
+}
+
+func (h *OptInHandler) View(c *fiber.Ctx) error {
+	var payload service.ViewOptInPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.optInSrv.View(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.view", fiber.Map{"Entity": "Opt-in"}), resp, nil))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PreferenceHandler struct {
+	preferenceSrv service.PreferenceService
+}
+
+func NewPreferenceHandler(preferenceSrv service.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{
+		preferenceSrv: preferenceSrv,
+	}
+}
+
+func (h *PreferenceHandler) View(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.ViewPreferencePayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.preferenceSrv.View(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.view", fiber.Map{"Entity": "Preference"}), resp, nil))
+}
+
+func (h *PreferenceHandler) Update(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.UpdatePreferencePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.preferenceSrv.Update(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.update", fiber.Map{"Entity": "Preference"}), resp, nil))
+}
+
+func (h *PreferenceHandler) ViewUnsubscribe(c *fiber.Ctx) error {
+	var payload service.UnsubscribePayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.preferenceSrv.ViewUnsubscribe(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "preference.unsubscribe"), resp, nil))
+}
+
+// Unsubscribe is the one-click unsubscribe of rfc 8058, the mail clients post
+// List-Unsubscribe=One-Click to the link with the token in its query
+func (h *PreferenceHandler) Unsubscribe(c *fiber.Ctx) error {
+	var payload service.UnsubscribePayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.preferenceSrv.Unsubscribe(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "preference.unsubscribed"), resp, nil))
+}
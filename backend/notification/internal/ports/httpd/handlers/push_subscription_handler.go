@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PushSubscriptionHandler struct {
+	pushSubscriptionSrv service.PushSubscriptionService
+}
+
+func NewPushSubscriptionHandler(pushSubscriptionSrv service.PushSubscriptionService) *PushSubscriptionHandler {
+	return &PushSubscriptionHandler{
+		pushSubscriptionSrv: pushSubscriptionSrv,
+	}
+}
+
+func (h *PushSubscriptionHandler) VapidKey(c *fiber.Ctx) error {
+	resp, err := h.pushSubscriptionSrv.VapidKey(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "push_subscription.vapid_key"), resp, nil))
+}
+
+func (h *PushSubscriptionHandler) Subscribe(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.SubscribePushPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	payload.UserAgent = c.Get(fiber.HeaderUserAgent)
+	resp, err := h.pushSubscriptionSrv.Subscribe(c.UserContext(), user.UserID, payload)
+	if err != nil {
+		return err
+	}
+	c.Status(http.StatusCreated)
+	return c.JSON(NewResponse(translation.Localize(c, "controller.create", fiber.Map{"Entity": "Push subscription"}), resp, nil))
+}
+
+func (h *PushSubscriptionHandler) Unsubscribe(c *fiber.Ctx) error {
+	user, err := authn.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+	var payload service.UnsubscribePushPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	if err := h.pushSubscriptionSrv.Unsubscribe(c.UserContext(), user.UserID, payload); err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.delete", fiber.Map{"Entity": "Push subscription"}), nil, nil))
+}
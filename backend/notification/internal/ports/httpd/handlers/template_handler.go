@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type TemplateHandler struct {
+	templateSrv service.TemplateService
+}
+
+func NewTemplateHandler(templateSrv service.TemplateService) *TemplateHandler {
+	return &TemplateHandler{
+		templateSrv: templateSrv,
+	}
+}
+
+func (h *TemplateHandler) Create(c *fiber.Ctx) error {
+	var payload service.CreateTemplatePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.templateSrv.Create(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(NewResponse(translation.Localize(c, "controller.create", fiber.Map{"Entity": "Template"}), resp, nil))
+}
+
+func (h *TemplateHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.TemplateNotFoundErr
+	}
+	var payload service.TemplateContentPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.templateSrv.Update(c.UserContext(), id, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.update", fiber.Map{"Entity": "Template"}), resp, nil))
+}
+
+func (h *TemplateHandler) List(c *fiber.Ctx) error {
+	var payload service.ListTemplatesPayload
+	if err := c.QueryParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.templateSrv.List(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Template"}), resp, nil))
+}
+
+func (h *TemplateHandler) View(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.TemplateNotFoundErr
+	}
+	resp, err := h.templateSrv.View(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.view", fiber.Map{"Entity": "Template"}), resp, nil))
+}
+
+func (h *TemplateHandler) Versions(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.TemplateNotFoundErr
+	}
+	resp, err := h.templateSrv.Versions(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "controller.list", fiber.Map{"Entity": "Template version"}), resp, nil))
+}
+
+func (h *TemplateHandler) Rollback(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.TemplateNotFoundErr
+	}
+	var payload service.RollbackTemplatePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.templateSrv.Rollback(c.UserContext(), id, payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "template.rollback"), resp, nil))
+}
+
+func (h *TemplateHandler) Preview(c *fiber.Ctx) error {
+	var payload service.PreviewTemplatePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return err
+	}
+	resp, err := h.templateSrv.Preview(c.UserContext(), payload)
+	if err != nil {
+		return err
+	}
+	return c.JSON(NewResponse(translation.Localize(c, "template.preview"), resp, nil))
+}
@@ -0,0 +1,55 @@
+package httpd
+
+import (
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+)
+
+func (s *Server) SetupRoutes() {
+	router := s.app
+	authMiddleware := authn.Middleware(s.tokens, s.gateway, s.verifyToken)
+	internalMiddleware := authn.InternalMiddleware(s.internalApiKey)
+	webhookMiddleware := authn.WebhookMiddleware(s.webhookToken)
+	router.Get("/api/v1/notification-srv/health", s.handlers.Health)
+	router.Get("/metrics", metrics.Handler())
+
+	// Inbox routes, the in-app notifications of the user
+	router.Get("/api/v1/notification-srv/inbox/list", authMiddleware, s.handlers.Inbox.List)
+	router.Get("/api/v1/notification-srv/inbox/unread-count", authMiddleware, s.handlers.Inbox.UnreadCount)
+	router.Post("/api/v1/notification-srv/inbox/read", authMiddleware, s.handlers.Inbox.Read)
+	router.Post("/api/v1/notification-srv/inbox/read-all", authMiddleware, s.handlers.Inbox.ReadAll)
+	router.Get("/api/v1/notification-srv/inbox/stream", authMiddleware, s.handlers.Inbox.Stream)
+
+	// Push subscription routes, web push to the browsers of the user
+	router.Get("/api/v1/notification-srv/push-subscriptions/vapid-key", s.handlers.PushSubscription.VapidKey)
+	router.Post("/api/v1/notification-srv/push-subscriptions/subscribe", authMiddleware, s.handlers.PushSubscription.Subscribe)
+	router.Post("/api/v1/notification-srv/push-subscriptions/unsubscribe", authMiddleware, s.handlers.PushSubscription.Unsubscribe)
+
+	// Preference routes, what the user wants to be notified of. the unsubscribe links
+	// of the emails are signed and work without a login
+	router.Get("/api/v1/notification-srv/preferences/view", authMiddleware, s.handlers.Preference.View)
+	router.Put("/api/v1/notification-srv/preferences/update", authMiddleware, s.handlers.Preference.Update)
+	router.Get("/api/v1/notification-srv/preferences/unsubscribe", s.handlers.Preference.ViewUnsubscribe)
+	router.Post("/api/v1/notification-srv/preferences/unsubscribe", s.handlers.Preference.Unsubscribe)
+
+	// Admin routes, the templates, the delivery log and the opt-ins
+	router.Post("/api/v1/notification-srv/admin/templates/create", internalMiddleware, s.handlers.Template.Create)
+	router.Put("/api/v1/notification-srv/admin/templates/update/:id", internalMiddleware, s.handlers.Template.Update)
+	router.Get("/api/v1/notification-srv/admin/templates/list", internalMiddleware, s.handlers.Template.List)
+	router.Get("/api/v1/notification-srv/admin/templates/view/:id", internalMiddleware, s.handlers.Template.View)
+	router.Get("/api/v1/notification-srv/admin/templates/versions/:id", internalMiddleware, s.handlers.Template.Versions)
+	router.Post("/api/v1/notification-srv/admin/templates/rollback/:id", internalMiddleware, s.handlers.Template.Rollback)
+	router.Post("/api/v1/notification-srv/admin/templates/preview", internalMiddleware, s.handlers.Template.Preview)
+	router.Get("/api/v1/notification-srv/admin/deliveries/list", internalMiddleware, s.handlers.Delivery.List)
+	router.Get("/api/v1/notification-srv/admin/deliveries/view/:id", internalMiddleware, s.handlers.Delivery.View)
+	router.Post("/api/v1/notification-srv/admin/deliveries/resend/:id", internalMiddleware, s.handlers.Delivery.Resend)
+	router.Post("/api/v1/notification-srv/admin/opt-ins/update", internalMiddleware, s.handlers.OptIn.Update)
+	router.Get("/api/v1/notification-srv/admin/opt-ins/view", internalMiddleware, s.handlers.OptIn.View)
+
+	// Provider webhooks
+	router.Get("/api/v1/notification-srv/webhooks/:channel/reports", webhookMiddleware, s.handlers.Delivery.Verify)
+	router.Post("/api/v1/notification-srv/webhooks/:channel/reports", webhookMiddleware, s.handlers.Delivery.Reports)
+
+	// the spec of the routes above, merged by the broker
+	s.apiSpec().Route(router)
+}
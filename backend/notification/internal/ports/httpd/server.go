@@ -0,0 +1,67 @@
+package httpd
+
+import (
+	"fmt"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
+	"github.com/aritradevelops/billbharat/backend/shared/translation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+type Server struct {
+	host     string
+	port     int
+	app      *fiber.App
+	handlers *handlers.Handler
+	// tokens verifies the access tokens of the users
+	tokens *identity.TokenVerifier
+	// gateway verifies the identity the broker forwards, nil to only accept access tokens
+	gateway     *identity.Signer
+	verifyToken bool
+	// internalApiKey guards the admin routes
+	internalApiKey string
+	// webhookToken guards the provider webhooks
+	webhookToken string
+}
+
+func NewServer(host string, port int, handlers *handlers.Handler, tokens *identity.TokenVerifier, gateway *identity.Signer, verifyToken bool, internalApiKey string, webhookToken string) *Server {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler(),
+	})
+	app.Use(recover.New(
+		recover.Config{
+			EnableStackTrace: true,
+		},
+	))
+	app.Use(tracing.New())
+	app.Use(metrics.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${respHeader:X-Request-ID} | ${error}\n",
+	}))
+	app.Use(translation.New())
+	server := &Server{
+		host:     host,
+		port:     port,
+		app:      app,
+		handlers: handlers,
+
+		tokens:         tokens,
+		gateway:        gateway,
+		verifyToken:    verifyToken,
+		internalApiKey: internalApiKey,
+		webhookToken:   webhookToken,
+	}
+	return server
+}
+
+func (s *Server) Start() error {
+	return s.app.Listen(fmt.Sprintf("%s:%d", s.host, s.port))
+}
+func (s *Server) Shutdown() error {
+	return s.app.Shutdown()
+}
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+const sendGridUrl = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends through the sendgrid v3 mail api, the other http api
+// providers (ses, postmark, ...) plug in the same way behind Mailer
+type SendGrid struct {
+	url      string
+	apiKey   string
+	from     string
+	fromName string
+	client   *http.Client
+}
+
+func NewSendGrid(config config.Mailer) (*SendGrid, error) {
+	if config.ApiKey == "" {
+		return nil, fmt.Errorf("sendgrid api key is required")
+	}
+	url := config.ApiUrl
+	if url == "" {
+		url = sendGridUrl
+	}
+	return &SendGrid{
+		url:      url,
+		apiKey:   config.ApiKey,
+		from:     config.From,
+		fromName: config.FromName,
+		client:   &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	CC  []sendGridAddress `json:"cc,omitempty"`
+	BCC []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	// Content is base64 encoded by json
+	Content  []byte `json:"content"`
+	Type     string `json:"type,omitempty"`
+	Filename string `json:"filename"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+func sendGridAddresses(emails []string) []sendGridAddress {
+	addresses := make([]sendGridAddress, 0, len(emails))
+	for _, email := range emails {
+		addresses = append(addresses, sendGridAddress{Email: email})
+	}
+	return addresses
+}
+
+func (s *SendGrid) Send(ctx context.Context, email notification.EmailData, subject string, body string, alternativeBody *string, headers map[string]string) (string, error) {
+	message := sendGridMessage{
+		Personalizations: []sendGridPersonalization{{
+			To:  sendGridAddresses(email.To),
+			CC:  sendGridAddresses(email.CC),
+			BCC: sendGridAddresses(email.BCC),
+		}},
+		From:    sendGridAddress{Email: s.from, Name: s.fromName},
+		Subject: subject,
+		Headers: headers,
+	}
+	if email.ReplyTo != "" {
+		message.ReplyTo = &sendGridAddress{Email: email.ReplyTo}
+	}
+	// the plain text part has to come first
+	if alternativeBody != nil {
+		message.Content = append(message.Content, sendGridContent{Type: "text/plain", Value: *alternativeBody})
+	}
+	message.Content = append(message.Content, sendGridContent{Type: "text/html", Value: body})
+	for _, attachment := range email.Attachments {
+		message.Attachments = append(message.Attachments, sendGridAttachment{
+			Content: attachment.Data, Type: attachment.MimeType, Filename: attachment.Name,
+		})
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", fmt.Errorf("sendgrid responded with %d: %s", res.StatusCode, reason)
+	}
+	// sendgrid tracks the message by its own id, e.g. in the event webhooks
+	return res.Header.Get("X-Message-Id"), nil
+}
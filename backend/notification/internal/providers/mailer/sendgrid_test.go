@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+)
+
+func newTestSendGrid(t *testing.T, handler http.HandlerFunc) *SendGrid {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	s, err := NewSendGrid(config.Mailer{
+		ApiKey: "key", ApiUrl: server.URL, From: "no-reply@billbharat.test", FromName: "BillBharat", Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSendGridSend(t *testing.T) {
+	var received sendGridMessage
+	s := newTestSendGrid(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Message-Id", "sg-1")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	text := "123456"
+
+	id, err := s.Send(context.Background(), testEmail, "Your code", "<p>123456</p>", &text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "sg-1" {
+		t.Fatalf("id = %q, want the one of sendgrid", id)
+	}
+	if len(received.Personalizations) != 1 || received.Personalizations[0].To[0].Email != "asha@example.com" {
+		t.Fatalf("personalizations = %+v", received.Personalizations)
+	}
+	if len(received.Content) != 2 || received.Content[0].Type != "text/plain" || received.Content[1].Type != "text/html" {
+		t.Fatalf("content = %+v, want the plain text first", received.Content)
+	}
+}
+
+func TestSendGridSendStopsWithTheContext(t *testing.T) {
+	// released before the server is closed, the cleanups run in reverse
+	release := make(chan struct{})
+	s := newTestSendGrid(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	t.Cleanup(func() { close(release) })
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.Send(ctx, testEmail, "Your code", "<p>123456</p>", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
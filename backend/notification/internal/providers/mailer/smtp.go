@@ -0,0 +1,214 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/toorop/go-dkim"
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+var encryptions = map[string]mail.Encryption{
+	"none":     mail.EncryptionNone,
+	"starttls": mail.EncryptionSTARTTLS,
+	"tls":      mail.EncryptionSSLTLS,
+}
+
+// SMTPMailer sends through an smtp server, e.g. mailhog locally.
+// connections are kept open and reused between the messages.
+type SMTPMailer struct {
+	server   *mail.SMTPServer
+	timeout  time.Duration
+	domain   string
+	from     string
+	fromName string
+	dkim     *dkim.SigOptions
+	// pool holds the idle connections
+	pool chan *smtpConn
+}
+
+// smtpConn keeps the connection under the client, the library has no context
+// support so the deadlines are set on it directly
+type smtpConn struct {
+	client *mail.SMTPClient
+	conn   net.Conn
+}
+
+func NewSMTPMailer(config config.Mailer) (*SMTPMailer, error) {
+	encryption, ok := encryptions[config.Encryption]
+	if !ok {
+		return nil, fmt.Errorf("unknown smtp encryption %q", config.Encryption)
+	}
+	if config.Host == "" || config.Port == 0 {
+		return nil, fmt.Errorf("smtp host and port are required")
+	}
+	server := mail.NewSMTPClient()
+	server.Host = config.Host
+	server.Port = config.Port
+	server.Username = config.Username
+	server.Password = config.Password
+	server.Encryption = encryption
+	server.KeepAlive = true
+	// config.Timeout bounds the context of the whole send instead, see Send
+	server.ConnectTimeout = 0
+	server.SendTimeout = 0
+
+	m := &SMTPMailer{
+		server:   server,
+		timeout:  config.Timeout,
+		domain:   config.Domain,
+		from:     config.From,
+		fromName: config.FromName,
+		pool:     make(chan *smtpConn, max(config.PoolSize, 1)),
+	}
+	if config.DkimSelector != "" {
+		key, err := os.ReadFile(config.DkimPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dkim private key: %w", err)
+		}
+		options := dkim.NewSigOptions()
+		options.PrivateKey = key
+		options.Domain = config.Domain
+		options.Selector = config.DkimSelector
+		options.Canonicalization = "relaxed/relaxed"
+		options.Headers = []string{
+			"from", "to", "cc", "reply-to", "subject", "date", "message-id",
+			// the mailbox providers only honour a one-click unsubscribe which is signed
+			"list-unsubscribe", "list-unsubscribe-post",
+		}
+		m.dkim = &options
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email notification.EmailData, subject string, body string, alternativeBody *string, headers map[string]string) (string, error) {
+	messageID := newMessageID(m.domain)
+	message := mail.NewMSG()
+	message.SetFrom(fmt.Sprintf("%s <%s>", m.fromName, m.from))
+	message.AddTo(email.To...)
+	message.AddCc(email.CC...)
+	message.AddBcc(email.BCC...)
+	if email.ReplyTo != "" {
+		message.SetReplyTo(email.ReplyTo)
+	}
+	message.SetSubject(subject)
+	message.AddHeader("Message-ID", messageID)
+	for name, value := range headers {
+		message.AddHeader(name, value)
+	}
+	message.SetBody(mail.TextHTML, body)
+	if alternativeBody != nil {
+		message.AddAlternative(mail.TextPlain, *alternativeBody)
+	}
+	for _, attachment := range email.Attachments {
+		message.Attach(&mail.File{Name: attachment.Name, MimeType: attachment.MimeType, Data: attachment.Data})
+	}
+	// the signature covers the final message, so it has to come last
+	if m.dkim != nil {
+		message.SetDkim(*m.dkim)
+	}
+	if message.Error != nil {
+		return "", message.Error
+	}
+
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	c, err := m.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := c.send(ctx, message); err != nil {
+		// the connection may be left in the middle of a command
+		c.client.Close()
+		return "", err
+	}
+	m.release(c)
+	return messageID, nil
+}
+
+// acquire returns an idle connection which is still alive or a new one
+func (m *SMTPMailer) acquire(ctx context.Context) (*smtpConn, error) {
+	for {
+		select {
+		case c := <-m.pool:
+			// the server drops the connections idle for too long
+			if err := c.noop(ctx); err == nil {
+				return c, nil
+			}
+			c.client.Close()
+		default:
+			return m.connect(ctx)
+		}
+	}
+}
+
+func (m *SMTPMailer) connect(ctx context.Context) (*smtpConn, error) {
+	address := net.JoinHostPort(m.server.Host, strconv.Itoa(m.server.Port))
+	var conn net.Conn
+	var err error
+	if m.server.Encryption == mail.EncryptionSSLTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: m.server.Host}}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &smtpConn{conn: conn}
+	// the greeting, starttls and auth happen on the dialed connection
+	err = c.within(ctx, func() error {
+		server := *m.server
+		server.CustomConn = conn
+		client, err := server.Connect()
+		c.client = client
+		return err
+	})
+	if err != nil {
+		if c.client != nil {
+			c.client.Close()
+		} else {
+			conn.Close()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *SMTPMailer) release(c *smtpConn) {
+	select {
+	case m.pool <- c:
+	default:
+		c.client.Quit()
+		c.client.Close()
+	}
+}
+
+// within runs fn until ctx is done, which interrupts the pending read or write
+func (c *smtpConn) within(ctx context.Context, fn func() error) error {
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+	err := fn()
+	if !stop() {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+func (c *smtpConn) noop(ctx context.Context) error {
+	return c.within(ctx, c.client.Noop)
+}
+
+func (c *smtpConn) send(ctx context.Context, message *mail.Email) error {
+	return c.within(ctx, func() error { return message.Send(c.client) })
+}
@@ -0,0 +1,186 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+// smtpStandIn speaks just enough smtp to take messages
+type smtpStandIn struct {
+	listener net.Listener
+	// silent never greets, stall never answers the end of the data
+	silent, stall bool
+
+	mu          sync.Mutex
+	connections int
+	messages    []string
+}
+
+func newSMTPStandIn(t *testing.T, silent, stall bool) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{listener: listener, silent: silent, stall: stall}
+	done := make(chan struct{})
+	go s.accept(done)
+	t.Cleanup(func() {
+		close(done)
+		listener.Close()
+	})
+	return s
+}
+
+func (s *smtpStandIn) accept(done chan struct{}) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		s.mu.Unlock()
+		go s.serve(conn, done)
+	}
+}
+
+func (s *smtpStandIn) serve(conn net.Conn, done chan struct{}) {
+	defer conn.Close()
+	go func() {
+		<-done
+		conn.Close()
+	}()
+	if s.silent {
+		<-done
+		return
+	}
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 stand-in ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 stand-in")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mu.Unlock()
+			if s.stall {
+				<-done
+				return
+			}
+			c.PrintfLine("250 OK queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func newTestSMTPMailer(t *testing.T, s *smtpStandIn) *SMTPMailer {
+	t.Helper()
+	m, err := NewSMTPMailer(config.Mailer{
+		Domain:     "billbharat.test",
+		From:       "no-reply@billbharat.test",
+		FromName:   "BillBharat",
+		Host:       "127.0.0.1",
+		Port:       s.listener.Addr().(*net.TCPAddr).Port,
+		Encryption: "none",
+		Timeout:    5 * time.Second,
+		PoolSize:   1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+var testEmail = notification.EmailData{To: []string{"asha@example.com"}}
+
+func TestSMTPSendsOverOneConnection(t *testing.T) {
+	s := newSMTPStandIn(t, false, false)
+	m := newTestSMTPMailer(t, s)
+	headers := map[string]string{"List-Unsubscribe": "<https://billbharat.test/unsubscribe>"}
+
+	var ids []string
+	for range 2 {
+		id, err := m.Send(context.Background(), testEmail, "Your code", "<p>123456</p>", nil, headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.connections != 1 {
+		t.Fatalf("opened %d connections, want the first one reused", s.connections)
+	}
+	if len(s.messages) != 2 {
+		t.Fatalf("received %d messages, want 2", len(s.messages))
+	}
+	message := s.messages[0]
+	for _, want := range []string{"Message-Id: " + ids[0], "Subject: Your code", "List-Unsubscribe: <https://billbharat.test/unsubscribe>", "asha@example.com"} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("message lacks %q:\n%s", want, message)
+		}
+	}
+	if ids[0] == ids[1] || !strings.HasSuffix(ids[0], "@billbharat.test>") {
+		t.Fatalf("message ids = %v", ids)
+	}
+}
+
+func TestSMTPSendStopsAtTheDeadline(t *testing.T) {
+	s := newSMTPStandIn(t, false, true)
+	m := newTestSMTPMailer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := m.Send(ctx, testEmail, "Your code", "<p>123456</p>", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %s", elapsed)
+	}
+	// the connection is in the middle of the data, it must not be reused
+	if len(m.pool) != 0 {
+		t.Fatal("the interrupted connection went back to the pool")
+	}
+}
+
+func TestSMTPConnectStopsWhenCancelled(t *testing.T) {
+	s := newSMTPStandIn(t, true, false)
+	m := newTestSMTPMailer(t, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := m.Send(ctx, testEmail, "Your code", "<p>123456</p>", nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
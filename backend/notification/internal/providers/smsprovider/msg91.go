@@ -0,0 +1,164 @@
+package smsprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+const msg91Url = "https://api.msg91.com/api/sendhttp.php"
+
+// MSG91 sends through the msg91 http api, gupshup and the other indian
+// gateways take the same parameters under different names
+type MSG91 struct {
+	url      string
+	authKey  string
+	senderID string
+	entityID string
+	route    string
+	country  string
+	client   *http.Client
+}
+
+func NewMSG91(config config.Sms) (*MSG91, error) {
+	if config.ApiKey == "" || config.SenderID == "" || config.EntityID == "" {
+		return nil, fmt.Errorf("msg91 api key, sender id and entity id are required")
+	}
+	url := config.ApiUrl
+	if url == "" {
+		url = msg91Url
+	}
+	return &MSG91{
+		url:      url,
+		authKey:  config.ApiKey,
+		senderID: config.SenderID,
+		entityID: config.EntityID,
+		route:    config.Route,
+		country:  config.Country,
+		client:   &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// msg91MessageID tells the recipients of a request apart, msg91 reports on each of them
+// under the id of the request
+func msg91MessageID(requestID string, mobile string) string {
+	return requestID + ":" + mobile
+}
+
+func msg91Mobile(to string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(to, " ", ""), "+")
+}
+
+func (m *MSG91) Send(ctx context.Context, data notification.SMSData, body string, dltTemplateID string) ([]string, error) {
+	// the operators drop the messages which do not match a registered template
+	if dltTemplateID == "" {
+		return nil, fmt.Errorf("sms template has no dlt template id")
+	}
+	mobiles := make([]string, 0, len(data.To))
+	for _, to := range data.To {
+		mobiles = append(mobiles, msg91Mobile(to))
+	}
+	encoding, segments := Segments(body)
+	form := url.Values{
+		"authkey":   {m.authKey},
+		"mobiles":   {strings.Join(mobiles, ",")},
+		"message":   {body},
+		"sender":    {m.senderID},
+		"route":     {m.route},
+		"country":   {m.country},
+		"PE_ID":     {m.entityID},
+		"DLT_TE_ID": {dltTemplateID},
+		"response":  {"json"},
+	}
+	if encoding == UCS2 {
+		form.Set("unicode", "1")
+	}
+	logger.Debug().Str("encoding", string(encoding)).Int("segments", segments).Msg("sending sms")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(res.Body, 4096))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("msg91 responded with %d: %s", res.StatusCode, payload)
+	}
+	// on success the message is the request id the delivery reports refer to
+	var response struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, fmt.Errorf("unexpected msg91 response: %s", payload)
+	}
+	if response.Type != "success" {
+		return nil, fmt.Errorf("msg91 rejected the message: %s", response.Message)
+	}
+	ids := make([]string, 0, len(mobiles))
+	for _, mobile := range mobiles {
+		ids = append(ids, msg91MessageID(response.Message, mobile))
+	}
+	return ids, nil
+}
+
+// msg91 report statuses, the ones not listed are not final
+var msg91Statuses = map[string]bool{
+	"1":  true,  // delivered
+	"2":  false, // failed
+	"9":  false, // ndnc, the number opted out of promotional messages
+	"16": false, // rejected
+	"17": false, // blocked
+	"25": false, // rejected by the dlt scrubbing
+	"26": false, // rejected by the dlt scrubbing
+}
+
+// ParseDeliveryReports decodes the reports msg91 posts to the webhook, either
+// as json or as a form with the json in the data field
+func (m *MSG91) ParseDeliveryReports(body []byte) ([]DeliveryReport, error) {
+	if values, err := url.ParseQuery(string(body)); err == nil && values.Has("data") {
+		body = []byte(values.Get("data"))
+	}
+	var requests []struct {
+		RequestID string `json:"requestId"`
+		Report    []struct {
+			Number string `json:"number"`
+			Status string `json:"status"`
+			Desc   string `json:"desc"`
+		} `json:"report"`
+	}
+	if err := json.Unmarshal(body, &requests); err != nil {
+		return nil, err
+	}
+	var reports []DeliveryReport
+	for _, request := range requests {
+		for _, report := range request.Report {
+			delivered, final := msg91Statuses[report.Status]
+			if !final {
+				continue
+			}
+			reports = append(reports, DeliveryReport{
+				MessageID: msg91MessageID(request.RequestID, msg91Mobile(report.Number)),
+				Delivered: delivered,
+				Reason:    fmt.Sprintf("%s: %s", report.Number, report.Desc),
+			})
+		}
+	}
+	return reports, nil
+}
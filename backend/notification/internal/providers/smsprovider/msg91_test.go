@@ -0,0 +1,108 @@
+package smsprovider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+// newTestMSG91 points msg91 at a fake server
+func newTestMSG91(t *testing.T, handler http.HandlerFunc) *MSG91 {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	m, err := NewMSG91(config.Sms{
+		ApiUrl: server.URL, ApiKey: "key", SenderID: "BILBRT", EntityID: "1101", Route: "4", Country: "91",
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMSG91SendReturnsAnIDPerNumber(t *testing.T) {
+	var form url.Values
+	m := newTestMSG91(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		form = r.PostForm
+		w.Write([]byte(`{"type":"success","message":"req-1"}`))
+	})
+
+	ids, err := m.Send(context.Background(), notification.SMSData{To: []string{"+91 98765 43210", "+919123456789"}}, "আপনার কোড 123456", "dlt-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []string{"req-1:919876543210", "req-1:919123456789"}) {
+		t.Fatalf("ids = %v", ids)
+	}
+	if form.Get("authkey") != "key" || form.Get("mobiles") != "919876543210,919123456789" || form.Get("DLT_TE_ID") != "dlt-1" {
+		t.Fatalf("form = %v", form)
+	}
+	if form.Get("unicode") != "1" {
+		t.Fatal("a bengali message was not sent as unicode")
+	}
+}
+
+func TestMSG91SendFailures(t *testing.T) {
+	rejecting := newTestMSG91(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"error","message":"invalid template"}`))
+	})
+	if _, err := rejecting.Send(context.Background(), notification.SMSData{To: []string{"+919876543210"}}, "hi", "dlt-1"); err == nil {
+		t.Fatal("a rejected message was sent")
+	}
+	if _, err := rejecting.Send(context.Background(), notification.SMSData{To: []string{"+919876543210"}}, "hi", ""); err == nil {
+		t.Fatal("a message without a dlt template was sent")
+	}
+
+	release := make(chan struct{})
+	stalled := newTestMSG91(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	t.Cleanup(func() { close(release) })
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := stalled.Send(ctx, notification.SMSData{To: []string{"+919876543210"}}, "hi", "dlt-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMSG91ParseDeliveryReportsPerNumber(t *testing.T) {
+	body := `[{"requestId":"req-1","report":[
+		{"number":"919876543210","status":"1","desc":"DELIVERED"},
+		{"number":"919123456789","status":"2","desc":"FAILED"},
+		{"number":"919000000000","status":"8","desc":"SUBMITTED"}
+	]}]`
+	want := []DeliveryReport{
+		{MessageID: "req-1:919876543210", Delivered: true, Reason: "919876543210: DELIVERED"},
+		{MessageID: "req-1:919123456789", Delivered: false, Reason: "919123456789: FAILED"},
+	}
+	m := &MSG91{}
+
+	for _, encoded := range []string{body, url.Values{"data": {body}}.Encode()} {
+		reports, err := m.ParseDeliveryReports([]byte(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(reports, want) {
+			t.Fatalf("reports = %+v, want %+v", reports, want)
+		}
+	}
+}
+
+func TestLoggerHasNoDeliveryReports(t *testing.T) {
+	if _, err := (&Logger{}).ParseDeliveryReports([]byte(`[]`)); !errors.Is(err, ErrReportsNotSupported) {
+		t.Fatalf("err = %v, want %v", err, ErrReportsNotSupported)
+	}
+}
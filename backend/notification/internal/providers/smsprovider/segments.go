@@ -0,0 +1,51 @@
+package smsprovider
+
+import "unicode/utf16"
+
+type Encoding string
+
+const (
+	GSM7 Encoding = "gsm7"
+	UCS2 Encoding = "ucs2"
+)
+
+// the gsm 03.38 alphabet, the extended characters take an escape septet more
+const (
+	gsmBasic    = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtended = "\f^{}\\[~]|€"
+)
+
+var gsmSeptets = func() map[rune]int {
+	septets := map[rune]int{}
+	for _, r := range gsmBasic {
+		septets[r] = 1
+	}
+	for _, r := range gsmExtended {
+		septets[r] = 2
+	}
+	return septets
+}()
+
+// Segments returns the encoding a message is sent with and the number of sms
+// it is split into. a single character outside the gsm alphabet (e.g. bengali)
+// switches the whole message to ucs2, which fits 70 characters instead of 160.
+// concatenated messages lose some room to the header of every part.
+func Segments(body string) (Encoding, int) {
+	septets := 0
+	for _, r := range body {
+		n, ok := gsmSeptets[r]
+		if !ok {
+			units := len(utf16.Encode([]rune(body)))
+			return UCS2, segments(units, 70, 67)
+		}
+		septets += n
+	}
+	return GSM7, segments(septets, 160, 153)
+}
+
+func segments(length int, single int, multi int) int {
+	if length <= single {
+		return 1
+	}
+	return (length + multi - 1) / multi
+}
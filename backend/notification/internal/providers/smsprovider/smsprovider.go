@@ -1,35 +1,61 @@
 package smsprovider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
 	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
 )
 
+// ErrReportsNotSupported is returned by the providers without delivery reports
+var ErrReportsNotSupported = errors.New("delivery reports are not supported")
+
 type SMSProvider interface {
-	Send(data notification.SMSData, subject string, body string) error
+	// Send returns an id per recipient, the delivery reports refer to them. in india
+	// every message has to match a template registered on the DLT platform, its id
+	// is stored with the template.
+	Send(ctx context.Context, data notification.SMSData, body string, dltTemplateID string) ([]string, error)
+	// ParseDeliveryReports decodes the body of a delivery report webhook
+	ParseDeliveryReports(body []byte) ([]DeliveryReport, error)
 }
 
-type SMSProviderImpl struct {
-	// some config
+// DeliveryReport is the final status of a message reported by the provider
+type DeliveryReport struct {
+	MessageID string
+	Delivered bool
+	Reason    string
+}
+
+func New(config config.Sms) (SMSProvider, error) {
+	switch config.Provider {
+	case "log":
+		return &Logger{}, nil
+	case "msg91":
+		return NewMSG91(config)
+	}
+	return nil, fmt.Errorf("unknown sms provider %q", config.Provider)
 }
+
+// Logger only logs the messages, for running in local
 type Logger struct {
 	// some config
 }
 
-func New(env string) SMSProvider {
-	if env == "production" {
-		return &SMSProviderImpl{}
+func (s *Logger) Send(ctx context.Context, data notification.SMSData, body string, dltTemplateID string) ([]string, error) {
+	encoding, segments := Segments(body)
+	logger.Info().Int("recipients", len(data.To)).Str("dlt_template_id", dltTemplateID).
+		Str("encoding", string(encoding)).Int("segments", segments).Msg("sending sms")
+	ids := make([]string, 0, len(data.To))
+	for range data.To {
+		ids = append(ids, uuid.NewString())
 	}
-	return &Logger{}
-}
-
-func (s *SMSProviderImpl) Send(data notification.SMSData, subject string, body string) error {
-	return fmt.Errorf("not implemented")
+	return ids, nil
 }
 
-func (s *Logger) Send(data notification.SMSData, subject string, body string) error {
-	logger.Info().Interface("data", data).Interface("subject", subject).Interface("body", body).Msg("sending sms")
-	return nil
+func (s *Logger) ParseDeliveryReports(body []byte) ([]DeliveryReport, error) {
+	return nil, ErrReportsNotSupported
 }
@@ -2,9 +2,11 @@ package templatestore
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type DbTemplateStore struct {
@@ -22,5 +24,9 @@ func (d *DbTemplateStore) CreateTemplate(ctx context.Context, template dao.Templ
 }
 
 func (d *DbTemplateStore) FindTemplate(ctx context.Context, params FindTemplateParams) (dao.Template, error) {
-	return d.repo.FindTemplate(ctx, repository.FindTemplateParams(params))
+	template, err := d.repo.FindTemplate(ctx, repository.FindTemplateParams(params))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dao.Template{}, ErrTemplateNotFound
+	}
+	return template, err
 }
@@ -0,0 +1,34 @@
+package templatestore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+)
+
+// FallbackTemplateStore looks in the primary store first and in the fallback one
+// for what is missing, new templates go to the primary
+type FallbackTemplateStore struct {
+	primary  TemplateStorage
+	fallback TemplateStorage
+}
+
+func NewFallbackTemplateStore(primary TemplateStorage, fallback TemplateStorage) TemplateStorage {
+	return &FallbackTemplateStore{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (f *FallbackTemplateStore) CreateTemplate(ctx context.Context, template dao.Template) (dao.Template, error) {
+	return f.primary.CreateTemplate(ctx, template)
+}
+
+func (f *FallbackTemplateStore) FindTemplate(ctx context.Context, params FindTemplateParams) (dao.Template, error) {
+	template, err := f.primary.FindTemplate(ctx, params)
+	if errors.Is(err, ErrTemplateNotFound) {
+		return f.fallback.FindTemplate(ctx, params)
+	}
+	return template, err
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"mime"
 	"path"
+	"strings"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/shared/notification"
@@ -81,6 +84,9 @@ func (f *FSTemplateStore) FindTemplate(ctx context.Context, params FindTemplateP
 		return dao.Template{}, err
 	}
 	content, err := f.fs.ReadFile(contentPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dao.Template{}, ErrTemplateNotFound
+	}
 	if err != nil {
 		return dao.Template{}, err
 	}
@@ -92,7 +98,10 @@ func (f *FSTemplateStore) FindTemplate(ctx context.Context, params FindTemplateP
 	}
 
 	metadata := struct {
-		Subject string `json:"subject"`
+		Subject            string   `json:"subject"`
+		DltTemplateID      string   `json:"dlt_template_id"`
+		WhatsappTemplate   string   `json:"whatsapp_template"`
+		WhatsappParameters []string `json:"whatsapp_parameters"`
 	}{}
 
 	err = json.Unmarshal(metadataBytes, &metadata)
@@ -109,5 +118,49 @@ func (f *FSTemplateStore) FindTemplate(ctx context.Context, params FindTemplateP
 		Body:     string(content),
 		Mimetype: params.Mimetype,
 		Scope:    params.Scope,
+
+		DltTemplateID:      metadata.DltTemplateID,
+		WhatsappTemplate:   metadata.WhatsappTemplate,
+		WhatsappParameters: metadata.WhatsappParameters,
 	}, nil
 }
+
+// Embedded lists the templates shipped with the service, e.g. to seed the database.
+// the layout is templates/{event}/{channel}/{locale}.{ext}, the json files hold the metadata.
+func Embedded() ([]dao.Template, error) {
+	store := &FSTemplateStore{fs: templateFs}
+	var templates []dao.Template
+	err := fs.WalkDir(templateFs, "templates", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(name) == ".json" {
+			return err
+		}
+		dir, file := path.Split(name)
+		parts := strings.Split(strings.Trim(dir, "/"), "/")
+		if len(parts) != 3 {
+			return fmt.Errorf("unexpected template path: %s", name)
+		}
+		ext := path.Ext(file)
+		var mimetype string
+		for m, e := range preferred {
+			if e == ext {
+				mimetype = m
+			}
+		}
+		if mimetype == "" {
+			return fmt.Errorf("unsupported template extension: %s", name)
+		}
+		template, err := store.FindTemplate(context.Background(), FindTemplateParams{
+			Event:    notification.Event(parts[1]),
+			Channel:  notification.Channel(parts[2]),
+			Locale:   strings.TrimSuffix(file, ext),
+			Scope:    DefaultScope,
+			Mimetype: mimetype,
+		})
+		if err != nil {
+			return err
+		}
+		templates = append(templates, template)
+		return nil
+	})
+	return templates, err
+}
@@ -0,0 +1,136 @@
+package templatestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+const (
+	// LayoutEvent holds the layouts the html templates are composed with, a layout
+	// renders the body with {{template "content" .}} and has blocks like header and footer
+	LayoutEvent notification.Event = "layout"
+	// BrandingToken is filled by the notifier for every event, see Branding
+	BrandingToken = "Business"
+	// UnsubscribeToken is filled by the notifier with the one-click unsubscribe link
+	// of the emails which can be turned off, it is empty for the others
+	UnsubscribeToken = "UnsubscribeURL"
+	// RecipientToken is filled by the notifier for the broadcasts, see Recipient
+	RecipientToken = "Recipient"
+)
+
+// Branding is the business the notification is sent on behalf of, or BillBharat
+type Branding struct {
+	Name string
+	Logo string
+}
+
+// Recipient is the member of the business a broadcast is sent to, every member
+// gets a message of their own
+type Recipient struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// Layouts finds the layouts an html template of the target is composed with, the one
+// of the default scope first and then the one of the scope, which only needs to define
+// the blocks it changes. no layout at all leaves the body on its own.
+func Layouts(ctx context.Context, store TemplateStorage, target FindTemplateParams) ([]string, error) {
+	target.Event, target.Mimetype = LayoutEvent, "text/html"
+	base := target
+	base.Scope = DefaultScope
+	layout, err := Resolve(ctx, store, base)
+	if errors.Is(err, ErrTemplateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	layouts := []string{layout.Body}
+	if target.Scope == DefaultScope {
+		return layouts, nil
+	}
+	for _, locale := range []string{target.Locale, DefaultLocale} {
+		target.Locale = locale
+		scoped, err := store.FindTemplate(ctx, target)
+		if errors.Is(err, ErrTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the embedded store has no scopes and gives back the default one
+		if scoped.Body != layout.Body {
+			layouts = append(layouts, scoped.Body)
+		}
+		break
+	}
+	return layouts, nil
+}
+
+// HTMLLayouts finds the layouts an html template is validated with, the ones it is
+// rendered with or, for a layout, the ones below it
+func HTMLLayouts(ctx context.Context, store TemplateStorage, t dao.Template) ([]string, error) {
+	if t.Mimetype != "text/html" || (t.Event == LayoutEvent && t.Scope == DefaultScope) {
+		return nil, nil
+	}
+	target := FindTemplateParams{Channel: t.Channel, Locale: t.Locale, Scope: t.Scope}
+	if t.Event == LayoutEvent {
+		target.Scope = DefaultScope
+	}
+	return Layouts(ctx, store, target)
+}
+
+// RenderHTML renders the body within the layouts with contextual escaping, so
+// the tokens can't inject markup, and inlines the css for the email clients
+func RenderHTML(layouts []string, body string, tokens any) (string, error) {
+	root, entry, err := parseHTML(layouts, body)
+	if err != nil {
+		return "", err
+	}
+	var document bytes.Buffer
+	if err := root.ExecuteTemplate(&document, entry, tokens); err != nil {
+		return "", err
+	}
+	return InlineCSS(document.String())
+}
+
+// validateHTML escapes the body within the layouts like RenderHTML, a body can be
+// fine on its own and leave a layout in the middle of a tag or an attribute
+func validateHTML(layouts []string, body string) error {
+	root, entry, err := parseHTML(layouts, body)
+	if err != nil {
+		return err
+	}
+	// the escaping runs before the first execution, the failures of the missing
+	// tokens are left to the preview
+	err = root.ExecuteTemplate(io.Discard, entry, nil)
+	var escapeErr *template.Error
+	if errors.As(err, &escapeErr) {
+		return err
+	}
+	return nil
+}
+
+func parseHTML(layouts []string, body string) (*template.Template, string, error) {
+	root := template.New("layout")
+	for _, layout := range layouts {
+		if _, err := root.Parse(layout); err != nil {
+			return nil, "", err
+		}
+	}
+	// parsed last so a body can override the blocks of the layouts too
+	if _, err := root.New("content").Parse(body); err != nil {
+		return nil, "", err
+	}
+	if len(layouts) > 0 {
+		return root, "layout", nil
+	}
+	return root, "content", nil
+}
@@ -0,0 +1,292 @@
+package templatestore
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// cssRule is a rule of a style sheet with a selector the inliner understands
+type cssRule struct {
+	selector     []compoundSelector
+	specificity  [3]int
+	order        int
+	declarations []string
+}
+
+// compoundSelector is a tag, classes and an id without any space, e.g. td.content-cell
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// InlineCSS moves the rules of the style sheets into the style attributes, most
+// email clients drop the <style> elements. the rules it can't inline, like media
+// queries and pseudo classes, are kept in the sheet for the clients which do read it.
+func InlineCSS(document string) (string, error) {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+	var rules []cssRule
+	var styles []*html.Node
+	walk(root, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.DataAtom != atom.Style || n.FirstChild == nil {
+			return
+		}
+		inlinable, kept := parseStyleSheet(n.FirstChild.Data, len(rules))
+		rules = append(rules, inlinable...)
+		n.FirstChild.Data = kept
+		styles = append(styles, n)
+	})
+	if len(rules) == 0 {
+		return document, nil
+	}
+	for _, style := range styles {
+		if strings.TrimSpace(style.FirstChild.Data) == "" {
+			style.Parent.RemoveChild(style)
+		}
+	}
+	// the most specific rule wins, then the last one, and the style attribute over all
+	slices.SortStableFunc(rules, func(a, b cssRule) int {
+		for i := range a.specificity {
+			if a.specificity[i] != b.specificity[i] {
+				return a.specificity[i] - b.specificity[i]
+			}
+		}
+		return a.order - b.order
+	})
+	walk(root, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		var declarations []string
+		for _, rule := range rules {
+			if matches(n, rule.selector) {
+				declarations = append(declarations, rule.declarations...)
+			}
+		}
+		if len(declarations) == 0 {
+			return
+		}
+		for i, attr := range n.Attr {
+			if attr.Key == "style" {
+				n.Attr[i].Val = mergeDeclarations(append(declarations, splitDeclarations(attr.Val)...))
+				return
+			}
+		}
+		n.Attr = append(n.Attr, html.Attribute{Key: "style", Val: mergeDeclarations(declarations)})
+	})
+	var out bytes.Buffer
+	if err := html.Render(&out, root); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func walk(n *html.Node, visit func(*html.Node)) {
+	visit(n)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, visit)
+	}
+}
+
+// parseStyleSheet splits the sheet in the rules which can be inlined and the
+// text of the ones which stay in the sheet
+func parseStyleSheet(sheet string, order int) ([]cssRule, string) {
+	var rules []cssRule
+	var kept strings.Builder
+	sheet = stripComments(sheet)
+	for len(strings.TrimSpace(sheet)) > 0 {
+		open := strings.Index(sheet, "{")
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(sheet[:open])
+		end := blockEnd(sheet, open)
+		block := sheet[open+1 : end]
+		sheet = sheet[min(end+1, len(sheet)):]
+		if strings.HasPrefix(prelude, "@") {
+			kept.WriteString(prelude + " {" + block + "}\n")
+			continue
+		}
+		declarations := splitDeclarations(block)
+		var unsupported []string
+		for _, selector := range strings.Split(prelude, ",") {
+			selector = strings.TrimSpace(selector)
+			compounds, ok := parseSelector(selector)
+			if !ok {
+				unsupported = append(unsupported, selector)
+				continue
+			}
+			rules = append(rules, cssRule{
+				selector:     compounds,
+				specificity:  specificity(compounds),
+				order:        order,
+				declarations: declarations,
+			})
+			order++
+		}
+		if len(unsupported) > 0 {
+			kept.WriteString(strings.Join(unsupported, ", ") + " {" + block + "}\n")
+		}
+	}
+	return rules, kept.String()
+}
+
+func stripComments(sheet string) string {
+	for {
+		start := strings.Index(sheet, "/*")
+		if start < 0 {
+			return sheet
+		}
+		end := strings.Index(sheet[start+2:], "*/")
+		if end < 0 {
+			return sheet[:start]
+		}
+		sheet = sheet[:start] + sheet[start+2+end+2:]
+	}
+}
+
+// blockEnd finds the brace closing the one at open, at-rules nest blocks
+func blockEnd(sheet string, open int) int {
+	depth := 0
+	for i := open; i < len(sheet); i++ {
+		switch sheet[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(sheet)
+}
+
+func splitDeclarations(block string) []string {
+	var declarations []string
+	for _, declaration := range strings.Split(block, ";") {
+		property, value, ok := strings.Cut(declaration, ":")
+		if !ok {
+			continue
+		}
+		property, value = strings.TrimSpace(property), strings.TrimSpace(value)
+		// vendor extensions of the inliners, e.g. -premailer-width, mean nothing to the clients
+		if property == "" || value == "" || strings.HasPrefix(property, "-premailer") {
+			continue
+		}
+		declarations = append(declarations, strings.ToLower(property)+": "+value)
+	}
+	return declarations
+}
+
+// mergeDeclarations keeps the last value of every property, in the order they first appear
+func mergeDeclarations(declarations []string) string {
+	var properties []string
+	values := map[string]string{}
+	for _, declaration := range declarations {
+		property, value, _ := strings.Cut(declaration, ": ")
+		if _, ok := values[property]; !ok {
+			properties = append(properties, property)
+		}
+		// an !important value is not overridden by the ones which aren't
+		if strings.HasSuffix(values[property], "!important") && !strings.HasSuffix(value, "!important") {
+			continue
+		}
+		values[property] = value
+	}
+	var merged []string
+	for _, property := range properties {
+		merged = append(merged, property+": "+values[property])
+	}
+	return strings.Join(merged, "; ")
+}
+
+// parseSelector understands tags, classes, ids and the descendant combinator
+func parseSelector(selector string) ([]compoundSelector, bool) {
+	if selector == "" || strings.ContainsAny(selector, ":[>+~*()") {
+		return nil, false
+	}
+	var compounds []compoundSelector
+	for _, part := range strings.Fields(selector) {
+		var compound compoundSelector
+		rest := part
+		for rest != "" {
+			i := strings.IndexAny(rest[1:], ".#") + 1
+			if i == 0 {
+				i = len(rest)
+			}
+			token := rest[:i]
+			rest = rest[i:]
+			switch token[0] {
+			case '.':
+				compound.classes = append(compound.classes, token[1:])
+			case '#':
+				compound.id = token[1:]
+			default:
+				compound.tag = strings.ToLower(token)
+			}
+		}
+		compounds = append(compounds, compound)
+	}
+	return compounds, true
+}
+
+func specificity(compounds []compoundSelector) [3]int {
+	var s [3]int
+	for _, compound := range compounds {
+		if compound.id != "" {
+			s[0]++
+		}
+		s[1] += len(compound.classes)
+		if compound.tag != "" {
+			s[2]++
+		}
+	}
+	return s
+}
+
+// matches checks the last compound against the node and the others against its ancestors
+func matches(n *html.Node, compounds []compoundSelector) bool {
+	last := len(compounds) - 1
+	if !matchesCompound(n, compounds[last]) {
+		return false
+	}
+	for ancestor := n.Parent; last > 0 && ancestor != nil; ancestor = ancestor.Parent {
+		if ancestor.Type == html.ElementNode && matchesCompound(ancestor, compounds[last-1]) {
+			last--
+		}
+	}
+	return last == 0
+}
+
+func matchesCompound(n *html.Node, compound compoundSelector) bool {
+	if compound.tag != "" && n.Data != compound.tag {
+		return false
+	}
+	var id string
+	var classes []string
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "id":
+			id = attr.Val
+		case "class":
+			classes = strings.Fields(attr.Val)
+		}
+	}
+	if compound.id != "" && compound.id != id {
+		return false
+	}
+	for _, class := range compound.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,11 +2,22 @@ package templatestore
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
 )
 
+const (
+	DefaultScope  = "default"
+	DefaultLocale = "en"
+)
+
+// ErrTemplateNotFound is returned by the stores when there is no template for the params
+var ErrTemplateNotFound = errors.New("template not found")
+
 type TemplateStorage interface {
 	CreateTemplate(ctx context.Context, template dao.Template) (dao.Template, error)
 	FindTemplate(ctx context.Context, params FindTemplateParams) (dao.Template, error)
@@ -16,5 +27,31 @@ func New(env string, repo repository.Repository) TemplateStorage {
 	if env == "production" {
 		return NewDbTemplateStore(repo)
 	}
-	return NewFSTemplateStore()
+	// in local the templates managed through the admin api take over the embedded ones
+	return NewFallbackTemplateStore(NewDbTemplateStore(repo), NewFSTemplateStore())
+}
+
+// Resolve finds the most specific template there is for the params, a business
+// without its own template gets the default one and a locale without a translation
+// gets the default locale: (scope, locale) -> (default, locale) -> (scope, en) -> (default, en)
+func Resolve(ctx context.Context, store TemplateStorage, params FindTemplateParams) (dao.Template, error) {
+	var candidates []FindTemplateParams
+	for _, locale := range []string{params.Locale, DefaultLocale} {
+		for _, scope := range []string{params.Scope, DefaultScope} {
+			candidate := params
+			candidate.Locale, candidate.Scope = locale, scope
+			if locale == "" || scope == "" || slices.Contains(candidates, candidate) {
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	for _, candidate := range candidates {
+		template, err := store.FindTemplate(ctx, candidate)
+		if errors.Is(err, ErrTemplateNotFound) {
+			continue
+		}
+		return template, err
+	}
+	return dao.Template{}, fmt.Errorf("%w: %s %s", ErrTemplateNotFound, params.Event, params.Channel)
 }
@@ -0,0 +1,93 @@
+package templatestore
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// HTMLToText makes the plain text alternative of an html email whose template has none,
+// the blocks go on their own lines and the links keep their url
+func HTMLToText(document string) string {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return ""
+	}
+	var text strings.Builder
+	writeText(&text, root)
+	lines := strings.Split(text.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
+
+func writeText(text *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		// the spaces around a text separate it from the inline elements next to it
+		words := strings.Join(strings.Fields(n.Data), " ")
+		if words == "" || strings.TrimLeftFunc(n.Data, unicode.IsSpace) != n.Data {
+			writeSpace(text)
+		}
+		text.WriteString(words)
+		if words != "" && strings.TrimRightFunc(n.Data, unicode.IsSpace) != n.Data {
+			writeSpace(text)
+		}
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Head, atom.Style, atom.Script, atom.Title:
+			return
+		case atom.Br:
+			text.WriteString("\n")
+			return
+		case atom.Li:
+			text.WriteString("\n- ")
+		case atom.P, atom.Div, atom.Table, atom.Tr, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Ul, atom.Ol:
+			text.WriteString("\n\n")
+		}
+		// the preheader is only the preview of the mail clients
+		for _, attr := range n.Attr {
+			if attr.Key == "class" && strings.Contains(attr.Val, "preheader") {
+				return
+			}
+		}
+	}
+	start := text.Len()
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeText(text, child)
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+	switch n.DataAtom {
+	case atom.A:
+		href := attribute(n, "href")
+		if href != "" && href != "#" && strings.TrimSpace(text.String()[start:]) != href {
+			text.WriteString(" (" + href + ")")
+		}
+	case atom.P, atom.Div, atom.Table, atom.Tr, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Ul, atom.Ol:
+		text.WriteString("\n\n")
+	}
+}
+
+func writeSpace(text *strings.Builder) {
+	if s := text.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		text.WriteString(" ")
+	}
+}
+
+func attribute(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
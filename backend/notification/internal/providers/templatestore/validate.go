@@ -0,0 +1,115 @@
+package templatestore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+// ErrInvalidTemplate is returned when a template does not parse or references unknown tokens
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// Validate checks that every part of the template parses and references only the tokens
+// of its event and the ones the notifier fills, the html bodies are escaped within the
+// layouts they are rendered with, see HTMLLayouts
+func Validate(t dao.Template, layouts []string) error {
+	names := []string{"subject", "body"}
+	parts := []string{t.Subject, t.Body}
+	for i, parameter := range t.WhatsappParameters {
+		names = append(names, fmt.Sprintf("whatsapp_parameters[%d]", i))
+		parts = append(parts, parameter)
+	}
+	// the layouts are shared by all the events, they only get the ones of the notifier
+	known := append(slices.Clone(notification.Tokens[t.Event]), BrandingToken, UnsubscribeToken, RecipientToken)
+	for i, text := range parts {
+		tmpl, err := template.New(names[i]).Parse(text)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+		}
+		// the blocks and defines of a template are templates of their own
+		for _, associated := range tmpl.Templates() {
+			if associated.Tree == nil {
+				continue
+			}
+			for _, token := range tokens(associated.Tree.Root) {
+				if !slices.Contains(known, token) {
+					return fmt.Errorf("%w: %s references unknown token %s", ErrInvalidTemplate, names[i], token)
+				}
+			}
+		}
+	}
+	if t.Mimetype != "text/html" {
+		return nil
+	}
+	body := t.Body
+	// a layout is checked on top of the ones it overrides, with an empty content
+	if t.Event == LayoutEvent {
+		layouts, body = append(slices.Clone(layouts), t.Body), ""
+	}
+	if err := validateHTML(layouts, body); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+// Render executes the template with the tokens
+func Render(text string, tokens any) (string, error) {
+	tmpl, err := template.New("any").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	body := bytes.Buffer{}
+	if err := tmpl.Execute(&body, tokens); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// tokens collects the fields referenced on the root of the template, the ones
+// inside range and with are relative to another value and are left out
+func tokens(node parse.Node) []string {
+	var found []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			found = append(found, tokens(child)...)
+		}
+	case *parse.ActionNode:
+		found = append(found, tokens(n.Pipe)...)
+	case *parse.IfNode:
+		found = append(found, tokens(n.Pipe)...)
+		found = append(found, tokens(n.List)...)
+		found = append(found, tokens(n.ElseList)...)
+	case *parse.RangeNode:
+		found = append(found, tokens(n.Pipe)...)
+	case *parse.WithNode:
+		found = append(found, tokens(n.Pipe)...)
+	case *parse.TemplateNode:
+		found = append(found, tokens(n.Pipe)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			found = append(found, tokens(cmd)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			found = append(found, tokens(arg)...)
+		}
+	case *parse.ChainNode:
+		found = append(found, tokens(n.Node)...)
+	case *parse.FieldNode:
+		found = append(found, n.Ident[0])
+	}
+	return found
+}
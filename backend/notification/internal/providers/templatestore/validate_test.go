@@ -0,0 +1,61 @@
+package templatestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/dao"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+func TestValidate(t *testing.T) {
+	layouts := []string{`<html><body><a title="{{template "content" .}}">{{.Business.Name}}</a></body></html>`}
+	html := func(event notification.Event, body string) dao.Template {
+		return dao.Template{Event: event, Channel: notification.EMAIL, Mimetype: "text/html", Subject: "Verify", Body: body}
+	}
+
+	for name, test := range map[string]struct {
+		template dao.Template
+		layouts  []string
+		valid    bool
+	}{
+		"html alone":               {html(notification.EMAIL_VERIFICATION, `<p>{{.Otp}}</p>`), nil, true},
+		"unknown token":            {html(notification.EMAIL_VERIFICATION, `<p>{{.Password}}</p>`), nil, false},
+		"unfinished attribute":     {html(notification.EMAIL_VERIFICATION, `<a href="{{.Otp}}`), nil, false},
+		"fine within the layout":   {html(notification.EMAIL_VERIFICATION, `code {{.Otp}}`), layouts, true},
+		"breaks out of the layout": {html(notification.EMAIL_VERIFICATION, `{{if .Otp}}"{{end}}`), layouts, false},
+		"fine on its own":          {html(notification.EMAIL_VERIFICATION, `{{if .Otp}}"{{end}}`), nil, true},
+		"layout":                   {html(LayoutEvent, layouts[0]), nil, true},
+		"layout over another":      {html(LayoutEvent, `{{define "footer"}}<p>{{.Business.Name}}</p>{{end}}`), layouts, true},
+		"broken layout":            {html(LayoutEvent, `<div class="{{template "content" .}}`), nil, false},
+		"text is not escaped": {
+			dao.Template{Event: notification.EMAIL_VERIFICATION, Channel: notification.SMS, Mimetype: "text/plain", Body: `<a href="{{.Otp}}`}, layouts, true,
+		},
+	} {
+		err := Validate(test.template, test.layouts)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrInvalidTemplate)
+		}
+	}
+}
+
+func TestEmbeddedTemplatesAreValid(t *testing.T) {
+	templates, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewFSTemplateStore()
+	for _, template := range templates {
+		layouts, err := HTMLLayouts(context.Background(), store, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(template, layouts); err != nil {
+			t.Errorf("%s/%s/%s: %v", template.Event, template.Channel, template.Locale, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Vapid sends web push messages signed with the VAPID keys (RFC 8292) and
+// encrypted for the subscription (RFC 8291), straight to the push services
+// of the browsers
+type Vapid struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+	subject    string
+	ttl        time.Duration
+	client     *http.Client
+}
+
+func NewVapid(config config.Push) (*Vapid, error) {
+	if config.VapidPrivateKey == "" || config.VapidSubject == "" {
+		return nil, fmt.Errorf("vapid private key and subject are required")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(config.VapidPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+	privateKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+	publicKey, err := privateKey.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &Vapid{
+		privateKey: privateKey,
+		publicKey:  base64.RawURLEncoding.EncodeToString(publicKey),
+		subject:    config.VapidSubject,
+		ttl:        config.TTL,
+		client:     &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+func (v *Vapid) PublicKey() string {
+	return v.publicKey
+}
+
+func (v *Vapid) Send(subscription Subscription, payload []byte) error {
+	// subscribed before the endpoints were checked, it is dropped like a gone one
+	if !ValidEndpoint(subscription.Endpoint) {
+		return fmt.Errorf("%w: %q is not a known push service", ErrSubscriptionGone, subscription.Endpoint)
+	}
+	body, err := encrypt(subscription, payload)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid push endpoint: %w", err)
+	}
+	// the token is bound to the origin of the push service
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{endpoint.Scheme + "://" + endpoint.Host},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
+		Subject:   v.subject,
+	}).SignedString(v.privateKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, v.publicKey))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(v.ttl.Seconds())))
+	res, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case res.StatusCode >= 300:
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("push service responded with %d: %s", res.StatusCode, reason)
+	}
+	return nil
+}
+
+// recordSize of the single record the payload is sent in, push services take up to 4096
+const recordSize = 4096
+
+// encrypt encodes the payload with the aes128gcm content encoding, keyed by
+// an ecdh agreement between a fresh key and the key of the subscription
+func encrypt(subscription Subscription, payload []byte) ([]byte, error) {
+	userAgentKey, err := base64.RawURLEncoding.DecodeString(subscription.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(subscription.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	userAgentPublic, err := ecdh.P256().NewPublicKey(userAgentKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	serverPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverPrivate.ECDH(userAgentPublic)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverPrivate.PublicKey().Bytes()
+
+	// ikm = hkdf(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public)
+	prk, err := hkdf.Extract(sha256.New, sharedSecret, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	info := append(append([]byte("WebPush: info\x00"), userAgentKey...), serverPublic...)
+	ikm, err := hkdf.Expand(sha256.New, prk, string(info), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk, err = hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 delimits the last (and only) record, no padding
+	plaintext := append(bytes.Clone(payload), 0x02)
+	if len(plaintext)+gcm.Overhead() > recordSize {
+		return nil, fmt.Errorf("push payload of %d bytes is too large", len(payload))
+	}
+
+	// header: salt || record size || key id length || key id (the server public key)
+	body := make([]byte, 0, 16+4+1+len(serverPublic)+len(plaintext)+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(serverPublic)))
+	body = append(body, serverPublic...)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
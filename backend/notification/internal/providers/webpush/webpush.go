@@ -0,0 +1,78 @@
+package webpush
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+)
+
+// ErrSubscriptionGone is returned when the push service no longer knows the
+// subscription, e.g. the user revoked the permission, it should be deleted
+var ErrSubscriptionGone = errors.New("push subscription is gone")
+
+// Subscription is what the browser returns from PushManager.subscribe
+type Subscription struct {
+	Endpoint string
+	// P256dh and Auth are the base64url encoded keys of the subscription
+	P256dh string
+	Auth   string
+}
+
+// pushServices are the hosts of the push services of the browsers, a leading
+// dot takes the subdomains
+var pushServices = []string{
+	"fcm.googleapis.com",
+	"updates.push.services.mozilla.com",
+	".push.apple.com",
+	".notify.windows.com",
+}
+
+// ValidEndpoint reports whether the endpoint is a push service of a browser,
+// anything else would have the notifier post to hosts the users pick
+func ValidEndpoint(endpoint string) bool {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.User != nil || (parsed.Port() != "" && parsed.Port() != "443") {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, service := range pushServices {
+		if host == service || (strings.HasPrefix(service, ".") && strings.HasSuffix(host, service)) {
+			return true
+		}
+	}
+	return false
+}
+
+type Provider interface {
+	// PublicKey is the application server key the browsers subscribe with
+	PublicKey() string
+	Send(subscription Subscription, payload []byte) error
+}
+
+func New(config config.Push) (Provider, error) {
+	switch config.Provider {
+	case "log":
+		return &Logger{}, nil
+	case "vapid":
+		return NewVapid(config)
+	}
+	return nil, fmt.Errorf("unknown push provider %q", config.Provider)
+}
+
+// Logger only logs the messages, for running in local
+type Logger struct {
+	// some config
+}
+
+func (l *Logger) PublicKey() string {
+	return ""
+}
+
+func (l *Logger) Send(subscription Subscription, payload []byte) error {
+	logger.Info().Str("endpoint", subscription.Endpoint).Int("bytes", len(payload)).Msg("sending web push")
+	return nil
+}
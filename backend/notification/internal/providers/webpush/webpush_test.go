@@ -0,0 +1,27 @@
+package webpush
+
+import "testing"
+
+func TestValidEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]bool{
+		"https://fcm.googleapis.com/fcm/send/abc":              true,
+		"https://updates.push.services.mozilla.com/wpush/v2/a": true,
+		"https://web.push.apple.com/QGx":                       true,
+		"https://wns2-par02p.notify.windows.com/w/?token=a":    true,
+		"https://FCM.googleapis.com:443/fcm/send/abc":          true,
+		"http://fcm.googleapis.com/fcm/send/abc":               false,
+		"https://fcm.googleapis.com:8443/fcm/send/abc":         false,
+		"https://user@fcm.googleapis.com/fcm/send/abc":         false,
+		"https://push.apple.com.attacker.test/a":               false,
+		"https://evilpush.apple.com/a":                         false,
+		"https://127.0.0.1/a":                                  false,
+		"https://169.254.169.254/latest/meta-data":             false,
+		"https://localhost/a":                                  false,
+		"not a url":                                            false,
+		"":                                                     false,
+	} {
+		if got := ValidEndpoint(endpoint); got != want {
+			t.Errorf("ValidEndpoint(%q) = %v, want %v", endpoint, got, want)
+		}
+	}
+}
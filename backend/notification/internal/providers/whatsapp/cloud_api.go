@@ -0,0 +1,263 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+const cloudApiUrl = "https://graph.facebook.com/v21.0"
+
+// CloudApi sends through the whatsapp business cloud api
+type CloudApi struct {
+	url           string
+	phoneNumberID string
+	accessToken   string
+	verifyToken   string
+	appSecret     string
+	client        *http.Client
+}
+
+func NewCloudApi(config config.Whatsapp) (*CloudApi, error) {
+	if config.PhoneNumberID == "" || config.AccessToken == "" {
+		return nil, fmt.Errorf("whatsapp phone number id and access token are required")
+	}
+	if config.VerifyToken == "" || config.AppSecret == "" {
+		return nil, fmt.Errorf("whatsapp verify token and app secret are required to verify the webhooks")
+	}
+	url := config.ApiUrl
+	if url == "" {
+		url = cloudApiUrl
+	}
+	return &CloudApi{
+		url:           strings.TrimSuffix(url, "/"),
+		phoneNumberID: config.PhoneNumberID,
+		accessToken:   config.AccessToken,
+		verifyToken:   config.VerifyToken,
+		appSecret:     config.AppSecret,
+		client:        &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+type cloudApiParameter struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Document *cloudApiDocument `json:"document,omitempty"`
+}
+
+type cloudApiDocument struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type cloudApiComponent struct {
+	Type       string              `json:"type"`
+	Parameters []cloudApiParameter `json:"parameters"`
+}
+
+type cloudApiMessage struct {
+	MessagingProduct string `json:"messaging_product"`
+	To               string `json:"to"`
+	Type             string `json:"type"`
+	Template         struct {
+		Name     string `json:"name"`
+		Language struct {
+			Code string `json:"code"`
+		} `json:"language"`
+		Components []cloudApiComponent `json:"components,omitempty"`
+	} `json:"template"`
+}
+
+func (c *CloudApi) Send(ctx context.Context, to string, template Template) (string, error) {
+	message := cloudApiMessage{MessagingProduct: "whatsapp", To: NormalizePhone(to), Type: "template"}
+	message.Template.Name = template.Name
+	message.Template.Language.Code = template.Language
+	if template.Document != nil {
+		mediaID, err := c.upload(ctx, *template.Document)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload document: %w", err)
+		}
+		message.Template.Components = append(message.Template.Components, cloudApiComponent{
+			Type: "header",
+			Parameters: []cloudApiParameter{{
+				Type:     "document",
+				Document: &cloudApiDocument{ID: mediaID, Filename: template.Document.Name},
+			}},
+		})
+	}
+	if len(template.Parameters) > 0 {
+		body := cloudApiComponent{Type: "body"}
+		for _, parameter := range template.Parameters {
+			body.Parameters = append(body.Parameters, cloudApiParameter{Type: "text", Text: parameter})
+		}
+		message.Template.Components = append(message.Template.Components, body)
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := c.do(ctx, "/messages", "application/json", bytes.NewReader(payload), &response); err != nil {
+		return "", err
+	}
+	if len(response.Messages) == 0 {
+		return "", fmt.Errorf("whatsapp returned no message id")
+	}
+	return response.Messages[0].ID, nil
+}
+
+// upload stores the document on whatsapp, the message refers to it by the returned id
+func (c *CloudApi) upload(ctx context.Context, document notification.Attachment) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField("messaging_product", "whatsapp"); err != nil {
+		return "", err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, document.Name))
+	header.Set("Content-Type", document.MimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(document.Data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, "/media", writer.FormDataContentType(), body, &response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+func (c *CloudApi) do(ctx context.Context, path string, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/"+c.phoneNumberID+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", contentType)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("whatsapp responded with %d: %s", res.StatusCode, reason)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// keywords of the inbound messages which change the opt-in of the sender
+var (
+	optOutKeywords = map[string]bool{"STOP": true, "UNSUBSCRIBE": true}
+	optInKeywords  = map[string]bool{"START": true, "SUBSCRIBE": true}
+)
+
+func (c *CloudApi) VerifyWebhook(token string) error {
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.verifyToken)) != 1 {
+		return ErrUnverifiedWebhook
+	}
+	return nil
+}
+
+// verifySignature checks the sha256 hmac of the body, keyed with the app secret
+func (c *CloudApi) verifySignature(body []byte, signature string) error {
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return ErrUnverifiedWebhook
+	}
+	mac := hmac.New(sha256.New, []byte(c.appSecret))
+	mac.Write(body)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return ErrUnverifiedWebhook
+	}
+	return nil
+}
+
+func (c *CloudApi) ParseWebhook(body []byte, signature string) (Webhook, error) {
+	if err := c.verifySignature(body, signature); err != nil {
+		return Webhook{}, err
+	}
+	var payload struct {
+		Entry []struct {
+			Changes []struct {
+				Value struct {
+					Statuses []struct {
+						ID     string `json:"id"`
+						Status string `json:"status"`
+						Errors []struct {
+							Code  int    `json:"code"`
+							Title string `json:"title"`
+						} `json:"errors"`
+					} `json:"statuses"`
+					Messages []struct {
+						From string `json:"from"`
+						Type string `json:"type"`
+						Text struct {
+							Body string `json:"body"`
+						} `json:"text"`
+					} `json:"messages"`
+				} `json:"value"`
+			} `json:"changes"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Webhook{}, err
+	}
+	var webhook Webhook
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			for _, status := range change.Value.Statuses {
+				// sent is not final, read comes after delivered
+				switch status.Status {
+				case "delivered", "read":
+					webhook.Statuses = append(webhook.Statuses, Status{MessageID: status.ID, Delivered: true})
+				case "failed":
+					reason := "failed"
+					if len(status.Errors) > 0 {
+						reason = fmt.Sprintf("%d: %s", status.Errors[0].Code, status.Errors[0].Title)
+					}
+					webhook.Statuses = append(webhook.Statuses, Status{MessageID: status.ID, Reason: reason})
+				}
+			}
+			for _, message := range change.Value.Messages {
+				if message.Type != "text" {
+					continue
+				}
+				keyword := strings.ToUpper(strings.TrimSpace(message.Text.Body))
+				if optOutKeywords[keyword] {
+					webhook.OptOuts = append(webhook.OptOuts, message.From)
+				}
+				if optInKeywords[keyword] {
+					webhook.OptIns = append(webhook.OptIns, message.From)
+				}
+			}
+		}
+	}
+	return webhook, nil
+}
@@ -0,0 +1,176 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+)
+
+// cloudApiStandIn answers like the graph api and keeps what was posted to it
+type cloudApiStandIn struct {
+	mu       sync.Mutex
+	paths    []string
+	messages []cloudApiMessage
+	uploads  []string
+}
+
+func newTestCloudApi(t *testing.T) (*CloudApi, *cloudApiStandIn) {
+	t.Helper()
+	standIn := &cloudApiStandIn{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		standIn.mu.Lock()
+		defer standIn.mu.Unlock()
+		standIn.paths = append(standIn.paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/1234/media":
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			standIn.uploads = append(standIn.uploads, header.Filename+":"+string(data))
+			fmt.Fprint(w, `{"id":"media-1"}`)
+		case "/1234/messages":
+			var message cloudApiMessage
+			if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if message.To == "910000000000" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":{"message":"invalid number"}}`)
+				return
+			}
+			standIn.messages = append(standIn.messages, message)
+			fmt.Fprintf(w, `{"messages":[{"id":"wamid.%d"}]}`, len(standIn.messages))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	c, err := NewCloudApi(config.Whatsapp{
+		ApiUrl: server.URL + "/", PhoneNumberID: "1234", AccessToken: "token",
+		VerifyToken: "verify", AppSecret: "secret", Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, standIn
+}
+
+func TestCloudApiSendsTheTemplate(t *testing.T) {
+	c, standIn := newTestCloudApi(t)
+
+	id, err := c.Send(context.Background(), "+91 98765-43210", Template{
+		Name: "invoice", Language: "en", Parameters: []string{"Asha", "INV-1"},
+		Document: &notification.Attachment{Name: "INV-1.pdf", MimeType: "application/pdf", Data: []byte("%PDF")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "wamid.1" {
+		t.Fatalf("id = %q, want the one of whatsapp", id)
+	}
+	if !slices.Equal(standIn.paths, []string{"/1234/media", "/1234/messages"}) {
+		t.Fatalf("paths = %v, want the document uploaded first", standIn.paths)
+	}
+	if !slices.Equal(standIn.uploads, []string{"INV-1.pdf:%PDF"}) {
+		t.Fatalf("uploads = %v", standIn.uploads)
+	}
+	message := standIn.messages[0]
+	if message.To != "919876543210" || message.Template.Name != "invoice" || message.Template.Language.Code != "en" {
+		t.Fatalf("message = %+v", message)
+	}
+	components := message.Template.Components
+	if len(components) != 2 || components[0].Parameters[0].Document.ID != "media-1" ||
+		components[1].Parameters[0].Text != "Asha" || components[1].Parameters[1].Text != "INV-1" {
+		t.Fatalf("components = %+v", components)
+	}
+}
+
+func TestCloudApiSendFailure(t *testing.T) {
+	c, _ := newTestCloudApi(t)
+	if _, err := c.Send(context.Background(), "+910000000000", Template{Name: "invoice", Language: "en"}); err == nil {
+		t.Fatal("a rejected message was sent")
+	}
+}
+
+func TestCloudApiVerifyWebhook(t *testing.T) {
+	c, _ := newTestCloudApi(t)
+	if err := c.VerifyWebhook("verify"); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"", "other"} {
+		if err := c.VerifyWebhook(token); !errors.Is(err, ErrUnverifiedWebhook) {
+			t.Fatalf("token %q: err = %v, want %v", token, err, ErrUnverifiedWebhook)
+		}
+	}
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestCloudApiParseWebhook(t *testing.T) {
+	c, _ := newTestCloudApi(t)
+	body := []byte(`{"entry":[{"changes":[{"value":{
+		"statuses":[
+			{"id":"wamid.1","status":"sent"},
+			{"id":"wamid.1","status":"delivered"},
+			{"id":"wamid.2","status":"failed","errors":[{"code":131026,"title":"Message undeliverable"}]}
+		],
+		"messages":[
+			{"from":"919876543210","type":"text","text":{"body":" stop "}},
+			{"from":"919123456789","type":"text","text":{"body":"START"}}
+		]
+	}}]}]}`)
+
+	webhook, err := c.ParseWebhook(body, sign("secret", body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStatuses := []Status{
+		{MessageID: "wamid.1", Delivered: true},
+		{MessageID: "wamid.2", Reason: "131026: Message undeliverable"},
+	}
+	if !slices.Equal(webhook.Statuses, wantStatuses) {
+		t.Fatalf("statuses = %+v, want %+v", webhook.Statuses, wantStatuses)
+	}
+	if !slices.Equal(webhook.OptOuts, []string{"919876543210"}) || !slices.Equal(webhook.OptIns, []string{"919123456789"}) {
+		t.Fatalf("opt outs = %v, opt ins = %v", webhook.OptOuts, webhook.OptIns)
+	}
+
+	for _, signature := range []string{"", sign("other", body), "sha256=zz", sign("secret", body)[7:]} {
+		if _, err := c.ParseWebhook(body, signature); !errors.Is(err, ErrUnverifiedWebhook) {
+			t.Fatalf("signature %q: err = %v, want %v", signature, err, ErrUnverifiedWebhook)
+		}
+	}
+}
+
+func TestNewCloudApiNeedsTheWebhookSecrets(t *testing.T) {
+	_, err := NewCloudApi(config.Whatsapp{PhoneNumberID: "1234", AccessToken: "token"})
+	if err == nil {
+		t.Fatal("created without the verify token and the app secret")
+	}
+}
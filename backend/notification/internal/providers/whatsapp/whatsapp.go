@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
+
+// Template is a message template approved by whatsapp, business initiated
+// conversations can only be started with one
+type Template struct {
+	Name     string
+	Language string
+	// Parameters fill the {{1}}, {{2}}... placeholders of the body in order
+	Parameters []string
+	// Document is sent in the header, e.g. an invoice pdf
+	Document *notification.Attachment
+}
+
+// Webhook is what the provider posted to the webhook
+type Webhook struct {
+	Statuses []Status
+	// OptOuts and OptIns are the numbers which asked to stop or start receiving messages
+	OptOuts []string
+	OptIns  []string
+}
+
+// Status is the final status of a message
+type Status struct {
+	MessageID string
+	Delivered bool
+	Reason    string
+}
+
+var (
+	// ErrWebhooksNotSupported is returned by the providers without webhooks
+	ErrWebhooksNotSupported = errors.New("webhooks are not supported")
+	// ErrUnverifiedWebhook is returned for a webhook request which was not sent by the provider
+	ErrUnverifiedWebhook = errors.New("webhook could not be verified")
+)
+
+type Provider interface {
+	// Send sends the template to a single number and returns the id of the message
+	Send(ctx context.Context, to string, template Template) (string, error)
+	// VerifyWebhook checks the verify token sent when the webhook is registered
+	VerifyWebhook(token string) error
+	// ParseWebhook decodes the body once its signature, the X-Hub-Signature-256 header, is verified
+	ParseWebhook(body []byte, signature string) (Webhook, error)
+}
+
+func New(config config.Whatsapp) (Provider, error) {
+	switch config.Provider {
+	case "log":
+		return &Logger{}, nil
+	case "cloud":
+		return NewCloudApi(config)
+	}
+	return nil, fmt.Errorf("unknown whatsapp provider %q", config.Provider)
+}
+
+// a number without a country code is taken as an indian one, like the sms
+const (
+	DefaultCountryCode   = "91"
+	nationalNumberLength = 10
+)
+
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "")
+
+// NormalizePhone returns the number the way whatsapp reports it, digits only
+// with the country code, so the opt-ins match the numbers of the webhooks
+func NormalizePhone(phone string) string {
+	phone = phoneSeparators.Replace(phone)
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return phone[1:]
+	case strings.HasPrefix(phone, "00"):
+		return phone[2:]
+	}
+	// the trunk prefix 0 is only dialled within the country
+	if national := strings.TrimPrefix(phone, "0"); len(national) == nationalNumberLength {
+		return DefaultCountryCode + national
+	}
+	return phone
+}
+
+// Logger only logs the messages, for running in local
+type Logger struct {
+	// some config
+}
+
+func (l *Logger) Send(ctx context.Context, to string, template Template) (string, error) {
+	logger.Info().Str("to", to).Str("template", template.Name).Strs("parameters", template.Parameters).
+		Bool("document", template.Document != nil).Msg("sending whatsapp message")
+	return uuid.NewString(), nil
+}
+
+func (l *Logger) VerifyWebhook(token string) error {
+	return ErrWebhooksNotSupported
+}
+
+func (l *Logger) ParseWebhook(body []byte, signature string) (Webhook, error) {
+	return Webhook{}, ErrWebhooksNotSupported
+}
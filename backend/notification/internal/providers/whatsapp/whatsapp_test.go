@@ -0,0 +1,21 @@
+package whatsapp
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	for phone, want := range map[string]string{
+		"+91 98765 43210":   "919876543210",
+		"+91-98765-43210":   "919876543210",
+		"+91 (987) 6543210": "919876543210",
+		"0091 9876543210":   "919876543210",
+		"919876543210":      "919876543210",
+		"98765 43210":       "919876543210",
+		"098765-43210":      "919876543210",
+		"+1 (415) 555-0100": "14155550100",
+		"":                  "",
+	} {
+		if got := NormalizePhone(phone); got != want {
+			t.Errorf("NormalizePhone(%q) = %q, want %q", phone, got, want)
+		}
+	}
+}
@@ -5,14 +5,24 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/notification/internal/config"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/core/consumer"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/inbox"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/core/notifier"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/preference"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/core/service"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/database"
 	"github.com/aritradevelops/billbharat/backend/notification/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/notification/internal/providers/templatestore"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	figure "github.com/common-nighthawk/go-figure"
 )
 
@@ -23,8 +33,27 @@ func main() {
 		return
 	}
 
+	if err := logger.Configure(conf.Log.Level, conf.Log.Levels); err != nil {
+		logger.Error().Err(err).Msg("failed to configure logger")
+		return
+	}
+
 	figure.NewColorFigure(conf.Service.Name, "", "blue", true).Print()
 
+	shutdownTracing, err := tracing.Init(context.Background(), conf.Service.Name, conf.Tracing)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to init tracing")
+		return
+	}
+	// flush the spans still buffered before exiting
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error().Err(err).Msg("failed to shutdown tracing")
+		}
+	}()
+
 	db := database.NewMongoDB(conf.Database.Uri)
 
 	if err := db.Connect(); err != nil {
@@ -34,21 +63,87 @@ func main() {
 	defer db.Disconnect()
 
 	repo := repository.NewRepository(db)
+	if err := repo.Migrate(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to migrate database")
+		return
+	}
+	if err := repo.EnsureIndexes(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("failed to create indexes")
+		return
+	}
+
+	// `seed-templates` copies the embedded templates missing in the database and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed-templates" {
+		created, err := service.NewTemplateService(repo, templatestore.New(conf.Deployment.Env, repo)).Seed(context.Background())
+		if err != nil {
+			// the deploy job must fail, the deferred disconnect does not run after the exit
+			db.Disconnect()
+			logger.Fatal().Err(err).Msg("failed to seed templates")
+		}
+		logger.Info().Int("created", created).Msg("templates seeded")
+		return
+	}
 
-	eventManager := events.NewKafkaEventManager(events.KafkaOpts{
+	eventManager := events.New(conf.EventBroker.Driver, events.KafkaOpts{
 		Servers: conf.EventBroker.Servers,
 		GroupId: conf.EventBroker.GroupID,
-	})
+	}, events.MemoryOpts{})
 	ctx, stop := signal.NotifyContext(
 		context.Background(),
 		os.Interrupt,
 		syscall.SIGTERM,
 	)
 	defer stop()
-	notifier := notifier.New(ctx, conf.Deployment.Env, repo, conf.Mailer)
-	consumer := consumer.New(ctx, eventManager, repo, notifier)
+	hub := inbox.NewHub()
+	templateStore := templatestore.New(conf.Deployment.Env, repo)
+	notifier, err := notifier.New(ctx, templateStore, repo, hub, conf.Mailer, conf.Sms, conf.Whatsapp, conf.Push, conf.Unsubscribe, conf.Broadcast)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create notifier")
+		return
+	}
+	consumer := consumer.New(ctx, eventManager, events.SubscribeOpts{Concurrency: conf.EventBroker.Concurrency}, repo, notifier)
+	if conf.Snapshot.Bootstrap {
+		if err := consumer.Bootstrap(snapshot.NewClient(conf.Snapshot.Url, conf.Snapshot.ApiKey)); err != nil {
+			logger.Error().Err(err).Msg("failed to bootstrap")
+			return
+		}
+	}
 	consumer.Start()
+	notifier.Start()
+
+	srv := service.New(repo, templateStore, notifier, hub, preference.NewSigner(conf.Unsubscribe.Secret, conf.Unsubscribe.Lifetime.Duration()))
+	handler := handlers.New(db, srv)
+	tokens := identity.NewTokenVerifier(conf.Jwt.Secret)
+	// the identities the broker forwards are only trusted with the shared secret
+	var gateway *identity.Signer
+	if conf.Gateway.Secret != "" {
+		gateway = identity.NewSigner(conf.Gateway.Secret)
+	}
+	server := httpd.NewServer(conf.Http.Host, conf.Http.Port, handler, tokens, gateway, conf.Gateway.VerifyToken, conf.Internal.ApiKey, conf.Webhook.Token)
+	server.SetupRoutes()
 
-	<-ctx.Done()
+	go func() {
+		<-ctx.Done()
+		// the live streams never end on their own
+		hub.Close()
+		if err := server.Shutdown(); err != nil {
+			logger.Error().Err(err).Msg("server failed to shutdown")
+		}
+	}()
+
+	if err := server.Start(); err != nil {
+		logger.Error().Err(err).Msg("server failed to start")
+		return
+	}
 	logger.Info().Msg("shutting down")
+
+	// drain the consumers and flush the pending events before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, stats := range eventManager.Stats() {
+		logger.Info().Interface("stats", stats).Msg("subscription stats")
+	}
+	if err := eventManager.Close(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("failed to close event manager")
+	}
 }
@@ -7,18 +7,23 @@ import (
 	// autoload the environment variables
 
 	"github.com/aritradevelops/billbharat/backend/shared/timex"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	"github.com/caarlos0/env/v10"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type Config struct {
-	Http        Http        `envPrefix:"HTTP_"`
-	Database    Database    `envPrefix:"DATABASE_"`
-	Service     Service     `envPrefix:"SERVICE_"`
-	Grpc        Grpc        `envPrefix:"GRPC_"`
-	Deployment  Deployment  `envPrefix:"DEPLOYMENT_"`
-	Jwt         Jwt         `envPrefix:"JWT_"`
-	EventBroker EventBroker `envPrefix:"EVENT_BROKER_"`
+	Http        Http           `envPrefix:"HTTP_"`
+	Database    Database       `envPrefix:"DATABASE_"`
+	Service     Service        `envPrefix:"SERVICE_"`
+	Grpc        Grpc           `envPrefix:"GRPC_"`
+	Deployment  Deployment     `envPrefix:"DEPLOYMENT_"`
+	Jwt         Jwt            `envPrefix:"JWT_"`
+	Gateway     Gateway        `envPrefix:"GATEWAY_"`
+	EventBroker EventBroker    `envPrefix:"EVENT_BROKER_"`
+	Tracing     tracing.Config `envPrefix:"TRACING_"`
+	Log         Log            `envPrefix:"LOG_"`
+	Snapshot    Snapshot       `envPrefix:"SNAPSHOT_"`
 }
 
 type Http struct {
@@ -49,9 +54,36 @@ type Jwt struct {
 	Lifetime timex.Duration `env:"LIFETIME,required"`
 }
 
+// Gateway is the trust in the identity the broker forwards after verifying the access token
+type Gateway struct {
+	// Secret is shared with the broker, the forwarded identity is ignored when it is empty
+	Secret string `env:"SECRET"`
+	// VerifyToken verifies the access token along with the forwarded identity
+	VerifyToken bool `env:"VERIFY_TOKEN" envDefault:"false"`
+}
+
+// Log sets the levels of the logs, Levels sets apart packages, e.g. events:warn
+type Log struct {
+	Level  string            `env:"LEVEL" envDefault:"info"`
+	Levels map[string]string `env:"LEVELS"`
+}
+
 type EventBroker struct {
+	// Driver is either kafka or memory, memory keeps the events in process
+	// and is only meant for running a single service without a broker
+	Driver  string   `env:"DRIVER" envDefault:"kafka"`
 	Servers []string `env:"SERVERS,required" envSeparator:","`
 	GroupID string   `env:"GROUP_ID,required"`
+	// Concurrency is the number of partitions of a topic handled in parallel
+	Concurrency int `env:"CONCURRENCY" envDefault:"4"`
+}
+
+type Snapshot struct {
+	// Bootstrap backfills users and businesses from the auth service on startup,
+	// meant for a new deployment or after the local copies were wiped
+	Bootstrap bool   `env:"BOOTSTRAP" envDefault:"false"`
+	Url       string `env:"URL"`
+	ApiKey    string `env:"API_KEY"`
 }
 
 func Load() (Config, error) {
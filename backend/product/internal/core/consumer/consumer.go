@@ -2,37 +2,68 @@ package consumer
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/aritradevelops/billbharat/backend/product/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/product/internal/persistence/repository"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
 )
 
 type Consumer struct {
 	ctx          context.Context
+	opts         events.SubscribeOpts
 	eventManager events.EventManager
 	repository   repository.Repository
 }
 
-func New(ctx context.Context, eventManager events.EventManager, repository repository.Repository) *Consumer {
+func New(ctx context.Context, eventManager events.EventManager, opts events.SubscribeOpts, repository repository.Repository) *Consumer {
 	return &Consumer{
 		eventManager: eventManager,
 		repository:   repository,
 		ctx:          ctx,
+		opts:         opts,
 	}
 }
 
 func (c *Consumer) Start() {
-	c.eventManager.OnManageUserEvent(c.ctx, c.handleUserEvent)
-	c.eventManager.OnManageBusinessEvent(c.ctx, c.handleBusinessEvent)
-	c.eventManager.OnManageBusinessUserEvent(c.ctx, c.handleBusinessUserEvent)
+	events.NewManageUserTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleUserEvent)
+	events.NewManageBusinessTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleBusinessEvent)
+	events.NewManageBusinessUserTopic(c.eventManager, c.opts).Subscribe(c.ctx, c.handleBusinessUserEvent)
 }
 
-func (c *Consumer) handleUserEvent(payload events.EventPayload[events.ManageUserEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage user event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+// Bootstrap backfills the local copies from the snapshot of the auth service,
+// it must be called before Start. The events published in the meantime stay in
+// the topics and are consumed right after, and since every sync skips data older
+// than the stored copy, the order in which both arrive does not matter.
+func (c *Consumer) Bootstrap(client *snapshot.Client) error {
+	users, err := snapshot.Fetch(c.ctx, client, snapshot.Users, func(ctx context.Context, user events.ManageUserEventPayload) error {
+		return c.repository.SyncUser(ctx, dao.SyncUserParams(user))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill users: %w", err)
+	}
+	businesses, err := snapshot.Fetch(c.ctx, client, snapshot.Businesses, func(ctx context.Context, business events.MangageBusinessEventPayload) error {
+		return c.repository.SyncBusiness(ctx, dao.SyncBusinessParams(business))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill businesses: %w", err)
+	}
+	businessUsers, err := snapshot.Fetch(c.ctx, client, snapshot.BusinessUsers, func(ctx context.Context, businessUser events.MangageBusinessUserEventPayload) error {
+		return c.repository.SyncBusinessUser(ctx, dao.SyncBusinessUserParams(businessUser))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill business users: %w", err)
+	}
+	logger.Info().Int("users", users).Int("businesses", businesses).Int("business_users", businessUsers).Msg("backfill completed")
+	return nil
+}
+
+func (c *Consumer) handleUserEvent(ctx context.Context, payload events.EventPayload[events.ManageUserEventPayload]) error {
+	logger.Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage user event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncUser(ctx, dao.SyncUserParams(payload.Data))
 	if err != nil {
@@ -43,9 +74,9 @@ func (c *Consumer) handleUserEvent(payload events.EventPayload[events.ManageUser
 	return nil
 }
 
-func (c *Consumer) handleBusinessEvent(payload events.EventPayload[events.MangageBusinessEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage business event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+func (c *Consumer) handleBusinessEvent(ctx context.Context, payload events.EventPayload[events.MangageBusinessEventPayload]) error {
+	logger.Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage business event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncBusiness(ctx, dao.SyncBusinessParams(payload.Data))
 	if err != nil {
@@ -56,9 +87,9 @@ func (c *Consumer) handleBusinessEvent(payload events.EventPayload[events.Mangag
 	return nil
 }
 
-func (c *Consumer) handleBusinessUserEvent(payload events.EventPayload[events.MangageBusinessUserEventPayload]) error {
-	logger.Info().Interface("payload", payload).Msg("manage business user event received")
-	ctx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+func (c *Consumer) handleBusinessUserEvent(ctx context.Context, payload events.EventPayload[events.MangageBusinessUserEventPayload]) error {
+	logger.Info().Stringer("id", payload.ID).Str("action", payload.Action).Msg("manage business user event received")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	err := c.repository.SyncBusinessUser(ctx, dao.SyncBusinessUserParams(payload.Data))
 	if err != nil {
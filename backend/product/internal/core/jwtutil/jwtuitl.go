@@ -12,6 +12,8 @@ type JwtPayload struct {
 	Name       string  `json:"name"`
 	Dp         *string `json:"dp"`
 	BusinessID string  `json:"business_id"`
+	// Role is the role of the user in the business
+	Role string `json:"role"`
 }
 type claims struct {
 	JwtPayload
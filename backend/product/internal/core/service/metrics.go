@@ -0,0 +1,11 @@
+package service
+
+import (
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var categoriesCreated = metrics.Factory.NewCounter(prometheus.CounterOpts{
+	Name: "product_categories_created_total",
+	Help: "Product categories created.",
+})
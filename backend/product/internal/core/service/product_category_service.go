@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/product/internal/core/validation"
 	"github.com/aritradevelops/billbharat/backend/product/internal/persistence/dao"
 	"github.com/aritradevelops/billbharat/backend/product/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
 	"github.com/google/uuid"
 )
@@ -14,6 +16,15 @@ type ProductCategoryService interface {
 	CreateProductCategory(ctx context.Context, payload CreateProductCategoryPayload) (CreateProductCategoryResponse, error)
 	UpdateProductCategory(ctx context.Context, payload UpdateProductCategoryPayload) (UpdateProductCategoryResponse, error)
 	ListProductCategories(ctx context.Context, payload ListProductCategoryPayload) ([]ListProductCategoryResponse, error)
+	// Version changes whenever a category of the business does, for the conditional requests
+	Version(ctx context.Context, businessID uuid.UUID) (CatalogVersion, error)
+}
+
+// CatalogVersion is the state of the categories of a business, the deleted ones
+// counted too so a delete changes it
+type CatalogVersion struct {
+	Count        int64
+	LastModified time.Time
 }
 
 type ListProductCategoryPayload struct {
@@ -50,12 +61,14 @@ type UpdateProductCategoryResponse struct {
 	Name string    `json:"name"`
 }
 type productCategoryService struct {
-	repository repository.Repository
+	repository    repository.Repository
+	categoryTopic *events.ManageProductCategoryTopic
 }
 
-func NewProductCategoryService(repository repository.Repository) ProductCategoryService {
+func NewProductCategoryService(repository repository.Repository, eventManager events.EventManager) ProductCategoryService {
 	return &productCategoryService{
-		repository: repository,
+		repository:    repository,
+		categoryTopic: events.NewManageProductCategoryTopic(eventManager, events.SubscribeOpts{}),
 	}
 }
 
@@ -76,10 +89,16 @@ func (s *productCategoryService) CreateProductCategory(ctx context.Context, payl
 		logger.Error().Err(err).Msg("failed to create product category")
 		return response, InternalError
 	}
+	err = s.categoryTopic.Publish(ctx, events.NewProductCategoryManageEvent("create", events.ManageProductCategoryEventPayload(category)))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to emit manage product category event")
+		return response, InternalError
+	}
 
 	response.ID = category.ID
 	response.Name = category.Name
 
+	categoriesCreated.Inc()
 	return response, nil
 }
 
@@ -99,6 +118,11 @@ func (s *productCategoryService) UpdateProductCategory(ctx context.Context, payl
 		logger.Error().Err(err).Msg("failed to update product category")
 		return response, err
 	}
+	err = s.categoryTopic.Publish(ctx, events.NewProductCategoryManageEvent("update", events.ManageProductCategoryEventPayload(category)))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to emit manage product category event")
+		return response, InternalError
+	}
 
 	response.ID = category.ID
 	response.Name = category.Name
@@ -140,3 +164,12 @@ func (s *productCategoryService) ListProductCategories(ctx context.Context, payl
 
 	return response, nil
 }
+
+func (s *productCategoryService) Version(ctx context.Context, businessID uuid.UUID) (CatalogVersion, error) {
+	version, err := s.repository.GetProductCategoriesVersionByBusinessID(ctx, businessID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get product categories version")
+		return CatalogVersion{}, InternalError
+	}
+	return CatalogVersion{Count: version.Count, LastModified: version.LastModified}, nil
+}
@@ -2,16 +2,15 @@ package service
 
 import (
 	"github.com/aritradevelops/billbharat/backend/product/internal/persistence/repository"
+	"github.com/aritradevelops/billbharat/backend/shared/events"
 )
 
 type Service struct {
 	Category ProductCategoryService
 }
 
-func New(repository repository.Repository) *Service {
+func New(repository repository.Repository, eventManager events.EventManager) *Service {
 	return &Service{
-		Category: &productCategoryService{
-			repository: repository,
-		},
+		Category: NewProductCategoryService(repository, eventManager),
 	}
 }
@@ -68,4 +68,5 @@ type User struct {
 	DeactivatedBy *uuid.UUID `json:"deactivated_by"`
 	DeletedAt     *time.Time `json:"deleted_at"`
 	DeletedBy     *uuid.UUID `json:"deleted_by"`
+	Locale        string     `json:"locale"`
 }
@@ -7,6 +7,7 @@ package dao
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -39,6 +40,23 @@ func (q *Queries) CreateProductCategory(ctx context.Context, arg CreateProductCa
 	return i, err
 }
 
+const getProductCategoriesVersionByBusinessID = `-- name: GetProductCategoriesVersionByBusinessID :one
+SELECT count(*)::bigint AS count, coalesce(max(greatest(updated_at, deleted_at)), 'epoch')::timestamptz AS last_modified
+FROM "product_categories" WHERE business_id = $1
+`
+
+type GetProductCategoriesVersionByBusinessIDRow struct {
+	Count        int64     `json:"count"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+func (q *Queries) GetProductCategoriesVersionByBusinessID(ctx context.Context, businessID uuid.UUID) (GetProductCategoriesVersionByBusinessIDRow, error) {
+	row := q.db.QueryRow(ctx, getProductCategoriesVersionByBusinessID, businessID)
+	var i GetProductCategoriesVersionByBusinessIDRow
+	err := row.Scan(&i.Count, &i.LastModified)
+	return i, err
+}
+
 const listProductCategoriesByBusinessID = `-- name: ListProductCategoriesByBusinessID :many
 SELECT id, name, business_id, created_at, created_by, updated_at, updated_by, deleted_at, deleted_by FROM "product_categories" WHERE business_id = $1 AND deleted_at IS NULL ORDER BY name ASC LIMIT $2 OFFSET $3
 `
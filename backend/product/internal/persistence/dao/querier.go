@@ -6,10 +6,13 @@ package dao
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
 	CreateProductCategory(ctx context.Context, arg CreateProductCategoryParams) (ProductCategory, error)
+	GetProductCategoriesVersionByBusinessID(ctx context.Context, businessID uuid.UUID) (GetProductCategoriesVersionByBusinessIDRow, error)
 	ListProductCategoriesByBusinessID(ctx context.Context, arg ListProductCategoriesByBusinessIDParams) ([]ProductCategory, error)
 	SetProductCategoryNameByID(ctx context.Context, arg SetProductCategoryNameByIDParams) (ProductCategory, error)
 	SyncBusiness(ctx context.Context, arg SyncBusinessParams) error
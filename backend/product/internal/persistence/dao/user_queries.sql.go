@@ -29,12 +29,14 @@ INSERT INTO users (
   deactivated_at,
   deactivated_by,
   deleted_at,
-  deleted_by
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT (id) DO 
+  deleted_by,
+  locale
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) ON CONFLICT (id) DO 
 UPDATE SET human_id = $2, name = $3, email = $4, dp = $5, email_verified = $6, phone = $7, 
  phone_verified = $8, created_at = $9,
  created_by = $10, updated_at = $11, updated_by = $12, deactivated_at = $13, deactivated_by = $14,
- deleted_at = $15, deleted_by = $16
+ deleted_at = $15, deleted_by = $16, locale = $17
+WHERE users.updated_at <= EXCLUDED.updated_at
 `
 
 type SyncUserParams struct {
@@ -54,6 +56,7 @@ type SyncUserParams struct {
 	DeactivatedBy *uuid.UUID `json:"deactivated_by"`
 	DeletedAt     *time.Time `json:"deleted_at"`
 	DeletedBy     *uuid.UUID `json:"deleted_by"`
+	Locale        string     `json:"locale"`
 }
 
 func (q *Queries) SyncUser(ctx context.Context, arg SyncUserParams) error {
@@ -74,6 +77,7 @@ func (q *Queries) SyncUser(ctx context.Context, arg SyncUserParams) error {
 		arg.DeactivatedBy,
 		arg.DeletedAt,
 		arg.DeletedBy,
+		arg.Locale,
 	)
 	return err
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -71,3 +72,21 @@ func (p *Postgres) Tx() (*pgxpool.Pool, error) {
 	}
 	return p.pool, nil
 }
+
+// Stats is the state of the pool for the metrics, empty until connected
+func (p *Postgres) Stats() metrics.PoolStats {
+	if p.pool == nil {
+		return metrics.PoolStats{}
+	}
+	stat := p.pool.Stat()
+	return metrics.PoolStats{
+		Total:                stat.TotalConns(),
+		Idle:                 stat.IdleConns(),
+		Acquired:             stat.AcquiredConns(),
+		Max:                  stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+	}
+}
@@ -10,4 +10,5 @@ INSERT INTO "business_users" (
     deleted_at,
     deleted_by
 ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (user_id, business_id) DO 
-UPDATE SET role = $3, created_at = $4, created_by = $5, updated_at = $6, updated_by = $7, deleted_at = $8, deleted_by = $9;
+UPDATE SET role = $3, created_at = $4, created_by = $5, updated_at = $6, updated_by = $7, deleted_at = $8, deleted_by = $9
+WHERE business_users.updated_at <= EXCLUDED.updated_at;
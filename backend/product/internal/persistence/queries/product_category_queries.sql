@@ -7,6 +7,10 @@ UPDATE "product_categories"
 SET name = $2, updated_at = now(), updated_by = $3
 WHERE id = $1 AND deleted_at IS NULL RETURNING *;
 
+-- name: GetProductCategoriesVersionByBusinessID :one
+SELECT count(*)::bigint AS count, coalesce(max(greatest(updated_at, deleted_at)), 'epoch')::timestamptz AS last_modified
+FROM "product_categories" WHERE business_id = $1;
+
 -- name: ListProductCategoriesByBusinessID :many
 SELECT * FROM "product_categories" WHERE business_id = $1 AND deleted_at IS NULL ORDER BY name ASC LIMIT $2 OFFSET $3;
 
@@ -15,9 +15,11 @@ INSERT INTO users (
   deactivated_at,
   deactivated_by,
   deleted_at,
-  deleted_by
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT (id) DO 
+  deleted_by,
+  locale
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) ON CONFLICT (id) DO 
 UPDATE SET human_id = $2, name = $3, email = $4, dp = $5, email_verified = $6, phone = $7, 
  phone_verified = $8, created_at = $9,
  created_by = $10, updated_at = $11, updated_by = $12, deactivated_at = $13, deactivated_by = $14,
- deleted_at = $15, deleted_by = $16;
+ deleted_at = $15, deleted_by = $16, locale = $17
+WHERE users.updated_at <= EXCLUDED.updated_at;
@@ -3,37 +3,84 @@ package authn
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/product/internal/core/jwtutil"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
 	"github.com/aritradevelops/billbharat/backend/shared/logger"
 	"github.com/gofiber/fiber/v2"
 )
 
 const authUserKey = "auth_user"
 
-func Middleware(jwtManager *jwtutil.JwtManager) fiber.Handler {
+// Middleware authenticates the users with the identity the broker forwards when the
+// gateway is set, the requests that do not come through the broker need an access token.
+// verifyToken checks the access token of the forwarded identities too.
+func Middleware(jwtManager *jwtutil.JwtManager, gateway *identity.Signer, verifyToken bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		bearer := c.Get("Authorization")
-		accessToken := strings.TrimPrefix(bearer, "Bearer ")
-		if accessToken == "" {
-			accessToken = c.Cookies("access_token")
-			logger.Info().Msg("Access token not found in header")
+		encoded := c.Get(identity.Header)
+		if gateway == nil || encoded == "" {
+			payload, err := verifyAccessToken(c, jwtManager)
+			if err != nil {
+				return err
+			}
+			return authenticated(c, payload)
 		}
-		if accessToken == "" {
-			logger.Info().Msg("Access token not found in cookies")
-			return fiber.ErrUnauthorized
-		}
-		payload, err := jwtManager.Verify(accessToken)
-		logger.Error().Err(err).Msg("Access token verification failed")
+		forwarded, err := gateway.Verify(encoded, c.Get(identity.SignatureHeader), time.Now())
 		if err != nil {
+			logger.Ctx(c.UserContext()).Info().Err(err).Msg("Forwarded identity verification failed")
 			return fiber.ErrUnauthorized
 		}
-		logger.Info().Msg("Access token verified successfully")
-		c.Locals(authUserKey, payload)
-		return c.Next()
+		if verifyToken {
+			payload, err := verifyAccessToken(c, jwtManager)
+			if err != nil {
+				return err
+			}
+			if payload.UserID != forwarded.UserID || payload.BusinessID != forwarded.BusinessID {
+				logger.Ctx(c.UserContext()).Info().Msg("Forwarded identity does not match the access token")
+				return fiber.ErrUnauthorized
+			}
+		}
+		return authenticated(c, &jwtutil.JwtPayload{
+			UserID:     forwarded.UserID,
+			Email:      forwarded.Email,
+			Name:       forwarded.Name,
+			Dp:         forwarded.Dp,
+			BusinessID: forwarded.BusinessID,
+			Role:       forwarded.Role,
+		})
 	}
 }
 
+// authenticated keeps the user for the handlers, the lines logged for the rest
+// of the request carry the user and the business
+func authenticated(c *fiber.Ctx, payload *jwtutil.JwtPayload) error {
+	c.Locals(authUserKey, payload)
+	c.SetUserContext(logger.WithContext(c.UserContext(), map[string]any{
+		"user_id":     payload.UserID,
+		"business_id": payload.BusinessID,
+	}))
+	return c.Next()
+}
+
+func verifyAccessToken(c *fiber.Ctx, jwtManager *jwtutil.JwtManager) (*jwtutil.JwtPayload, error) {
+	bearer := c.Get("Authorization")
+	accessToken := strings.TrimPrefix(bearer, "Bearer ")
+	if accessToken == "" {
+		accessToken = c.Cookies("access_token")
+	}
+	if accessToken == "" {
+		logger.Ctx(c.UserContext()).Info().Msg("Access token not found")
+		return nil, fiber.ErrUnauthorized
+	}
+	payload, err := jwtManager.Verify(accessToken)
+	if err != nil {
+		logger.Ctx(c.UserContext()).Info().Err(err).Msg("Access token verification failed")
+		return nil, fiber.ErrUnauthorized
+	}
+	return payload, nil
+}
+
 func GetUserFromContext(c *fiber.Ctx) (*jwtutil.JwtPayload, error) {
 	userIn := c.Locals(authUserKey)
 	if userIn == nil {
@@ -0,0 +1,45 @@
+package httpd
+
+import (
+	"net/http"
+
+	"github.com/aritradevelops/billbharat/backend/product/internal/core/service"
+	"github.com/aritradevelops/billbharat/backend/product/internal/ports/httpd/handlers"
+	"github.com/aritradevelops/billbharat/backend/shared/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiSpec documents the routes of SetupRoutes, served at /api/v1/product-srv/openapi.json
+// and merged by the broker
+func (s *Server) apiSpec() *openapi.Spec {
+	spec := openapi.New("product", "v1", "/api/v1/product-srv")
+	for _, e := range service.Errors {
+		spec.Errors(openapi.Error(*e))
+	}
+
+	spec.Describe(fiber.MethodGet, "/health", openapi.Operation{
+		Summary: "Health of the service and its database", Data: handlers.HealthResponse{}, Unwrapped: true,
+	})
+
+	listDescription := "Only the first page of 10 for now, the page and the limit are not applied yet. " +
+		"Answers 304 to the ETag or the Last-Modified while no category changed."
+	spec.Describe(fiber.MethodGet, "/product-categories/list", openapi.Operation{
+		Summary:     "List the product categories of the business selected",
+		Description: listDescription,
+		Security:    openapi.Bearer,
+		Query:       handlers.ListProductCategoriesQuery{}, Data: []service.ListProductCategoryResponse{},
+	})
+	spec.Describe(fiber.MethodPost, "/product-categories/create", openapi.Operation{
+		Summary:  "Create a product category in the business selected",
+		Security: openapi.Bearer,
+		Body:     handlers.CreateProductCategoryPayload{}, Rules: service.CreateProductCategoryPayload{},
+		Data: service.CreateProductCategoryResponse{}, Status: http.StatusCreated,
+	})
+	spec.Describe(fiber.MethodPut, "/product-categories/update/:id", openapi.Operation{
+		Summary:  "Rename a product category",
+		Security: openapi.Bearer,
+		Body:     handlers.UpdateProductCategoryPayload{}, Rules: service.UpdateProductCategoryPayload{},
+		Data: service.UpdateProductCategoryResponse{},
+	})
+	return spec
+}
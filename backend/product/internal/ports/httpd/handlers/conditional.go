@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/product/internal/core/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// catalogETag is weak, the message of the response is localized so the language is part
+// of it along with the page
+func catalogETag(version service.CatalogVersion, language string, page int, limit int) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d/%d/%s/%d/%d", version.Count, version.LastModified.UnixMicro(), language, page, limit))
+	return `W/"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified sets the validators of the response and reports whether the ones of the
+// request still match them, If-None-Match winning over If-Modified-Since as in rfc 9110
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	// the broker caches the catalog per business, the caches past it key it by the credentials
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Vary(fiber.HeaderAcceptLanguage, fiber.HeaderAuthorization, fiber.HeaderCookie)
+
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		for _, candidate := range strings.Split(noneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	modifiedSince, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	// the header only has seconds
+	return !lastModified.Truncate(time.Second).After(modifiedSince)
+}
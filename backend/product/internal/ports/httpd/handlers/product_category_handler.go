@@ -43,7 +43,7 @@ func (h *ProductCategoryHandler) CreateProductCategory(c *fiber.Ctx) error {
 		return err
 	}
 
-	category, err := h.service.CreateProductCategory(c.Context(), service.CreateProductCategoryPayload{
+	category, err := h.service.CreateProductCategory(c.UserContext(), service.CreateProductCategoryPayload{
 		Name:       payload.Name,
 		BusinessID: uuid.MustParse(user.BusinessID),
 		Initiator:  uuid.MustParse(user.UserID),
@@ -68,7 +68,7 @@ func (h *ProductCategoryHandler) UpdateProductCategory(c *fiber.Ctx) error {
 	if err := c.BodyParser(&payload); err != nil {
 		return err
 	}
-	category, err := h.service.UpdateProductCategory(c.Context(), service.UpdateProductCategoryPayload{
+	category, err := h.service.UpdateProductCategory(c.UserContext(), service.UpdateProductCategoryPayload{
 		ID:         uuid.MustParse(c.Params("id")),
 		Name:       payload.Name,
 		BusinessID: uuid.MustParse(user.BusinessID),
@@ -94,12 +94,23 @@ func (h *ProductCategoryHandler) ListProductCategories(c *fiber.Ctx) error {
 	if err := c.QueryParser(&query); err != nil {
 		return err
 	}
-
-	categories, err := h.service.ListProductCategories(c.Context(), service.ListProductCategoryPayload{
+	payload := service.ListProductCategoryPayload{
 		BusinessID: uuid.MustParse(user.BusinessID),
 		Page:       1,
 		Limit:      10,
-	})
+	}
+
+	// the pos clients poll the list, an unchanged one is answered without reading it
+	version, err := h.service.Version(c.UserContext(), payload.BusinessID)
+	if err != nil {
+		return err
+	}
+	etag := catalogETag(version, c.Get(fiber.HeaderAcceptLanguage), payload.Page, payload.Limit)
+	if notModified(c, etag, version.LastModified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	categories, err := h.service.ListProductCategories(c.UserContext(), payload)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
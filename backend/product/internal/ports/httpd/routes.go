@@ -1,12 +1,19 @@
 package httpd
 
-import "github.com/aritradevelops/billbharat/backend/product/internal/ports/httpd/authn"
+import (
+	"github.com/aritradevelops/billbharat/backend/product/internal/ports/httpd/authn"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+)
 
 func (s *Server) SetupRoutes() {
 	router := s.app
-	authMiddleware := authn.Middleware(s.jwtManager)
+	authMiddleware := authn.Middleware(s.jwtManager, s.gateway, s.verifyToken)
 	router.Get("/api/v1/product-srv/health", s.handlers.Health)
+	router.Get("/metrics", metrics.Handler())
 	router.Get("/api/v1/product-srv/product-categories/list", authMiddleware, s.handlers.Category.ListProductCategories)
 	router.Post("/api/v1/product-srv/product-categories/create", authMiddleware, s.handlers.Category.CreateProductCategory)
 	router.Put("/api/v1/product-srv/product-categories/update/:id", authMiddleware, s.handlers.Category.UpdateProductCategory)
+
+	// the spec of the routes above, merged by the broker
+	s.apiSpec().Route(router)
 }
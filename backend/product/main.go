@@ -3,6 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aritradevelops/billbharat/backend/product/internal/config"
 	"github.com/aritradevelops/billbharat/backend/product/internal/core/consumer"
@@ -13,6 +17,11 @@ import (
 	"github.com/aritradevelops/billbharat/backend/product/internal/ports/httpd"
 	"github.com/aritradevelops/billbharat/backend/product/internal/ports/httpd/handlers"
 	"github.com/aritradevelops/billbharat/backend/shared/events"
+	"github.com/aritradevelops/billbharat/backend/shared/identity"
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
 	figure "github.com/common-nighthawk/go-figure"
 )
 
@@ -23,9 +32,28 @@ func main() {
 		return
 	}
 
+	if err := logger.Configure(conf.Log.Level, conf.Log.Levels); err != nil {
+		fmt.Println("failed to configure logger", err)
+		return
+	}
+
 	logo := figure.NewColorFigure(conf.Service.Name, "", "blue", true)
 	logo.Print()
 
+	shutdownTracing, err := tracing.Init(context.Background(), conf.Service.Name, conf.Tracing)
+	if err != nil {
+		fmt.Println("failed to init tracing", err)
+		return
+	}
+	// flush the spans still buffered before exiting
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error().Err(err).Msg("failed to shutdown tracing")
+		}
+	}()
+
 	db := database.NewPostgres(conf.Database.Uri, conf.Database.Timeout)
 
 	err = db.Connect()
@@ -34,6 +62,10 @@ func main() {
 		return
 	}
 	defer db.Disconnect()
+	if err := metrics.RegisterPool("postgres", db.Stats); err != nil {
+		fmt.Println("failed to register pool metrics", err)
+		return
+	}
 
 	repo, err := repository.New(db)
 	if err != nil {
@@ -41,25 +73,59 @@ func main() {
 		return
 	}
 
-	eventManager := events.NewKafkaEventManager(events.KafkaOpts{
+	eventManager := events.New(conf.EventBroker.Driver, events.KafkaOpts{
 		Servers: conf.EventBroker.Servers,
 		GroupId: conf.EventBroker.GroupID,
-	})
+	}, events.MemoryOpts{})
 
 	jwtManager := jwtutil.NewJwtManager(conf.Jwt.Secret, conf.Jwt.Lifetime.Duration())
 
-	srv := service.New(repo)
+	srv := service.New(repo, eventManager)
 
 	handler := handlers.New(db, srv, conf.Deployment.Env)
 
-	server := httpd.NewServer(conf.Http.Host, conf.Http.Port, handler, jwtManager)
+	var gateway *identity.Signer
+	if conf.Gateway.Secret != "" {
+		gateway = identity.NewSigner(conf.Gateway.Secret)
+	}
+	server := httpd.NewServer(conf.Http.Host, conf.Http.Port, handler, jwtManager, gateway, conf.Gateway.VerifyToken)
 	server.SetupRoutes()
 
-	consumer := consumer.New(context.Background(), eventManager, repo)
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer stop()
+
+	consumer := consumer.New(ctx, eventManager, events.SubscribeOpts{Concurrency: conf.EventBroker.Concurrency}, repo)
+	if conf.Snapshot.Bootstrap {
+		if err := consumer.Bootstrap(snapshot.NewClient(conf.Snapshot.Url, conf.Snapshot.ApiKey)); err != nil {
+			fmt.Println("failed to bootstrap", err)
+			return
+		}
+	}
 	consumer.Start()
 
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(); err != nil {
+			fmt.Println("server failed to shutdown", err)
+		}
+	}()
+
 	if err := server.Start(); err != nil {
 		fmt.Println("server failed to start", err)
 		return
 	}
+
+	// drain the consumers and flush the pending events before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, stats := range eventManager.Stats() {
+		logger.Info().Interface("stats", stats).Msg("subscription stats")
+	}
+	if err := eventManager.Close(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("failed to close event manager")
+	}
 }
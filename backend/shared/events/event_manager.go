@@ -2,15 +2,52 @@ package events
 
 import (
 	"context"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
 )
 
+// log follows the level of "events" in the log levels, see logger.Configure
+var log = logger.Package("events")
+
+// Message is what actually travels through the broker,
+// typed payloads are encoded into it by a Topic
+type Message struct {
+	Topic Event
+	// Key decides the partition, messages with the same key keep their order
+	Key   []byte
+	Value []byte
+	// Headers carry the request id and the trace of the publisher to the consumers
+	Headers map[string]string
+}
+
+type SubscribeOpts struct {
+	// GroupID overrides the consumer group the event manager was created with
+	GroupID string
+	// Concurrency is the number of messages of the topic handled in parallel
+	Concurrency int
+}
+
+// EventManager is the transport, it knows nothing about the events
+// flowing through it, see Topic for the typed api.
 type EventManager interface {
-	EmitManageUserEvent(ctx context.Context, data EventPayload[ManageUserEventPayload]) error
-	OnManageUserEvent(ctx context.Context, handler func(EventPayload[ManageUserEventPayload]) error)
-	EmitManageNotificationEvent(ctx context.Context, data EventPayload[ManageNotificationEventPayload]) error
-	OnManageNotificationEvent(ctx context.Context, handler func(EventPayload[ManageNotificationEventPayload]) error)
-	EmitManageBusinessEvent(ctx context.Context, data EventPayload[MangageBusinessEventPayload]) error
-	OnManageBusinessEvent(ctx context.Context, handler func(EventPayload[MangageBusinessEventPayload]) error)
-	EmitManageBusinessUserEvent(ctx context.Context, data EventPayload[MangageBusinessUserEventPayload]) error
-	OnManageBusinessUserEvent(ctx context.Context, handler func(EventPayload[MangageBusinessUserEventPayload]) error)
+	Publish(ctx context.Context, msgs ...Message) error
+	Subscribe(ctx context.Context, topic Event, opts SubscribeOpts, handler func(context.Context, Message) error)
+	// Close stops the subscriptions, lets the in flight messages finish
+	// and flushes whatever is still being published
+	Close(ctx context.Context) error
+	Stats() []SubscriptionStats
+}
+
+const (
+	KafkaDriver  = "kafka"
+	MemoryDriver = "memory"
+)
+
+// New returns the event manager for the given driver, kafka being the default.
+// only the options of the driver are read
+func New(driver string, kafka KafkaOpts, memory MemoryOpts) EventManager {
+	if driver == MemoryDriver {
+		return NewMemoryEventManager(memory)
+	}
+	return NewKafkaEventManager(kafka)
 }
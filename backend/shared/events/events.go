@@ -21,6 +21,9 @@ const (
 	ManageNotification      Event = "manage-notification"
 	ManageBusinessEvent     Event = "manage-business"
 	ManageBusinessUserEvent Event = "manage-business-user"
+	// ManageProductCategoryEvent is published by the product service, the broker drops
+	// the cached catalog of the business on it
+	ManageProductCategoryEvent Event = "manage-product-category"
 )
 
 func newEvent[T any](event Event, action string, data T) EventPayload[T] {
@@ -29,9 +32,47 @@ func newEvent[T any](event Event, action string, data T) EventPayload[T] {
 		Event:     event,
 		Data:      data,
 		Timestamp: time.Now(),
+		Action:    action,
 	}
 }
 
+type (
+	ManageUserTopic            = Topic[EventPayload[ManageUserEventPayload]]
+	ManageBusinessTopic        = Topic[EventPayload[MangageBusinessEventPayload]]
+	ManageBusinessUserTopic    = Topic[EventPayload[MangageBusinessUserEventPayload]]
+	ManageProductCategoryTopic = Topic[EventPayload[ManageProductCategoryEventPayload]]
+)
+
+// all the events of an entity are keyed by its id so they stay in order
+
+func NewManageUserTopic(eventManager EventManager, opts SubscribeOpts) *ManageUserTopic {
+	return NewTopic[EventPayload[ManageUserEventPayload]](eventManager, ManageUserEvent, opts).
+		WithKey(func(e EventPayload[ManageUserEventPayload]) []byte {
+			return []byte(e.Data.ID.String())
+		})
+}
+
+func NewManageBusinessTopic(eventManager EventManager, opts SubscribeOpts) *ManageBusinessTopic {
+	return NewTopic[EventPayload[MangageBusinessEventPayload]](eventManager, ManageBusinessEvent, opts).
+		WithKey(func(e EventPayload[MangageBusinessEventPayload]) []byte {
+			return []byte(e.Data.ID.String())
+		})
+}
+
+func NewManageBusinessUserTopic(eventManager EventManager, opts SubscribeOpts) *ManageBusinessUserTopic {
+	return NewTopic[EventPayload[MangageBusinessUserEventPayload]](eventManager, ManageBusinessUserEvent, opts).
+		WithKey(func(e EventPayload[MangageBusinessUserEventPayload]) []byte {
+			return []byte(e.Data.BusinessID.String() + "/" + e.Data.UserID.String())
+		})
+}
+
+func NewManageProductCategoryTopic(eventManager EventManager, opts SubscribeOpts) *ManageProductCategoryTopic {
+	return NewTopic[EventPayload[ManageProductCategoryEventPayload]](eventManager, ManageProductCategoryEvent, opts).
+		WithKey(func(e EventPayload[ManageProductCategoryEventPayload]) []byte {
+			return []byte(e.Data.ID.String())
+		})
+}
+
 func NewUserManageEvent(action string, data ManageUserEventPayload) EventPayload[ManageUserEventPayload] {
 	return newEvent(ManageUserEvent, action, data)
 }
@@ -44,6 +85,10 @@ func NewBusinessUserManageEvent(action string, data MangageBusinessUserEventPayl
 	return newEvent(ManageBusinessUserEvent, action, data)
 }
 
+func NewProductCategoryManageEvent(action string, data ManageProductCategoryEventPayload) EventPayload[ManageProductCategoryEventPayload] {
+	return newEvent(ManageProductCategoryEvent, action, data)
+}
+
 type ManageUserEventPayload struct {
 	ID            uuid.UUID  `json:"id"`
 	HumanID       string     `json:"human_id"`
@@ -61,6 +106,8 @@ type ManageUserEventPayload struct {
 	DeactivatedBy *uuid.UUID `json:"deactivated_by"`
 	DeletedAt     *time.Time `json:"deleted_at"`
 	DeletedBy     *uuid.UUID `json:"deleted_by"`
+	// Locale is the language the user wants to be notified in, e.g. en or bn
+	Locale string `json:"locale"`
 }
 
 type MangageBusinessEventPayload struct {
@@ -91,3 +138,15 @@ type MangageBusinessUserEventPayload struct {
 	DeletedAt  *time.Time `json:"deleted_at"`
 	DeletedBy  *uuid.UUID `json:"deleted_by"`
 }
+
+type ManageProductCategoryEventPayload struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	BusinessID uuid.UUID  `json:"business_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  uuid.UUID  `json:"created_by"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UpdatedBy  *uuid.UUID `json:"updated_by"`
+	DeletedAt  *time.Time `json:"deleted_at"`
+	DeletedBy  *uuid.UUID `json:"deleted_by"`
+}
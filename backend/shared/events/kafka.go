@@ -2,17 +2,21 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"io"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/aritradevelops/billbharat/backend/shared/logger"
 	"github.com/segmentio/kafka-go"
 )
 
 type KafkaOpts struct {
 	Servers []string
 	GroupId string
+	// Sync makes Publish wait until the brokers acknowledged the messages, by default
+	// the writes are batched in the background and a failure is only logged
+	Sync bool
 }
 
 // kafka implements event manager
@@ -20,116 +24,230 @@ type Kafka struct {
 	servers []string
 	groupId string
 	writer  *kafka.Writer
+
+	mu            sync.Mutex
+	subscriptions []*kafkaSubscription
 }
 
 func NewKafkaEventManager(opts KafkaOpts) EventManager {
+	config := kafka.WriterConfig{
+		Brokers: opts.Servers,
+		// messages without a key are still spread round robin
+		Balancer: &kafka.Hash{},
+		Dialer: &kafka.Dialer{
+			Timeout: 10 * time.Second,
+		},
+		Async: !opts.Sync,
+	}
+	if opts.Sync {
+		// a sync write waits for the batch to fill up or time out, don't wait the default second
+		config.BatchTimeout = 10 * time.Millisecond
+	}
+	writer := kafka.NewWriter(config)
+	// the async writes only report their failures here
+	writer.Completion = func(msgs []kafka.Message, err error) {
+		if err != nil {
+			log.Error().Err(err).Int("messages", len(msgs)).Msg("failed to deliver messages")
+		}
+		for _, msg := range msgs {
+			eventsWritten.WithLabelValues(msg.Topic, result(err)).Inc()
+		}
+	}
 	return &Kafka{
 		servers: opts.Servers,
 		groupId: opts.GroupId,
-		writer: kafka.NewWriter(kafka.WriterConfig{
-			Brokers:  opts.Servers,
-			Balancer: &kafka.RoundRobin{},
-			Dialer: &kafka.Dialer{
-				Timeout: 10 * time.Second,
-			},
-			Async: true,
-		}),
+		writer:  writer,
 	}
 }
 
-func (k *Kafka) EmitManageUserEvent(ctx context.Context, data EventPayload[ManageUserEventPayload]) error {
-	return k.produce(ctx, ManageUserEvent, data)
-}
-
-func (k *Kafka) EmitManageNotificationEvent(ctx context.Context, data EventPayload[ManageNotificationEventPayload]) error {
-	return k.produce(ctx, ManageNotification, data)
-}
-
-func (k *Kafka) EmitManageBusinessEvent(ctx context.Context, data EventPayload[MangageBusinessEventPayload]) error {
-	return k.produce(ctx, ManageBusinessEvent, data)
-}
-
-func (k *Kafka) EmitManageBusinessUserEvent(ctx context.Context, data EventPayload[MangageBusinessUserEventPayload]) error {
-	return k.produce(ctx, ManageBusinessUserEvent, data)
-}
-
-func (k *Kafka) OnManageUserEvent(ctx context.Context, handler func(EventPayload[ManageUserEventPayload]) error) {
-	go startKafkaConsumer(ctx, k.newReader(ManageUserEvent), handler)
+func (k *Kafka) Publish(ctx context.Context, msgs ...Message) error {
+	kafkaMsgs := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		headers := make([]kafka.Header, 0, len(msg.Headers))
+		for key, value := range msg.Headers {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		kafkaMsgs = append(kafkaMsgs, kafka.Message{
+			Topic:   string(msg.Topic),
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: headers,
+		})
+	}
+	err := k.writer.WriteMessages(ctx, kafkaMsgs...)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to write message")
+		return err
+	}
+	for _, msg := range msgs {
+		log.Ctx(ctx).Info().Str("event", string(msg.Topic)).Int("bytes", len(msg.Value)).Msg("message written successfully.")
+	}
+	return nil
 }
 
-func (k *Kafka) OnManageNotificationEvent(ctx context.Context, handler func(EventPayload[ManageNotificationEventPayload]) error) {
-	go startKafkaConsumer(ctx, k.newReader(ManageNotification), handler)
+// Subscribe joins the consumer group with a single reader, the fetched messages
+// are handed to a pool of opts.Concurrency workers. All the messages of a
+// partition go to the same worker so they are still handled in order.
+func (k *Kafka) Subscribe(ctx context.Context, topic Event, opts SubscribeOpts, handler func(context.Context, Message) error) {
+	groupId := k.groupId
+	if opts.GroupID != "" {
+		groupId = opts.GroupID
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &kafkaSubscription{
+		topic:   topic,
+		groupId: groupId,
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:  k.servers,
+			GroupID:  groupId,
+			Topic:    string(topic),
+			MinBytes: 10e3,
+			MaxBytes: 10e6,
+			// a new group reads the topic from the start, together with the
+			// snapshot bootstrap this leaves no gap for a new service
+			StartOffset: kafka.FirstOffset,
+		}),
+		handler: handler,
+		workers: make([]chan kafka.Message, max(opts.Concurrency, 1)),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		lag:     map[int]int64{},
+	}
+	k.mu.Lock()
+	k.subscriptions = append(k.subscriptions, s)
+	k.mu.Unlock()
+	go s.run(ctx)
 }
 
-func (k *Kafka) OnManageBusinessEvent(ctx context.Context, handler func(EventPayload[MangageBusinessEventPayload]) error) {
-	go startKafkaConsumer(ctx, k.newReader(ManageBusinessEvent), handler)
+// Close stops fetching new messages, waits for the in flight ones to be handled
+// and committed, then flushes and closes the writer. ctx bounds the wait.
+func (k *Kafka) Close(ctx context.Context) error {
+	k.mu.Lock()
+	subscriptions := k.subscriptions
+	k.mu.Unlock()
+	for _, s := range subscriptions {
+		s.cancel()
+	}
+	var errs []error
+	for _, s := range subscriptions {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		}
+		if err := s.reader.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := k.writer.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	log.Info().Msg("kafka event manager closed")
+	return errors.Join(errs...)
 }
 
-func (k *Kafka) OnManageBusinessUserEvent(ctx context.Context, handler func(EventPayload[MangageBusinessUserEventPayload]) error) {
-	go startKafkaConsumer(ctx, k.newReader(ManageBusinessUserEvent), handler)
+func (k *Kafka) Stats() []SubscriptionStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	stats := make([]SubscriptionStats, 0, len(k.subscriptions))
+	for _, s := range k.subscriptions {
+		stats = append(stats, s.stats())
+	}
+	return stats
 }
 
-func (k *Kafka) newReader(e Event) *kafka.Reader {
-	return kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  k.servers,
-		GroupID:  k.groupId,
-		Topic:    string(e),
-		MinBytes: 10e3,
-		MaxBytes: 10e6,
-	})
+type kafkaSubscription struct {
+	topic   Event
+	groupId string
+	reader  *kafka.Reader
+	handler func(context.Context, Message) error
+	workers []chan kafka.Message
+	cancel  context.CancelFunc
+	done    chan struct{}
+	latency latency
+
+	lagMu sync.Mutex
+	// lag of the last fetched message of every partition
+	lag map[int]int64
 }
 
-func (k *Kafka) produce(ctx context.Context, event Event, data any) error {
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-	err = k.writer.WriteMessages(ctx, kafka.Message{
-		Topic: string(event),
-		Value: dataBytes,
-	})
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to write message")
-		return err
+func (s *kafkaSubscription) run(ctx context.Context) {
+	defer close(s.done)
+
+	// handlers and commits must outlive the subscription context,
+	// otherwise the in flight messages would be cut off on shutdown
+	workerCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for i := range s.workers {
+		s.workers[i] = make(chan kafka.Message)
+		wg.Add(1)
+		go func(msgs <-chan kafka.Message) {
+			defer wg.Done()
+			for msg := range msgs {
+				s.handle(workerCtx, msg)
+			}
+		}(s.workers[i])
 	}
-	logger.Info().Str("event", string(event)).Interface("data", data).Msg("message written successfully.")
-	return nil
-}
 
-func startKafkaConsumer[T any](
-	ctx context.Context,
-	reader *kafka.Reader,
-	handler func(T) error,
-) {
-	defer func() {
-		reader.Close()
-		logger.Info().Msg("reader closed")
-	}()
 	for {
-		msg, err := reader.FetchMessage(ctx)
+		msg, err := s.reader.FetchMessage(ctx)
 		if err != nil {
-			if err == io.EOF {
-				return
+			if err == io.EOF || ctx.Err() != nil {
+				break
 			}
-			logger.Error().Err(err).Msg("fetch failed")
+			log.Error().Err(err).Str("event", string(s.topic)).Msg("fetch failed")
 			continue
 		}
+		s.lagMu.Lock()
+		s.lag[msg.Partition] = msg.HighWaterMark - msg.Offset - 1
+		s.lagMu.Unlock()
+		eventsConsumerLag.WithLabelValues(string(s.topic), s.groupId, strconv.Itoa(msg.Partition)).
+			Set(float64(msg.HighWaterMark - msg.Offset - 1))
+		// blocks while the worker is busy, so at most one message
+		// per worker is in flight
+		s.workers[msg.Partition%len(s.workers)] <- msg
+	}
 
-		var event T
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
-			logger.Error().Err(err).Msg("unmarshal failed")
-			continue
-		}
+	for _, worker := range s.workers {
+		close(worker)
+	}
+	wg.Wait()
+	log.Info().Str("event", string(s.topic)).Msg("reader drained")
+}
 
-		if err := handler(event); err != nil {
-			logger.Error().Err(err).Msg("handler failed")
-			continue
-		}
+func (s *kafkaSubscription) handle(ctx context.Context, msg kafka.Message) {
+	start := time.Now()
+	headers := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	err := s.handler(ctx, Message{
+		Topic:   Event(msg.Topic),
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	s.latency.observe(time.Since(start), err)
+	if err != nil {
+		log.Error().Err(err).Str("event", string(s.topic)).Msg("handler failed")
+		return
+	}
 
-		if err := reader.CommitMessages(ctx, msg); err != nil {
-			logger.Error().Err(err).Msg("commit failed")
-		} else {
-			logger.Info().Msg("message committed successfully.")
-		}
+	if err := s.reader.CommitMessages(ctx, msg); err != nil {
+		log.Error().Err(err).Msg("commit failed")
+	} else {
+		log.Info().Msg("message committed successfully.")
+	}
+}
+
+func (s *kafkaSubscription) stats() SubscriptionStats {
+	stats := s.latency.stats()
+	stats.Topic = s.topic
+	stats.GroupID = s.groupId
+	s.lagMu.Lock()
+	defer s.lagMu.Unlock()
+	for _, lag := range s.lag {
+		stats.Lag += lag
 	}
+	return stats
 }
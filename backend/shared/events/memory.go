@@ -0,0 +1,231 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type MemoryOpts struct {
+	// Sync delivers every published message to the subscribers before Publish returns,
+	// the handler error (after redeliveries) is returned to the caller.
+	// useful for tests where the assertion must happen right after the emit.
+	Sync bool
+	// MaxRedeliveries is the number of times a failed message is redelivered
+	// before it is skipped, same as an uncommitted kafka message. defaults to 3
+	MaxRedeliveries int
+	// RedeliveryBackoff is the wait between two deliveries of a failed message
+	// in async mode. defaults to 100ms
+	RedeliveryBackoff time.Duration
+}
+
+// Memory implements event manager in process.
+// Every topic is an append only log and every subscriber keeps its own offset,
+// which is only moved forward once the handler succeeds (commit). So just like
+// a kafka consumer group starting from the first offset, a subscriber receives
+// all the events of the topic including the ones emitted before it subscribed.
+type Memory struct {
+	opts          MemoryOpts
+	mu            sync.Mutex
+	topics        map[Event]*memoryTopic
+	subscriptions []*memorySubscription
+}
+
+type memoryTopic struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	log         []Message
+	subscribers []*memorySubscription
+}
+
+type memorySubscription struct {
+	topic   *memoryTopic
+	event   Event
+	handler func(context.Context, Message) error
+	cancel  context.CancelFunc
+	done    chan struct{}
+	latency latency
+	// offset is guarded by the topic mutex
+	offset int
+}
+
+func NewMemoryEventManager(opts MemoryOpts) EventManager {
+	if opts.MaxRedeliveries == 0 {
+		opts.MaxRedeliveries = 3
+	}
+	if opts.RedeliveryBackoff == 0 {
+		opts.RedeliveryBackoff = 100 * time.Millisecond
+	}
+	return &Memory{
+		opts:   opts,
+		topics: map[Event]*memoryTopic{},
+	}
+}
+
+func (m *Memory) Publish(ctx context.Context, msgs ...Message) error {
+	var errs []error
+	for _, msg := range msgs {
+		errs = append(errs, m.produce(ctx, msg))
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe ignores the group and concurrency, every subscriber is its own
+// group and handles the messages of the topic one by one.
+func (m *Memory) Subscribe(ctx context.Context, topic Event, opts SubscribeOpts, handler func(context.Context, Message) error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &memorySubscription{
+		topic:   m.topic(topic),
+		event:   topic,
+		handler: handler,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.subscriptions = append(m.subscriptions, s)
+	m.mu.Unlock()
+
+	if !m.opts.Sync {
+		go m.consume(ctx, s)
+		return
+	}
+	defer close(s.done)
+	t := s.topic
+	t.mu.Lock()
+	backlog := t.log
+	s.offset = len(backlog)
+	t.subscribers = append(t.subscribers, s)
+	t.mu.Unlock()
+	// replay whatever was published before subscribing
+	for _, msg := range backlog {
+		if err := m.deliver(ctx, s, msg); err != nil {
+			log.Error().Err(err).Str("event", string(topic)).Msg("handler failed")
+		}
+	}
+}
+
+// Close stops the consumers once their current message is handled
+func (m *Memory) Close(ctx context.Context) error {
+	m.mu.Lock()
+	subscriptions := m.subscriptions
+	m.mu.Unlock()
+	for _, s := range subscriptions {
+		s.cancel()
+	}
+	for _, s := range subscriptions {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Stats() []SubscriptionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make([]SubscriptionStats, 0, len(m.subscriptions))
+	for _, s := range m.subscriptions {
+		stat := s.latency.stats()
+		stat.Topic = s.event
+		s.topic.mu.Lock()
+		stat.Lag = int64(len(s.topic.log) - s.offset)
+		s.topic.mu.Unlock()
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func (m *Memory) topic(event Event) *memoryTopic {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.topics[event]
+	if !ok {
+		t = &memoryTopic{}
+		t.cond = sync.NewCond(&t.mu)
+		m.topics[event] = t
+	}
+	return t
+}
+
+func (m *Memory) produce(ctx context.Context, msg Message) error {
+	t := m.topic(msg.Topic)
+	t.mu.Lock()
+	t.log = append(t.log, msg)
+	subscribers := t.subscribers
+	t.cond.Broadcast()
+	t.mu.Unlock()
+	log.Debug().Str("event", string(msg.Topic)).Msg("message written to memory.")
+
+	if !m.opts.Sync {
+		return nil
+	}
+	var errs []error
+	for _, s := range subscribers {
+		errs = append(errs, m.deliver(ctx, s, msg))
+		t.mu.Lock()
+		s.offset++
+		t.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// consume mimics a kafka reader, it waits for the next message in the log
+// and only moves the offset after the handler succeeded or gave up.
+// the message being handled when ctx is done is still finished.
+func (m *Memory) consume(ctx context.Context, s *memorySubscription) {
+	defer close(s.done)
+	t := s.topic
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		t.cond.Broadcast()
+		t.mu.Unlock()
+	})
+	defer stop()
+
+	handlerCtx := context.WithoutCancel(ctx)
+	for {
+		t.mu.Lock()
+		for s.offset >= len(t.log) && ctx.Err() == nil {
+			t.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			t.mu.Unlock()
+			log.Info().Str("event", string(s.event)).Msg("memory reader closed")
+			return
+		}
+		msg := t.log[s.offset]
+		t.mu.Unlock()
+
+		if err := m.deliver(handlerCtx, s, msg); err != nil {
+			log.Error().Err(err).Str("event", string(s.event)).Msg("handler failed")
+		}
+		t.mu.Lock()
+		s.offset++
+		t.mu.Unlock()
+	}
+}
+
+// deliver calls the handler until it succeeds or the redeliveries are exhausted
+func (m *Memory) deliver(ctx context.Context, s *memorySubscription, msg Message) error {
+	var err error
+	for attempt := 0; attempt <= m.opts.MaxRedeliveries; attempt++ {
+		start := time.Now()
+		err = s.handler(ctx, msg)
+		s.latency.observe(time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		if m.opts.Sync {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(m.opts.RedeliveryBackoff):
+		}
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemorySyncDeliversBeforePublishReturns(t *testing.T) {
+	em := New(MemoryDriver, KafkaOpts{}, MemoryOpts{Sync: true})
+	topic := NewManageUserTopic(em, SubscribeOpts{})
+	var received []string
+	topic.Subscribe(context.Background(), func(ctx context.Context, e EventPayload[ManageUserEventPayload]) error {
+		received = append(received, e.Data.Name)
+		return nil
+	})
+	if err := topic.Publish(context.Background(), NewUserManageEvent("create", ManageUserEventPayload{Name: "asha"})); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != "asha" {
+		t.Fatalf("received %v, want [asha]", received)
+	}
+}
+
+func TestMemorySyncReturnsTheHandlerErrorAfterTheRedeliveries(t *testing.T) {
+	em := New(MemoryDriver, KafkaOpts{}, MemoryOpts{Sync: true, MaxRedeliveries: 2})
+	failure := errors.New("down")
+	var attempts int
+	em.Subscribe(context.Background(), ManageUserEvent, SubscribeOpts{}, func(ctx context.Context, msg Message) error {
+		attempts++
+		return failure
+	})
+	err := em.Publish(context.Background(), Message{Topic: ManageUserEvent, Value: []byte("{}")})
+	if !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want %v", err, failure)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestMemoryReplaysTheBacklogToNewSubscribers(t *testing.T) {
+	em := New(MemoryDriver, KafkaOpts{}, MemoryOpts{Sync: true})
+	for range 3 {
+		em.Publish(context.Background(), Message{Topic: ManageUserEvent, Value: []byte("{}")})
+	}
+	var received int
+	em.Subscribe(context.Background(), ManageUserEvent, SubscribeOpts{}, func(ctx context.Context, msg Message) error {
+		received++
+		return nil
+	})
+	if received != 3 {
+		t.Fatalf("received %d, want 3", received)
+	}
+}
+
+func TestMemoryAsyncRedeliversUntilTheHandlerSucceeds(t *testing.T) {
+	em := New(MemoryDriver, KafkaOpts{}, MemoryOpts{RedeliveryBackoff: time.Millisecond})
+	var attempts atomic.Int64
+	done := make(chan struct{})
+	em.Subscribe(context.Background(), ManageUserEvent, SubscribeOpts{}, func(ctx context.Context, msg Message) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		close(done)
+		return nil
+	})
+	em.Publish(context.Background(), Message{Topic: ManageUserEvent, Value: []byte("{}")})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("not delivered after %d attempts", attempts.Load())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := em.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if lag := em.Stats()[0].Lag; lag != 0 {
+		t.Fatalf("lag = %d, want 0", lag)
+	}
+}
+
+func TestRecorderKeepsThePublishedEvents(t *testing.T) {
+	recorder := NewRecorder()
+	topic := NewManageUserTopic(recorder, SubscribeOpts{})
+	topic.Publish(context.Background(), NewUserManageEvent("create", ManageUserEventPayload{Name: "asha"}))
+	topic.Publish(context.Background(), NewUserManageEvent("update", ManageUserEventPayload{Name: "asha"}))
+
+	created, err := Recorded[ManageUserEventPayload](recorder, ManageUserEvent, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Data.Name != "asha" {
+		t.Fatalf("created = %+v", created)
+	}
+	if all := recorder.Events(ManageUserEvent, ""); len(all) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(all))
+	}
+
+	var delivered string
+	topic.Subscribe(context.Background(), func(ctx context.Context, e EventPayload[ManageUserEventPayload]) error {
+		delivered = e.Action
+		return nil
+	})
+	if err := recorder.Deliver(ManageUserEvent, NewUserManageEvent("delete", ManageUserEventPayload{})); err != nil {
+		t.Fatal(err)
+	}
+	if delivered != "delete" {
+		t.Fatalf("delivered %q, want delete", delivered)
+	}
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"github.com/aritradevelops/billbharat/backend/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsPublished = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Events published, by event and result.",
+	}, []string{"event", "result"})
+	eventsWritten = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_written_total",
+		Help: "Messages acknowledged by kafka or failed to be written, by event and result.",
+	}, []string{"event", "result"})
+	eventsConsumed = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_consumed_total",
+		Help: "Events handled, by event and result. skipped ones could not be decoded.",
+	}, []string{"event", "result"})
+	eventsHandlerDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "events_handler_duration_seconds",
+		Help:    "Time taken by the handlers of the events.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"event"})
+	eventsConsumerLag = metrics.Factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "events_consumer_lag",
+		Help: "Messages of the topic not fetched yet by the consumer group, by partition.",
+	}, []string{"event", "group", "partition"})
+)
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
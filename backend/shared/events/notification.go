@@ -1,12 +1,20 @@
 package events
 
-import "github.com/aritradevelops/billbharat/backend/shared/notification"
+import (
+	"github.com/aritradevelops/billbharat/backend/shared/notification"
+	"github.com/google/uuid"
+)
 
 type ManageNotificationEventPayload struct {
 	Event   notification.Event           `json:"event"`
 	Kind    notification.Kind            `json:"kind"`
 	Payload []NotificationChannelPayload `json:"payload"`
 	Tokens  any                          `json:"tokens"`
+	// BusinessID is set when the notification is sent on behalf of a business
+	BusinessID *uuid.UUID `json:"business_id,omitempty"`
+	// Audience is who a BROADCAST goes to, the recipients of its channels are left
+	// empty and filled with the address of every member
+	Audience *notification.Audience `json:"audience,omitempty"`
 }
 
 type NotificationChannelPayload struct {
@@ -17,3 +25,9 @@ type NotificationChannelPayload struct {
 func NewNotificationManageEvent(data ManageNotificationEventPayload) EventPayload[ManageNotificationEventPayload] {
 	return newEvent(ManageNotification, "send", data)
 }
+
+type ManageNotificationTopic = Topic[EventPayload[ManageNotificationEventPayload]]
+
+func NewManageNotificationTopic(eventManager EventManager, opts SubscribeOpts) *ManageNotificationTopic {
+	return NewTopic[EventPayload[ManageNotificationEventPayload]](eventManager, ManageNotification, opts)
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Recorder is a fake event manager for tests, it does not deliver anything
+// on publish but keeps every published event so that it can be asserted later.
+// handlers registered with Subscribe can be invoked with Deliver.
+type Recorder struct {
+	// Err is returned from every Publish call when set
+	Err error
+
+	mu       sync.Mutex
+	emitted  []RecordedEvent
+	handlers map[Event][]func(context.Context, Message) error
+}
+
+var _ EventManager = (*Recorder)(nil)
+
+type RecordedEvent struct {
+	Event  Event
+	Action string
+	// Data is the json encoded event payload
+	Data []byte
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		handlers: map[Event][]func(context.Context, Message) error{},
+	}
+}
+
+func (r *Recorder) Publish(ctx context.Context, msgs ...Message) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		// every event of this package travels in an EventPayload envelope,
+		// pick the action from it so that the events can be filtered
+		var envelope struct {
+			Action string `json:"action"`
+		}
+		_ = json.Unmarshal(msg.Value, &envelope)
+		r.emitted = append(r.emitted, RecordedEvent{Event: msg.Topic, Action: envelope.Action, Data: msg.Value})
+	}
+	return nil
+}
+
+func (r *Recorder) Subscribe(ctx context.Context, topic Event, opts SubscribeOpts, handler func(context.Context, Message) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[topic] = append(r.handlers[topic], handler)
+}
+
+func (r *Recorder) Close(ctx context.Context) error {
+	return nil
+}
+
+func (r *Recorder) Stats() []SubscriptionStats {
+	return nil
+}
+
+// Events returns the recorded events of the given kind and action,
+// an empty action matches every action.
+func (r *Recorder) Events(event Event, action string) []RecordedEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []RecordedEvent
+	for _, e := range r.emitted {
+		if e.Event == event && (action == "" || e.Action == action) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Reset forgets all the recorded events, registered handlers are kept
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emitted = nil
+}
+
+// Deliver synchronously hands the payload to every handler registered
+// for the event, as if it was received from the broker.
+func (r *Recorder) Deliver(event Event, payload any) error {
+	dataBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	handlers := r.handlers[event]
+	r.mu.Unlock()
+	if len(handlers) == 0 {
+		return fmt.Errorf("no handler registered for %s", event)
+	}
+	var errs []error
+	for _, handler := range handlers {
+		errs = append(errs, handler(context.Background(), Message{Topic: event, Value: dataBytes}))
+	}
+	return errors.Join(errs...)
+}
+
+// Recorded decodes the recorded events of the given kind and action
+// e.g. Recorded[ManageUserEventPayload](recorder, ManageUserEvent, "create")
+func Recorded[T any](r *Recorder, event Event, action string) ([]EventPayload[T], error) {
+	var payloads []EventPayload[T]
+	for _, e := range r.Events(event, action) {
+		var payload EventPayload[T]
+		if err := json.Unmarshal(e.Data, &payload); err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads, nil
+}
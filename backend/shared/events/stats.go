@@ -0,0 +1,53 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// SubscriptionStats is a snapshot of a single subscription of the event manager
+type SubscriptionStats struct {
+	Topic   Event  `json:"topic"`
+	GroupID string `json:"group_id"`
+	// Lag is the number of messages of the topic not fetched yet
+	Lag int64 `json:"lag"`
+	// Handled is the number of handler calls, including the failed ones
+	Handled int64         `json:"handled"`
+	Failed  int64         `json:"failed"`
+	AvgTime time.Duration `json:"avg_time"`
+	MaxTime time.Duration `json:"max_time"`
+}
+
+// latency accumulates the handler timings of a subscription
+type latency struct {
+	mu      sync.Mutex
+	handled int64
+	failed  int64
+	total   time.Duration
+	max     time.Duration
+}
+
+func (l *latency) observe(d time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handled++
+	if err != nil {
+		l.failed++
+	}
+	l.total += d
+	l.max = max(l.max, d)
+}
+
+func (l *latency) stats() SubscriptionStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := SubscriptionStats{
+		Handled: l.handled,
+		Failed:  l.failed,
+		MaxTime: l.max,
+	}
+	if l.handled > 0 {
+		stats.AvgTime = l.total / time.Duration(l.handled)
+	}
+	return stats
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Codec[T any] interface {
+	Encode(data T) ([]byte, error)
+	Decode(raw []byte) (T, error)
+}
+
+// JSONCodec is the default codec of every topic
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(data T) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (JSONCodec[T]) Decode(raw []byte) (T, error) {
+	var data T
+	err := json.Unmarshal(raw, &data)
+	return data, err
+}
+
+// Topic is a typed view over a single topic of the event manager.
+// every event defines its topic once (see NewManageUserTopic) and
+// publishers and consumers only deal with T.
+type Topic[T any] struct {
+	name         Event
+	eventManager EventManager
+	opts         SubscribeOpts
+	codec        Codec[T]
+	key          func(T) []byte
+}
+
+func NewTopic[T any](eventManager EventManager, name Event, opts SubscribeOpts) *Topic[T] {
+	return &Topic[T]{
+		name:         name,
+		eventManager: eventManager,
+		opts:         opts,
+		codec:        JSONCodec[T]{},
+	}
+}
+
+// WithCodec replaces the default json codec
+func (t *Topic[T]) WithCodec(codec Codec[T]) *Topic[T] {
+	t.codec = codec
+	return t
+}
+
+// WithKey sets the partition key of the published messages
+func (t *Topic[T]) WithKey(key func(T) []byte) *Topic[T] {
+	t.key = key
+	return t
+}
+
+func (t *Topic[T]) Name() Event {
+	return t.name
+}
+
+func (t *Topic[T]) Publish(ctx context.Context, data T) error {
+	value, err := t.codec.Encode(data)
+	if err != nil {
+		return err
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "publish "+string(t.name), trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	msg := Message{
+		Topic:   t.name,
+		Value:   value,
+		Headers: map[string]string{},
+	}
+	if t.key != nil {
+		msg.Key = t.key(data)
+	}
+	tracing.Inject(ctx, propagation.MapCarrier(msg.Headers))
+	err = t.eventManager.Publish(ctx, msg)
+	eventsPublished.WithLabelValues(string(t.name), result(err)).Inc()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Subscribe starts consuming the topic in the background until ctx is done.
+// messages that can not be decoded are logged and skipped. the handlers continue
+// the trace of the publisher and log with its request id, see logger.Ctx
+func (t *Topic[T]) Subscribe(ctx context.Context, handler func(context.Context, T) error) {
+	t.eventManager.Subscribe(ctx, t.name, t.opts, func(ctx context.Context, msg Message) error {
+		ctx = tracing.Extract(ctx, propagation.MapCarrier(msg.Headers))
+		ctx, span := tracing.Tracer().Start(ctx, "consume "+string(t.name), trace.WithSpanKind(trace.SpanKindConsumer))
+		defer span.End()
+		ctx = logger.WithContext(ctx, tracing.LogFields(ctx))
+
+		data, err := t.codec.Decode(msg.Value)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("event", string(t.name)).Msg("unmarshal failed")
+			eventsConsumed.WithLabelValues(string(t.name), "skipped").Inc()
+			return nil
+		}
+		start := time.Now()
+		err = handler(ctx, data)
+		eventsHandlerDuration.WithLabelValues(string(t.name)).Observe(time.Since(start).Seconds())
+		eventsConsumed.WithLabelValues(string(t.name), result(err)).Inc()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		return nil
+	})
+}
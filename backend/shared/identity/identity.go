@@ -0,0 +1,90 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Header carries the identity the broker verified, base64url encoded json
+	Header = "X-Identity"
+	// SignatureHeader carries the hmac of the identity
+	SignatureHeader = "X-Identity-Signature"
+	// MaxAge bounds how long a captured identity can be replayed
+	MaxAge = 5 * time.Minute
+)
+
+var ErrInvalidIdentity = errors.New("invalid identity")
+
+// Identity is the user the broker verified the access token of
+type Identity struct {
+	UserID     string  `json:"user_id"`
+	BusinessID string  `json:"business_id"`
+	Role       string  `json:"role"`
+	Email      string  `json:"email"`
+	Name       string  `json:"name"`
+	Dp         *string `json:"dp"`
+	IssuedAt   int64   `json:"iat"`
+}
+
+// Signer signs the identities in the broker and verifies them in the services,
+// they share the secret
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret)}
+}
+
+// Sign returns the values of the Header and the SignatureHeader
+func (s *Signer) Sign(identity Identity, now time.Time) (string, string, error) {
+	identity.IssuedAt = now.Unix()
+	payload, err := json.Marshal(identity)
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded, base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+func (s *Signer) Verify(encoded string, signature string, now time.Time) (Identity, error) {
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return Identity{}, ErrInvalidIdentity
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Identity{}, ErrInvalidIdentity
+	}
+	var identity Identity
+	if err := json.Unmarshal(payload, &identity); err != nil {
+		return Identity{}, ErrInvalidIdentity
+	}
+	issuedAt := time.Unix(identity.IssuedAt, 0)
+	if now.Sub(issuedAt) > MaxAge || issuedAt.Sub(now) > MaxAge || identity.UserID == "" {
+		return Identity{}, ErrInvalidIdentity
+	}
+	return identity, nil
+}
+
+func (s *Signer) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// Strip removes the identity headers, the clients must not be able to set them
+func Strip(header http.Header) {
+	for name := range header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), Header) {
+			header.Del(name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claims are the claims of the access tokens the auth service issues
+type claims struct {
+	UserID     string  `json:"user_id"`
+	Email      string  `json:"email"`
+	Name       string  `json:"name"`
+	Dp         *string `json:"dp"`
+	BusinessID string  `json:"business_id"`
+	Role       string  `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenVerifier verifies the access tokens of the auth service, only the auth
+// service signs them
+type TokenVerifier struct {
+	secret []byte
+}
+
+func NewTokenVerifier(secret string) *TokenVerifier {
+	return &TokenVerifier{secret: []byte(secret)}
+}
+
+func (v *TokenVerifier) Verify(accessToken string) (Identity, error) {
+	verified := &claims{}
+	_, err := jwt.ParseWithClaims(accessToken, verified, func(t *jwt.Token) (any, error) {
+		return v.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Identity{}, err
+	}
+	if verified.UserID == "" {
+		return Identity{}, ErrInvalidIdentity
+	}
+	return Identity{
+		UserID:     verified.UserID,
+		BusinessID: verified.BusinessID,
+		Role:       verified.Role,
+		Email:      verified.Email,
+		Name:       verified.Name,
+		Dp:         verified.Dp,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestTokenVerifier(t *testing.T) {
+	verifier := NewTokenVerifier("secret")
+	valid := claims{
+		UserID: "user-1", BusinessID: "business-1", Role: "owner",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}
+
+	verified, err := verifier.Verify(signToken(t, jwt.SigningMethodHS256, []byte("secret"), valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.UserID != "user-1" || verified.BusinessID != "business-1" || verified.Role != "owner" {
+		t.Fatalf("verified = %+v", verified)
+	}
+
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	noUser := valid
+	noUser.UserID = ""
+	for name, token := range map[string]string{
+		"other secret": signToken(t, jwt.SigningMethodHS256, []byte("other"), valid),
+		"other method": signToken(t, jwt.SigningMethodHS512, []byte("secret"), valid),
+		"none":         signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid),
+		"expired":      signToken(t, jwt.SigningMethodHS256, []byte("secret"), expired),
+		"no expiry":    signToken(t, jwt.SigningMethodHS256, []byte("secret"), noExpiry),
+		"no user":      signToken(t, jwt.SigningMethodHS256, []byte("secret"), noUser),
+	} {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+	if _, err := verifier.Verify(signToken(t, jwt.SigningMethodHS256, []byte("secret"), noUser)); !errors.Is(err, ErrInvalidIdentity) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidIdentity)
+	}
+}
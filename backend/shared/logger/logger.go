@@ -1,19 +1,61 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 )
 
-var instance zerolog.Logger
+var (
+	instance zerolog.Logger
+	// packageLevels are the levels of the packages set apart, see Configure
+	packageLevels atomic.Pointer[map[string]zerolog.Level]
+)
+
+type contextKey struct{}
 
 func init() {
-	if os.Getenv("ENV") == "production" {
-		instance = zerolog.New(os.Stdout).With().Timestamp().Logger()
-	} else {
-		instance = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
+	var out io.Writer = os.Stdout
+	if os.Getenv("ENV") != "production" {
+		out = zerolog.ConsoleWriter{Out: os.Stdout}
+	}
+	// the console writer reads the json lines too, so they are redacted before it
+	instance = zerolog.New(&redactor{out: out}).With().Timestamp().Logger()
+	packageLevels.Store(&map[string]zerolog.Level{})
+}
+
+// Configure sets the level of every line and the levels of the packages set apart,
+// e.g. {"events": "warn"}. it is meant to be called once, at the start of main
+func Configure(level string, levels map[string]string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]zerolog.Level, len(levels))
+	for pkg, level := range levels {
+		if parsed[pkg], err = parseLevel(level); err != nil {
+			return fmt.Errorf("%s: %w", pkg, err)
+		}
+	}
+	instance = instance.Level(lvl)
+	packageLevels.Store(&parsed)
+	return nil
+}
+
+// parseLevel defaults to info, zerolog takes an empty level for no level at all
+func parseLevel(level string) (zerolog.Level, error) {
+	if level == "" {
+		return zerolog.InfoLevel, nil
 	}
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return lvl, fmt.Errorf("invalid log level %q", level)
+	}
+	return lvl, nil
 }
 
 func Debug() *zerolog.Event {
@@ -39,3 +81,47 @@ func Fatal() *zerolog.Event {
 func Panic() *zerolog.Event {
 	return instance.Panic()
 }
+
+// WithContext returns ctx carrying a logger with the fields, e.g. the request id
+// and the trace of a request, the loggers of Ctx(ctx) add them to every line
+func WithContext(ctx context.Context, fields map[string]any) context.Context {
+	l := Ctx(ctx).With().Fields(fields).Logger()
+	return context.WithValue(ctx, contextKey{}, &l)
+}
+
+// Ctx returns the logger of ctx, the global one when ctx has none
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	return &instance
+}
+
+// Package is the logger of a package, its lines carry the name of the package and
+// follow its level when Configure sets one, e.g. var log = logger.Package("events")
+type Package string
+
+// Ctx is the logger of ctx for the package
+func (p Package) Ctx(ctx context.Context) *zerolog.Logger {
+	l := Ctx(ctx).With().Str("package", string(p)).Logger()
+	if level, ok := (*packageLevels.Load())[string(p)]; ok {
+		l = l.Level(level)
+	}
+	return &l
+}
+
+func (p Package) Debug() *zerolog.Event {
+	return p.Ctx(context.Background()).Debug()
+}
+
+func (p Package) Info() *zerolog.Event {
+	return p.Ctx(context.Background()).Info()
+}
+
+func (p Package) Warn() *zerolog.Event {
+	return p.Ctx(context.Background()).Warn()
+}
+
+func (p Package) Error() *zerolog.Event {
+	return p.Ctx(context.Background()).Error()
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are redacted whatever their value, matched in the keys lowercased
+// without _ and -, e.g. access_token, X-Api-Key, otp_code
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "otp", "apikey", "authorization",
+	"cookie", "signature", "hash", "invitationurl",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// the digits next to a letter or a - are ids, e.g. the uuids, not phones
+	phonePattern = regexp.MustCompile(`(^|[^\w\-+])(\+?\d{8,13})(\d{2})($|[^\w\-])`)
+)
+
+// redactor masks the emails, the phones and the secrets of the json lines before they
+// reach out, the lines it can not parse go through as they are
+type redactor struct {
+	out io.Writer
+}
+
+func (r *redactor) Write(p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	var line map[string]any
+	if err := decoder.Decode(&line); err != nil {
+		return r.out.Write(p)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactValue(line)); err != nil {
+		return r.out.Write(p)
+	}
+	if _, err := r.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if sensitive(key) {
+				v[key] = redactSensitive(value)
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+		return v
+	case string:
+		return redactString(v)
+	}
+	return v
+}
+
+// redactSensitive redacts every scalar under a sensitive key, keeping the shape of the objects
+func redactSensitive(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = redactSensitive(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactSensitive(value)
+		}
+		return v
+	case nil:
+		return nil
+	}
+	return redacted
+}
+
+func sensitive(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString keeps the first letter and the domain of the emails, the last two digits of the phones
+func redactString(s string) string {
+	s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	return phonePattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := phonePattern.FindStringSubmatch(m)
+		return parts[1] + strings.Repeat("*", len(parts[2])) + parts[3] + parts[4]
+	})
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStats is a snapshot of a database connection pool, the drivers fill it in
+type PoolStats struct {
+	Total                int32
+	Idle                 int32
+	Acquired             int32
+	Max                  int32
+	AcquireCount         int64
+	CanceledAcquireCount int64
+	EmptyAcquireCount    int64
+	AcquireDuration      time.Duration
+}
+
+var (
+	poolConnections = prometheus.NewDesc("db_pool_connections",
+		"Connections of the pool, by state.", []string{"database", "state"}, nil)
+	poolMaxConnections = prometheus.NewDesc("db_pool_max_connections",
+		"Maximum size of the pool.", []string{"database"}, nil)
+	poolAcquires = prometheus.NewDesc("db_pool_acquires_total",
+		"Connections acquired from the pool.", []string{"database"}, nil)
+	poolCanceledAcquires = prometheus.NewDesc("db_pool_canceled_acquires_total",
+		"Acquires canceled by their context.", []string{"database"}, nil)
+	poolEmptyAcquires = prometheus.NewDesc("db_pool_empty_acquires_total",
+		"Acquires that waited for a connection as the pool was empty.", []string{"database"}, nil)
+	poolAcquireDuration = prometheus.NewDesc("db_pool_acquire_duration_seconds_total",
+		"Time spent acquiring the connections.", []string{"database"}, nil)
+)
+
+type poolCollector struct {
+	database string
+	stats    func() PoolStats
+}
+
+// RegisterPool reports the stats of the pool of the database on every scrape
+func RegisterPool(database string, stats func() PoolStats) error {
+	return Registry.Register(&poolCollector{database: database, stats: stats})
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolConnections
+	ch <- poolMaxConnections
+	ch <- poolAcquires
+	ch <- poolCanceledAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolAcquireDuration
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := p.stats()
+	ch <- prometheus.MustNewConstMetric(poolConnections, prometheus.GaugeValue, float64(stats.Idle), p.database, "idle")
+	ch <- prometheus.MustNewConstMetric(poolConnections, prometheus.GaugeValue, float64(stats.Acquired), p.database, "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConnections, prometheus.GaugeValue, float64(stats.Total-stats.Idle-stats.Acquired), p.database, "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMaxConnections, prometheus.GaugeValue, float64(stats.Max), p.database)
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stats.AcquireCount), p.database)
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquires, prometheus.CounterValue, float64(stats.CanceledAcquireCount), p.database)
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stats.EmptyAcquireCount), p.database)
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, stats.AcquireDuration.Seconds(), p.database)
+}
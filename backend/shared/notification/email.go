@@ -1,9 +1,19 @@
 package notification
 
 type EmailData struct {
-	To  []string `json:"to"`
-	CC  []string `json:"cc"`
-	BCC []string `json:"bcc"`
+	To          []string     `json:"to"`
+	CC          []string     `json:"cc"`
+	BCC         []string     `json:"bcc"`
+	ReplyTo     string       `json:"reply_to,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is sent along with the event, keep it small (e.g. an invoice pdf)
+type Attachment struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	// Data is base64 encoded in json
+	Data []byte `json:"data"`
 }
 
 func NewEmail(to ...string) *EmailData {
@@ -23,3 +33,13 @@ func (e *EmailData) WithBCC(bcc ...string) *EmailData {
 	e.BCC = append(e.BCC, bcc...)
 	return e
 }
+
+func (e *EmailData) WithReplyTo(replyTo string) *EmailData {
+	e.ReplyTo = replyTo
+	return e
+}
+
+func (e *EmailData) WithAttachment(name string, mimeType string, data []byte) *EmailData {
+	e.Attachments = append(e.Attachments, Attachment{Name: name, MimeType: mimeType, Data: data})
+	return e
+}
@@ -14,6 +14,36 @@ const (
 	USER_INVITED       Event = "user_invited"
 )
 
+// Category groups the events for the preferences of the users
+type Category string
+
+const (
+	// TRANSACTIONAL events are needed to use the account, they can't be turned off
+	TRANSACTIONAL Category = "transactional"
+	ACCOUNT       Category = "account"
+	TEAM          Category = "team"
+)
+
+var categories = map[Event]Category{
+	SIGNUP:             TRANSACTIONAL,
+	EMAIL_VERIFICATION: TRANSACTIONAL,
+	PHONE_VERIFICATION: TRANSACTIONAL,
+	FORGOT_PASSWORD:    TRANSACTIONAL,
+	RESET_PASSWORD:     TRANSACTIONAL,
+	CHANGE_PASSWORD:    TRANSACTIONAL,
+	EMAIL_VERIFIED:     ACCOUNT,
+	PHONE_VERIFIED:     ACCOUNT,
+	USER_INVITED:       TEAM,
+}
+
+// Category of the event, an event without one is treated as transactional
+func (e Event) Category() Category {
+	if category, ok := categories[e]; ok {
+		return category
+	}
+	return TRANSACTIONAL
+}
+
 type Channel string
 
 const (
@@ -30,6 +60,12 @@ const (
 	BROADCAST Kind = "broadcast"
 )
 
+// Audience is who a broadcast goes to, the members of the business of the event.
+// only the members with one of the roles when any is set, e.g. ["Owner"]
+type Audience struct {
+	Roles []string `json:"roles,omitempty"`
+}
+
 func NewSMS(to ...string) *SMSData {
 	return &SMSData{
 		To: to,
@@ -46,12 +82,22 @@ func NewPushMessage(to ...string) *PushMessageData {
 	}
 }
 
+// PushMessageData is sent to the inbox and the browsers of the users, To has their ids
 type PushMessageData struct {
 	To []string `json:"to"`
+	// Link is opened when the notification is clicked, e.g. /invoices/{id}
+	Link string `json:"link,omitempty"`
+}
+
+func (p *PushMessageData) WithLink(link string) *PushMessageData {
+	p.Link = link
+	return p
 }
 
 type WhatsappMessageData struct {
 	To []string `json:"to"`
+	// Document is sent in the header of the template, e.g. an invoice pdf
+	Document *Attachment `json:"document,omitempty"`
 }
 
 func NewWhatsappMessage(to ...string) *WhatsappMessageData {
@@ -59,3 +105,8 @@ func NewWhatsappMessage(to ...string) *WhatsappMessageData {
 		To: to,
 	}
 }
+
+func (w *WhatsappMessageData) WithDocument(name string, mimeType string, data []byte) *WhatsappMessageData {
+	w.Document = &Attachment{Name: name, MimeType: mimeType, Data: data}
+	return w
+}
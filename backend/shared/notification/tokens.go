@@ -0,0 +1,38 @@
+package notification
+
+import "encoding/json"
+
+// Tokens are the values each event fills its templates with, the templates may
+// reference only these. keep it in sync with what the producers of the events send.
+var Tokens = map[Event][]string{
+	EMAIL_VERIFICATION: {"Name", "Email", "Otp", "Expires_In"},
+	EMAIL_VERIFIED:     {"Name", "Email"},
+	PHONE_VERIFICATION: {"Name", "Phone", "Otp", "Expires_In"},
+	PHONE_VERIFIED:     {"Name", "Phone"},
+	USER_INVITED:       {"InvitationURL", "Email", "Name", "Phone", "BusinessID", "ExpiresAt", "BusinessName"},
+}
+
+// SecretTokens grant access to whoever reads them, they are only needed to send
+// the message and are never stored
+var SecretTokens = []string{"Otp", "InvitationURL"}
+
+// RedactTokens drops the secret tokens from the json encoded tokens, redacted
+// tells whether there was any
+func RedactTokens(encoded []byte) (_ []byte, redacted bool, _ error) {
+	var tokens map[string]json.RawMessage
+	// only an object has named tokens
+	if err := json.Unmarshal(encoded, &tokens); err != nil || tokens == nil {
+		return encoded, false, nil
+	}
+	for _, token := range SecretTokens {
+		if _, ok := tokens[token]; ok {
+			delete(tokens, token)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return encoded, false, nil
+	}
+	encoded, err := json.Marshal(tokens)
+	return encoded, true, err
+}
@@ -0,0 +1,27 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRedactTokens(t *testing.T) {
+	encoded, redacted, err := RedactTokens([]byte(`{"Name":"Asha","Otp":"123456","Expires_In":"10 minutes"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens map[string]string
+	if err := json.Unmarshal(encoded, &tokens); err != nil {
+		t.Fatal(err)
+	}
+	if !redacted || tokens["Otp"] != "" || tokens["Name"] != "Asha" || tokens["Expires_In"] != "10 minutes" {
+		t.Fatalf("tokens = %s, redacted = %v", encoded, redacted)
+	}
+
+	for _, kept := range []string{`{"Name":"Asha"}`, `null`, `["Otp"]`} {
+		encoded, redacted, err := RedactTokens([]byte(kept))
+		if err != nil || redacted || string(encoded) != kept {
+			t.Fatalf("%s became %s, redacted = %v, err = %v", kept, encoded, redacted, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	uuidType          = reflect.TypeFor[uuid.UUID]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// patterns of the validate tags openapi has no format for
+var patterns = map[string]string{
+	"numeric":    `^[-+]?[0-9]+(\.[0-9]+)?$`,
+	"number":     `^[0-9]+$`,
+	"alpha":      `^[a-zA-Z]+$`,
+	"alphanum":   `^[a-zA-Z0-9]+$`,
+	"alphaspace": `^[a-zA-Z ]+$`,
+	"e164":       `^\+[1-9][0-9]{7,14}$`,
+	"lowercase":  `^[^A-Z]*$`,
+	"uppercase":  `^[^a-z]*$`,
+}
+
+var formats = map[string]string{
+	"email":    "email",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"url":      "uri",
+	"uri":      "uri",
+	"http_url": "uri",
+	"datetime": "date-time",
+	"ip":       "ipv4",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+}
+
+// schemas turns the go types in the schemas of the components, the named structs are
+// referenced and the others inlined
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+func (g *schemas) of(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := g.schema(t)
+	if !nullable {
+		return s
+	}
+	if s.Ref != "" {
+		// the siblings of a $ref are ignored
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	s.Nullable = true
+	return s
+}
+
+func (g *schemas) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.of(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref adds the struct to the components, named after its package when the name is taken
+func (g *schemas) ref(t reflect.Type) *Schema {
+	// the generic types are named after the full paths of their parameters
+	if t.Name() == "" || strings.Contains(t.Name(), "[") {
+		return g.object(t)
+	}
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	name := t.Name()
+	if _, taken := g.components[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.names[t] = name
+	// in place before the fields for the recursive types
+	s := &Schema{}
+	g.components[name] = s
+	*s = *g.object(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemas) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, s)
+	return s
+}
+
+func (g *schemas) fields(t reflect.Type, s *Schema) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, s)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = g.of(field.Type)
+		if rules := field.Tag.Get("validate"); rules != "" {
+			constrain(s, name, field.Type, rules)
+		}
+	}
+}
+
+// constrain applies the rules of the validate tag to the property, see validateWith
+// for the rules coming from another struct
+func constrain(s *Schema, name string, t reflect.Type, rules string) {
+	prop := s.Properties[name]
+	if prop == nil || rules == "-" {
+		return
+	}
+	if prop.Ref != "" {
+		prop = &Schema{AllOf: []*Schema{prop}}
+		s.Properties[name] = prop
+	}
+	prop.Validate = rules
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// the rules after dive are the ones of the items
+	rules, itemRules, _ := strings.Cut(","+rules, ",dive")
+	rules = strings.TrimPrefix(rules, ",")
+	if prop.Items != nil && itemRules != "" && prop.Items.Ref == "" {
+		apply(prop.Items, t.Elem(), strings.TrimPrefix(itemRules, ","))
+	}
+	if apply(prop, t, rules) && !slices.Contains(s.Required, name) {
+		s.Required = append(s.Required, name)
+	}
+}
+
+// apply reports whether the rules make the value required, the validator checks the
+// empty values too without omitempty, e.g. an empty email fails email
+func apply(s *Schema, t reflect.Type, rules string) bool {
+	required, rejectsEmpty, omitempty := false, false, false
+	for _, rule := range strings.Split(rules, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = true
+		case "omitempty":
+			omitempty = true
+		case "min", "max", "len", "gte", "lte", "gt", "lt":
+			bound(s, t, tag, param)
+			if n, err := strconv.ParseFloat(param, 64); err == nil && n > 0 && (tag == "min" || tag == "len" || tag == "gte") {
+				rejectsEmpty = true
+			}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(value, 64); err == nil && s.Type != "string" {
+					s.Enum = append(s.Enum, n)
+				} else {
+					s.Enum = append(s.Enum, value)
+				}
+			}
+		default:
+			if format, ok := formats[tag]; ok {
+				s.Format = format
+				rejectsEmpty = true
+			} else if pattern, ok := patterns[tag]; ok && s.Type == "string" {
+				s.Pattern = pattern
+				rejectsEmpty = true
+			}
+		}
+	}
+	return required || (rejectsEmpty && !omitempty)
+}
+
+func bound(s *Schema, t reflect.Type, tag string, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	length := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		switch tag {
+		case "min", "gte":
+			s.MinLength = &length
+		case "max", "lte":
+			s.MaxLength = &length
+		case "len":
+			s.MinLength, s.MaxLength = &length, &length
+		}
+	case reflect.Slice, reflect.Array:
+		switch tag {
+		case "min", "gte":
+			s.MinItems = &length
+		case "max", "lte":
+			s.MaxItems = &length
+		case "len":
+			s.MinItems, s.MaxItems = &length, &length
+		}
+	case reflect.Map, reflect.Struct:
+	default:
+		switch tag {
+		case "min", "gte":
+			s.Minimum = &n
+		case "max", "lte":
+			s.Maximum = &n
+		case "gt":
+			s.Minimum, s.ExclusiveMinimum = &n, true
+		case "lt":
+			s.Maximum, s.ExclusiveMaximum = &n, true
+		case "len":
+			s.Minimum, s.Maximum = &n, &n
+		}
+	}
+}
+
+// validateWith applies the validate tags of the fields of rules to the properties of
+// the same json name, e.g. a payload of a handler checked as the one of its service
+func (g *schemas) validateWith(s *Schema, rules reflect.Type) {
+	if s.Ref != "" {
+		s = g.components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	for rules.Kind() == reflect.Pointer {
+		rules = rules.Elem()
+	}
+	if s == nil || rules.Kind() != reflect.Struct {
+		return
+	}
+	for i := range rules.NumField() {
+		field := rules.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if tag := field.Tag.Get("validate"); tag != "" {
+			constrain(s, name, field.Type, tag)
+		}
+	}
+}
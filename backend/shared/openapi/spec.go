@@ -0,0 +1,380 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/aritradevelops/billbharat/backend/shared/snapshot"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Path is where a service serves its spec, under its prefix
+const Path = "/openapi.json"
+
+// the security schemes guarding the operations
+const (
+	Bearer         = "bearer"
+	InternalApiKey = "internal_api_key"
+	WebhookToken   = "webhook_token"
+)
+
+var paramPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)\??`)
+
+// Operation documents a route of the service, the routes without one are still listed
+type Operation struct {
+	Summary     string
+	Description string
+	// Tags default to the first segment of the path
+	Tags []string
+	// Security is the scheme guarding the route, none for the public ones
+	Security string
+	// Query is the struct of the query parameters, read through their query tags
+	Query any
+	// Body is the json body, Rules the struct it is validated as when it is not the body itself
+	Body  any
+	Rules any
+	// Data is the data of the response, Status its status when not 200
+	Data   any
+	Status int
+	// ContentType is the one of the responses that are not json, e.g. text/event-stream
+	ContentType string
+	// Unwrapped responses are the Data itself, without the envelope, e.g. the health
+	Unwrapped bool
+}
+
+// Error is an entry of the error catalog of a service, its fields are the ones of the
+// ServiceError of the services so they convert, openapi.Error(*service.UserExistsErr)
+type Error struct {
+	HttpErrorCode int    `json:"http_error_code"`
+	DevErrorCode  string `json:"dev_error_code"`
+	Short         string `json:"short"`
+	Long          string `json:"long"`
+}
+
+// Spec builds the openapi document of a service from its fiber routes and the
+// operations described for them
+type Spec struct {
+	title      string
+	version    string
+	prefix     string
+	operations map[string]Operation
+	errors     []Error
+}
+
+// New is the spec of the routes under prefix, their paths are relative to it
+func New(title string, version string, prefix string) *Spec {
+	return &Spec{title: title, version: version, prefix: prefix, operations: map[string]Operation{}}
+}
+
+// Describe documents the route of the method, the path is relative to the prefix
+// and has the params of fiber, e.g. /users/profile/:id
+func (s *Spec) Describe(method string, path string, op Operation) {
+	s.operations[method+" "+path] = op
+}
+
+// Errors adds the errors to the catalog documented with the error responses
+func (s *Spec) Errors(errs ...Error) {
+	s.errors = append(s.errors, errs...)
+}
+
+// Route serves the spec under the prefix, built once the routes are all set up
+func (s *Spec) Route(app *fiber.App) {
+	var once sync.Once
+	var doc []byte
+	var err error
+	app.Get(s.prefix+Path, func(c *fiber.Ctx) error {
+		once.Do(func() {
+			doc, err = json.Marshal(s.Build(app.GetRoutes()))
+		})
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(doc)
+	})
+}
+
+// Build documents the routes under the prefix, the described operations without a
+// route are reported, the docs of the routes gone
+func (s *Spec) Build(routes []fiber.Route) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: s.title, Version: s.version},
+		Servers: []Server{{URL: s.prefix}},
+		Paths:   map[string]*PathItem{},
+	}
+	g := newSchemas()
+	used := map[string]bool{}
+	seen := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, s.prefix) {
+			continue
+		}
+		path := strings.TrimPrefix(route.Path, s.prefix)
+		key := route.Method + " " + path
+		if path == Path || seen[key] {
+			continue
+		}
+		seen[key] = true
+		op := s.operations[key]
+		operation := s.operation(g, route.Method, path, op)
+		if op.Security != "" {
+			used[op.Security] = true
+		}
+		openapiPath := paramPattern.ReplaceAllString(path, "{$1}")
+		if openapiPath == "" {
+			openapiPath = "/"
+		}
+		item := doc.Paths[openapiPath]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[openapiPath] = item
+		}
+		switch route.Method {
+		case fiber.MethodGet:
+			item.Get = operation
+		case fiber.MethodPost:
+			item.Post = operation
+		case fiber.MethodPut:
+			item.Put = operation
+		case fiber.MethodPatch:
+			item.Patch = operation
+		case fiber.MethodDelete:
+			item.Delete = operation
+		}
+	}
+	for key := range s.operations {
+		if !seen[key] {
+			logger.Warn().Str("operation", key).Msg("described operation has no route")
+		}
+	}
+
+	s.components(g, doc, used)
+	return doc
+}
+
+func (s *Spec) operation(g *schemas, method string, path string, op Operation) *OperationObject {
+	operation := &OperationObject{
+		OperationID: operationID(method, path),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   map[string]*Response{},
+	}
+	if len(operation.Tags) == 0 {
+		if tag, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/"); tag != "" {
+			operation.Tags = []string{tag}
+		}
+	}
+	for _, match := range paramPattern.FindAllStringSubmatch(path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	if op.Query != nil {
+		operation.Parameters = append(operation.Parameters, g.query(reflect.TypeOf(op.Query))...)
+	}
+	if op.Body != nil {
+		body := g.of(reflect.TypeOf(op.Body))
+		if op.Rules != nil {
+			g.validateWith(body, reflect.TypeOf(op.Rules))
+		}
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: body}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &Response{Description: http.StatusText(status)}
+	if op.ContentType != "" {
+		response.Content = map[string]*MediaType{op.ContentType: {Schema: &Schema{Type: "string"}}}
+	} else {
+		data := &Schema{Nullable: true}
+		if op.Data != nil {
+			data = g.of(reflect.TypeOf(op.Data))
+		}
+		if !op.Unwrapped {
+			data = envelope(data, &Schema{Nullable: true})
+		}
+		response.Content = map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: data}}
+	}
+	operation.Responses[strconv.Itoa(status)] = response
+	if op.Rules != nil || validated(op.Body) || validated(op.Query) {
+		operation.Responses["422"] = &Response{Ref: "#/components/responses/ValidationFailed"}
+	}
+	switch op.Security {
+	case "":
+	case Bearer:
+		// the browsers send the token in the cookie set by the login
+		operation.Security = []map[string][]string{{Bearer: {}}, {"cookie": {}}}
+		operation.Responses["401"] = &Response{Ref: "#/components/responses/Unauthorized"}
+	default:
+		operation.Security = []map[string][]string{{op.Security: {}}}
+		operation.Responses["401"] = &Response{Ref: "#/components/responses/Unauthorized"}
+	}
+	operation.Responses["default"] = &Response{Ref: "#/components/responses/Error"}
+	return operation
+}
+
+// validated reports whether a field of the struct has a validate tag, the services
+// without a validator answer no 422
+func validated(v any) bool {
+	if v == nil {
+		return false
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range t.NumField() {
+		if t.Field(i).Tag.Get("validate") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// query lists the query parameters of the fields of the struct
+func (g *schemas) query(t reflect.Type) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []Parameter
+	if t.Kind() != reflect.Struct {
+		return params
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("query"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		holder := &Schema{Properties: map[string]*Schema{name: g.of(field.Type)}}
+		if rules := field.Tag.Get("validate"); rules != "" {
+			constrain(holder, name, field.Type, rules)
+		}
+		params = append(params, Parameter{
+			Name: name, In: "query", Required: slices.Contains(holder.Required, name), Schema: holder.Properties[name],
+		})
+	}
+	return params
+}
+
+func (s *Spec) components(g *schemas, doc *Document, used map[string]bool) {
+	codes := []any{}
+	examples := map[string]Example{}
+	var catalog strings.Builder
+	catalog.WriteString("The errors of the service, by their dev_error_code:\n\n| status | dev_error_code | short | long |\n|---|---|---|---|\n")
+	for _, e := range s.errors {
+		codes = append(codes, e.DevErrorCode)
+		examples[e.DevErrorCode] = Example{
+			Summary: e.Long,
+			Value:   map[string]any{"message": e.Short, "data": nil, "error": e},
+		}
+		fmt.Fprintf(&catalog, "| %d | %s | %s | %s |\n", e.HttpErrorCode, e.DevErrorCode, e.Short, e.Long)
+	}
+	g.components["ServiceError"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"http_error_code": {Type: "integer"},
+			"dev_error_code":  {Type: "string", Enum: codes},
+			"short":           {Type: "string", Description: "the translation key of the message"},
+			"long":            {Type: "string"},
+		},
+	}
+	g.components["ValidationError"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"message": {Type: "string"},
+			"code":    {Type: "string", Description: "the failed rule of the validate tag, see x-validate"},
+			"field":   {Type: "string"},
+			"value":   {},
+			"param":   {},
+		},
+	}
+	doc.Components.Schemas = g.components
+	doc.Components.Responses = map[string]*Response{
+		"Error": {
+			Description: catalog.String(),
+			Content: map[string]*MediaType{fiber.MIMEApplicationJSON: {
+				Schema:   envelope(&Schema{Nullable: true}, &Schema{Ref: "#/components/schemas/ServiceError"}),
+				Examples: examples,
+			}},
+		},
+		"ValidationFailed": {
+			Description: http.StatusText(http.StatusUnprocessableEntity),
+			Content: map[string]*MediaType{fiber.MIMEApplicationJSON: {
+				Schema: envelope(&Schema{Nullable: true}, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/ValidationError"}}),
+			}},
+		},
+		"Unauthorized": {
+			Description: http.StatusText(http.StatusUnauthorized),
+			Content: map[string]*MediaType{fiber.MIMEApplicationJSON: {
+				Schema: envelope(&Schema{Nullable: true}, &Schema{Type: "object", Properties: map[string]*Schema{
+					"code": {Type: "integer"}, "message": {Type: "string"},
+				}}),
+			}},
+		},
+	}
+
+	schemes := map[string]*SecurityScheme{}
+	if used[Bearer] {
+		schemes[Bearer] = &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+			Description: "the access token of the login"}
+		schemes["cookie"] = &SecurityScheme{Type: "apiKey", In: "cookie", Name: "access_token",
+			Description: "the access token of the login, set by it"}
+	}
+	if used[InternalApiKey] {
+		schemes[InternalApiKey] = &SecurityScheme{Type: "apiKey", In: "header", Name: snapshot.ApiKeyHeader,
+			Description: "shared by the services and the operators"}
+	}
+	if used[WebhookToken] {
+		schemes[WebhookToken] = &SecurityScheme{Type: "apiKey", In: "query", Name: "token",
+			Description: "registered with the providers in the url of the webhook"}
+	}
+	doc.Components.SecuritySchemes = schemes
+}
+
+// envelope is the response of every route, see the Response of the handlers
+func envelope(data *Schema, err *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"message": {Type: "string", Description: "localized by the Accept-Language of the request"},
+			"data":    data,
+			"error":   err,
+		},
+		Required: []string{"message", "data", "error"},
+	}
+}
+
+// operationID is the method and the path in camel case, e.g. getUsersProfileById
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '_' }) {
+		if param, ok := strings.CutPrefix(segment, ":"); ok {
+			segment = "by_" + strings.TrimSuffix(param, "?")
+		}
+		for _, word := range strings.Split(segment, "_") {
+			if word != "" {
+				id += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return id
+}
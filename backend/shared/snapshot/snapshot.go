@@ -0,0 +1,119 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+)
+
+// ApiKeyHeader carries the internal api key of service to service calls
+const ApiKeyHeader = "X-Internal-Api-Key"
+
+const (
+	DefaultPageSize = 500
+	MaxPageSize     = 1000
+)
+
+// Resource is a mirrored entity exported by the auth service
+type Resource string
+
+const (
+	Users         Resource = "users"
+	Businesses    Resource = "businesses"
+	BusinessUsers Resource = "business-users"
+)
+
+// Page is a single page of a snapshot, pages are keyset paginated so rows
+// written while the snapshot is being walked can not shift the pages.
+// Next is the cursor of the next page and is empty on the last one.
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next"`
+}
+
+type Client struct {
+	url        string
+	apiKey     string
+	pageSize   int
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the snapshot api served under url,
+// e.g. http://localhost:9000/api/v1/auth-srv
+func NewClient(url string, apiKey string) *Client {
+	return &Client{
+		url:      url,
+		apiKey:   apiKey,
+		pageSize: DefaultPageSize,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (c *Client) WithPageSize(pageSize int) *Client {
+	c.pageSize = pageSize
+	return c
+}
+
+// Fetch walks every page of the resource and hands each item to handle,
+// it returns the number of items handled.
+func Fetch[T any](ctx context.Context, c *Client, resource Resource, handle func(context.Context, T) error) (int, error) {
+	count := 0
+	after := ""
+	for {
+		page, err := fetchPage[T](ctx, c, resource, after)
+		if err != nil {
+			return count, err
+		}
+		for _, item := range page.Items {
+			if err := handle(ctx, item); err != nil {
+				return count, err
+			}
+			count++
+		}
+		logger.Debug().Str("resource", string(resource)).Int("count", count).Msg("snapshot page fetched")
+		if page.Next == "" {
+			return count, nil
+		}
+		after = page.Next
+	}
+}
+
+func fetchPage[T any](ctx context.Context, c *Client, resource Resource, after string) (Page[T], error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(c.pageSize))
+	if after != "" {
+		query.Set("after", after)
+	}
+	endpoint := fmt.Sprintf("%s/internal/snapshot/%s?%s", c.url, resource, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	req.Header.Set(ApiKeyHeader, c.apiKey)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Data  Page[T] `json:"data"`
+		Error any     `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return Page[T]{}, fmt.Errorf("failed to decode %s snapshot: %w", resource, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return Page[T]{}, fmt.Errorf("failed to fetch %s snapshot: %s: %v", resource, res.Status, body.Error)
+	}
+	return body.Data, nil
+}
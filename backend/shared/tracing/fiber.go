@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"github.com/aritradevelops/billbharat/backend/shared/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New continues the trace and the request id the broker sent, or starts them for
+// the requests that skip it. the user context of the request carries them and a
+// logger with them, see logger.Ctx, so the handlers must pass c.UserContext() on
+func New() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := Extract(c.UserContext(), fiberCarrier{c: c})
+		if RequestID(ctx) == "" {
+			ctx = WithRequestID(ctx, uuid.NewString())
+		}
+		ctx, span := Tracer().Start(ctx, c.Method()+" "+c.Path(), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		ctx = logger.WithContext(ctx, LogFields(ctx))
+		c.SetUserContext(ctx)
+		c.Set(RequestIDHeader, RequestID(ctx))
+
+		err := c.Next()
+		// the route is only known once the request is routed
+		span.SetName(c.Method() + " " + c.Route().Path)
+		span.SetAttributes(
+			attribute.String("http.request.method", c.Method()),
+			attribute.String("http.route", c.Route().Path),
+			attribute.Int("http.response.status_code", c.Response().StatusCode()),
+		)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			// the errors are mostly handled already by the middlewares after this one
+			span.SetStatus(codes.Error, fiber.ErrInternalServerError.Message)
+		}
+		return err
+	}
+}
+
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+func (f fiberCarrier) Get(key string) string {
+	return f.c.Get(key)
+}
+
+func (f fiberCarrier) Set(key string, value string) {
+	f.c.Request().Header.Set(key, value)
+}
+
+func (f fiberCarrier) Keys() []string {
+	var keys []string
+	f.c.Request().Header.VisitAll(func(key, value []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
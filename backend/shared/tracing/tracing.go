@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RequestIDHeader correlates the log lines of a request across the broker and the services
+	RequestIDHeader = "X-Request-ID"
+
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOtlp   = "otlp"
+
+	instrumentation = "github.com/aritradevelops/billbharat/backend/shared/tracing"
+)
+
+type Config struct {
+	// Exporter is none, stdout for local use or otlp. none still mints and
+	// propagates the trace ids for the logs, it only exports nothing
+	Exporter string `env:"EXPORTER" envDefault:"none" yaml:"exporter"`
+	// Endpoint is the url of the otlp http collector, e.g. http://localhost:4318
+	Endpoint string `env:"ENDPOINT" yaml:"endpoint"`
+	// SampleRatio is the share of the new traces recorded, the ones started upstream follow their parent
+	SampleRatio float64 `env:"SAMPLE_RATIO" envDefault:"1" yaml:"sample_ratio"`
+}
+
+// Init sets the global tracer provider and the w3c trace context propagator,
+// the returned func flushes the spans still buffered on shutdown
+func Init(ctx context.Context, serviceName string, conf Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.Exporter {
+	case "", ExporterNone:
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOtlp:
+		var opts []otlptracehttp.Option
+		// without an endpoint the exporter reads OTEL_EXPORTER_OTLP_ENDPOINT
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(conf.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", conf.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer is the tracer of the packages of billbharat
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID is the id of the request ctx belongs to, empty outside of one
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Inject writes the request id and the trace of ctx to the carrier, e.g. the headers of a message
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if requestID := RequestID(ctx); requestID != "" {
+		carrier.Set(RequestIDHeader, requestID)
+	}
+}
+
+// Extract reads the request id and the trace of the carrier into ctx
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	if requestID := carrier.Get(RequestIDHeader); requestID != "" {
+		ctx = WithRequestID(ctx, requestID)
+	}
+	return ctx
+}
+
+// LogFields are the fields correlating the log lines of ctx with its request and trace
+func LogFields(ctx context.Context) map[string]any {
+	fields := map[string]any{}
+	if requestID := RequestID(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+		fields["span_id"] = sc.SpanID().String()
+	}
+	return fields
+}